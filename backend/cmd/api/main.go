@@ -10,12 +10,25 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/checkout"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/gateway"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/oauth"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/order"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/privacy"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/returns"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/seller"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/tax"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/user"
 	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/config"
 	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
 	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/logger"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/mailer"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/password"
 	"go.uber.org/zap"
 )
 
@@ -51,19 +64,101 @@ func main() {
 	)
 
 	// Initialize services
-	jwtService := jwtPkg.NewService(
-		getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		15*time.Minute,  // access token duration
-		7*24*time.Hour,  // refresh token duration
+	signingKey, err := jwtSigningKey(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing key: %v", err)
+	}
+	jwtService := jwtPkg.NewServiceWithKeys(
+		jwtPkg.NewKeySet(signingKey),
+		15*time.Minute, // access token duration
+		7*24*time.Hour, // refresh token duration
 	)
+	if cfg.JWT.RotationInterval > 0 {
+		stopRotation := jwtService.StartKeyRotation(cfg.JWT.RotationInterval, func(key *jwtPkg.Key, err error) {
+			if err != nil {
+				zapLogger.Error("Failed to rotate JWT signing key", zap.Error(err))
+				return
+			}
+			zapLogger.Info("Rotated JWT signing key", zap.String("kid", key.ID))
+		})
+		defer stopRotation()
+	}
 
 	// Initialize repositories
 	userRepo := user.NewInMemoryRepository()
 	productRepo := product.NewInMemoryRepository()
+	paymentRepo := payment.NewInMemoryRepository()
+	promotionRepo := promotion.NewInMemoryRepository()
+	returnRepo := returns.NewInMemoryRepository()
+	orderRepo := order.NewInMemoryRepository()
+	sessionRepo := user.NewInMemorySessionRepository()
+	passwordResetRepo := user.NewInMemoryPasswordResetRepository()
+	loginAttemptRepo := user.NewInMemoryLoginAttemptRepository()
+	apiKeyRepo := user.NewInMemoryAPIKeyRepository()
+	oidcLoginRepo := user.NewInMemoryOIDCLoginRepository()
+	addressRepo := user.NewInMemoryAddressRepository()
+	oauthRepo := oauth.NewInMemoryRepository()
+	deletionRepo := privacy.NewInMemoryRepository()
+	sellerRepo := seller.NewInMemoryRepository()
+
+	// Initialize payment provider; the mock gateway delivers its webhooks in-process
+	paymentProvider := payment.NewMockProvider(cfg.Payment.WebhookSecret, cfg.Payment.WebhookDelay)
+
+	// Initialize the mailer for account emails
+	accountMailer, err := newMailer(cfg.Mailer)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Load the password policy along with its breached password list
+	passwordPolicy, err := newPasswordPolicy(cfg.Users.PasswordPolicy)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	passwordHashers := newPasswordHashers(cfg.Users.PasswordHashing)
+	if err := user.CheckRoles(cfg.Users.Roles); err != nil {
+		log.Fatalf("Invalid role configuration: %v", err)
+	}
 
 	// Initialize services
-	userService := user.NewService(userRepo, jwtService, zapLogger)
+	userService := user.NewService(userRepo, sessionRepo, passwordResetRepo, loginAttemptRepo, apiKeyRepo, oidcLoginRepo, addressRepo, jwtService, accountMailer, userConfig(cfg.Users, passwordPolicy, passwordHashers, oidcProviders(cfg.OIDC)), zapLogger)
 	productService := product.NewService(productRepo, zapLogger)
+	promotionService := promotion.NewService(promotionRepo, zapLogger)
+	taxCalculator := tax.NewCalculator(taxConfig(cfg.Tax))
+	shippingCalculator := shipping.NewCalculator(shippingConfig(cfg.Shipping))
+	checkoutService := checkout.NewService(productService, promotionService, taxCalculator, shippingCalculator, zapLogger)
+	// Verified captures mark their orders paid, and returns look up the orders they are raised against
+	orderService := order.NewService(orderRepo, checkoutService, productService, promotionService, zapLogger)
+	paymentService := payment.NewService(paymentRepo, paymentProvider, orderService, zapLogger)
+	returnService := returns.NewService(returnRepo, order.NewReturnsReader(orderService), productService, paymentService, cfg.Returns.Window, zapLogger)
+	oauthService := oauth.NewService(oauthRepo, userService, jwtService, zapLogger)
+	sellerService := seller.NewService(sellerRepo, userService, productService, zapLogger)
+
+	// Every module keeping personal data takes part in exports and erasure; the account
+	// goes last so a failed erasure can be retried while the user still exists
+	privacyService := privacy.NewService(deletionRepo, cfg.Privacy.DeletionGracePeriod, zapLogger)
+	privacyService.Register("orders", orderService)
+	privacyService.Register("payments", paymentService)
+	privacyService.Register("returns", returnService)
+	privacyService.Register("promotions", promotionService)
+	privacyService.Register("oauth_consents", oauthService)
+	privacyService.Register("seller", sellerService)
+	privacyService.Register("account", userService)
+	stopDeletions := privacyService.StartDeletionWorker(cfg.Privacy.DeletionInterval, func(erased int, err error) {
+		if err != nil {
+			zapLogger.Error("Failed to process account deletions", zap.Error(err))
+		}
+		if erased > 0 {
+			zapLogger.Info("Erased deleted accounts", zap.Int("count", erased))
+		}
+	})
+	defer stopDeletions()
+
+	paymentProvider.SetWebhookSink(func(payload []byte, signature string) {
+		if err := paymentService.HandleWebhook(context.Background(), payload, signature); err != nil {
+			zapLogger.Error("Failed to handle mock payment webhook", zap.Error(err))
+		}
+	})
 
 	// Bootstrap admin user if needed
 	if err := userService.BootstrapAdmin(context.Background()); err != nil {
@@ -71,11 +166,33 @@ func main() {
 	}
 
 	// Initialize handlers
-	userHandler := user.NewHandler(userService, zapLogger)
+	userHandler := user.NewHandler(userService, privacyService, zapLogger)
 	productHandler := product.NewHandler(productService, zapLogger)
+	paymentHandler := payment.NewHandler(paymentService, zapLogger)
+	promotionHandler := promotion.NewHandler(promotionService, zapLogger)
+	checkoutHandler := checkout.NewHandler(checkoutService, zapLogger)
+	orderHandler := order.NewHandler(orderService, zapLogger)
+	returnHandler := returns.NewHandler(returnService, zapLogger)
+	oauthHandler := oauth.NewHandler(oauthService, zapLogger)
+	privacyHandler := privacy.NewHandler(privacyService, zapLogger)
+	sellerHandler := seller.NewHandler(sellerService, zapLogger)
 
 	// Setup router
-	router := gateway.Router(userHandler, productHandler, jwtService, zapLogger)
+	router := gateway.Router(
+		userHandler,
+		productHandler,
+		paymentHandler,
+		promotionHandler,
+		checkoutHandler,
+		orderHandler,
+		returnHandler,
+		oauthHandler,
+		privacyHandler,
+		sellerHandler,
+		jwtService,
+		userService,
+		zapLogger,
+	)
 
 	// Setup HTTP server
 	server := &http.Server{
@@ -120,6 +237,10 @@ func main() {
 			zapLogger.Error("Server forced to shutdown", zap.Error(err))
 			os.Exit(1)
 		}
+		// Account emails queued by the last requests are sent before exiting
+		if err := userService.Close(ctx); err != nil {
+			zapLogger.Error("Account emails left unsent at shutdown", zap.Error(err))
+		}
 
 		appLogger.Info("Server exited")
 		zapLogger.Info("Server exited")
@@ -134,3 +255,181 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// jwtSigningKey returns the initial token signing key. HS256 uses the JWT_SECRET shared
+// secret; other algorithms load the configured PEM file or generate a key.
+func jwtSigningKey(cfg config.JWTConfig) (*jwtPkg.Key, error) {
+	if cfg.Algorithm == jwtPkg.AlgorithmHS256 {
+		return jwtPkg.NewHMACKey([]byte(getEnv("JWT_SECRET", "your-secret-key-change-in-production"))), nil
+	}
+	if cfg.PrivateKeyFile == "" {
+		return jwtPkg.GenerateKey(cfg.Algorithm)
+	}
+
+	data, err := os.ReadFile(cfg.PrivateKeyFile) // #nosec G304 - path comes from trusted configuration
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwtPkg.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != cfg.Algorithm {
+		return nil, fmt.Errorf("private key is for %s, not %s", key.Algorithm, cfg.Algorithm)
+	}
+	return key, nil
+}
+
+// newMailer creates the configured mailer
+func newMailer(cfg config.MailerConfig) (mailer.Mailer, error) {
+	if cfg.Driver == "smtp" {
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.From,
+		}), nil
+	}
+	return mailer.NewOutboxMailer(cfg.OutboxDir, cfg.From)
+}
+
+// newPasswordPolicy creates the configured password policy
+func newPasswordPolicy(cfg config.PasswordPolicyConfig) (password.Policy, error) {
+	policy := password.Policy{
+		MinLength:            cfg.MinLength,
+		MaxLength:            cfg.MaxLength,
+		MinAdminLength:       cfg.MinAdminLength,
+		RequireLowercase:     cfg.RequireLowercase,
+		RequireUppercase:     cfg.RequireUppercase,
+		RequireDigit:         cfg.RequireDigit,
+		RequireSymbol:        cfg.RequireSymbol,
+		DisallowPersonalInfo: cfg.DisallowPersonalInfo,
+	}
+	if cfg.BreachedListFile != "" {
+		source, err := password.LoadFile(cfg.BreachedListFile)
+		if err != nil {
+			return password.Policy{}, err
+		}
+		policy.Breached = source
+	}
+	return policy, nil
+}
+
+// newPasswordHashers creates hashers that hash new passwords with the configured algorithm
+// and still verify hashes made with the other one
+func newPasswordHashers(cfg config.PasswordHashingConfig) *password.Hashers {
+	bcryptHasher := password.BcryptHasher{Cost: cfg.BcryptCost}
+	argon2idHasher := password.Argon2idHasher{
+		Memory:      cfg.Argon2id.MemoryKiB,
+		Iterations:  cfg.Argon2id.Iterations,
+		Parallelism: cfg.Argon2id.Parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+	if cfg.Algorithm == "argon2id" {
+		return password.NewHashers(argon2idHasher, bcryptHasher)
+	}
+	return password.NewHashers(bcryptHasher, argon2idHasher)
+}
+
+// userConfig converts the users section of the configuration into account policy settings
+func userConfig(cfg config.UsersConfig, passwordPolicy password.Policy, passwordHashers *password.Hashers, oidcProviders []user.OIDCProvider) user.Config {
+	return user.Config{
+		EmailVerification:     cfg.EmailVerification,
+		VerificationTokenTTL:  cfg.VerificationTokenTTL,
+		VerifyEmailURL:        cfg.VerifyEmailURL,
+		PasswordResetTokenTTL: cfg.PasswordResetTokenTTL,
+		ResetPasswordURL:      cfg.ResetPasswordURL,
+		ConfirmEmailChangeURL: cfg.ConfirmEmailChangeURL,
+		MFAIssuer:             cfg.MFAIssuer,
+		RequireMFAForAdmins:   cfg.RequireMFAForAdmins,
+		LockoutThreshold:      cfg.Lockout.Threshold,
+		LockoutDuration:       cfg.Lockout.Duration,
+		LoginBackoffAfter:     cfg.Lockout.BackoffAfter,
+		LoginBackoffBase:      cfg.Lockout.BackoffBase,
+		IPFailureThreshold:    cfg.Lockout.IPThreshold,
+		PasswordPolicy:        passwordPolicy,
+		PasswordHashers:       passwordHashers,
+		Roles:                 cfg.Roles,
+		OIDCProviders:         oidcProviders,
+		ImpersonationTTL:      cfg.ImpersonationTTL,
+	}
+}
+
+// oidcProviders creates clients for the configured OpenID providers. Their discovery
+// documents are fetched on first use, so a provider being down does not stop startup.
+func oidcProviders(cfg config.OIDCConfig) []user.OIDCProvider {
+	providers := make([]user.OIDCProvider, len(cfg.Providers))
+	for i, provider := range cfg.Providers {
+		providers[i] = user.OIDCProvider{
+			Name: provider.Name,
+			Client: oidc.NewClient(oidc.Config{
+				IssuerURL:    provider.IssuerURL,
+				ClientID:     provider.ClientID,
+				ClientSecret: provider.ClientSecret,
+				RedirectURL:  provider.RedirectURL,
+				Scopes:       provider.Scopes,
+			}, nil),
+			TrustEmail: provider.TrustEmail,
+		}
+	}
+	return providers
+}
+
+// taxConfig converts the tax section of the configuration into calculator rules
+func taxConfig(cfg config.TaxConfig) tax.Config {
+	regions := make([]tax.Region, len(cfg.Regions))
+	for i, region := range cfg.Regions {
+		regions[i] = tax.Region{
+			Name:        region.Name,
+			Country:     region.Country,
+			Region:      region.Region,
+			PostalCodes: region.PostalCodes,
+			Rates:       region.Rates,
+		}
+	}
+
+	return tax.Config{
+		PricesIncludeTax: cfg.PricesIncludeTax,
+		Rounding:         cfg.Rounding,
+		DefaultClass:     cfg.DefaultClass,
+		Regions:          regions,
+	}
+}
+
+// shippingConfig converts the shipping section of the configuration into calculator rules
+func shippingConfig(cfg config.ShippingConfig) shipping.Config {
+	zones := make([]shipping.Zone, len(cfg.Zones))
+	for i, zone := range cfg.Zones {
+		zones[i] = shipping.Zone{
+			ID:          zone.ID,
+			Name:        zone.Name,
+			Countries:   zone.Countries,
+			PostalCodes: zone.PostalCodes,
+		}
+	}
+
+	methods := make([]shipping.Method, len(cfg.Methods))
+	for i, method := range cfg.Methods {
+		tiers := make([]shipping.Tier, len(method.Tiers))
+		for j, tier := range method.Tiers {
+			tiers[j] = shipping.Tier{MaxWeight: tier.MaxWeight, Rate: tier.Rate}
+		}
+		methods[i] = shipping.Method{
+			ID:        method.ID,
+			Name:      method.Name,
+			Type:      method.Type,
+			Zones:     method.Zones,
+			Rate:      method.Rate,
+			Threshold: method.Threshold,
+			Tiers:     tiers,
+		}
+	}
+
+	return shipping.Config{
+		VolumetricDivisor: cfg.VolumetricDivisor,
+		Zones:             zones,
+		Methods:           methods,
+	}
+}
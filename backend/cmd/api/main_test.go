@@ -1,16 +1,36 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/checkout"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/gateway"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/oauth"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/order"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/privacy"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/returns"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/seller"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/tax"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/user"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/config"
 	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/mailer"
 	"go.uber.org/zap"
 )
 
@@ -22,13 +42,44 @@ func TestHealthEndpoint(t *testing.T) {
 	userRepo := user.NewInMemoryRepository()
 	productRepo := product.NewInMemoryRepository()
 	
-	userService := user.NewService(userRepo, jwtService, zapLogger)
+	outbox, _ := mailer.NewOutboxMailer(t.TempDir(), "no-reply@angidi.test")
+	userService := user.NewService(userRepo, user.NewInMemorySessionRepository(), user.NewInMemoryPasswordResetRepository(), user.NewInMemoryLoginAttemptRepository(), user.NewInMemoryAPIKeyRepository(), user.NewInMemoryOIDCLoginRepository(), user.NewInMemoryAddressRepository(), jwtService, outbox, user.Config{}, zapLogger)
 	productService := product.NewService(productRepo, zapLogger)
+	promotionService := promotion.NewService(promotion.NewInMemoryRepository(), zapLogger)
+	checkoutService := checkout.NewService(productService, promotionService, tax.NewCalculator(tax.Config{}), shipping.NewCalculator(shipping.Config{}), zapLogger)
+	orderService := order.NewService(order.NewInMemoryRepository(), checkoutService, productService, promotionService, zapLogger)
+	paymentService := payment.NewService(payment.NewInMemoryRepository(), payment.NewMockProvider("test-webhook-secret", 0), orderService, zapLogger)
+	returnService := returns.NewService(returns.NewInMemoryRepository(), order.NewReturnsReader(orderService), productService, paymentService, 0, zapLogger)
+	oauthService := oauth.NewService(oauth.NewInMemoryRepository(), userService, jwtService, zapLogger)
+	privacyService := privacy.NewService(privacy.NewInMemoryRepository(), 30*24*time.Hour, zapLogger)
+	sellerService := seller.NewService(seller.NewInMemoryRepository(), userService, productService, zapLogger)
 	
-	userHandler := user.NewHandler(userService, zapLogger)
+	userHandler := user.NewHandler(userService, privacyService, zapLogger)
 	productHandler := product.NewHandler(productService, zapLogger)
+	paymentHandler := payment.NewHandler(paymentService, zapLogger)
+	promotionHandler := promotion.NewHandler(promotionService, zapLogger)
+	checkoutHandler := checkout.NewHandler(checkoutService, zapLogger)
+	orderHandler := order.NewHandler(orderService, zapLogger)
+	returnHandler := returns.NewHandler(returnService, zapLogger)
+	oauthHandler := oauth.NewHandler(oauthService, zapLogger)
+	privacyHandler := privacy.NewHandler(privacyService, zapLogger)
+	sellerHandler := seller.NewHandler(sellerService, zapLogger)
 	
-	router := gateway.Router(userHandler, productHandler, jwtService, zapLogger)
+	router := gateway.Router(
+		userHandler,
+		productHandler,
+		paymentHandler,
+		promotionHandler,
+		checkoutHandler,
+		orderHandler,
+		returnHandler,
+		oauthHandler,
+		privacyHandler,
+		sellerHandler,
+		jwtService,
+		userService,
+		zapLogger,
+	)
 
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	w := httptest.NewRecorder()
@@ -64,3 +115,92 @@ func TestVersion(t *testing.T) {
 	}
 }
 
+
+func TestJWTSigningKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		cfg           config.JWTConfig
+		wantAlgorithm string
+		wantErr       bool
+	}{
+		{name: "shared secret", cfg: config.JWTConfig{Algorithm: "HS256"}, wantAlgorithm: "HS256"},
+		{name: "generated key", cfg: config.JWTConfig{Algorithm: "ES256"}, wantAlgorithm: "ES256"},
+		{name: "key file", cfg: config.JWTConfig{Algorithm: "EdDSA", PrivateKeyFile: keyFile}, wantAlgorithm: "EdDSA"},
+		{name: "key file for another algorithm", cfg: config.JWTConfig{Algorithm: "RS256", PrivateKeyFile: keyFile}, wantErr: true},
+		{name: "missing key file", cfg: config.JWTConfig{Algorithm: "EdDSA", PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := jwtSigningKey(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if key.Algorithm != tt.wantAlgorithm {
+				t.Errorf("Expected algorithm %s, got %s", tt.wantAlgorithm, key.Algorithm)
+			}
+		})
+	}
+}
+
+func TestNewPasswordPolicy(t *testing.T) {
+	policy, err := newPasswordPolicy(config.PasswordPolicyConfig{
+		MinLength:        8,
+		MaxLength:        128,
+		BreachedListFile: filepath.Join("..", "..", "configs", "breached-passwords.txt"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to load the bundled breached password list: %v", err)
+	}
+	if err := policy.Check(context.Background(), "Password123!", false); err == nil {
+		t.Error("Expected a common password to be rejected")
+	}
+	if err := policy.Check(context.Background(), "SecurePass123!", false); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, err := newPasswordPolicy(config.PasswordPolicyConfig{BreachedListFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("Expected an error for a missing breached password list")
+	}
+}
+
+func TestNewPasswordHashers(t *testing.T) {
+	cfg := config.PasswordHashingConfig{
+		Algorithm:  "argon2id",
+		BcryptCost: 10,
+		Argon2id:   config.Argon2idConfig{MemoryKiB: 8 * 1024, Iterations: 1, Parallelism: 1},
+	}
+	hash, err := newPasswordHashers(cfg).Hash("SecurePass123!")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Errorf("Expected an Argon2id hash, got %s", hash)
+	}
+
+	// Switching back to bcrypt keeps Argon2id hashes working until they are upgraded
+	cfg.Algorithm = "bcrypt"
+	ok, rehash, err := newPasswordHashers(cfg).Verify(hash, "SecurePass123!")
+	if err != nil || !ok || !rehash {
+		t.Errorf("Expected a valid hash that needs rehashing, got ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+}
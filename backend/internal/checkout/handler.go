@@ -0,0 +1,104 @@
+package checkout
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+	"go.uber.org/zap"
+)
+
+// Handler handles HTTP requests for checkout operations
+type Handler struct {
+	service   Service
+	validator *validator.Validate
+	logger    *zap.Logger
+}
+
+// NewHandler creates a new checkout handler
+func NewHandler(service Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service:   service,
+		validator: validator.New(),
+		logger:    logger,
+	}
+}
+
+// Totals handles calculating cart totals
+func (h *Handler) Totals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req TotalsRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	totals, err := h.service.CalculateTotals(r.Context(), userID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to calculate totals")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, totals)
+}
+
+// ShippingQuote handles quoting the shipping methods available for a cart
+func (h *Handler) ShippingQuote(w http.ResponseWriter, r *http.Request) {
+	var req ShippingQuoteRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	quote, err := h.service.QuoteShipping(r.Context(), req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to quote shipping")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, quote)
+}
+
+// decodeRequest decodes and validates a request body into req
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return false
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return false
+	}
+
+	return true
+}
+
+// writeServiceError maps service errors to HTTP responses
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error, logMessage string) {
+	switch err {
+	case product.ErrProductNotFound:
+		response.WriteError(w, http.StatusBadRequest, "PRODUCT_NOT_FOUND", "Cart contains an unknown product", "")
+	case ErrInsufficientStock:
+		response.WriteError(w, http.StatusConflict, "INSUFFICIENT_STOCK", "Not enough stock for one or more items", "")
+	case shipping.ErrMethodUnavailable:
+		response.WriteError(w, http.StatusBadRequest, "SHIPPING_METHOD_UNAVAILABLE", "Shipping method is not available for this cart and address", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
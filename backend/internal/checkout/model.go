@@ -0,0 +1,67 @@
+package checkout
+
+import (
+	"errors"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/tax"
+)
+
+var (
+	// ErrInsufficientStock is returned when a cart asks for more units than are in stock
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// TotalsRequest represents a cart whose totals should be calculated
+type TotalsRequest struct {
+	Items           []ItemRequest `json:"items" validate:"required,min=1,max=100,dive"`
+	CouponCodes     []string      `json:"coupon_codes" validate:"max=5,dive,max=32"`
+	ShippingAddress *Address      `json:"shipping_address,omitempty" validate:"required_with=ShippingMethod"`
+	ShippingMethod  string        `json:"shipping_method,omitempty" validate:"omitempty,max=64"`
+}
+
+// ShippingQuoteRequest represents a cart and destination to quote shipping for
+type ShippingQuoteRequest struct {
+	Items           []ItemRequest `json:"items" validate:"required,min=1,max=100,dive"`
+	ShippingAddress *Address      `json:"shipping_address" validate:"required"`
+}
+
+// ItemRequest represents a product and quantity in a cart
+type ItemRequest struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"required,min=1,max=1000"`
+}
+
+// Address represents the destination of a cart, used for shipping and tax
+type Address struct {
+	Country    string `json:"country" validate:"required,len=2,alpha"`
+	Region     string `json:"region,omitempty" validate:"omitempty,max=64"`
+	PostalCode string `json:"postal_code,omitempty" validate:"omitempty,max=16"`
+}
+
+// Totals represents the calculated totals of a cart. Shipping is only priced when a
+// method is chosen, and tax is only calculated when a shipping address is given.
+type Totals struct {
+	Lines            []LineTotal             `json:"lines"`
+	Subtotal         float64                 `json:"subtotal"`
+	DiscountTotal    float64                 `json:"discount_total"`
+	ShippingTotal    float64                 `json:"shipping_total"`
+	ShippingDiscount float64                 `json:"shipping_discount"`
+	TaxTotal         float64                 `json:"tax_total"`
+	Total            float64                 `json:"total"`
+	Promotions       []promotion.RuleOutcome `json:"promotions"`
+	Shipping         *shipping.Option        `json:"shipping,omitempty"`
+	Tax              *tax.Breakdown          `json:"tax,omitempty"`
+}
+
+// LineTotal represents the totals of a single cart line
+type LineTotal struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	UnitPrice float64 `json:"unit_price"`
+	Quantity  int     `json:"quantity"`
+	Subtotal  float64 `json:"subtotal"`
+	Discount  float64 `json:"discount"`
+	Total     float64 `json:"total"`
+}
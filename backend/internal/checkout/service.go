@@ -0,0 +1,201 @@
+package checkout
+
+import (
+	"context"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/tax"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+// Service defines the interface for checkout calculations
+type Service interface {
+	CalculateTotals(ctx context.Context, userID string, req TotalsRequest) (*Totals, error)
+	QuoteShipping(ctx context.Context, req ShippingQuoteRequest) (*shipping.Quote, error)
+}
+
+// service implements Service
+type service struct {
+	productService     product.Service
+	promotionService   promotion.Service
+	taxCalculator      *tax.Calculator
+	shippingCalculator *shipping.Calculator
+	logger             *zap.Logger
+}
+
+// NewService creates a new checkout service
+func NewService(productService product.Service, promotionService promotion.Service, taxCalculator *tax.Calculator, shippingCalculator *shipping.Calculator, logger *zap.Logger) Service {
+	return &service{
+		productService:     productService,
+		promotionService:   promotionService,
+		taxCalculator:      taxCalculator,
+		shippingCalculator: shippingCalculator,
+		logger:             logger,
+	}
+}
+
+// CalculateTotals prices the cart from the catalog, applies promotions and adds shipping and tax
+func (s *service) CalculateTotals(ctx context.Context, userID string, req TotalsRequest) (*Totals, error) {
+	s.logger.Debug("Calculating cart totals", zap.String("user_id", userID), zap.Int("items", len(req.Items)))
+
+	items, products, err := s.loadItems(ctx, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	cart := promotion.Cart{
+		UserID: userID,
+		Lines:  make([]promotion.Line, len(items)),
+		Codes:  req.CouponCodes,
+	}
+	for i, item := range items {
+		cart.Lines[i] = promotion.Line{
+			ProductID:  products[i].ID,
+			CategoryID: products[i].CategoryID,
+			UnitPrice:  products[i].Price,
+			Quantity:   item.Quantity,
+		}
+	}
+
+	// Shipping is priced on the merchandise subtotal before discounts so that
+	// free-shipping promotions know the cost they are waiving
+	var option *shipping.Option
+	if req.ShippingMethod != "" {
+		parcel := parcelFor(items, products)
+		option, err = s.shippingCalculator.Price(destinationFor(req.ShippingAddress), parcel, req.ShippingMethod)
+		if err != nil {
+			s.logger.Debug("Shipping method unavailable", zap.String("method", req.ShippingMethod), zap.Error(err))
+			return nil, err
+		}
+		cart.ShippingCost = option.Price
+	}
+
+	result, err := s.promotionService.Apply(ctx, cart)
+	if err != nil {
+		s.logger.Error("Failed to apply promotions", zap.Error(err))
+		return nil, err
+	}
+
+	totals := &Totals{
+		Lines:            make([]LineTotal, len(items)),
+		Subtotal:         result.Subtotal,
+		DiscountTotal:    result.DiscountTotal,
+		ShippingTotal:    cart.ShippingCost,
+		ShippingDiscount: result.ShippingDiscount,
+		Promotions:       result.Rules,
+		Shipping:         option,
+	}
+	for i, line := range cart.Lines {
+		subtotal := money.Round(line.UnitPrice * float64(line.Quantity))
+		totals.Lines[i] = LineTotal{
+			ProductID: line.ProductID,
+			Name:      products[i].Name,
+			UnitPrice: line.UnitPrice,
+			Quantity:  line.Quantity,
+			Subtotal:  subtotal,
+			Discount:  result.LineDiscounts[i],
+			Total:     money.Round(subtotal - result.LineDiscounts[i]),
+		}
+	}
+	totals.Total = money.Round(totals.Subtotal - totals.DiscountTotal + totals.ShippingTotal - totals.ShippingDiscount)
+
+	if req.ShippingAddress != nil {
+		taxLines := make([]tax.Line, len(totals.Lines))
+		for i, line := range totals.Lines {
+			taxLines[i] = tax.Line{
+				ProductID: line.ProductID,
+				TaxClass:  products[i].TaxClass,
+				Amount:    line.Total,
+			}
+		}
+
+		address := tax.Address{
+			Country:    req.ShippingAddress.Country,
+			Region:     req.ShippingAddress.Region,
+			PostalCode: req.ShippingAddress.PostalCode,
+		}
+		totals.Tax = s.taxCalculator.Calculate(address, taxLines)
+		totals.TaxTotal = totals.Tax.TotalTax
+		// Tax-inclusive prices already contain the tax, so only exclusive tax is added
+		if !totals.Tax.PricesIncludeTax {
+			totals.Total = money.Round(totals.Total + totals.TaxTotal)
+		}
+	}
+
+	return totals, nil
+}
+
+// QuoteShipping returns the shipping methods available for a cart and destination
+func (s *service) QuoteShipping(ctx context.Context, req ShippingQuoteRequest) (*shipping.Quote, error) {
+	s.logger.Debug("Quoting shipping", zap.String("country", req.ShippingAddress.Country), zap.Int("items", len(req.Items)))
+
+	items, products, err := s.loadItems(ctx, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.shippingCalculator.Quote(destinationFor(req.ShippingAddress), parcelFor(items, products)), nil
+}
+
+// loadItems merges repeated items and loads their products, checking stock
+func (s *service) loadItems(ctx context.Context, requested []ItemRequest) ([]ItemRequest, []*product.Product, error) {
+	items := mergeItems(requested)
+	products := make([]*product.Product, len(items))
+
+	for i, item := range items {
+		p, err := s.productService.GetByID(ctx, item.ProductID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if item.Quantity > p.Stock {
+			s.logger.Debug("Insufficient stock", zap.String("product_id", p.ID), zap.Int("requested", item.Quantity))
+			return nil, nil, ErrInsufficientStock
+		}
+		products[i] = p
+	}
+
+	return items, products, nil
+}
+
+// mergeItems combines repeated products into a single line, keeping first-seen order
+func mergeItems(items []ItemRequest) []ItemRequest {
+	merged := make([]ItemRequest, 0, len(items))
+	index := make(map[string]int, len(items))
+	for _, item := range items {
+		if i, exists := index[item.ProductID]; exists {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// parcelFor describes the cart contents for shipping
+func parcelFor(items []ItemRequest, products []*product.Product) shipping.Parcel {
+	parcel := shipping.Parcel{Items: make([]shipping.Item, len(items))}
+	for i, item := range items {
+		p := products[i]
+		parcel.Subtotal += p.Price * float64(item.Quantity)
+		parcel.Items[i] = shipping.Item{Quantity: item.Quantity, Weight: p.Weight}
+		if p.Dimensions != nil {
+			parcel.Items[i].Length = p.Dimensions.Length
+			parcel.Items[i].Width = p.Dimensions.Width
+			parcel.Items[i].Height = p.Dimensions.Height
+		}
+	}
+	parcel.Subtotal = money.Round(parcel.Subtotal)
+	return parcel
+}
+
+// destinationFor converts a request address into a shipping destination
+func destinationFor(address *Address) shipping.Destination {
+	return shipping.Destination{
+		Country:    address.Country,
+		PostalCode: address.PostalCode,
+	}
+}
@@ -0,0 +1,287 @@
+package checkout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/tax"
+)
+
+type testFixture struct {
+	service          Service
+	productService   product.Service
+	promotionService promotion.Service
+}
+
+var testShippingConfig = shipping.Config{
+	Zones: []shipping.Zone{{ID: "uk", Name: "United Kingdom", Countries: []string{"GB"}}},
+	Methods: []shipping.Method{
+		{ID: "standard", Name: "Standard", Type: shipping.TypeFlatRate, Zones: []string{"uk"}, Rate: 4.99},
+		{ID: "parcel", Name: "Parcel", Type: shipping.TypeWeightBased, Tiers: []shipping.Tier{{MaxWeight: 2, Rate: 3}, {MaxWeight: 10, Rate: 8}}},
+		{ID: "free", Name: "Free", Type: shipping.TypeFreeOverThreshold, Zones: []string{"uk"}, Threshold: 100},
+	},
+}
+
+func setupTestService() testFixture {
+	return setupTestServiceWithTax(tax.Config{
+		DefaultClass: "standard",
+		Regions: []tax.Region{
+			{Name: "United Kingdom", Country: "GB", Rates: map[string]float64{"standard": 20, "reduced": 5}},
+		},
+	})
+}
+
+func setupTestServiceWithTax(taxConfig tax.Config) testFixture {
+	logger, _ := zap.NewDevelopment()
+	productService := product.NewService(product.NewInMemoryRepository(), logger)
+	promotionService := promotion.NewService(promotion.NewInMemoryRepository(), logger)
+	return testFixture{
+		service:          NewService(productService, promotionService, tax.NewCalculator(taxConfig), shipping.NewCalculator(testShippingConfig), logger),
+		productService:   productService,
+		promotionService: promotionService,
+	}
+}
+
+func (f testFixture) createProduct(t *testing.T, name string, price float64, stock int, category string) *product.Product {
+	t.Helper()
+	p, err := f.productService.Create(context.Background(), product.CreateProductRequest{
+		Name:       name,
+		Price:      price,
+		Stock:      stock,
+		CategoryID: category,
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func TestService_CalculateTotals(t *testing.T) {
+	f := setupTestService()
+	ctx := context.Background()
+
+	shirt := f.createProduct(t, "Shirt", 25, 10, "apparel")
+	mug := f.createProduct(t, "Mug", 12.5, 10, "kitchen")
+
+	_, err := f.promotionService.Create(ctx, promotion.PromotionRequest{
+		Code:        "KITCHEN20",
+		Name:        "Kitchen sale",
+		Type:        promotion.TypePercentage,
+		Value:       20,
+		CategoryIDs: []string{"kitchen"},
+		Active:      true,
+	})
+	require.NoError(t, err)
+
+	totals, err := f.service.CalculateTotals(ctx, "user-1", TotalsRequest{
+		Items: []ItemRequest{
+			{ProductID: shirt.ID, Quantity: 1},
+			{ProductID: mug.ID, Quantity: 1},
+			{ProductID: shirt.ID, Quantity: 1},
+		},
+		CouponCodes: []string{"kitchen20"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, totals.Lines, 2)
+	assert.Equal(t, 2, totals.Lines[0].Quantity)
+	assert.Equal(t, 50.0, totals.Lines[0].Total)
+	assert.Equal(t, 2.5, totals.Lines[1].Discount)
+	assert.Equal(t, 10.0, totals.Lines[1].Total)
+	assert.Equal(t, 62.5, totals.Subtotal)
+	assert.Equal(t, 2.5, totals.DiscountTotal)
+	assert.Equal(t, 60.0, totals.Total)
+	require.Len(t, totals.Promotions, 1)
+	assert.True(t, totals.Promotions[0].Applied)
+	assert.Nil(t, totals.Tax)
+	assert.Zero(t, totals.TaxTotal)
+}
+
+func TestService_CalculateTotals_Tax(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("exclusive tax is charged on discounted lines", func(t *testing.T) {
+		f := setupTestService()
+		shirt := f.createProduct(t, "Shirt", 25, 10, "apparel")
+		book, err := f.productService.Create(ctx, product.CreateProductRequest{
+			Name:       "Book",
+			Price:      10,
+			Stock:      10,
+			CategoryID: "books",
+			TaxClass:   "reduced",
+		})
+		require.NoError(t, err)
+
+		_, err = f.promotionService.Create(ctx, promotion.PromotionRequest{
+			Code:   "TENOFF",
+			Name:   "Ten percent",
+			Type:   promotion.TypePercentage,
+			Value:  10,
+			Active: true,
+		})
+		require.NoError(t, err)
+
+		totals, err := f.service.CalculateTotals(ctx, "user-1", TotalsRequest{
+			Items:           []ItemRequest{{ProductID: shirt.ID, Quantity: 2}, {ProductID: book.ID, Quantity: 1}},
+			CouponCodes:     []string{"TENOFF"},
+			ShippingAddress: &Address{Country: "GB"},
+		})
+		require.NoError(t, err)
+
+		require.NotNil(t, totals.Tax)
+		assert.Equal(t, "United Kingdom", totals.Tax.Jurisdiction)
+		// 45 of shirts at 20% and 9 of books at 5%
+		assert.Equal(t, 9.0, totals.Tax.Lines[0].Tax)
+		assert.Equal(t, 0.45, totals.Tax.Lines[1].Tax)
+		assert.Equal(t, 9.45, totals.TaxTotal)
+		assert.Equal(t, 63.45, totals.Total)
+	})
+
+	t.Run("inclusive tax is not added to the total", func(t *testing.T) {
+		f := setupTestServiceWithTax(tax.Config{
+			PricesIncludeTax: true,
+			DefaultClass:     "standard",
+			Regions:          []tax.Region{{Name: "United Kingdom", Country: "GB", Rates: map[string]float64{"standard": 20}}},
+		})
+		shirt := f.createProduct(t, "Shirt", 24, 10, "apparel")
+
+		totals, err := f.service.CalculateTotals(ctx, "user-1", TotalsRequest{
+			Items:           []ItemRequest{{ProductID: shirt.ID, Quantity: 1}},
+			ShippingAddress: &Address{Country: "GB"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 4.0, totals.TaxTotal)
+		assert.Equal(t, 20.0, totals.Tax.Lines[0].TaxableAmount)
+		assert.Equal(t, 24.0, totals.Total)
+	})
+}
+
+func TestService_CalculateTotals_Errors(t *testing.T) {
+	f := setupTestService()
+	ctx := context.Background()
+
+	shirt := f.createProduct(t, "Shirt", 25, 2, "apparel")
+
+	tests := []struct {
+		name    string
+		items   []ItemRequest
+		wantErr error
+	}{
+		{
+			name:    "unknown product",
+			items:   []ItemRequest{{ProductID: "missing", Quantity: 1}},
+			wantErr: product.ErrProductNotFound,
+		},
+		{
+			name:    "insufficient stock across merged lines",
+			items:   []ItemRequest{{ProductID: shirt.ID, Quantity: 2}, {ProductID: shirt.ID, Quantity: 1}},
+			wantErr: ErrInsufficientStock,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			totals, err := f.service.CalculateTotals(ctx, "user-1", TotalsRequest{Items: tt.items})
+			assert.Equal(t, tt.wantErr, err)
+			assert.Nil(t, totals)
+		})
+	}
+}
+
+func TestService_QuoteShipping(t *testing.T) {
+	f := setupTestService()
+	ctx := context.Background()
+
+	kettle, err := f.productService.Create(ctx, product.CreateProductRequest{
+		Name:       "Kettle",
+		Price:      30,
+		Stock:      10,
+		CategoryID: "kitchen",
+		Weight:     1.5,
+	})
+	require.NoError(t, err)
+
+	quote, err := f.service.QuoteShipping(ctx, ShippingQuoteRequest{
+		Items:           []ItemRequest{{ProductID: kettle.ID, Quantity: 2}},
+		ShippingAddress: &Address{Country: "GB"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3.0, quote.BillableWeight)
+	require.Len(t, quote.Options, 2)
+	assert.Equal(t, "standard", quote.Options[0].MethodID)
+	assert.Equal(t, "parcel", quote.Options[1].MethodID)
+	assert.Equal(t, 8.0, quote.Options[1].Price)
+
+	quote, err = f.service.QuoteShipping(ctx, ShippingQuoteRequest{
+		Items:           []ItemRequest{{ProductID: kettle.ID, Quantity: 4}},
+		ShippingAddress: &Address{Country: "FR"},
+	})
+	require.NoError(t, err)
+	require.Len(t, quote.Options, 1)
+	assert.Equal(t, "parcel", quote.Options[0].MethodID)
+}
+
+func TestService_CalculateTotals_Shipping(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("chosen method is added to the total", func(t *testing.T) {
+		f := setupTestService()
+		shirt := f.createProduct(t, "Shirt", 25, 10, "apparel")
+
+		totals, err := f.service.CalculateTotals(ctx, "user-1", TotalsRequest{
+			Items:           []ItemRequest{{ProductID: shirt.ID, Quantity: 1}},
+			ShippingAddress: &Address{Country: "GB"},
+			ShippingMethod:  "standard",
+		})
+		require.NoError(t, err)
+
+		require.NotNil(t, totals.Shipping)
+		assert.Equal(t, 4.99, totals.ShippingTotal)
+		// 25 + 20% tax + shipping
+		assert.Equal(t, 34.99, totals.Total)
+	})
+
+	t.Run("free shipping promotion waives the cost", func(t *testing.T) {
+		f := setupTestService()
+		shirt := f.createProduct(t, "Shirt", 25, 10, "apparel")
+		_, err := f.promotionService.Create(ctx, promotion.PromotionRequest{
+			Code:   "SHIPFREE",
+			Name:   "Free shipping",
+			Type:   promotion.TypeFreeShipping,
+			Active: true,
+		})
+		require.NoError(t, err)
+
+		totals, err := f.service.CalculateTotals(ctx, "user-1", TotalsRequest{
+			Items:           []ItemRequest{{ProductID: shirt.ID, Quantity: 1}},
+			CouponCodes:     []string{"SHIPFREE"},
+			ShippingAddress: &Address{Country: "GB"},
+			ShippingMethod:  "standard",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 4.99, totals.ShippingTotal)
+		assert.Equal(t, 4.99, totals.ShippingDiscount)
+		assert.Equal(t, 30.0, totals.Total)
+	})
+
+	t.Run("unavailable method is rejected", func(t *testing.T) {
+		f := setupTestService()
+		shirt := f.createProduct(t, "Shirt", 25, 10, "apparel")
+
+		totals, err := f.service.CalculateTotals(ctx, "user-1", TotalsRequest{
+			Items:           []ItemRequest{{ProductID: shirt.ID, Quantity: 1}},
+			ShippingAddress: &Address{Country: "GB"},
+			ShippingMethod:  "free",
+		})
+		assert.Equal(t, shipping.ErrMethodUnavailable, err)
+		assert.Nil(t, totals)
+	})
+}
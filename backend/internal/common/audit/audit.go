@@ -0,0 +1,10 @@
+// Package audit records security events, which monitoring picks out of the logs by their
+// security_event field
+package audit
+
+import "go.uber.org/zap"
+
+// SecurityEvent logs a structured security event for monitoring and alerting
+func SecurityEvent(logger *zap.Logger, event string, fields ...zap.Field) {
+	logger.Warn("Security event", append([]zap.Field{zap.String("security_event", event)}, fields...)...)
+}
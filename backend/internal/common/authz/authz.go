@@ -0,0 +1,107 @@
+// Package authz names the permissions roles grant and checks them against the
+// authenticated user of a request
+package authz
+
+import "context"
+
+// Permissions, named resource:action
+const (
+	ProductWrite   = "product:write"
+	PaymentRead    = "payment:read"
+	PaymentWrite   = "payment:write"
+	PromotionRead  = "promotion:read"
+	PromotionWrite = "promotion:write"
+	OrderRead      = "order:read"
+	OrderWrite     = "order:write"
+	ReturnRead     = "return:read"
+	ReturnWrite    = "return:write"
+	UserRead       = "user:read"
+	UserWrite      = "user:write"
+	// UserImpersonate allows acting as another user, e.g. to reproduce an issue they report
+	UserImpersonate = "user:impersonate"
+	// RoleAssign allows changing anyone's roles, including granting admin
+	RoleAssign = "role:assign"
+	// ClientRead and ClientWrite allow viewing and registering the OAuth clients of
+	// third-party applications
+	ClientRead  = "client:read"
+	ClientWrite = "client:write"
+	// ProductSell allows sellers to list products of their own and change only those,
+	// where ProductWrite allows changing any product
+	ProductSell = "product:sell"
+	// SellerRead and SellerWrite allow reviewing seller applications and approving,
+	// rejecting or suspending sellers
+	SellerRead  = "seller:read"
+	SellerWrite = "seller:write"
+)
+
+// All lists every permission
+var All = []string{
+	ProductWrite,
+	ProductSell,
+	PaymentRead,
+	PaymentWrite,
+	PromotionRead,
+	PromotionWrite,
+	OrderRead,
+	OrderWrite,
+	ReturnRead,
+	ReturnWrite,
+	UserRead,
+	UserWrite,
+	UserImpersonate,
+	RoleAssign,
+	ClientRead,
+	ClientWrite,
+	SellerRead,
+	SellerWrite,
+}
+
+// Delegable lists the permissions OAuth clients may be registered with. Permissions over
+// users, roles and OAuth clients are left out, so that a third-party application can never
+// manage accounts or credentials.
+var Delegable = []string{
+	ProductWrite,
+	ProductSell,
+	PaymentRead,
+	PaymentWrite,
+	PromotionRead,
+	PromotionWrite,
+	OrderRead,
+	OrderWrite,
+	ReturnRead,
+	ReturnWrite,
+	SellerRead,
+	SellerWrite,
+}
+
+// IsKnown reports whether permission is one of All
+func IsKnown(permission string) bool {
+	for _, known := range All {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the authenticated user of ctx was granted permission
+func HasPermission(ctx context.Context, permission string) bool {
+	permissions, _ := ctx.Value("user_permissions").([]string)
+	for _, granted := range permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the authenticated user of ctx holds role
+func HasRole(ctx context.Context, role string) bool {
+	roles, _ := ctx.Value("user_roles").([]string)
+	for _, held := range roles {
+		if held == role {
+			return true
+		}
+	}
+	return false
+}
@@ -2,56 +2,174 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
 
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
 	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
 	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
 	"go.uber.org/zap"
 )
 
-// Authentication middleware validates JWT tokens
-func Authentication(jwtService *jwtPkg.Service, logger *zap.Logger) func(http.Handler) http.Handler {
+// APIKeyAuthenticator resolves API keys, which requests may authenticate with instead of
+// an access token, to the claims they act with
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key, ipAddress string) (*jwtPkg.Claims, error)
+}
+
+// Authentication middleware validates JWT tokens, or API keys sent in the X-API-Key
+// header or as an "ApiKey" Authorization header
+func Authentication(jwtService *jwtPkg.Service, apiKeys APIKeyAuthenticator, logger *zap.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			apiKey := r.Header.Get("X-API-Key")
+			if authHeader == "" && apiKey == "" {
 				response.WriteError(w, http.StatusUnauthorized, "MISSING_TOKEN", "Authorization header is required", "")
 				return
 			}
 
-			// Check Bearer token format
 			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN_FORMAT", "Authorization header must be Bearer token", "")
-				return
+			if apiKey == "" && len(parts) == 2 && parts[0] == "ApiKey" {
+				apiKey = parts[1]
 			}
 
-			token := parts[1]
+			var claims *jwtPkg.Claims
+			if apiKey != "" {
+				var err error
+				claims, err = apiKeys.AuthenticateAPIKey(r.Context(), apiKey, remoteIP(r))
+				if err != nil {
+					logger.Debug("API key rejected", zap.Error(err))
+					response.WriteError(w, http.StatusUnauthorized, "INVALID_API_KEY", "Invalid, expired or revoked API key", "")
+					return
+				}
+			} else {
+				// Check Bearer token format
+				if len(parts) != 2 || parts[0] != "Bearer" {
+					response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN_FORMAT", "Authorization header must be Bearer token", "")
+					return
+				}
 
-			// Validate token
-			claims, err := jwtService.ValidateToken(token)
-			if err != nil {
-				if err == jwtPkg.ErrExpiredToken {
-					response.WriteError(w, http.StatusUnauthorized, "EXPIRED_TOKEN", "Token has expired", "")
+				token := parts[1]
+
+				// Validate token
+				var err error
+				claims, err = jwtService.ValidateToken(token)
+				if err != nil {
+					if err == jwtPkg.ErrExpiredToken {
+						response.WriteError(w, http.StatusUnauthorized, "EXPIRED_TOKEN", "Token has expired", "")
+						return
+					}
+					if err == jwtPkg.ErrWrongTokenType {
+						response.WriteError(w, http.StatusUnauthorized, "WRONG_TOKEN_TYPE", "A refresh token cannot be used to access the API", "")
+						return
+					}
+					response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token", "")
 					return
 				}
-				response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token", "")
-				return
 			}
 
-			// Add user information to context
+			// Add user information to context. Tokens a client got for itself carry no user.
 			ctx := r.Context()
-			ctx = context.WithValue(ctx, "user_id", claims.UserID)
+			if claims.UserID != "" {
+				ctx = context.WithValue(ctx, "user_id", claims.UserID)
+			}
 			ctx = context.WithValue(ctx, "user_email", claims.Email)
 			ctx = context.WithValue(ctx, "user_role", claims.Role)
+			ctx = context.WithValue(ctx, "user_roles", claims.Roles)
+			ctx = context.WithValue(ctx, "user_permissions", claims.Permissions)
+			ctx = context.WithValue(ctx, "user_amr", claims.AMR)
+			if claims.APIKeyID != "" {
+				ctx = context.WithValue(ctx, "api_key_id", claims.APIKeyID)
+			}
+			if claims.ID != "" {
+				ctx = context.WithValue(ctx, "token_id", claims.ID)
+			}
+			if claims.ClientID != "" {
+				ctx = context.WithValue(ctx, "client_id", claims.ClientID)
+			}
+			if claims.Act != nil {
+				ctx = context.WithValue(ctx, "impersonator_id", claims.Act.Subject)
+			}
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// RejectAPIKeys middleware turns away requests authenticated with an API key, for routes
+// only the user themselves may use, such as changing their password
+func RejectAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("api_key_id").(string); ok {
+			response.WriteError(w, http.StatusForbidden, "API_KEY_NOT_ALLOWED", "This endpoint cannot be used with an API key", "")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RejectClientTokens middleware turns away requests made with access tokens issued to
+// OAuth clients, for routes only the user themselves may use, such as authorizing clients
+func RejectClientTokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("client_id").(string); ok {
+			response.WriteError(w, http.StatusForbidden, "CLIENT_TOKEN_NOT_ALLOWED", "This endpoint cannot be used by third-party applications", "")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RejectImpersonation middleware turns away requests made with an impersonation token,
+// for routes only the user themselves may use, such as changing their password
+func RejectImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("impersonator_id").(string); ok {
+			response.WriteError(w, http.StatusForbidden, "IMPERSONATION_NOT_ALLOWED", "This endpoint cannot be used while impersonating a user", "")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuditImpersonation middleware records every request made with an impersonation token
+// in the audit log, naming the admin, the user they act as and the outcome. It must run
+// after authentication.
+func AuditImpersonation(auditLogger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			impersonatorID, ok := r.Context().Value("impersonator_id").(string)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			userID, _ := r.Context().Value("user_id").(string)
+			tokenID, _ := r.Context().Value("token_id").(string)
+			auditLogger.Info("Impersonated request",
+				zap.String("audit_event", "impersonated_request"),
+				zap.String("admin_id", impersonatorID),
+				zap.String("user_id", userID),
+				zap.String("token_id", tokenID),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rw.statusCode),
+				zap.String("request_id", w.Header().Get("X-Request-ID")),
+				zap.String("remote_addr", remoteIP(r)),
+			)
+		})
+	}
+}
+
 // RequireRole middleware checks if user has required role
 func RequireRole(requiredRole string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -62,7 +180,30 @@ func RequireRole(requiredRole string) func(http.Handler) http.Handler {
 				return
 			}
 
-			if role != requiredRole {
+			if role != requiredRole && !authz.HasRole(r.Context(), requiredRole) {
+				response.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Insufficient permissions", "")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission middleware checks if one of the user's roles grants permission, or
+// the token a client got for itself does. Permissions are read from the access token, so
+// role changes apply from the next token refresh.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, isUser := r.Context().Value("user_id").(string)
+			_, isClient := r.Context().Value("client_id").(string)
+			if !isUser && !isClient {
+				response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+				return
+			}
+
+			if !authz.HasPermission(r.Context(), permission) {
 				response.WriteError(w, http.StatusForbidden, "FORBIDDEN", "Insufficient permissions", "")
 				return
 			}
@@ -71,3 +212,12 @@ func RequireRole(requiredRole string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// remoteIP returns the client address of r without its port
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -10,8 +10,17 @@ import (
 	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
 	"go.uber.org/zap"
 
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/checkout"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/middleware"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/oauth"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/order"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/privacy"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/returns"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/seller"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/user"
 )
 
@@ -19,7 +28,16 @@ import (
 func Router(
 	userHandler *user.Handler,
 	productHandler *product.Handler,
+	paymentHandler *payment.Handler,
+	promotionHandler *promotion.Handler,
+	checkoutHandler *checkout.Handler,
+	orderHandler *order.Handler,
+	returnHandler *returns.Handler,
+	oauthHandler *oauth.Handler,
+	privacyHandler *privacy.Handler,
+	sellerHandler *seller.Handler,
 	jwtService *jwtPkg.Service,
+	apiKeys middleware.APIKeyAuthenticator,
 	logger *zap.Logger,
 ) http.Handler {
 	r := chi.NewRouter()
@@ -35,7 +53,7 @@ func Router(
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:8080"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key"},
 		ExposedHeaders:   []string{"X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -49,32 +67,174 @@ func Router(
 		})
 	})
 
+	// Public token signing keys, so other services can verify access tokens themselves
+	r.Get("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		response.WriteJSON(w, http.StatusOK, jwtService.JWKS())
+	})
+
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
 		// Public user routes
 		r.Post("/users/register", userHandler.Register)
 		r.Post("/users/login", userHandler.Login)
+		r.Post("/users/login/mfa", userHandler.LoginMFA)
 		r.Post("/users/refresh-token", userHandler.RefreshToken)
+		r.Post("/users/logout", userHandler.Logout)
+		r.Post("/users/verify-email", userHandler.VerifyEmail)
+		r.Post("/users/verify-email/resend", userHandler.ResendVerification)
+		r.Post("/users/password/forgot", userHandler.ForgotPassword)
+		r.Post("/users/password/reset", userHandler.ResetPassword)
+		r.Post("/users/email/confirm", userHandler.ConfirmEmailChange)
+		r.Get("/users/oidc/providers", userHandler.ListOIDCProviders)
+		r.Get("/users/oidc/{provider}/authorize", userHandler.StartOIDCLogin)
+		r.Post("/users/oidc/{provider}/callback", userHandler.CompleteOIDCLogin)
 
 		// Public product routes
 		r.Get("/products", productHandler.List)
 		r.Get("/products/{id}", productHandler.GetByID)
 
+		// Public storefronts of approved sellers
+		r.Get("/sellers/{id}", sellerHandler.GetStorefront)
+		r.Get("/sellers/{id}/products", sellerHandler.ListStorefrontProducts)
+
+		// Payment provider webhooks (authenticated by signature)
+		r.Post("/payments/webhook", paymentHandler.Webhook)
+
+		// OAuth client endpoints (authenticated by client credentials)
+		r.Post("/oauth/token", oauthHandler.Token)
+		r.Post("/oauth/introspect", oauthHandler.Introspect)
+		r.Post("/oauth/revoke", oauthHandler.Revoke)
+
 		// Protected routes (require authentication)
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.Authentication(jwtService, logger))
+			r.Use(middleware.Authentication(jwtService, apiKeys, logger))
+			r.Use(middleware.AuditImpersonation(logger.Named("audit")))
+			r.Use(userHandler.RequireActiveUser)
+			r.Use(oauthHandler.RequireActiveToken)
 
-			// User profile routes
+			// User profile and address book routes
 			r.Get("/users/me", userHandler.GetProfile)
 			r.Put("/users/me", userHandler.UpdateProfile)
+			r.Post("/users/me/addresses", userHandler.CreateAddress)
+			r.Get("/users/me/addresses", userHandler.ListAddresses)
+			r.Get("/users/me/addresses/{id}", userHandler.GetAddress)
+			r.Put("/users/me/addresses/{id}", userHandler.UpdateAddress)
+			r.Delete("/users/me/addresses/{id}", userHandler.DeleteAddress)
+
+			// Seller profile routes; managing products takes the seller role, which is granted
+			// when an admin approves the application
+			r.Post("/sellers/me", sellerHandler.Apply)
+			r.Get("/sellers/me", sellerHandler.GetProfile)
+			r.Put("/sellers/me", sellerHandler.UpdateProfile)
+			r.Get("/sellers/me/products", sellerHandler.ListOwnProducts)
+			r.With(middleware.RequirePermission(authz.ProductSell)).Post("/sellers/me/products", sellerHandler.CreateProduct)
+			r.With(middleware.RequirePermission(authz.ProductSell)).Put("/sellers/me/products/{id}", sellerHandler.UpdateProduct)
+			r.With(middleware.RequirePermission(authz.ProductSell)).Delete("/sellers/me/products/{id}", sellerHandler.DeleteProduct)
 
-			// Admin-only product routes
+			// Account security routes, which need the user themselves rather than an API key,
+			// a third-party application or an admin impersonating them
 			r.Group(func(r chi.Router) {
-				r.Use(middleware.RequireRole("admin"))
+				r.Use(middleware.RejectAPIKeys)
+				r.Use(middleware.RejectClientTokens)
+				r.Use(middleware.RejectImpersonation)
+
+				r.Put("/users/me/password", userHandler.ChangePassword)
+				r.Post("/users/me/email", userHandler.ChangeEmail)
+				r.Post("/users/me/mfa/totp", userHandler.EnrollTOTP)
+				r.Post("/users/me/mfa/totp/confirm", userHandler.ConfirmTOTP)
+				r.Post("/users/me/mfa/disable", userHandler.DisableMFA)
+				r.Post("/users/me/mfa/recovery-codes", userHandler.RegenerateRecoveryCodes)
+				r.Post("/users/logout-all", userHandler.LogoutAll)
+				r.Get("/users/me/sessions", userHandler.ListSessions)
+				r.Delete("/users/me/sessions/{id}", userHandler.RevokeSession)
+				r.Post("/users/me/api-keys", userHandler.CreateAPIKey)
+				r.Get("/users/me/api-keys", userHandler.ListAPIKeys)
+				r.Delete("/users/me/api-keys/{id}", userHandler.RevokeAPIKey)
+				r.Get("/oauth/authorize", oauthHandler.GetAuthorization)
+				r.Post("/oauth/authorize", oauthHandler.Authorize)
+				r.Get("/users/me/oauth/consents", oauthHandler.ListConsents)
+				r.Delete("/users/me/oauth/consents/{clientId}", oauthHandler.RevokeConsent)
+				r.Get("/users/me/export", privacyHandler.Export)
+				r.Delete("/users/me", privacyHandler.RequestDeletion)
+				r.Get("/users/me/deletion", privacyHandler.GetDeletion)
+				r.Delete("/users/me/deletion", privacyHandler.CancelDeletion)
+			})
+
+			// Payment routes (paying requires a verified email when so configured, and cannot
+			// be done by an admin impersonating the user)
+			r.With(middleware.RejectImpersonation, userHandler.RequireVerifiedEmail).Post("/payments", paymentHandler.Create)
+			r.Get("/payments/{id}", paymentHandler.GetByID)
+			r.Post("/payments/{id}/challenge", paymentHandler.CompleteChallenge)
+
+			// Checkout routes
+			r.Post("/checkout/totals", checkoutHandler.Totals)
+			r.Post("/checkout/shipping-quote", checkoutHandler.ShippingQuote)
+
+			// Order routes (ordering requires a verified email when so configured, and cannot
+			// be done by an admin impersonating the user)
+			r.With(middleware.RejectImpersonation, userHandler.RequireVerifiedEmail).Post("/orders", orderHandler.Place)
+			r.Get("/orders", orderHandler.List)
+			r.Get("/orders/{id}", orderHandler.GetByID)
+
+			// Return routes
+			r.With(userHandler.RequireVerifiedEmail).Post("/returns", returnHandler.Create)
+			r.Get("/returns", returnHandler.List)
+			r.Get("/returns/{id}", returnHandler.GetByID)
+			r.Post("/returns/{id}/cancel", returnHandler.Cancel)
+
+			// Staff product, payment, promotion, order, return, user and seller routes, each requiring the
+			// permission of the roles allowed to use it. Staff cannot be impersonated, and admins
+			// impersonating a customer cannot use them either.
+			r.Group(func(r chi.Router) {
+				r.Use(userHandler.RequireMFA)
+				r.Use(middleware.RejectImpersonation)
+
+				r.With(middleware.RequirePermission(authz.ProductWrite)).Post("/products", productHandler.Create)
+				r.With(middleware.RequirePermission(authz.ProductWrite)).Put("/products/{id}", productHandler.Update)
+				r.With(middleware.RequirePermission(authz.ProductWrite)).Delete("/products/{id}", productHandler.Delete)
+
+				r.With(middleware.RequirePermission(authz.PaymentWrite)).Post("/payments/{id}/capture", paymentHandler.Capture)
+				r.With(middleware.RequirePermission(authz.PaymentWrite)).Post("/payments/{id}/void", paymentHandler.Void)
+				r.With(middleware.RequirePermission(authz.PaymentWrite)).Post("/payments/{id}/refund", paymentHandler.Refund)
+
+				r.With(middleware.RequirePermission(authz.PromotionRead)).Get("/promotions", promotionHandler.List)
+				r.With(middleware.RequirePermission(authz.PromotionWrite)).Post("/promotions", promotionHandler.Create)
+				r.With(middleware.RequirePermission(authz.PromotionRead)).Get("/promotions/{id}", promotionHandler.GetByID)
+				r.With(middleware.RequirePermission(authz.PromotionWrite)).Put("/promotions/{id}", promotionHandler.Update)
+				r.With(middleware.RequirePermission(authz.PromotionWrite)).Delete("/promotions/{id}", promotionHandler.Delete)
+
+				r.With(middleware.RequirePermission(authz.OrderWrite)).Post("/orders/{id}/deliver", orderHandler.Deliver)
+
+				r.With(middleware.RequirePermission(authz.ReturnWrite)).Post("/returns/{id}/approve", returnHandler.Approve)
+				r.With(middleware.RequirePermission(authz.ReturnWrite)).Post("/returns/{id}/reject", returnHandler.Reject)
+				r.With(middleware.RequirePermission(authz.ReturnWrite)).Post("/returns/{id}/receive", returnHandler.Receive)
+				r.With(middleware.RequirePermission(authz.ReturnWrite)).Post("/returns/{id}/refund", returnHandler.Refund)
+
+				r.With(middleware.RequirePermission(authz.UserRead)).Get("/admin/users", userHandler.ListUsers)
+				r.With(middleware.RequirePermission(authz.UserRead)).Get("/admin/users/{id}", userHandler.GetUser)
+				r.With(middleware.RequirePermission(authz.UserWrite)).Delete("/admin/users/{id}", userHandler.DeleteUser)
+				r.With(middleware.RequirePermission(authz.UserWrite)).Post("/admin/users/{id}/disable", userHandler.DisableUser)
+				r.With(middleware.RequirePermission(authz.UserWrite)).Post("/admin/users/{id}/enable", userHandler.EnableUser)
+				r.With(middleware.RequirePermission(authz.UserWrite)).Post("/admin/users/{id}/password-reset", userHandler.ForcePasswordReset)
+				r.With(middleware.RequirePermission(authz.UserWrite)).Post("/admin/users/{id}/unlock", userHandler.UnlockUser)
+				r.With(middleware.RejectAPIKeys, middleware.RejectClientTokens, middleware.RequirePermission(authz.UserImpersonate)).Post("/admin/users/{id}/impersonate", userHandler.Impersonate)
+				r.With(middleware.RequirePermission(authz.UserRead)).Get("/admin/users/{id}/api-keys", userHandler.ListUserAPIKeys)
+				r.With(middleware.RequirePermission(authz.UserWrite)).Delete("/admin/users/{id}/api-keys/{keyId}", userHandler.RevokeUserAPIKey)
+				r.With(middleware.RequirePermission(authz.UserRead)).Get("/admin/roles", userHandler.ListRoles)
+				r.With(middleware.RequirePermission(authz.UserRead)).Get("/admin/users/{id}/roles", userHandler.GetUserRoles)
+				r.With(middleware.RequirePermission(authz.RoleAssign)).Put("/admin/users/{id}/roles", userHandler.SetUserRoles)
+
+				r.With(middleware.RequirePermission(authz.ClientRead)).Get("/admin/oauth/clients", oauthHandler.ListClients)
+				r.With(middleware.RequirePermission(authz.ClientWrite)).Post("/admin/oauth/clients", oauthHandler.RegisterClient)
+				r.With(middleware.RequirePermission(authz.ClientRead)).Get("/admin/oauth/clients/{id}", oauthHandler.GetClient)
+				r.With(middleware.RequirePermission(authz.ClientWrite)).Delete("/admin/oauth/clients/{id}", oauthHandler.DeleteClient)
 
-				r.Post("/products", productHandler.Create)
-				r.Put("/products/{id}", productHandler.Update)
-				r.Delete("/products/{id}", productHandler.Delete)
+				r.With(middleware.RequirePermission(authz.SellerRead)).Get("/admin/sellers", sellerHandler.List)
+				r.With(middleware.RequirePermission(authz.SellerRead)).Get("/admin/sellers/{id}", sellerHandler.GetByID)
+				r.With(middleware.RequirePermission(authz.SellerWrite)).Post("/admin/sellers/{id}/approve", sellerHandler.Approve)
+				r.With(middleware.RequirePermission(authz.SellerWrite)).Post("/admin/sellers/{id}/reject", sellerHandler.Reject)
+				r.With(middleware.RequirePermission(authz.SellerWrite)).Post("/admin/sellers/{id}/suspend", sellerHandler.Suspend)
 			})
 		})
 	})
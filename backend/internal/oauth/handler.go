@@ -0,0 +1,372 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+	"go.uber.org/zap"
+)
+
+// Handler handles HTTP requests for the OAuth 2.0 authorization server
+type Handler struct {
+	service   Service
+	validator *validator.Validate
+	logger    *zap.Logger
+}
+
+// NewHandler creates a new OAuth handler
+func NewHandler(service Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service:   service,
+		validator: validator.New(),
+		logger:    logger,
+	}
+}
+
+// RegisterClient handles an admin registering a client
+func (h *Handler) RegisterClient(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+	permissions, _ := r.Context().Value("user_permissions").([]string)
+
+	var req RegisterClientRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	client, err := h.service.RegisterClient(r.Context(), adminID, permissions, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to register client")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, client)
+}
+
+// ListClients handles listing the registered clients
+func (h *Handler) ListClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.service.ListClients(r.Context())
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to list clients")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, clients)
+}
+
+// GetClient handles getting a registered client
+func (h *Handler) GetClient(w http.ResponseWriter, r *http.Request) {
+	client, err := h.service.GetClient(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get client")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, client)
+}
+
+// DeleteClient handles an admin deleting a client
+func (h *Handler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.DeleteClient(r.Context(), chi.URLParam(r, "id"), adminID); err != nil {
+		h.writeServiceError(w, err, "Failed to delete client")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAuthorization handles the frontend asking what a client the user was sent from
+// wants, passing on the query parameters of the authorization request
+func (h *Handler) GetAuthorization(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	query := r.URL.Query()
+	req := AuthorizationRequest{
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		ResponseType:        query.Get("response_type"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+	if !h.validate(w, req) {
+		return
+	}
+
+	prompt, err := h.service.PrepareAuthorization(r.Context(), userID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to prepare authorization")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, prompt)
+}
+
+// Authorize handles the user approving or denying a client. The frontend sends the user
+// to the returned redirect URI.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+	methods, _ := r.Context().Value("user_amr").([]string)
+
+	var req AuthorizationDecision
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	result, err := h.service.Authorize(r.Context(), userID, methods, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to authorize client")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, result)
+}
+
+// Token handles token requests of clients (RFC 6749 section 3.2)
+func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
+	creds, ok := h.clientCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.Token(r.Context(), creds, TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+	})
+	if err != nil {
+		h.writeOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.WriteJSON(w, http.StatusOK, resp)
+}
+
+// Introspect handles token introspection requests of confidential clients (RFC 7662)
+func (h *Handler) Introspect(w http.ResponseWriter, r *http.Request) {
+	creds, ok := h.clientCredentials(w, r)
+	if !ok {
+		return
+	}
+	if r.PostForm.Get("token") == "" {
+		h.writeOAuthError(w, ErrInvalidRequest)
+		return
+	}
+
+	introspection, err := h.service.Introspect(r.Context(), creds, r.PostForm.Get("token"))
+	if err != nil {
+		h.writeOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.WriteJSON(w, http.StatusOK, introspection)
+}
+
+// Revoke handles token revocation requests of clients (RFC 7009)
+func (h *Handler) Revoke(w http.ResponseWriter, r *http.Request) {
+	creds, ok := h.clientCredentials(w, r)
+	if !ok {
+		return
+	}
+	if r.PostForm.Get("token") == "" {
+		h.writeOAuthError(w, ErrInvalidRequest)
+		return
+	}
+
+	if err := h.service.Revoke(r.Context(), creds, r.PostForm.Get("token")); err != nil {
+		h.writeOAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListConsents handles listing the clients the user authorized
+func (h *Handler) ListConsents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	consents, err := h.service.ListConsents(r.Context(), userID)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to list consents")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, consents)
+}
+
+// RevokeConsent handles the user withdrawing their authorization of a client
+func (h *Handler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.RevokeConsent(r.Context(), userID, chi.URLParam(r, "clientId")); err != nil {
+		h.writeServiceError(w, err, "Failed to revoke consent")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequireActiveToken is middleware that rejects access tokens issued to clients once
+// they are revoked. It must run after authentication.
+func (h *Handler) RequireActiveToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("client_id").(string); !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tokenID, _ := r.Context().Value("token_id").(string)
+		if err := h.service.EnsureActiveToken(r.Context(), tokenID); err != nil {
+			if err == ErrTokenRevoked {
+				response.WriteError(w, http.StatusUnauthorized, "TOKEN_REVOKED", "Token has been revoked", "")
+				return
+			}
+			h.logger.Error("Failed to check token status", zap.Error(err))
+			response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientCredentials parses a form-encoded client request and returns the credentials the
+// client authenticated with: HTTP Basic authentication, or client_id and client_secret
+// form parameters. Using both is an error.
+func (h *Handler) clientCredentials(w http.ResponseWriter, r *http.Request) (ClientCredentials, bool) {
+	if err := r.ParseForm(); err != nil {
+		h.writeOAuthError(w, ErrInvalidRequest)
+		return ClientCredentials{}, false
+	}
+
+	id, secret, hasBasic := r.BasicAuth()
+	if !hasBasic {
+		return ClientCredentials{ID: r.PostForm.Get("client_id"), Secret: r.PostForm.Get("client_secret")}, true
+	}
+	if r.PostForm.Get("client_secret") != "" {
+		h.writeOAuthError(w, ErrInvalidRequest)
+		return ClientCredentials{}, false
+	}
+
+	// Basic credentials are form-encoded first (RFC 6749 section 2.3.1)
+	var err error
+	if id, err = url.QueryUnescape(id); err != nil {
+		h.writeOAuthError(w, ErrInvalidClient)
+		return ClientCredentials{}, false
+	}
+	if secret, err = url.QueryUnescape(secret); err != nil {
+		h.writeOAuthError(w, ErrInvalidClient)
+		return ClientCredentials{}, false
+	}
+	return ClientCredentials{ID: id, Secret: secret}, true
+}
+
+// decodeRequest decodes and validates a JSON request body, writing the error response
+// when it is invalid
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return false
+	}
+	return h.validate(w, req)
+}
+
+// validate validates a request, writing the error response when it is invalid
+func (h *Handler) validate(w http.ResponseWriter, req interface{}) bool {
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return false
+	}
+
+	return true
+}
+
+// writeServiceError maps service errors to HTTP responses
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error, logMessage string) {
+	switch err {
+	case ErrClientNotFound:
+		response.WriteError(w, http.StatusNotFound, "CLIENT_NOT_FOUND", "Client not found", "")
+	case ErrConsentNotFound:
+		response.WriteError(w, http.StatusNotFound, "CONSENT_NOT_FOUND", "The client has not been authorized", "")
+	case ErrInvalidRedirectURI:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REDIRECT_URI", "Redirect URI is not registered for the client or not allowed", "")
+	case ErrInvalidScope:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_SCOPE", "Scopes must be permissions allowed for the client", "")
+	case ErrUnauthorizedClient:
+		response.WriteError(w, http.StatusBadRequest, "UNAUTHORIZED_CLIENT", "The client may not use the authorization code flow", "")
+	case ErrInvalidClientMetadata:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_CLIENT_METADATA", "Grant types and redirect URIs do not fit together", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
+
+// writeOAuthError writes the error response of the token, introspection and revocation
+// endpoints (RFC 6749 section 5.2)
+func (h *Handler) writeOAuthError(w http.ResponseWriter, err error) {
+	status, code := http.StatusBadRequest, ""
+	switch err {
+	case ErrInvalidClient:
+		status, code = http.StatusUnauthorized, "invalid_client"
+		w.Header().Set("WWW-Authenticate", `Basic realm="angidi"`)
+	case ErrInvalidGrant:
+		code = "invalid_grant"
+	case ErrInvalidScope:
+		code = "invalid_scope"
+	case ErrUnauthorizedClient:
+		code = "unauthorized_client"
+	case ErrUnsupportedGrantType:
+		code = "unsupported_grant_type"
+	case ErrInvalidRequest:
+		code = "invalid_request"
+	default:
+		h.logger.Error("Failed to handle client request", zap.Error(err))
+		status, code = http.StatusInternalServerError, "server_error"
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.WriteJSON(w, status, map[string]string{"error": code})
+}
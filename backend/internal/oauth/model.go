@@ -0,0 +1,198 @@
+package oauth
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrClientNotFound is returned when a client is not registered
+	ErrClientNotFound = errors.New("client not found")
+	// ErrInvalidClient is returned when a client fails to authenticate
+	ErrInvalidClient = errors.New("invalid client")
+	// ErrInvalidRedirectURI is returned when a redirect URI is not registered for the client
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+	// ErrInvalidScope is returned when a scope is unknown or not allowed for the client
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidGrant is returned when an authorization code or refresh token is invalid,
+	// expired, revoked or was issued to another client
+	ErrInvalidGrant = errors.New("invalid grant")
+	// ErrUnauthorizedClient is returned when a client may not use the grant type it asks for
+	ErrUnauthorizedClient = errors.New("unauthorized client")
+	// ErrUnsupportedGrantType is returned when the grant type is not supported
+	ErrUnsupportedGrantType = errors.New("unsupported grant type")
+	// ErrInvalidRequest is returned when a token request misses a parameter its grant needs
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrConsentNotFound is returned when the user has not authorized the client
+	ErrConsentNotFound = errors.New("consent not found")
+	// ErrTokenNotFound is returned when an issued token is not known
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrTokenRevoked is returned when a token issued to a client was revoked
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrInvalidClientMetadata is returned when a client is registered with grant types or
+	// redirect URIs that do not fit together
+	ErrInvalidClientMetadata = errors.New("invalid client metadata")
+)
+
+// Grant types clients can be registered for
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// Kinds of issued tokens
+const (
+	TokenKindAccess  = "access_token"
+	TokenKindRefresh = "refresh_token"
+)
+
+// Client is a third-party application registered to act on behalf of users, or on its own
+// with the client credentials grant. Confidential clients authenticate with a secret, of
+// which only a SHA-256 hash is kept; public clients such as mobile apps have none and
+// rely on PKCE.
+type Client struct {
+	ID           string   `json:"client_id"`
+	Name         string   `json:"name"`
+	Public       bool     `json:"public"`
+	SecretHash   string   `json:"-"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	// Scopes are the permissions the client may ask for. Users only grant those they
+	// hold themselves; client credentials tokens get them outright.
+	Scopes    []string  `json:"scopes"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewClient is a newly registered client along with its secret, which is shown only once
+type NewClient struct {
+	*Client
+	Secret string `json:"client_secret,omitempty"`
+}
+
+// Consent records the scopes a user allowed a client to use on their behalf
+type Consent struct {
+	UserID     string    `json:"-"`
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// AuthorizationCode is a one-time code a user's approval is exchanged with. Only a
+// SHA-256 hash of the code is kept.
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        string
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	// AuthMethods records how the user authenticated when approving, e.g. "pwd" and "otp"
+	AuthMethods []string
+	// GrantID identifies the tokens issued for the code, which are revoked if it is reused
+	GrantID   string
+	UsedAt    *time.Time
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// Token is an access or refresh token issued to a client. Access tokens are JWTs
+// identified by their ID; refresh tokens are random and kept only as a SHA-256 hash.
+// Tokens issued for one authorization code share a GrantID.
+type Token struct {
+	ID          string
+	Kind        string
+	Hash        string
+	GrantID     string
+	ClientID    string
+	UserID      string
+	Scopes      []string
+	AuthMethods []string
+	ExpiresAt   time.Time
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+}
+
+// RegisterClientRequest represents a request to register a client
+type RegisterClientRequest struct {
+	Name         string   `json:"name" validate:"required,min=2,max=100"`
+	Public       bool     `json:"public"`
+	RedirectURIs []string `json:"redirect_uris" validate:"max=10,dive,url,max=512"`
+	GrantTypes   []string `json:"grant_types" validate:"required,min=1,max=3,dive,oneof=authorization_code client_credentials refresh_token"`
+	Scopes       []string `json:"scopes" validate:"max=50,dive,required,max=64"`
+}
+
+// AuthorizationRequest represents the parameters a client sends a user to authorize it
+// with, using the authorization code flow with PKCE (RFC 7636)
+type AuthorizationRequest struct {
+	ClientID            string `json:"client_id" validate:"required,max=64"`
+	RedirectURI         string `json:"redirect_uri" validate:"required,max=512"`
+	ResponseType        string `json:"response_type" validate:"required,eq=code"`
+	Scope               string `json:"scope" validate:"max=2048"`
+	State               string `json:"state" validate:"max=512"`
+	CodeChallenge       string `json:"code_challenge" validate:"required,min=43,max=128"`
+	CodeChallengeMethod string `json:"code_challenge_method" validate:"required,eq=S256"`
+}
+
+// AuthorizationDecision represents a user approving or denying an authorization request
+type AuthorizationDecision struct {
+	AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// AuthorizationPrompt describes an authorization request for the user to decide on
+type AuthorizationPrompt struct {
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+	// Consented is set when the user already allowed the client every requested scope
+	Consented bool `json:"consented"`
+}
+
+// AuthorizationResult holds the redirect URI, with the authorization code or error, to
+// send the user back to the client with
+type AuthorizationResult struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// ClientCredentials are the credentials a client authenticates to the token,
+// introspection and revocation endpoints with. Public clients only send their ID.
+type ClientCredentials struct {
+	ID     string
+	Secret string
+}
+
+// TokenRequest represents the parameters of a token request (RFC 6749 section 4)
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+// TokenResponse represents a successful token response (RFC 6749 section 5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// Introspection represents a token introspection response (RFC 7662 section 2.2).
+// Inactive tokens only report Active.
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+}
@@ -0,0 +1,336 @@
+package oauth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Repository defines the interface for OAuth data access: registered clients, the
+// consents users gave them, and the authorization codes and tokens issued to them
+type Repository interface {
+	CreateClient(ctx context.Context, client *Client) error
+	FindClient(ctx context.Context, id string) (*Client, error)
+	ListClients(ctx context.Context) ([]*Client, error)
+	// DeleteClient deletes a client along with its consents, codes and tokens
+	DeleteClient(ctx context.Context, id string) error
+
+	SaveConsent(ctx context.Context, consent *Consent) error
+	FindConsent(ctx context.Context, userID, clientID string) (*Consent, error)
+	ListConsents(ctx context.Context, userID string) ([]*Consent, error)
+	// DeleteConsent deletes a consent and revokes the tokens issued under it
+	DeleteConsent(ctx context.Context, userID, clientID string, at time.Time) error
+
+	CreateCode(ctx context.Context, code *AuthorizationCode) error
+	// ConsumeCode marks a code used and returns it as it was before, so reuse of a code
+	// can be told apart from an unknown one by its UsedAt
+	ConsumeCode(ctx context.Context, codeHash string, at time.Time) (*AuthorizationCode, error)
+
+	CreateToken(ctx context.Context, token *Token) error
+	FindToken(ctx context.Context, id string) (*Token, error)
+	FindTokenByHash(ctx context.Context, hash string) (*Token, error)
+	// RevokeToken revokes one token, failing with ErrTokenRevoked when it already was, so
+	// that only one of two concurrent refreshes with a refresh token succeeds
+	RevokeToken(ctx context.Context, id string, at time.Time) error
+	RevokeGrant(ctx context.Context, grantID string, at time.Time) error
+
+	// DeleteUserGrants deletes the consents of a user with the codes and tokens issued on
+	// their behalf
+	DeleteUserGrants(ctx context.Context, userID string) error
+}
+
+// InMemoryRepository implements Repository using in-memory storage. Everything is stored
+// and returned as copies; expired codes and tokens are dropped as new ones are added.
+type InMemoryRepository struct {
+	clients  map[string]*Client
+	consents map[string]*Consent
+	codes    map[string]*AuthorizationCode
+	tokens   map[string]*Token
+	byHash   map[string]*Token
+	mutex    sync.RWMutex
+}
+
+// NewInMemoryRepository creates a new in-memory OAuth repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		clients:  make(map[string]*Client),
+		consents: make(map[string]*Consent),
+		codes:    make(map[string]*AuthorizationCode),
+		tokens:   make(map[string]*Token),
+		byHash:   make(map[string]*Token),
+	}
+}
+
+// CreateClient stores a new client
+func (r *InMemoryRepository) CreateClient(ctx context.Context, client *Client) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *client
+	r.clients[client.ID] = &stored
+	return nil
+}
+
+// FindClient finds a client by ID
+func (r *InMemoryRepository) FindClient(ctx context.Context, id string) (*Client, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	client, exists := r.clients[id]
+	if !exists {
+		return nil, ErrClientNotFound
+	}
+
+	found := *client
+	return &found, nil
+}
+
+// ListClients lists every client, oldest first
+func (r *InMemoryRepository) ListClients(ctx context.Context) ([]*Client, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	clients := make([]*Client, 0, len(r.clients))
+	for _, client := range r.clients {
+		found := *client
+		clients = append(clients, &found)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].CreatedAt.Before(clients[j].CreatedAt) })
+	return clients, nil
+}
+
+// DeleteClient deletes a client along with its consents, codes and tokens
+func (r *InMemoryRepository) DeleteClient(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.clients[id]; !exists {
+		return ErrClientNotFound
+	}
+	delete(r.clients, id)
+
+	for key, consent := range r.consents {
+		if consent.ClientID == id {
+			delete(r.consents, key)
+		}
+	}
+	for hash, code := range r.codes {
+		if code.ClientID == id {
+			delete(r.codes, hash)
+		}
+	}
+	for tokenID, token := range r.tokens {
+		if token.ClientID == id {
+			delete(r.tokens, tokenID)
+			delete(r.byHash, token.Hash)
+		}
+	}
+	return nil
+}
+
+// SaveConsent creates or replaces the consent of a user for a client
+func (r *InMemoryRepository) SaveConsent(ctx context.Context, consent *Consent) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *consent
+	r.consents[consentKey(consent.UserID, consent.ClientID)] = &stored
+	return nil
+}
+
+// FindConsent finds the consent of a user for a client
+func (r *InMemoryRepository) FindConsent(ctx context.Context, userID, clientID string) (*Consent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	consent, exists := r.consents[consentKey(userID, clientID)]
+	if !exists {
+		return nil, ErrConsentNotFound
+	}
+
+	found := *consent
+	return &found, nil
+}
+
+// ListConsents lists the consents of a user, most recently granted first
+func (r *InMemoryRepository) ListConsents(ctx context.Context, userID string) ([]*Consent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	consents := make([]*Consent, 0)
+	for _, consent := range r.consents {
+		if consent.UserID == userID {
+			found := *consent
+			consents = append(consents, &found)
+		}
+	}
+	sort.Slice(consents, func(i, j int) bool { return consents[i].GrantedAt.After(consents[j].GrantedAt) })
+	return consents, nil
+}
+
+// DeleteConsent deletes the consent of a user for a client and revokes the tokens issued
+// to the client on their behalf
+func (r *InMemoryRepository) DeleteConsent(ctx context.Context, userID, clientID string, at time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := consentKey(userID, clientID)
+	if _, exists := r.consents[key]; !exists {
+		return ErrConsentNotFound
+	}
+	delete(r.consents, key)
+
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.ClientID == clientID && token.RevokedAt == nil {
+			revokedAt := at
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+// CreateCode stores a new authorization code
+func (r *InMemoryRepository) CreateCode(ctx context.Context, code *AuthorizationCode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for hash, existing := range r.codes {
+		if !now.Before(existing.ExpiresAt) {
+			delete(r.codes, hash)
+		}
+	}
+
+	stored := *code
+	r.codes[code.CodeHash] = &stored
+	return nil
+}
+
+// ConsumeCode marks an authorization code used and returns it as it was before. Unknown
+// and expired codes are reported as ErrInvalidGrant.
+func (r *InMemoryRepository) ConsumeCode(ctx context.Context, codeHash string, at time.Time) (*AuthorizationCode, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code, exists := r.codes[codeHash]
+	if !exists || !at.Before(code.ExpiresAt) {
+		return nil, ErrInvalidGrant
+	}
+
+	found := *code
+	if code.UsedAt == nil {
+		usedAt := at
+		code.UsedAt = &usedAt
+	}
+	return &found, nil
+}
+
+// CreateToken stores a new issued token
+func (r *InMemoryRepository) CreateToken(ctx context.Context, token *Token) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for tokenID, existing := range r.tokens {
+		if !now.Before(existing.ExpiresAt) {
+			delete(r.tokens, tokenID)
+			delete(r.byHash, existing.Hash)
+		}
+	}
+
+	stored := *token
+	r.tokens[token.ID] = &stored
+	if token.Hash != "" {
+		r.byHash[token.Hash] = &stored
+	}
+	return nil
+}
+
+// FindToken finds an issued token by ID
+func (r *InMemoryRepository) FindToken(ctx context.Context, id string) (*Token, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	token, exists := r.tokens[id]
+	if !exists {
+		return nil, ErrTokenNotFound
+	}
+
+	found := *token
+	return &found, nil
+}
+
+// FindTokenByHash finds a refresh token by the hash of its value
+func (r *InMemoryRepository) FindTokenByHash(ctx context.Context, hash string) (*Token, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	token, exists := r.byHash[hash]
+	if !exists {
+		return nil, ErrTokenNotFound
+	}
+
+	found := *token
+	return &found, nil
+}
+
+// RevokeToken revokes one token unless it already was
+func (r *InMemoryRepository) RevokeToken(ctx context.Context, id string, at time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, exists := r.tokens[id]
+	if !exists {
+		return ErrTokenNotFound
+	}
+	if token.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+	token.RevokedAt = &at
+	return nil
+}
+
+// RevokeGrant revokes every token issued for one grant
+func (r *InMemoryRepository) RevokeGrant(ctx context.Context, grantID string, at time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.GrantID == grantID && token.RevokedAt == nil {
+			revokedAt := at
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+// DeleteUserGrants deletes the consents of a user with the codes and tokens issued on
+// their behalf
+func (r *InMemoryRepository) DeleteUserGrants(ctx context.Context, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, consent := range r.consents {
+		if consent.UserID == userID {
+			delete(r.consents, key)
+		}
+	}
+	for hash, code := range r.codes {
+		if code.UserID == userID {
+			delete(r.codes, hash)
+		}
+	}
+	for tokenID, token := range r.tokens {
+		if token.UserID == userID {
+			delete(r.tokens, tokenID)
+			delete(r.byHash, token.Hash)
+		}
+	}
+	return nil
+}
+
+// consentKey identifies the consent of a user for a client
+func consentKey(userID, clientID string) string {
+	return userID + "/" + clientID
+}
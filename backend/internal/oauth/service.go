@@ -0,0 +1,708 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+	"go.uber.org/zap"
+)
+
+const (
+	// authorizationCodeTTL is how long clients have to exchange an authorization code
+	authorizationCodeTTL = 5 * time.Minute
+	refreshTokenTTL      = 30 * 24 * time.Hour
+)
+
+// Service defines the interface for the OAuth 2.0 authorization server
+type Service interface {
+	RegisterClient(ctx context.Context, adminID string, permissions []string, req RegisterClientRequest) (*NewClient, error)
+	ListClients(ctx context.Context) ([]*Client, error)
+	GetClient(ctx context.Context, id string) (*Client, error)
+	DeleteClient(ctx context.Context, id, adminID string) error
+	PrepareAuthorization(ctx context.Context, userID string, req AuthorizationRequest) (*AuthorizationPrompt, error)
+	Authorize(ctx context.Context, userID string, methods []string, req AuthorizationDecision) (*AuthorizationResult, error)
+	Token(ctx context.Context, creds ClientCredentials, req TokenRequest) (*TokenResponse, error)
+	Introspect(ctx context.Context, creds ClientCredentials, token string) (*Introspection, error)
+	Revoke(ctx context.Context, creds ClientCredentials, token string) error
+	ListConsents(ctx context.Context, userID string) ([]*Consent, error)
+	RevokeConsent(ctx context.Context, userID, clientID string) error
+	EnsureActiveToken(ctx context.Context, tokenID string) error
+	ExportPersonalData(ctx context.Context, userID string) (interface{}, error)
+	ErasePersonalData(ctx context.Context, userID string) error
+}
+
+// service implements Service
+type service struct {
+	repo       Repository
+	users      UserResolver
+	jwtService *jwtPkg.Service
+	logger     *zap.Logger
+}
+
+// NewService creates a new OAuth service that issues access tokens with jwtService for
+// the users resolved by users
+func NewService(repo Repository, users UserResolver, jwtService *jwtPkg.Service, logger *zap.Logger) Service {
+	return &service{
+		repo:       repo,
+		users:      users,
+		jwtService: jwtService,
+		logger:     logger,
+	}
+}
+
+// RegisterClient registers a client. Its scopes must be delegable permissions the
+// registering admin holds. Confidential clients get a secret, which is returned only this once.
+func (s *service) RegisterClient(ctx context.Context, adminID string, permissions []string, req RegisterClientRequest) (*NewClient, error) {
+	grantTypes := uniqueStrings(req.GrantTypes)
+	usesCode := containsString(grantTypes, GrantAuthorizationCode)
+	if usesCode != (len(req.RedirectURIs) > 0) {
+		return nil, ErrInvalidClientMetadata
+	}
+	// Public clients cannot keep a secret to act on their own with
+	if req.Public && containsString(grantTypes, GrantClientCredentials) {
+		return nil, ErrInvalidClientMetadata
+	}
+	if containsString(grantTypes, GrantRefreshToken) && !usesCode {
+		return nil, ErrInvalidClientMetadata
+	}
+	for _, redirectURI := range req.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return nil, ErrInvalidRedirectURI
+		}
+	}
+
+	scopes := uniqueStrings(req.Scopes)
+	for _, scope := range scopes {
+		if !containsString(authz.Delegable, scope) || !containsString(permissions, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	client := &Client{
+		ID:           uuid.New().String(),
+		Name:         req.Name,
+		Public:       req.Public,
+		RedirectURIs: uniqueStrings(req.RedirectURIs),
+		GrantTypes:   grantTypes,
+		Scopes:       scopes,
+		CreatedBy:    adminID,
+		CreatedAt:    time.Now(),
+	}
+	var secret string
+	if !client.Public {
+		secret = rand.Text()
+		client.SecretHash = hashToken(secret)
+	}
+	if err := s.repo.CreateClient(ctx, client); err != nil {
+		s.logger.Error("Failed to store client", zap.Error(err))
+		return nil, err
+	}
+
+	audit.SecurityEvent(s.logger, "oauth_client_registered", zap.String("client_id", client.ID), zap.String("admin_id", adminID), zap.Strings("scopes", scopes))
+	return &NewClient{Client: client, Secret: secret}, nil
+}
+
+// ListClients lists every registered client, oldest first
+func (s *service) ListClients(ctx context.Context) ([]*Client, error) {
+	clients, err := s.repo.ListClients(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list clients", zap.Error(err))
+		return nil, err
+	}
+	return clients, nil
+}
+
+// GetClient gets a registered client
+func (s *service) GetClient(ctx context.Context, id string) (*Client, error) {
+	return s.repo.FindClient(ctx, id)
+}
+
+// DeleteClient deletes a client, ending every authorization users gave it
+func (s *service) DeleteClient(ctx context.Context, id, adminID string) error {
+	if err := s.repo.DeleteClient(ctx, id); err != nil {
+		if err != ErrClientNotFound {
+			s.logger.Error("Failed to delete client", zap.String("client_id", id), zap.Error(err))
+		}
+		return err
+	}
+
+	audit.SecurityEvent(s.logger, "oauth_client_deleted", zap.String("client_id", id), zap.String("admin_id", adminID))
+	return nil
+}
+
+// PrepareAuthorization checks an authorization request and describes it for the user to
+// approve or deny
+func (s *service) PrepareAuthorization(ctx context.Context, userID string, req AuthorizationRequest) (*AuthorizationPrompt, error) {
+	client, scopes, err := s.checkAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt := &AuthorizationPrompt{ClientID: client.ID, ClientName: client.Name, Scopes: scopes}
+	consent, err := s.repo.FindConsent(ctx, userID, client.ID)
+	if err == nil {
+		prompt.Consented = containsAll(consent.Scopes, scopes)
+	} else if err != ErrConsentNotFound {
+		s.logger.Error("Failed to find consent", zap.String("client_id", client.ID), zap.Error(err))
+		return nil, err
+	}
+	return prompt, nil
+}
+
+// Authorize records the user's decision on an authorization request and returns where to
+// send them back to the client: with an authorization code when they approved, which
+// also records their consent, or with an access_denied error otherwise
+func (s *service) Authorize(ctx context.Context, userID string, methods []string, req AuthorizationDecision) (*AuthorizationResult, error) {
+	client, scopes, err := s.checkAuthorization(ctx, req.AuthorizationRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if !req.Approve {
+		s.logger.Info("User denied client authorization", zap.String("user_id", userID), zap.String("client_id", client.ID))
+		params.Set("error", "access_denied")
+		return &AuthorizationResult{RedirectTo: withQuery(req.RedirectURI, params)}, nil
+	}
+
+	now := time.Now()
+	consent, err := s.repo.FindConsent(ctx, userID, client.ID)
+	if err == ErrConsentNotFound {
+		consent = &Consent{UserID: userID, ClientID: client.ID, GrantedAt: now}
+	} else if err != nil {
+		s.logger.Error("Failed to find consent", zap.String("client_id", client.ID), zap.Error(err))
+		return nil, err
+	}
+	if !containsAll(consent.Scopes, scopes) || consent.UpdatedAt.IsZero() {
+		consent.ClientName = client.Name
+		consent.Scopes = uniqueStrings(append(consent.Scopes, scopes...))
+		consent.UpdatedAt = now
+		if err := s.repo.SaveConsent(ctx, consent); err != nil {
+			s.logger.Error("Failed to store consent", zap.String("client_id", client.ID), zap.Error(err))
+			return nil, err
+		}
+		audit.SecurityEvent(s.logger, "oauth_consent_granted", zap.String("user_id", userID), zap.String("client_id", client.ID), zap.Strings("scopes", consent.Scopes))
+	}
+
+	code := rand.Text()
+	err = s.repo.CreateCode(ctx, &AuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		AuthMethods:   methods,
+		GrantID:       uuid.New().String(),
+		ExpiresAt:     now.Add(authorizationCodeTTL),
+		CreatedAt:     now,
+	})
+	if err != nil {
+		s.logger.Error("Failed to store authorization code", zap.Error(err))
+		return nil, err
+	}
+
+	params.Set("code", code)
+	return &AuthorizationResult{RedirectTo: withQuery(req.RedirectURI, params)}, nil
+}
+
+// Token handles a token request of an authenticated client
+func (s *service) Token(ctx context.Context, creds ClientCredentials, req TokenRequest) (*TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case GrantAuthorizationCode, GrantClientCredentials, GrantRefreshToken:
+		if !containsString(client.GrantTypes, req.GrantType) {
+			return nil, ErrUnauthorizedClient
+		}
+	case "":
+		return nil, ErrInvalidRequest
+	default:
+		return nil, ErrUnsupportedGrantType
+	}
+
+	switch req.GrantType {
+	case GrantAuthorizationCode:
+		return s.exchangeCode(ctx, client, req)
+	case GrantRefreshToken:
+		return s.refresh(ctx, client, req)
+	default:
+		scopes, err := requestedScopes(client.Scopes, req.Scope)
+		if err != nil {
+			return nil, err
+		}
+		return s.issueAccessToken(ctx, client, "", uuid.New().String(), nil, scopes)
+	}
+}
+
+// exchangeCode exchanges an authorization code for tokens. A code presented again
+// revokes the tokens issued for it, as it may have been stolen.
+func (s *service) exchangeCode(ctx context.Context, client *Client, req TokenRequest) (*TokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" || req.RedirectURI == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	code, err := s.repo.ConsumeCode(ctx, hashToken(req.Code), time.Now())
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+	if code.ClientID != client.ID {
+		s.logger.Warn("Authorization code presented by another client", zap.String("client_id", client.ID))
+		return nil, ErrInvalidGrant
+	}
+	if code.UsedAt != nil {
+		if err := s.repo.RevokeGrant(ctx, code.GrantID, time.Now()); err != nil {
+			s.logger.Error("Failed to revoke grant", zap.String("client_id", client.ID), zap.Error(err))
+			return nil, err
+		}
+		audit.SecurityEvent(s.logger, "oauth_code_reused", zap.String("user_id", code.UserID), zap.String("client_id", client.ID))
+		return nil, ErrInvalidGrant
+	}
+	if code.RedirectURI != req.RedirectURI {
+		return nil, ErrInvalidGrant
+	}
+	if len(req.CodeVerifier) < 43 || len(req.CodeVerifier) > 128 ||
+		subtle.ConstantTimeCompare([]byte(oidc.CodeChallenge(req.CodeVerifier)), []byte(code.CodeChallenge)) != 1 {
+		s.logger.Warn("Authorization code presented with wrong code verifier", zap.String("client_id", client.ID))
+		return nil, ErrInvalidGrant
+	}
+
+	resp, err := s.issueAccessToken(ctx, client, code.UserID, code.GrantID, code.AuthMethods, code.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if containsString(client.GrantTypes, GrantRefreshToken) {
+		if resp.RefreshToken, err = s.issueRefreshToken(ctx, client, code.UserID, code.GrantID, code.AuthMethods, code.Scopes); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// refresh exchanges a refresh token for new tokens. Refresh tokens are single-use; one
+// presented again revokes every token of its grant.
+func (s *service) refresh(ctx context.Context, client *Client, req TokenRequest) (*TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	token, err := s.repo.FindTokenByHash(ctx, hashToken(req.RefreshToken))
+	if err != nil || token.Kind != TokenKindRefresh || token.ClientID != client.ID {
+		return nil, ErrInvalidGrant
+	}
+	now := time.Now()
+	if token.RevokedAt != nil {
+		return nil, s.refreshTokenReused(ctx, client, token, now)
+	}
+	if !now.Before(token.ExpiresAt) {
+		return nil, ErrInvalidGrant
+	}
+
+	// The access token may be narrowed to fewer scopes; the refresh token keeps them all
+	scopes := token.Scopes
+	if req.Scope != "" {
+		if scopes, err = requestedScopes(token.Scopes, req.Scope); err != nil {
+			return nil, err
+		}
+	}
+
+	// Revoking fails when a concurrent refresh used the token first, which is reuse too
+	if err := s.repo.RevokeToken(ctx, token.ID, now); err != nil {
+		if err == ErrTokenRevoked {
+			return nil, s.refreshTokenReused(ctx, client, token, now)
+		}
+		s.logger.Error("Failed to revoke refresh token", zap.String("client_id", client.ID), zap.Error(err))
+		return nil, err
+	}
+	resp, err := s.issueAccessToken(ctx, client, token.UserID, token.GrantID, token.AuthMethods, scopes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.RefreshToken, err = s.issueRefreshToken(ctx, client, token.UserID, token.GrantID, token.AuthMethods, token.Scopes); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// refreshTokenReused revokes every token of the grant of a refresh token that was used
+// before, as it may have been stolen, and returns the error to fail the refresh with
+func (s *service) refreshTokenReused(ctx context.Context, client *Client, token *Token, now time.Time) error {
+	if err := s.repo.RevokeGrant(ctx, token.GrantID, now); err != nil {
+		s.logger.Error("Failed to revoke grant", zap.String("client_id", client.ID), zap.Error(err))
+		return err
+	}
+	audit.SecurityEvent(s.logger, "oauth_refresh_token_reused", zap.String("user_id", token.UserID), zap.String("client_id", client.ID))
+	return ErrInvalidGrant
+}
+
+// issueAccessToken issues an access token to client for scopes, on behalf of userID or,
+// when it is empty, for the client itself
+func (s *service) issueAccessToken(ctx context.Context, client *Client, userID, grantID string, methods, scopes []string) (*TokenResponse, error) {
+	claims := &jwtPkg.Claims{Permissions: scopes}
+	claims.Subject = client.ID
+	if userID != "" {
+		// Users may have withdrawn their consent since approving the request
+		if _, err := s.repo.FindConsent(ctx, userID, client.ID); err != nil {
+			return nil, ErrInvalidGrant
+		}
+		var err error
+		claims, err = s.users.ScopedClaims(ctx, userID, scopes)
+		if err != nil {
+			s.logger.Warn("Token requested for unavailable user", zap.String("user_id", userID), zap.String("client_id", client.ID), zap.Error(err))
+			return nil, ErrInvalidGrant
+		}
+		claims.Subject = userID
+	}
+	claims.ClientID = client.ID
+	claims.AMR = methods
+	claims.ID = uuid.New().String()
+
+	accessToken, err := s.jwtService.IssueAccessToken(*claims)
+	if err != nil {
+		s.logger.Error("Failed to issue access token", zap.String("client_id", client.ID), zap.Error(err))
+		return nil, err
+	}
+	now := time.Now()
+	err = s.repo.CreateToken(ctx, &Token{
+		ID:        claims.ID,
+		Kind:      TokenKindAccess,
+		GrantID:   grantID,
+		ClientID:  client.ID,
+		UserID:    userID,
+		Scopes:    claims.Permissions,
+		ExpiresAt: now.Add(s.jwtService.AccessTokenDuration()),
+		CreatedAt: now,
+	})
+	if err != nil {
+		s.logger.Error("Failed to store access token", zap.String("client_id", client.ID), zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Issued client access token", zap.String("client_id", client.ID), zap.String("user_id", userID))
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.jwtService.AccessTokenDuration() / time.Second),
+		Scope:       strings.Join(claims.Permissions, " "),
+	}, nil
+}
+
+// issueRefreshToken issues a refresh token to client for scopes on behalf of userID
+func (s *service) issueRefreshToken(ctx context.Context, client *Client, userID, grantID string, methods, scopes []string) (string, error) {
+	refreshToken := rand.Text()
+	now := time.Now()
+	err := s.repo.CreateToken(ctx, &Token{
+		ID:          uuid.New().String(),
+		Kind:        TokenKindRefresh,
+		Hash:        hashToken(refreshToken),
+		GrantID:     grantID,
+		ClientID:    client.ID,
+		UserID:      userID,
+		Scopes:      scopes,
+		AuthMethods: methods,
+		ExpiresAt:   now.Add(refreshTokenTTL),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		s.logger.Error("Failed to store refresh token", zap.String("client_id", client.ID), zap.Error(err))
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// Introspect describes a token issued to the confidential client asking (RFC 7662).
+// Tokens of other clients are reported as inactive like unknown ones.
+func (s *service) Introspect(ctx context.Context, creds ClientCredentials, raw string) (*Introspection, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	if client.Public {
+		return nil, ErrInvalidClient
+	}
+
+	token := s.findIssuedToken(ctx, raw)
+	if token == nil || token.ClientID != client.ID || token.RevokedAt != nil || !time.Now().Before(token.ExpiresAt) {
+		return &Introspection{Active: false}, nil
+	}
+	if token.UserID != "" {
+		if _, err := s.users.ScopedClaims(ctx, token.UserID, nil); err != nil {
+			return &Introspection{Active: false}, nil
+		}
+	}
+
+	introspection := &Introspection{
+		Active:    true,
+		Scope:     strings.Join(token.Scopes, " "),
+		ClientID:  token.ClientID,
+		ExpiresAt: token.ExpiresAt.Unix(),
+		IssuedAt:  token.CreatedAt.Unix(),
+		Subject:   token.UserID,
+	}
+	if introspection.Subject == "" {
+		introspection.Subject = token.ClientID
+	}
+	if token.Kind == TokenKindAccess {
+		introspection.TokenType = "Bearer"
+		introspection.TokenID = token.ID
+	}
+	return introspection, nil
+}
+
+// Revoke revokes a token issued to the client asking (RFC 7009). Revoking a refresh
+// token revokes every token of its grant. Unknown tokens and tokens of other clients are
+// ignored.
+func (s *service) Revoke(ctx context.Context, creds ClientCredentials, raw string) error {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return err
+	}
+
+	token := s.findIssuedToken(ctx, raw)
+	if token == nil || token.ClientID != client.ID {
+		return nil
+	}
+
+	now := time.Now()
+	if token.Kind == TokenKindRefresh {
+		err = s.repo.RevokeGrant(ctx, token.GrantID, now)
+	} else {
+		err = s.repo.RevokeToken(ctx, token.ID, now)
+	}
+	// Revoking a revoked token succeeds (RFC 7009)
+	if err != nil && err != ErrTokenRevoked {
+		s.logger.Error("Failed to revoke token", zap.String("client_id", client.ID), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Client revoked token", zap.String("client_id", client.ID), zap.String("kind", token.Kind))
+	return nil
+}
+
+// ListConsents lists the clients a user authorized, most recent first
+func (s *service) ListConsents(ctx context.Context, userID string) ([]*Consent, error) {
+	consents, err := s.repo.ListConsents(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list consents", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return consents, nil
+}
+
+// RevokeConsent withdraws a user's authorization of a client and revokes the tokens it
+// holds on their behalf
+func (s *service) RevokeConsent(ctx context.Context, userID, clientID string) error {
+	if err := s.repo.DeleteConsent(ctx, userID, clientID, time.Now()); err != nil {
+		if err != ErrConsentNotFound {
+			s.logger.Error("Failed to delete consent", zap.String("user_id", userID), zap.Error(err))
+		}
+		return err
+	}
+
+	audit.SecurityEvent(s.logger, "oauth_consent_revoked", zap.String("user_id", userID), zap.String("client_id", clientID))
+	return nil
+}
+
+// EnsureActiveToken returns ErrTokenRevoked when an access token issued to a client was
+// revoked, directly or along with its grant, consent or client
+func (s *service) EnsureActiveToken(ctx context.Context, tokenID string) error {
+	token, err := s.repo.FindToken(ctx, tokenID)
+	if err != nil {
+		if err == ErrTokenNotFound {
+			return ErrTokenRevoked
+		}
+		s.logger.Error("Failed to find token", zap.Error(err))
+		return err
+	}
+	if token.Kind != TokenKindAccess || token.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// ExportPersonalData returns the clients a user authorized
+func (s *service) ExportPersonalData(ctx context.Context, userID string) (interface{}, error) {
+	return s.ListConsents(ctx, userID)
+}
+
+// ErasePersonalData deletes the consents of a user along with the codes and tokens
+// clients hold on their behalf, which stop working at once
+func (s *service) ErasePersonalData(ctx context.Context, userID string) error {
+	if err := s.repo.DeleteUserGrants(ctx, userID); err != nil {
+		s.logger.Error("Failed to delete grants", zap.String("user_id", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// checkAuthorization checks the client and redirect URI of an authorization request and
+// returns the client with the scopes requested
+func (s *service) checkAuthorization(ctx context.Context, req AuthorizationRequest) (*Client, []string, error) {
+	client, err := s.repo.FindClient(ctx, req.ClientID)
+	if err != nil {
+		if err != ErrClientNotFound {
+			s.logger.Error("Failed to find client", zap.Error(err))
+		}
+		return nil, nil, err
+	}
+	// Redirect URIs must match a registered one exactly
+	if !containsString(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, ErrInvalidRedirectURI
+	}
+	if !containsString(client.GrantTypes, GrantAuthorizationCode) {
+		return nil, nil, ErrUnauthorizedClient
+	}
+
+	scopes, err := requestedScopes(client.Scopes, req.Scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, scopes, nil
+}
+
+// authenticateClient returns the client creds belong to. Confidential clients must
+// present their secret and public clients none.
+func (s *service) authenticateClient(ctx context.Context, creds ClientCredentials) (*Client, error) {
+	if creds.ID == "" {
+		return nil, ErrInvalidClient
+	}
+	client, err := s.repo.FindClient(ctx, creds.ID)
+	if err != nil {
+		if err != ErrClientNotFound {
+			s.logger.Error("Failed to find client", zap.Error(err))
+			return nil, err
+		}
+		return nil, ErrInvalidClient
+	}
+
+	if client.Public {
+		if creds.Secret != "" {
+			return nil, ErrInvalidClient
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(creds.Secret)), []byte(client.SecretHash)) != 1 {
+		s.logger.Warn("Client failed to authenticate", zap.String("client_id", client.ID))
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// findIssuedToken returns the record of an access token issued to a client or of a
+// refresh token, or nil when raw is neither
+func (s *service) findIssuedToken(ctx context.Context, raw string) *Token {
+	if claims, err := s.jwtService.ValidateToken(raw); err == nil {
+		if claims.ClientID == "" || claims.ID == "" {
+			return nil
+		}
+		token, err := s.repo.FindToken(ctx, claims.ID)
+		if err != nil {
+			return nil
+		}
+		return token
+	}
+
+	token, err := s.repo.FindTokenByHash(ctx, hashToken(raw))
+	if err != nil || token.Kind != TokenKindRefresh {
+		return nil
+	}
+	return token
+}
+
+// requestedScopes parses a space-delimited scope parameter, which must only name scopes
+// of allowed. Without one, every allowed scope is requested.
+func requestedScopes(allowed []string, scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+	for _, name := range requested {
+		if !containsString(allowed, name) {
+			return nil, ErrInvalidScope
+		}
+	}
+	return uniqueStrings(requested), nil
+}
+
+// validRedirectURI reports whether a redirect URI may be registered: an absolute URI
+// without a fragment, using https unless it points at the loopback interface. Custom
+// schemes of native apps are allowed.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return false
+	}
+	if u.Scheme == "http" {
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return true
+}
+
+// withQuery adds params to the query of a redirect URI
+func withQuery(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// hashToken returns the hex-encoded SHA-256 hash of a secret, code or refresh token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAll reports whether values contains every one of wanted
+func containsAll(values, wanted []string) bool {
+	for _, value := range wanted {
+		if !containsString(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueStrings returns values without duplicates, in their original order
+func uniqueStrings(values []string) []string {
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !containsString(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
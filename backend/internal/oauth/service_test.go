@@ -0,0 +1,427 @@
+package oauth
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+)
+
+const redirectURI = "https://partner.example.com/callback"
+
+// stubUsers resolves users from a map of their permissions
+type stubUsers map[string][]string
+
+func (u stubUsers) ScopedClaims(ctx context.Context, userID string, scopes []string) (*jwtPkg.Claims, error) {
+	permissions, exists := u[userID]
+	if !exists {
+		return nil, ErrInvalidGrant
+	}
+	scoped := make([]string, 0)
+	for _, permission := range permissions {
+		if containsString(scopes, permission) {
+			scoped = append(scoped, permission)
+		}
+	}
+	return &jwtPkg.Claims{UserID: userID, Email: userID + "@example.com", Permissions: scoped}, nil
+}
+
+type testFixture struct {
+	service    Service
+	jwtService *jwtPkg.Service
+	users      stubUsers
+}
+
+func setupTestService(t *testing.T) testFixture {
+	t.Helper()
+	logger, _ := zap.NewDevelopment()
+	jwtService := jwtPkg.NewService("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+	users := stubUsers{
+		"user-1":  {authz.ProductWrite, authz.PromotionRead},
+		"admin-1": authz.All,
+	}
+
+	return testFixture{
+		service:    NewService(NewInMemoryRepository(), users, jwtService, logger),
+		jwtService: jwtService,
+		users:      users,
+	}
+}
+
+// registerPartner registers a confidential client using the authorization code and
+// refresh token grants
+func registerPartner(t *testing.T, service Service) *NewClient {
+	t.Helper()
+	client, err := service.RegisterClient(context.Background(), "admin-1", authz.All, RegisterClientRequest{
+		Name:         "Partner App",
+		RedirectURIs: []string{redirectURI},
+		GrantTypes:   []string{GrantAuthorizationCode, GrantRefreshToken},
+		Scopes:       []string{authz.ProductWrite, authz.PromotionRead},
+	})
+	require.NoError(t, err)
+	return client
+}
+
+// authorize has user-1 approve client for scope and returns the authorization code and
+// the code verifier to exchange it with
+func authorize(t *testing.T, service Service, clientID, scope string) (code, verifier string) {
+	t.Helper()
+	verifier, err := oidc.NewCodeVerifier()
+	require.NoError(t, err)
+
+	result, err := service.Authorize(context.Background(), "user-1", []string{jwtPkg.AuthMethodPassword}, AuthorizationDecision{
+		AuthorizationRequest: AuthorizationRequest{
+			ClientID:            clientID,
+			RedirectURI:         redirectURI,
+			ResponseType:        "code",
+			Scope:               scope,
+			State:               "xyz",
+			CodeChallenge:       oidc.CodeChallenge(verifier),
+			CodeChallengeMethod: "S256",
+		},
+		Approve: true,
+	})
+	require.NoError(t, err)
+
+	redirect, err := url.Parse(result.RedirectTo)
+	require.NoError(t, err)
+	assert.Equal(t, "partner.example.com", redirect.Host)
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+	require.NotEmpty(t, redirect.Query().Get("code"))
+	return redirect.Query().Get("code"), verifier
+}
+
+func TestService_RegisterClient(t *testing.T) {
+	fixture := setupTestService(t)
+	ctx := context.Background()
+
+	client := registerPartner(t, fixture.service)
+	assert.NotEmpty(t, client.ID)
+	assert.NotEmpty(t, client.Secret)
+	assert.NotEqual(t, client.Secret, client.SecretHash)
+
+	public, err := fixture.service.RegisterClient(ctx, "admin-1", authz.All, RegisterClientRequest{
+		Name:         "Mobile App",
+		Public:       true,
+		RedirectURIs: []string{"com.partner.app:/callback", "http://localhost:8000/callback"},
+		GrantTypes:   []string{GrantAuthorizationCode},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, public.Secret)
+
+	tests := []struct {
+		name        string
+		permissions []string
+		req         RegisterClientRequest
+		wantErr     error
+	}{
+		{
+			name:        "scope the admin does not hold",
+			permissions: []string{authz.ClientWrite},
+			req:         RegisterClientRequest{Name: "Sync", GrantTypes: []string{GrantClientCredentials}, Scopes: []string{authz.ProductWrite}},
+			wantErr:     ErrInvalidScope,
+		},
+		{
+			name:        "scope managing accounts",
+			permissions: authz.All,
+			req:         RegisterClientRequest{Name: "Sync", GrantTypes: []string{GrantClientCredentials}, Scopes: []string{authz.RoleAssign}},
+			wantErr:     ErrInvalidScope,
+		},
+		{
+			name:        "unknown scope",
+			permissions: []string{"orders:delete"},
+			req:         RegisterClientRequest{Name: "Sync", GrantTypes: []string{GrantClientCredentials}, Scopes: []string{"orders:delete"}},
+			wantErr:     ErrInvalidScope,
+		},
+		{
+			name:    "public client acting on its own",
+			req:     RegisterClientRequest{Name: "Sync", Public: true, GrantTypes: []string{GrantClientCredentials}},
+			wantErr: ErrInvalidClientMetadata,
+		},
+		{
+			name:    "authorization code without redirect URI",
+			req:     RegisterClientRequest{Name: "Web", GrantTypes: []string{GrantAuthorizationCode}},
+			wantErr: ErrInvalidClientMetadata,
+		},
+		{
+			name:    "refresh token without authorization code",
+			req:     RegisterClientRequest{Name: "Sync", GrantTypes: []string{GrantClientCredentials, GrantRefreshToken}},
+			wantErr: ErrInvalidClientMetadata,
+		},
+		{
+			name:    "plain http redirect URI",
+			req:     RegisterClientRequest{Name: "Web", RedirectURIs: []string{"http://partner.example.com/callback"}, GrantTypes: []string{GrantAuthorizationCode}},
+			wantErr: ErrInvalidRedirectURI,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fixture.service.RegisterClient(ctx, "admin-1", tt.permissions, tt.req)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+
+	clients, err := fixture.service.ListClients(ctx)
+	require.NoError(t, err)
+	assert.Len(t, clients, 2)
+}
+
+func TestService_AuthorizationCodeFlow(t *testing.T) {
+	fixture := setupTestService(t)
+	ctx := context.Background()
+	client := registerPartner(t, fixture.service)
+	creds := ClientCredentials{ID: client.ID, Secret: client.Secret}
+
+	req := AuthorizationRequest{ClientID: client.ID, RedirectURI: redirectURI, ResponseType: "code", Scope: authz.ProductWrite}
+	prompt, err := fixture.service.PrepareAuthorization(ctx, "user-1", req)
+	require.NoError(t, err)
+	assert.Equal(t, "Partner App", prompt.ClientName)
+	assert.Equal(t, []string{authz.ProductWrite}, prompt.Scopes)
+	assert.False(t, prompt.Consented)
+
+	// Only registered redirect URIs and scopes may be asked for
+	_, err = fixture.service.PrepareAuthorization(ctx, "user-1", AuthorizationRequest{ClientID: client.ID, RedirectURI: "https://evil.example.com/callback"})
+	assert.Equal(t, ErrInvalidRedirectURI, err)
+	_, err = fixture.service.PrepareAuthorization(ctx, "user-1", AuthorizationRequest{ClientID: client.ID, RedirectURI: redirectURI, Scope: authz.UserWrite})
+	assert.Equal(t, ErrInvalidScope, err)
+
+	code, verifier := authorize(t, fixture.service, client.ID, authz.ProductWrite)
+
+	prompt, err = fixture.service.PrepareAuthorization(ctx, "user-1", req)
+	require.NoError(t, err)
+	assert.True(t, prompt.Consented)
+
+	// The code needs the client's secret, its redirect URI and the code verifier
+	exchange := TokenRequest{GrantType: GrantAuthorizationCode, Code: code, RedirectURI: redirectURI, CodeVerifier: verifier}
+	_, err = fixture.service.Token(ctx, ClientCredentials{ID: client.ID, Secret: "wrong"}, exchange)
+	assert.Equal(t, ErrInvalidClient, err)
+
+	tokens, err := fixture.service.Token(ctx, creds, exchange)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, 900, tokens.ExpiresIn)
+	assert.Equal(t, authz.ProductWrite, tokens.Scope)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	claims, err := fixture.jwtService.ValidateToken(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, client.ID, claims.ClientID)
+	assert.Equal(t, []string{authz.ProductWrite}, claims.Permissions)
+	assert.Equal(t, []string{jwtPkg.AuthMethodPassword}, claims.AMR)
+	require.NoError(t, fixture.service.EnsureActiveToken(ctx, claims.ID))
+
+	// A code used twice may have been stolen, so the tokens issued for it are revoked
+	_, err = fixture.service.Token(ctx, creds, exchange)
+	assert.Equal(t, ErrInvalidGrant, err)
+	assert.Equal(t, ErrTokenRevoked, fixture.service.EnsureActiveToken(ctx, claims.ID))
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantRefreshToken, RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, ErrInvalidGrant, err)
+
+	// A wrong code verifier fails the exchange
+	code, _ = authorize(t, fixture.service, client.ID, authz.ProductWrite)
+	other, err := oidc.NewCodeVerifier()
+	require.NoError(t, err)
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantAuthorizationCode, Code: code, RedirectURI: redirectURI, CodeVerifier: other})
+	assert.Equal(t, ErrInvalidGrant, err)
+
+	// Clients may not use grants they were not registered for
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantClientCredentials})
+	assert.Equal(t, ErrUnauthorizedClient, err)
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: "password"})
+	assert.Equal(t, ErrUnsupportedGrantType, err)
+}
+
+func TestService_AuthorizationDenied(t *testing.T) {
+	fixture := setupTestService(t)
+	client := registerPartner(t, fixture.service)
+
+	result, err := fixture.service.Authorize(context.Background(), "user-1", nil, AuthorizationDecision{
+		AuthorizationRequest: AuthorizationRequest{ClientID: client.ID, RedirectURI: redirectURI, State: "xyz"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, redirectURI+"?error=access_denied&state=xyz", result.RedirectTo)
+
+	consents, err := fixture.service.ListConsents(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, consents)
+}
+
+func TestService_RefreshToken(t *testing.T) {
+	fixture := setupTestService(t)
+	ctx := context.Background()
+	client := registerPartner(t, fixture.service)
+	creds := ClientCredentials{ID: client.ID, Secret: client.Secret}
+
+	code, verifier := authorize(t, fixture.service, client.ID, "")
+	tokens, err := fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantAuthorizationCode, Code: code, RedirectURI: redirectURI, CodeVerifier: verifier})
+	require.NoError(t, err)
+	assert.Equal(t, authz.ProductWrite+" "+authz.PromotionRead, tokens.Scope)
+
+	// Access tokens can be narrowed to fewer scopes, but not widened
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantRefreshToken, RefreshToken: tokens.RefreshToken, Scope: authz.UserRead})
+	assert.Equal(t, ErrInvalidScope, err)
+	refreshed, err := fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantRefreshToken, RefreshToken: tokens.RefreshToken, Scope: authz.PromotionRead})
+	require.NoError(t, err)
+	assert.Equal(t, authz.PromotionRead, refreshed.Scope)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+	// The rotated refresh token still covers every scope
+	rotated, err := fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantRefreshToken, RefreshToken: refreshed.RefreshToken})
+	require.NoError(t, err)
+	assert.Equal(t, authz.ProductWrite+" "+authz.PromotionRead, rotated.Scope)
+
+	// Presenting a used refresh token revokes the whole grant
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantRefreshToken, RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, ErrInvalidGrant, err)
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantRefreshToken, RefreshToken: rotated.RefreshToken})
+	assert.Equal(t, ErrInvalidGrant, err)
+	claims, err := fixture.jwtService.ValidateToken(rotated.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, ErrTokenRevoked, fixture.service.EnsureActiveToken(ctx, claims.ID))
+}
+
+func TestService_ConcurrentRefresh(t *testing.T) {
+	fixture := setupTestService(t)
+	ctx := context.Background()
+	client := registerPartner(t, fixture.service)
+	creds := ClientCredentials{ID: client.ID, Secret: client.Secret}
+	code, verifier := authorize(t, fixture.service, client.ID, "")
+	tokens, err := fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantAuthorizationCode, Code: code, RedirectURI: redirectURI, CodeVerifier: verifier})
+	require.NoError(t, err)
+
+	// Only one of the refreshes made with the same token at once gets new tokens
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantRefreshToken, RefreshToken: tokens.RefreshToken}); err == nil {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestService_ClientCredentials(t *testing.T) {
+	fixture := setupTestService(t)
+	ctx := context.Background()
+
+	client, err := fixture.service.RegisterClient(ctx, "admin-1", authz.All, RegisterClientRequest{
+		Name:       "Inventory Sync",
+		GrantTypes: []string{GrantClientCredentials},
+		Scopes:     []string{authz.ProductWrite},
+	})
+	require.NoError(t, err)
+	creds := ClientCredentials{ID: client.ID, Secret: client.Secret}
+
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantClientCredentials, Scope: authz.UserWrite})
+	assert.Equal(t, ErrInvalidScope, err)
+
+	tokens, err := fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantClientCredentials})
+	require.NoError(t, err)
+	assert.Empty(t, tokens.RefreshToken)
+
+	claims, err := fixture.jwtService.ValidateToken(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Empty(t, claims.UserID)
+	assert.Equal(t, client.ID, claims.Subject)
+	assert.Equal(t, client.ID, claims.ClientID)
+	assert.Equal(t, []string{authz.ProductWrite}, claims.Permissions)
+
+	// Deleting the client revokes its tokens
+	require.NoError(t, fixture.service.DeleteClient(ctx, client.ID, "admin-1"))
+	assert.Equal(t, ErrTokenRevoked, fixture.service.EnsureActiveToken(ctx, claims.ID))
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantClientCredentials})
+	assert.Equal(t, ErrInvalidClient, err)
+}
+
+func TestService_IntrospectAndRevoke(t *testing.T) {
+	fixture := setupTestService(t)
+	ctx := context.Background()
+	client := registerPartner(t, fixture.service)
+	creds := ClientCredentials{ID: client.ID, Secret: client.Secret}
+	other := registerPartner(t, fixture.service)
+	otherCreds := ClientCredentials{ID: other.ID, Secret: other.Secret}
+
+	code, verifier := authorize(t, fixture.service, client.ID, authz.ProductWrite)
+	tokens, err := fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantAuthorizationCode, Code: code, RedirectURI: redirectURI, CodeVerifier: verifier})
+	require.NoError(t, err)
+
+	introspection, err := fixture.service.Introspect(ctx, creds, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, authz.ProductWrite, introspection.Scope)
+	assert.Equal(t, client.ID, introspection.ClientID)
+	assert.Equal(t, "user-1", introspection.Subject)
+	assert.Equal(t, "Bearer", introspection.TokenType)
+
+	introspection, err = fixture.service.Introspect(ctx, creds, tokens.RefreshToken)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+
+	// Other clients' tokens and garbage look the same
+	introspection, err = fixture.service.Introspect(ctx, otherCreds, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, introspection.Active)
+	introspection, err = fixture.service.Introspect(ctx, creds, "not-a-token")
+	require.NoError(t, err)
+	assert.Equal(t, &Introspection{Active: false}, introspection)
+
+	// Other clients cannot revoke the tokens
+	require.NoError(t, fixture.service.Revoke(ctx, otherCreds, tokens.AccessToken))
+	introspection, err = fixture.service.Introspect(ctx, creds, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+
+	// Revoking the refresh token revokes the access token issued with it
+	require.NoError(t, fixture.service.Revoke(ctx, creds, tokens.RefreshToken))
+	introspection, err = fixture.service.Introspect(ctx, creds, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, introspection.Active)
+}
+
+func TestService_RevokeConsent(t *testing.T) {
+	fixture := setupTestService(t)
+	ctx := context.Background()
+	client := registerPartner(t, fixture.service)
+	creds := ClientCredentials{ID: client.ID, Secret: client.Secret}
+
+	code, verifier := authorize(t, fixture.service, client.ID, authz.ProductWrite)
+	tokens, err := fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantAuthorizationCode, Code: code, RedirectURI: redirectURI, CodeVerifier: verifier})
+	require.NoError(t, err)
+	// A later code not yet exchanged when consent is withdrawn
+	code, verifier = authorize(t, fixture.service, client.ID, authz.PromotionRead)
+
+	consents, err := fixture.service.ListConsents(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, consents, 1)
+	assert.Equal(t, "Partner App", consents[0].ClientName)
+	assert.Equal(t, []string{authz.ProductWrite, authz.PromotionRead}, consents[0].Scopes)
+
+	require.NoError(t, fixture.service.RevokeConsent(ctx, "user-1", client.ID))
+	assert.Equal(t, ErrConsentNotFound, fixture.service.RevokeConsent(ctx, "user-1", client.ID))
+
+	claims, err := fixture.jwtService.ValidateToken(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, ErrTokenRevoked, fixture.service.EnsureActiveToken(ctx, claims.ID))
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantRefreshToken, RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, ErrInvalidGrant, err)
+	_, err = fixture.service.Token(ctx, creds, TokenRequest{GrantType: GrantAuthorizationCode, Code: code, RedirectURI: redirectURI, CodeVerifier: verifier})
+	assert.Equal(t, ErrInvalidGrant, err)
+}
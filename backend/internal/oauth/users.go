@@ -0,0 +1,15 @@
+package oauth
+
+import (
+	"context"
+
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+)
+
+// UserResolver resolves the users clients act on behalf of. It is implemented by the user
+// domain.
+type UserResolver interface {
+	// ScopedClaims returns the access token claims of a user with their permissions
+	// narrowed to scopes, or an error when the user no longer exists or may not sign in
+	ScopedClaims(ctx context.Context, userID string, scopes []string) (*jwtPkg.Claims, error)
+}
@@ -0,0 +1,143 @@
+package order
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/checkout"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+	"go.uber.org/zap"
+)
+
+// Handler handles HTTP requests for orders
+type Handler struct {
+	service   Service
+	validator *validator.Validate
+	logger    *zap.Logger
+}
+
+// NewHandler creates a new order handler
+func NewHandler(service Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service:   service,
+		validator: validator.New(),
+		logger:    logger,
+	}
+}
+
+// Place handles a customer ordering their cart
+func (h *Handler) Place(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req PlaceOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	order, err := h.service.Place(r.Context(), userID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to place order")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, order)
+}
+
+// List handles listing orders; staff who may read orders see every order, customers only
+// their own
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+	if authz.HasPermission(r.Context(), authz.OrderRead) {
+		userID = r.URL.Query().Get("user_id")
+	}
+
+	orders, err := h.service.List(r.Context(), userID)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to list orders")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, orders)
+}
+
+// GetByID handles getting an order; users may only view their own orders
+func (h *Handler) GetByID(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+	order, err := h.service.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get order")
+		return
+	}
+
+	// Hide other users' orders behind a 404 rather than revealing they exist
+	if order.UserID != userID && !authz.HasPermission(r.Context(), authz.OrderRead) {
+		response.WriteError(w, http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, order)
+}
+
+// Deliver handles staff recording that a paid order was delivered
+func (h *Handler) Deliver(w http.ResponseWriter, r *http.Request) {
+	order, err := h.service.MarkDelivered(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to mark order delivered")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, order)
+}
+
+// writeServiceError maps service errors to HTTP responses
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error, logMessage string) {
+	switch err {
+	case ErrOrderNotFound:
+		response.WriteError(w, http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found", "")
+	case ErrInvalidOrderState:
+		response.WriteError(w, http.StatusConflict, "INVALID_ORDER_STATE", "Operation not allowed in current order state", "")
+	case product.ErrProductNotFound:
+		response.WriteError(w, http.StatusBadRequest, "PRODUCT_NOT_FOUND", "Cart contains an unknown product", "")
+	case checkout.ErrInsufficientStock:
+		response.WriteError(w, http.StatusConflict, "INSUFFICIENT_STOCK", "Not enough stock for one or more items", "")
+	case promotion.ErrUsageLimitReached:
+		response.WriteError(w, http.StatusConflict, "PROMOTION_USAGE_LIMIT_REACHED", "A promotion applied to the cart has no uses left; price the cart again", "")
+	case shipping.ErrMethodUnavailable:
+		response.WriteError(w, http.StatusBadRequest, "SHIPPING_METHOD_UNAVAILABLE", "Shipping method is not available for this cart and address", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
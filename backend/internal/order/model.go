@@ -0,0 +1,62 @@
+package order
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/checkout"
+)
+
+var (
+	// ErrOrderNotFound is returned when an order is not found
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidOrderState is returned when an order cannot make the requested transition
+	ErrInvalidOrderState = errors.New("invalid order state")
+	// ErrPaymentMismatch is returned when a payment was made by someone else or for another amount than the order total
+	ErrPaymentMismatch = errors.New("payment does not match order")
+)
+
+// Order statuses
+const (
+	StatusPendingPayment = "pending_payment"
+	StatusPaid           = "paid"
+	StatusDelivered      = "delivered"
+)
+
+// Order represents a priced cart a customer has committed to buy. Its totals are fixed
+// when the order is placed.
+type Order struct {
+	ID               string     `json:"id"`
+	UserID           string     `json:"user_id"`
+	Status           string     `json:"status"`
+	Lines            []Line     `json:"lines"`
+	Subtotal         float64    `json:"subtotal"`
+	DiscountTotal    float64    `json:"discount_total"`
+	ShippingTotal    float64    `json:"shipping_total"`
+	ShippingDiscount float64    `json:"shipping_discount"`
+	TaxTotal         float64    `json:"tax_total"`
+	Total            float64    `json:"total"`
+	PromotionIDs     []string   `json:"promotion_ids"`
+	PaymentID        string     `json:"payment_id,omitempty"`
+	PaidAt           *time.Time `json:"paid_at,omitempty"`
+	DeliveredAt      *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Line represents a product bought on an order. Total is what was paid for the line
+// after discounts and before tax.
+type Line struct {
+	ID        string  `json:"id"`
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	UnitPrice float64 `json:"unit_price"`
+	Quantity  int     `json:"quantity"`
+	Discount  float64 `json:"discount"`
+	Total     float64 `json:"total"`
+}
+
+// PlaceOrderRequest represents a cart to order. It is priced exactly like checkout totals.
+type PlaceOrderRequest struct {
+	checkout.TotalsRequest
+}
@@ -0,0 +1,91 @@
+package order
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository defines the interface for order data access
+type Repository interface {
+	Create(ctx context.Context, order *Order) error
+	FindByID(ctx context.Context, id string) (*Order, error)
+	List(ctx context.Context, userID string) ([]*Order, error)
+	Update(ctx context.Context, order *Order) error
+}
+
+// InMemoryRepository implements Repository using in-memory storage. It stores and returns
+// copies, so a rejected change never leaks into the stored order.
+type InMemoryRepository struct {
+	orders map[string]*Order
+	mutex  sync.RWMutex
+}
+
+// NewInMemoryRepository creates a new in-memory repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		orders: make(map[string]*Order),
+	}
+}
+
+// Create creates a new order
+func (r *InMemoryRepository) Create(ctx context.Context, order *Order) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.orders[order.ID] = copyOrder(order)
+	return nil
+}
+
+// FindByID finds an order by ID
+func (r *InMemoryRepository) FindByID(ctx context.Context, id string) (*Order, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	order, exists := r.orders[id]
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+
+	return copyOrder(order), nil
+}
+
+// List lists orders newest first, optionally filtered by user
+func (r *InMemoryRepository) List(ctx context.Context, userID string) ([]*Order, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	orders := make([]*Order, 0)
+	for _, order := range r.orders {
+		if userID != "" && order.UserID != userID {
+			continue
+		}
+		orders = append(orders, copyOrder(order))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].CreatedAt.After(orders[j].CreatedAt)
+	})
+
+	return orders, nil
+}
+
+// Update updates an order
+func (r *InMemoryRepository) Update(ctx context.Context, order *Order) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.orders[order.ID]; !exists {
+		return ErrOrderNotFound
+	}
+
+	r.orders[order.ID] = copyOrder(order)
+	return nil
+}
+
+// copyOrder returns a copy of order that shares no slices with it
+func copyOrder(order *Order) *Order {
+	copied := *order
+	copied.Lines = append(make([]Line, 0, len(order.Lines)), order.Lines...)
+	copied.PromotionIDs = append(make([]string, 0, len(order.PromotionIDs)), order.PromotionIDs...)
+	return &copied
+}
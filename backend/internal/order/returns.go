@@ -0,0 +1,49 @@
+package order
+
+import (
+	"context"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/returns"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/money"
+)
+
+// ReturnsReader looks up orders for the returns raised against them. It implements
+// returns.OrderReader.
+type ReturnsReader struct {
+	service Service
+}
+
+// NewReturnsReader creates a returns.OrderReader reading from service
+func NewReturnsReader(service Service) *ReturnsReader {
+	return &ReturnsReader{service: service}
+}
+
+// GetOrder returns the parts of an order a return needs. Lines are priced at what was
+// paid per unit after discounts, rounded to the cent, so returned items are refunded at
+// their discounted price.
+func (r *ReturnsReader) GetOrder(ctx context.Context, orderID string) (*returns.Order, error) {
+	order, err := r.service.GetByID(ctx, orderID)
+	if err != nil {
+		if err == ErrOrderNotFound {
+			return nil, returns.ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	found := &returns.Order{
+		ID:          order.ID,
+		UserID:      order.UserID,
+		PaymentID:   order.PaymentID,
+		DeliveredAt: order.DeliveredAt,
+		Lines:       make([]returns.OrderLine, len(order.Lines)),
+	}
+	for i, line := range order.Lines {
+		found.Lines[i] = returns.OrderLine{
+			ID:        line.ID,
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+			UnitPrice: money.Round(line.Total / float64(line.Quantity)),
+		}
+	}
+	return found, nil
+}
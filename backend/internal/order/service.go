@@ -0,0 +1,270 @@
+package order
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/checkout"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+// Service defines the interface for order business logic
+type Service interface {
+	Place(ctx context.Context, userID string, req PlaceOrderRequest) (*Order, error)
+	GetByID(ctx context.Context, id string) (*Order, error)
+	List(ctx context.Context, userID string) ([]*Order, error)
+	MarkPaid(ctx context.Context, p payment.Payment) error
+	MarkDelivered(ctx context.Context, id string) (*Order, error)
+	ExportPersonalData(ctx context.Context, userID string) (interface{}, error)
+	ErasePersonalData(ctx context.Context, userID string) error
+}
+
+// service implements Service
+type service struct {
+	repo             Repository
+	checkoutService  checkout.Service
+	productService   product.Service
+	promotionService promotion.Service
+	logger           *zap.Logger
+	// mutex serializes status transitions
+	mutex sync.Mutex
+}
+
+// NewService creates a new order service. Carts are priced by checkoutService, their stock
+// taken through productService and the promotions they used redeemed through promotionService.
+func NewService(repo Repository, checkoutService checkout.Service, productService product.Service, promotionService promotion.Service, logger *zap.Logger) Service {
+	return &service{
+		repo:             repo,
+		checkoutService:  checkoutService,
+		productService:   productService,
+		promotionService: promotionService,
+		logger:           logger,
+	}
+}
+
+// Place prices a cart and orders it, redeeming the promotions that discounted it and taking
+// its items out of stock. The order then waits for a payment of its total.
+func (s *service) Place(ctx context.Context, userID string, req PlaceOrderRequest) (*Order, error) {
+	s.logger.Info("Placing order", zap.String("user_id", userID), zap.Int("items", len(req.Items)))
+
+	totals, err := s.checkoutService.CalculateTotals(ctx, userID, req.TotalsRequest)
+	if err != nil {
+		s.logger.Debug("Failed to price order", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	now := time.Now()
+	order := &Order{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		Status:           StatusPendingPayment,
+		Lines:            make([]Line, len(totals.Lines)),
+		Subtotal:         totals.Subtotal,
+		DiscountTotal:    totals.DiscountTotal,
+		ShippingTotal:    totals.ShippingTotal,
+		ShippingDiscount: totals.ShippingDiscount,
+		TaxTotal:         totals.TaxTotal,
+		Total:            totals.Total,
+		PromotionIDs:     make([]string, 0, len(totals.Promotions)),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	for i, line := range totals.Lines {
+		order.Lines[i] = Line{
+			ID:        uuid.New().String(),
+			ProductID: line.ProductID,
+			Name:      line.Name,
+			UnitPrice: line.UnitPrice,
+			Quantity:  line.Quantity,
+			Discount:  line.Discount,
+			Total:     line.Total,
+		}
+	}
+	for _, outcome := range totals.Promotions {
+		if outcome.Applied {
+			order.PromotionIDs = append(order.PromotionIDs, outcome.PromotionID)
+		}
+	}
+
+	// Usage limits were checked when pricing, but other orders may have used the promotions
+	// since
+	if err := s.promotionService.Redeem(ctx, userID, order.PromotionIDs); err != nil {
+		return nil, err
+	}
+
+	// Stock was checked when pricing, but another order may have taken it since
+	for i, line := range order.Lines {
+		if _, err := s.productService.AdjustStock(ctx, line.ProductID, -line.Quantity); err != nil {
+			s.restock(ctx, order.Lines[:i])
+			s.release(ctx, order)
+			if err == product.ErrInsufficientStock {
+				return nil, checkout.ErrInsufficientStock
+			}
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Create(ctx, order); err != nil {
+		s.logger.Error("Failed to create order", zap.Error(err))
+		s.restock(ctx, order.Lines)
+		s.release(ctx, order)
+		return nil, err
+	}
+
+	s.logger.Info("Order placed successfully", zap.String("order_id", order.ID), zap.Float64("total", order.Total))
+	return order, nil
+}
+
+// GetByID retrieves an order by ID
+func (s *service) GetByID(ctx context.Context, id string) (*Order, error) {
+	s.logger.Debug("Getting order", zap.String("order_id", id))
+
+	order, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Debug("Failed to get order", zap.String("order_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// List retrieves orders newest first; an empty userID lists every order
+func (s *service) List(ctx context.Context, userID string) ([]*Order, error) {
+	s.logger.Debug("Listing orders", zap.String("user_id", userID))
+
+	orders, err := s.repo.List(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list orders", zap.Error(err))
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// MarkPaid records that p, a verified capture, paid for its order. It implements
+// payment.OrderNotifier; the payment must come from the customer who placed the order and
+// cover its total. Notifying the same payment again has no effect.
+func (s *service) MarkPaid(ctx context.Context, p payment.Payment) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, err := s.repo.FindByID(ctx, p.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.PaymentID == p.ID {
+		return nil
+	}
+	if order.Status != StatusPendingPayment {
+		return ErrInvalidOrderState
+	}
+	if p.UserID != order.UserID || money.Round(p.Amount) != order.Total {
+		s.logger.Warn("Payment does not match order",
+			zap.String("order_id", order.ID),
+			zap.String("payment_id", p.ID),
+			zap.Float64("amount", p.Amount),
+			zap.Float64("total", order.Total),
+		)
+		return ErrPaymentMismatch
+	}
+
+	now := time.Now()
+	order.Status = StatusPaid
+	order.PaymentID = p.ID
+	order.PaidAt = &now
+	order.UpdatedAt = now
+	if err := s.repo.Update(ctx, order); err != nil {
+		s.logger.Error("Failed to update order", zap.String("order_id", order.ID), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Order paid", zap.String("order_id", order.ID), zap.String("payment_id", p.ID))
+	return nil
+}
+
+// MarkDelivered records that a paid order reached the customer, which opens its return window
+func (s *service) MarkDelivered(ctx context.Context, id string) (*Order, error) {
+	s.logger.Info("Marking order delivered", zap.String("order_id", id))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != StatusPaid {
+		return nil, ErrInvalidOrderState
+	}
+
+	now := time.Now()
+	order.Status = StatusDelivered
+	order.DeliveredAt = &now
+	order.UpdatedAt = now
+	if err := s.repo.Update(ctx, order); err != nil {
+		s.logger.Error("Failed to update order", zap.String("order_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// ExportPersonalData returns the orders of a user
+func (s *service) ExportPersonalData(ctx context.Context, userID string) (interface{}, error) {
+	orders, err := s.repo.List(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list orders", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ErasePersonalData unlinks the orders of a user from them. Like payments, the orders are
+// kept as accounting records but no longer name the user.
+func (s *service) ErasePersonalData(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	orders, err := s.repo.List(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list orders", zap.String("user_id", userID), zap.Error(err))
+		return err
+	}
+
+	now := time.Now()
+	for _, order := range orders {
+		order.UserID = ""
+		order.UpdatedAt = now
+		if err := s.repo.Update(ctx, order); err != nil {
+			s.logger.Error("Failed to anonymize order", zap.String("order_id", order.ID), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// restock puts the items of lines back into stock after an order could not be placed
+func (s *service) restock(ctx context.Context, lines []Line) {
+	for _, line := range lines {
+		if _, err := s.productService.AdjustStock(ctx, line.ProductID, line.Quantity); err != nil {
+			s.logger.Error("Failed to restock product",
+				zap.String("product_id", line.ProductID),
+				zap.Int("quantity", line.Quantity),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// release takes back the promotions an order redeemed after it could not be placed
+func (s *service) release(ctx context.Context, order *Order) {
+	if err := s.promotionService.Release(ctx, order.UserID, order.PromotionIDs); err != nil {
+		s.logger.Error("Failed to release promotions", zap.String("user_id", order.UserID), zap.Error(err))
+	}
+}
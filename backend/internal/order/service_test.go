@@ -0,0 +1,187 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/checkout"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/returns"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/tax"
+)
+
+// failingRepository fails to create orders
+type failingRepository struct {
+	Repository
+}
+
+func (r failingRepository) Create(ctx context.Context, order *Order) error {
+	return errors.New("storage unavailable")
+}
+
+type testFixture struct {
+	service          Service
+	checkoutService  checkout.Service
+	productService   product.Service
+	promotionService promotion.Service
+	mug              *product.Product
+	plate            *product.Product
+}
+
+func setupTestService(t *testing.T) testFixture {
+	t.Helper()
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	productService := product.NewService(product.NewInMemoryRepository(), logger)
+	promotionService := promotion.NewService(promotion.NewInMemoryRepository(), logger)
+	checkoutService := checkout.NewService(productService, promotionService, tax.NewCalculator(tax.Config{}), shipping.NewCalculator(shipping.Config{}), logger)
+
+	mug, err := productService.Create(ctx, product.CreateProductRequest{Name: "Mug", Price: 10, Stock: 5, CategoryID: "kitchen"})
+	require.NoError(t, err)
+	plate, err := productService.Create(ctx, product.CreateProductRequest{Name: "Plate", Price: 20, Stock: 1, CategoryID: "kitchen"})
+	require.NoError(t, err)
+
+	return testFixture{
+		service:          NewService(NewInMemoryRepository(), checkoutService, productService, promotionService, logger),
+		checkoutService:  checkoutService,
+		productService:   productService,
+		promotionService: promotionService,
+		mug:              mug,
+		plate:            plate,
+	}
+}
+
+func orderRequest(items ...checkout.ItemRequest) PlaceOrderRequest {
+	return PlaceOrderRequest{TotalsRequest: checkout.TotalsRequest{Items: items}}
+}
+
+func (f testFixture) stock(t *testing.T, id string) int {
+	t.Helper()
+	p, err := f.productService.GetByID(context.Background(), id)
+	require.NoError(t, err)
+	return p.Stock
+}
+
+func TestService_Place(t *testing.T) {
+	f := setupTestService(t)
+	ctx := context.Background()
+
+	order, err := f.service.Place(ctx, "user-1", orderRequest(checkout.ItemRequest{ProductID: f.mug.ID, Quantity: 2}))
+	require.NoError(t, err)
+	assert.Equal(t, StatusPendingPayment, order.Status)
+	assert.Equal(t, 20.0, order.Total)
+	require.Len(t, order.Lines, 1)
+	assert.NotEmpty(t, order.Lines[0].ID)
+	assert.Equal(t, 3, f.stock(t, f.mug.ID))
+
+	// A line that ran out of stock puts back what the earlier lines took
+	_, err = f.service.Place(ctx, "user-1", orderRequest(
+		checkout.ItemRequest{ProductID: f.plate.ID, Quantity: 1},
+		checkout.ItemRequest{ProductID: f.plate.ID, Quantity: 1},
+	))
+	assert.Equal(t, checkout.ErrInsufficientStock, err)
+	assert.Equal(t, 1, f.stock(t, f.plate.ID))
+
+	orders, err := f.service.List(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Len(t, orders, 1)
+}
+
+func TestService_PlaceRedeemsPromotions(t *testing.T) {
+	f := setupTestService(t)
+	ctx := context.Background()
+
+	promo, err := f.promotionService.Create(ctx, promotion.PromotionRequest{Name: "Tenth off", Type: promotion.TypePercentage, Value: 10, MaxUses: 2, MaxUsesPerUser: 1, Active: true})
+	require.NoError(t, err)
+	place := func(userID string) *Order {
+		t.Helper()
+		order, err := f.service.Place(ctx, userID, orderRequest(checkout.ItemRequest{ProductID: f.mug.ID, Quantity: 1}))
+		require.NoError(t, err)
+		return order
+	}
+
+	// An order that could not be saved gives back its stock and does not use up the promotion
+	failing := NewService(failingRepository{NewInMemoryRepository()}, f.checkoutService, f.productService, f.promotionService, zap.NewNop())
+	_, err = failing.Place(ctx, "user-1", orderRequest(checkout.ItemRequest{ProductID: f.mug.ID, Quantity: 1}))
+	require.Error(t, err)
+	assert.Equal(t, 5, f.stock(t, f.mug.ID))
+
+	order := place("user-1")
+	assert.Equal(t, []string{promo.ID}, order.PromotionIDs)
+	assert.Equal(t, 9.0, order.Total)
+
+	// max_uses_per_user stops user-1 using it again
+	order = place("user-1")
+	assert.Empty(t, order.PromotionIDs)
+	assert.Equal(t, 10.0, order.Total)
+
+	// max_uses stops everyone once the promotion was used twice
+	order = place("user-2")
+	assert.Equal(t, []string{promo.ID}, order.PromotionIDs)
+	order = place("user-3")
+	assert.Empty(t, order.PromotionIDs)
+
+	found, err := f.promotionService.GetByID(ctx, promo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, found.UsageCount)
+}
+
+func TestService_MarkPaidAndDelivered(t *testing.T) {
+	f := setupTestService(t)
+	ctx := context.Background()
+
+	order, err := f.service.Place(ctx, "user-1", orderRequest(checkout.ItemRequest{ProductID: f.mug.ID, Quantity: 3}))
+	require.NoError(t, err)
+
+	// Orders are delivered only once paid
+	_, err = f.service.MarkDelivered(ctx, order.ID)
+	assert.Equal(t, ErrInvalidOrderState, err)
+
+	// Payments by someone else or for another amount do not pay for the order
+	assert.Equal(t, ErrPaymentMismatch, f.service.MarkPaid(ctx, payment.Payment{ID: "pay-1", OrderID: order.ID, UserID: "user-2", Amount: 30}))
+	assert.Equal(t, ErrPaymentMismatch, f.service.MarkPaid(ctx, payment.Payment{ID: "pay-1", OrderID: order.ID, UserID: "user-1", Amount: 1}))
+	assert.Equal(t, ErrOrderNotFound, f.service.MarkPaid(ctx, payment.Payment{ID: "pay-1", OrderID: "unknown", UserID: "user-1", Amount: 30}))
+
+	require.NoError(t, f.service.MarkPaid(ctx, payment.Payment{ID: "pay-1", OrderID: order.ID, UserID: "user-1", Amount: 30}))
+	// A repeated notification is ignored, a second payment is not
+	require.NoError(t, f.service.MarkPaid(ctx, payment.Payment{ID: "pay-1", OrderID: order.ID, UserID: "user-1", Amount: 30}))
+	assert.Equal(t, ErrInvalidOrderState, f.service.MarkPaid(ctx, payment.Payment{ID: "pay-2", OrderID: order.ID, UserID: "user-1", Amount: 30}))
+
+	delivered, err := f.service.MarkDelivered(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDelivered, delivered.Status)
+	assert.Equal(t, "pay-1", delivered.PaymentID)
+	require.NotNil(t, delivered.DeliveredAt)
+}
+
+func TestReturnsReader_GetOrder(t *testing.T) {
+	f := setupTestService(t)
+	ctx := context.Background()
+	reader := NewReturnsReader(f.service)
+
+	_, err := reader.GetOrder(ctx, "unknown")
+	assert.Equal(t, returns.ErrOrderNotFound, err)
+
+	_, err = f.promotionService.Create(ctx, promotion.PromotionRequest{Name: "Third off", Type: promotion.TypePercentage, Value: 33.34, Active: true})
+	require.NoError(t, err)
+	order, err := f.service.Place(ctx, "user-1", orderRequest(checkout.ItemRequest{ProductID: f.mug.ID, Quantity: 3}))
+	require.NoError(t, err)
+
+	found, err := reader.GetOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", found.UserID)
+	assert.Nil(t, found.DeliveredAt)
+	require.Len(t, found.Lines, 1)
+	// Lines are worth what was paid for them, not the catalog price
+	assert.Equal(t, order.Lines[0].ID, found.Lines[0].ID)
+	assert.Equal(t, 6.67, found.Lines[0].UnitPrice)
+}
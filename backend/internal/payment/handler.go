@@ -0,0 +1,215 @@
+package payment
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+	"go.uber.org/zap"
+)
+
+// maxWebhookBodySize limits the size of webhook payloads
+const maxWebhookBodySize = 1 << 20
+
+// Handler handles HTTP requests for payment operations
+type Handler struct {
+	service   Service
+	validator *validator.Validate
+	logger    *zap.Logger
+}
+
+// NewHandler creates a new payment handler
+func NewHandler(service Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service:   service,
+		validator: validator.New(),
+		logger:    logger,
+	}
+}
+
+// Create handles authorizing a payment for an order
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req CreatePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	payment, err := h.service.Authorize(r.Context(), userID, req)
+	if err != nil {
+		if err == ErrPaymentDeclined {
+			response.WriteError(w, http.StatusPaymentRequired, "PAYMENT_DECLINED", "Payment declined: "+payment.DeclineCode, "")
+			return
+		}
+		h.logger.Error("Failed to authorize payment", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, payment)
+}
+
+// GetByID handles getting a payment by ID; users may only view their own payments
+func (h *Handler) GetByID(w http.ResponseWriter, r *http.Request) {
+	payment, ok := h.loadOwnedPayment(w, r)
+	if !ok {
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, payment)
+}
+
+// Capture handles capturing an authorized payment
+func (h *Handler) Capture(w http.ResponseWriter, r *http.Request) {
+	payment, err := h.service.Capture(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to capture payment")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, payment)
+}
+
+// Void handles voiding an authorized payment
+func (h *Handler) Void(w http.ResponseWriter, r *http.Request) {
+	payment, err := h.service.Void(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to void payment")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, payment)
+}
+
+// Refund handles refunding a captured payment
+func (h *Handler) Refund(w http.ResponseWriter, r *http.Request) {
+	var req RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	payment, err := h.service.Refund(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to refund payment")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, payment)
+}
+
+// CompleteChallenge handles a simulated 3-D Secure challenge outcome for the customer's payment
+func (h *Handler) CompleteChallenge(w http.ResponseWriter, r *http.Request) {
+	payment, ok := h.loadOwnedPayment(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	payment, err := h.service.SimulateChallenge(r.Context(), payment.ID, req.Approve)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to complete challenge")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusAccepted, payment)
+}
+
+// Webhook handles provider webhook deliveries
+func (h *Handler) Webhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	if err := h.service.HandleWebhook(r.Context(), payload, r.Header.Get("X-Payment-Signature")); err != nil {
+		h.writeServiceError(w, err, "Failed to handle webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadOwnedPayment fetches the payment in the URL and checks the caller owns it or may read
+// every payment
+func (h *Handler) loadOwnedPayment(w http.ResponseWriter, r *http.Request) (*Payment, bool) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return nil, false
+	}
+	payment, err := h.service.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get payment")
+		return nil, false
+	}
+
+	// Hide other users' payments behind a 404 rather than revealing they exist
+	if payment.UserID != userID && !authz.HasPermission(r.Context(), authz.PaymentRead) {
+		response.WriteError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "Payment not found", "")
+		return nil, false
+	}
+
+	return payment, true
+}
+
+// writeServiceError maps service errors to HTTP responses
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error, logMessage string) {
+	switch err {
+	case ErrPaymentNotFound:
+		response.WriteError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "Payment not found", "")
+	case ErrInvalidPaymentState:
+		response.WriteError(w, http.StatusConflict, "INVALID_PAYMENT_STATE", "Operation not allowed in current payment state", "")
+	case ErrInvalidRefundAmount:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REFUND_AMOUNT", "Refund amount exceeds refundable amount", "")
+	case ErrInvalidWebhookSignature:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_SIGNATURE", "Invalid webhook signature", "")
+	case ErrChallengeNotSupported:
+		response.WriteError(w, http.StatusBadRequest, "CHALLENGE_NOT_SUPPORTED", "Payment provider does not support challenge simulation", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
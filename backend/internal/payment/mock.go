@@ -0,0 +1,326 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payment methods understood by the mock provider
+const (
+	MockMethodSuccess           = "mock_card_success"
+	MockMethodDeclined          = "mock_card_declined"
+	MockMethodInsufficientFunds = "mock_card_insufficient_funds"
+	MockMethodChallenge         = "mock_card_3ds"
+	MockMethodDelayedCapture    = "mock_card_delayed_capture"
+)
+
+// MockProviderName is the provider identifier of the mock gateway
+const MockProviderName = "mock"
+
+// webhookTolerance is the maximum age of a webhook signature timestamp
+const webhookTolerance = 5 * time.Minute
+
+// WebhookSink receives webhook deliveries from the mock provider
+type WebhookSink func(payload []byte, signature string)
+
+// mockCharge is the provider-side state of a payment
+type mockCharge struct {
+	reference   string
+	method      string
+	amount      float64
+	captured    float64
+	refunded    float64
+	status      Status
+	challengeID string
+	// refundKeys holds the idempotency keys of the refunds made
+	refundKeys map[string]bool
+}
+
+// MockProvider is a fully local payment gateway that simulates successes, declines,
+// 3-D Secure challenges and delayed webhook delivery for development and tests
+type MockProvider struct {
+	secret       []byte
+	webhookDelay time.Duration
+	sink         WebhookSink
+	charges      map[string]*mockCharge
+	challenges   map[string]string
+	mutex        sync.Mutex
+}
+
+// NewMockProvider creates a mock provider that signs webhooks with webhookSecret
+// and delivers them after webhookDelay
+func NewMockProvider(webhookSecret string, webhookDelay time.Duration) *MockProvider {
+	return &MockProvider{
+		secret:       []byte(webhookSecret),
+		webhookDelay: webhookDelay,
+		charges:      make(map[string]*mockCharge),
+		challenges:   make(map[string]string),
+	}
+}
+
+// SetWebhookSink sets the destination for webhook deliveries
+func (p *MockProvider) SetWebhookSink(sink WebhookSink) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.sink = sink
+}
+
+// Name returns the provider identifier
+func (p *MockProvider) Name() string {
+	return MockProviderName
+}
+
+// Authorize simulates an authorization based on the payment method
+func (p *MockProvider) Authorize(ctx context.Context, req AuthorizeRequest) (*ProviderResult, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	charge := &mockCharge{
+		reference: "mock_" + uuid.New().String(),
+		method:    req.PaymentMethod,
+		amount:    req.Amount,
+	}
+	result := &ProviderResult{Reference: charge.reference}
+
+	switch req.PaymentMethod {
+	case MockMethodSuccess, MockMethodDelayedCapture:
+		charge.status = StatusAuthorized
+	case MockMethodChallenge:
+		charge.status = StatusRequiresAction
+		charge.challengeID = "chl_" + uuid.New().String()
+		p.challenges[charge.challengeID] = charge.reference
+		result.NextAction = &NextAction{Type: "three_d_secure", ChallengeID: charge.challengeID}
+	case MockMethodDeclined:
+		charge.status = StatusFailed
+		result.DeclineCode = "card_declined"
+	case MockMethodInsufficientFunds:
+		charge.status = StatusFailed
+		result.DeclineCode = "insufficient_funds"
+	default:
+		charge.status = StatusFailed
+		result.DeclineCode = "invalid_payment_method"
+	}
+
+	p.charges[charge.reference] = charge
+	result.Status = charge.status
+	return result, nil
+}
+
+// Capture simulates capturing authorized funds. Delayed-capture methods are confirmed by webhook only.
+func (p *MockProvider) Capture(ctx context.Context, reference string, amount float64) (*ProviderResult, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	charge, err := p.findCharge(reference)
+	if err != nil {
+		return nil, err
+	}
+	if charge.status != StatusAuthorized {
+		return nil, fmt.Errorf("cannot capture charge in status %s", charge.status)
+	}
+	if amount <= 0 || amount > charge.amount {
+		return nil, errors.New("capture amount must be positive and not exceed the authorized amount")
+	}
+
+	if charge.method == MockMethodDelayedCapture {
+		charge.status = StatusCapturePending
+		p.scheduleWebhook(EventCaptured, charge.reference, amount, "", func() {
+			charge.status = StatusCaptured
+			charge.captured = amount
+		})
+		return &ProviderResult{Reference: reference, Status: StatusCapturePending}, nil
+	}
+
+	charge.status = StatusCaptured
+	charge.captured = amount
+	p.scheduleWebhook(EventCaptured, charge.reference, amount, "", nil)
+	return &ProviderResult{Reference: reference, Status: StatusCaptured}, nil
+}
+
+// Void simulates releasing an authorization
+func (p *MockProvider) Void(ctx context.Context, reference string) (*ProviderResult, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	charge, err := p.findCharge(reference)
+	if err != nil {
+		return nil, err
+	}
+	if charge.status != StatusAuthorized && charge.status != StatusRequiresAction {
+		return nil, fmt.Errorf("cannot void charge in status %s", charge.status)
+	}
+
+	charge.status = StatusVoided
+	p.scheduleWebhook(EventVoided, charge.reference, charge.amount, "", nil)
+	return &ProviderResult{Reference: reference, Status: StatusVoided}, nil
+}
+
+// Refund simulates returning captured funds
+func (p *MockProvider) Refund(ctx context.Context, reference string, amount float64, idempotencyKey string) (*ProviderResult, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	charge, err := p.findCharge(reference)
+	if err != nil {
+		return nil, err
+	}
+	if idempotencyKey != "" && charge.refundKeys[idempotencyKey] {
+		return &ProviderResult{Reference: reference, Status: charge.status}, nil
+	}
+	if charge.status != StatusCaptured && charge.status != StatusPartiallyRefunded {
+		return nil, fmt.Errorf("cannot refund charge in status %s", charge.status)
+	}
+	if amount <= 0 || amount > charge.captured-charge.refunded {
+		return nil, errors.New("refund amount must be positive and not exceed the captured amount")
+	}
+
+	charge.refunded += amount
+	if idempotencyKey != "" {
+		if charge.refundKeys == nil {
+			charge.refundKeys = make(map[string]bool)
+		}
+		charge.refundKeys[idempotencyKey] = true
+	}
+	charge.status = StatusPartiallyRefunded
+	if charge.refunded >= charge.captured {
+		charge.status = StatusRefunded
+	}
+	p.scheduleWebhook(EventRefunded, charge.reference, amount, "", nil)
+	return &ProviderResult{Reference: reference, Status: charge.status}, nil
+}
+
+// CompleteChallenge simulates the customer passing or failing a 3-D Secure challenge.
+// The outcome is reported asynchronously through a webhook.
+func (p *MockProvider) CompleteChallenge(ctx context.Context, challengeID string, approve bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	reference, exists := p.challenges[challengeID]
+	if !exists {
+		return ErrPaymentNotFound
+	}
+	charge := p.charges[reference]
+	if charge.status != StatusRequiresAction {
+		return fmt.Errorf("cannot complete challenge for charge in status %s", charge.status)
+	}
+	delete(p.challenges, challengeID)
+
+	if approve {
+		charge.status = StatusAuthorized
+		p.scheduleWebhook(EventAuthorized, charge.reference, charge.amount, "", nil)
+		return nil
+	}
+
+	charge.status = StatusFailed
+	p.scheduleWebhook(EventFailed, charge.reference, charge.amount, "authentication_failed", nil)
+	return nil
+}
+
+// VerifyWebhook verifies a signature of the form "t=<unix>,v1=<hex hmac-sha256>"
+func (p *MockProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	var timestamp, mac string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			mac = value
+		}
+	}
+	if timestamp == "" || mac == "" {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidWebhookSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	expected := p.sign(timestamp, payload)
+	if !hmac.Equal([]byte(expected), []byte(mac)) {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, ErrInvalidWebhookSignature
+	}
+	return &event, nil
+}
+
+// SignWebhook produces a signature header value for payload, for use by local tooling and tests
+func (p *MockProvider) SignWebhook(payload []byte) string {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, p.sign(timestamp, payload))
+}
+
+func (p *MockProvider) sign(timestamp string, payload []byte) string {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p *MockProvider) findCharge(reference string) (*mockCharge, error) {
+	charge, exists := p.charges[reference]
+	if !exists {
+		return nil, ErrPaymentNotFound
+	}
+	return charge, nil
+}
+
+// scheduleWebhook delivers an event to the sink after the configured delay.
+// settle, if set, is applied to the provider state right before delivery.
+// Must be called with p.mutex held.
+func (p *MockProvider) scheduleWebhook(eventType, reference string, amount float64, declineCode string, settle func()) {
+	event := WebhookEvent{
+		ID:          "evt_" + uuid.New().String(),
+		Type:        eventType,
+		ProviderRef: reference,
+		Amount:      amount,
+		DeclineCode: declineCode,
+	}
+
+	time.AfterFunc(p.webhookDelay, func() {
+		p.mutex.Lock()
+		if settle != nil {
+			settle()
+		}
+		sink := p.sink
+		p.mutex.Unlock()
+
+		if sink == nil {
+			return
+		}
+
+		event.CreatedAt = time.Now()
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		sink(payload, p.SignWebhook(payload))
+	})
+}
@@ -0,0 +1,125 @@
+package payment
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPaymentNotFound is returned when a payment is not found
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentDeclined is returned when the provider declines a payment
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrInvalidPaymentState is returned when an operation is not allowed in the payment's current status
+	ErrInvalidPaymentState = errors.New("operation not allowed in current payment state")
+	// ErrInvalidRefundAmount is returned when a refund exceeds the captured amount
+	ErrInvalidRefundAmount = errors.New("refund amount exceeds refundable amount")
+	// ErrInvalidWebhookSignature is returned when a webhook signature cannot be verified
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	// ErrChallengeNotSupported is returned when the provider cannot simulate authentication challenges
+	ErrChallengeNotSupported = errors.New("provider does not support challenge simulation")
+)
+
+// Status represents the lifecycle state of a payment
+type Status string
+
+const (
+	// StatusRequiresAction means the customer must complete an authentication challenge (3-D Secure)
+	StatusRequiresAction Status = "requires_action"
+	// StatusAuthorized means funds are held but not yet captured
+	StatusAuthorized Status = "authorized"
+	// StatusCapturePending means a capture was requested and awaits provider confirmation
+	StatusCapturePending Status = "capture_pending"
+	// StatusCaptured means funds were captured and verified
+	StatusCaptured Status = "captured"
+	// StatusVoided means the authorization was released without capture
+	StatusVoided Status = "voided"
+	// StatusPartiallyRefunded means part of the captured amount was refunded
+	StatusPartiallyRefunded Status = "partially_refunded"
+	// StatusRefunded means the full captured amount was refunded
+	StatusRefunded Status = "refunded"
+	// StatusFailed means the payment was declined or the challenge failed
+	StatusFailed Status = "failed"
+)
+
+// Webhook event types emitted by providers
+const (
+	EventAuthorized = "payment.authorized"
+	EventCaptured   = "payment.captured"
+	EventFailed     = "payment.failed"
+	EventVoided     = "payment.voided"
+	EventRefunded   = "payment.refunded"
+)
+
+// Payment represents a payment attempt for an order
+type Payment struct {
+	ID             string      `json:"id"`
+	OrderID        string      `json:"order_id"`
+	UserID         string      `json:"user_id"`
+	Provider       string      `json:"provider"`
+	ProviderRef    string      `json:"provider_ref"`
+	Amount         float64     `json:"amount"`
+	Currency       string      `json:"currency"`
+	Status         Status      `json:"status"`
+	RefundedAmount float64     `json:"refunded_amount"`
+	Refunds        []Refund    `json:"refunds,omitempty"`
+	DeclineCode    string      `json:"decline_code,omitempty"`
+	NextAction     *NextAction `json:"next_action,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// Refund represents captured funds returned to the customer
+type Refund struct {
+	Amount         float64   `json:"amount"`
+	IdempotencyKey string    `json:"idempotency_key,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// FindRefund returns the refund made with idempotencyKey, or nil if there is none
+func (p *Payment) FindRefund(idempotencyKey string) *Refund {
+	for i := range p.Refunds {
+		if p.Refunds[i].IdempotencyKey == idempotencyKey {
+			return &p.Refunds[i]
+		}
+	}
+	return nil
+}
+
+// NextAction describes what the customer must do to continue a payment
+type NextAction struct {
+	Type        string `json:"type"`
+	ChallengeID string `json:"challenge_id"`
+}
+
+// CreatePaymentRequest represents a request to authorize a payment for an order
+type CreatePaymentRequest struct {
+	OrderID       string  `json:"order_id" validate:"required"`
+	Amount        float64 `json:"amount" validate:"required,gt=0"`
+	Currency      string  `json:"currency" validate:"required,len=3"`
+	PaymentMethod string  `json:"payment_method" validate:"required"`
+}
+
+// RefundRequest represents a refund request; a zero amount refunds the remaining balance.
+// Repeating a refund with the same IdempotencyKey returns the payment as refunded the
+// first time instead of refunding again; callers such as returns set it so that a refund
+// can be retried safely.
+type RefundRequest struct {
+	Amount         float64 `json:"amount" validate:"gte=0"`
+	IdempotencyKey string  `json:"-"`
+}
+
+// ChallengeRequest represents the outcome of a simulated authentication challenge
+type ChallengeRequest struct {
+	Approve bool `json:"approve"`
+}
+
+// WebhookEvent represents a verified event delivered by a provider
+type WebhookEvent struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	ProviderRef string    `json:"provider_ref"`
+	Amount      float64   `json:"amount"`
+	DeclineCode string    `json:"decline_code,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
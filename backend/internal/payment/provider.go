@@ -0,0 +1,49 @@
+package payment
+
+import "context"
+
+// Provider defines the operations a payment processor must support
+type Provider interface {
+	// Name returns the provider identifier stored on payments
+	Name() string
+	// Authorize places a hold on the customer's funds
+	Authorize(ctx context.Context, req AuthorizeRequest) (*ProviderResult, error)
+	// Capture collects previously authorized funds
+	Capture(ctx context.Context, reference string, amount float64) (*ProviderResult, error)
+	// Void releases an authorization that has not been captured
+	Void(ctx context.Context, reference string) (*ProviderResult, error)
+	// Refund returns captured funds to the customer. Repeating a refund with the same
+	// non-empty idempotencyKey does not refund again.
+	Refund(ctx context.Context, reference string, amount float64, idempotencyKey string) (*ProviderResult, error)
+	// VerifyWebhook checks the signature of a webhook payload and decodes the event
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+// ChallengeSimulator is implemented by providers that can simulate the customer
+// completing an authentication challenge (e.g. 3-D Secure) without a real issuer
+type ChallengeSimulator interface {
+	CompleteChallenge(ctx context.Context, challengeID string, approve bool) error
+}
+
+// AuthorizeRequest contains the data sent to a provider to authorize a payment
+type AuthorizeRequest struct {
+	PaymentID     string
+	Amount        float64
+	Currency      string
+	PaymentMethod string
+}
+
+// ProviderResult is the provider's response to an operation
+type ProviderResult struct {
+	Reference   string
+	Status      Status
+	DeclineCode string
+	NextAction  *NextAction
+}
+
+// OrderNotifier is implemented by the order domain to learn when an order has been paid.
+// MarkPaid is only called once a capture has been verified with the provider, with the
+// captured payment so the order domain can check who paid and how much.
+type OrderNotifier interface {
+	MarkPaid(ctx context.Context, payment Payment) error
+}
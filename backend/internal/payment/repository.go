@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository defines the interface for payment data access
+type Repository interface {
+	Create(ctx context.Context, payment *Payment) error
+	FindByID(ctx context.Context, id string) (*Payment, error)
+	FindByProviderRef(ctx context.Context, providerRef string) (*Payment, error)
+	Update(ctx context.Context, payment *Payment) error
+	ListByUser(ctx context.Context, userID string) ([]*Payment, error)
+}
+
+// InMemoryRepository implements Repository using in-memory storage
+type InMemoryRepository struct {
+	payments      map[string]*Payment
+	paymentsByRef map[string]*Payment
+	mutex         sync.RWMutex
+}
+
+// NewInMemoryRepository creates a new in-memory repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		payments:      make(map[string]*Payment),
+		paymentsByRef: make(map[string]*Payment),
+	}
+}
+
+// Create creates a new payment
+func (r *InMemoryRepository) Create(ctx context.Context, payment *Payment) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.payments[payment.ID] = payment
+	if payment.ProviderRef != "" {
+		r.paymentsByRef[payment.ProviderRef] = payment
+	}
+	return nil
+}
+
+// FindByID finds a payment by ID
+func (r *InMemoryRepository) FindByID(ctx context.Context, id string) (*Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	payment, exists := r.payments[id]
+	if !exists {
+		return nil, ErrPaymentNotFound
+	}
+
+	return payment, nil
+}
+
+// FindByProviderRef finds a payment by the provider's reference
+func (r *InMemoryRepository) FindByProviderRef(ctx context.Context, providerRef string) (*Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	payment, exists := r.paymentsByRef[providerRef]
+	if !exists {
+		return nil, ErrPaymentNotFound
+	}
+
+	return payment, nil
+}
+
+// Update updates a payment
+func (r *InMemoryRepository) Update(ctx context.Context, payment *Payment) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.payments[payment.ID]; !exists {
+		return ErrPaymentNotFound
+	}
+
+	r.payments[payment.ID] = payment
+	if payment.ProviderRef != "" {
+		r.paymentsByRef[payment.ProviderRef] = payment
+	}
+	return nil
+}
+
+// ListByUser lists the payments of a user, oldest first
+func (r *InMemoryRepository) ListByUser(ctx context.Context, userID string) ([]*Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	payments := make([]*Payment, 0)
+	for _, payment := range r.payments {
+		if payment.UserID == userID {
+			payments = append(payments, payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+	})
+
+	return payments, nil
+}
@@ -0,0 +1,374 @@
+package payment
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Service defines the interface for payment business logic
+type Service interface {
+	Authorize(ctx context.Context, userID string, req CreatePaymentRequest) (*Payment, error)
+	GetByID(ctx context.Context, id string) (*Payment, error)
+	Capture(ctx context.Context, id string) (*Payment, error)
+	Void(ctx context.Context, id string) (*Payment, error)
+	Refund(ctx context.Context, id string, req RefundRequest) (*Payment, error)
+	HandleWebhook(ctx context.Context, payload []byte, signature string) error
+	SimulateChallenge(ctx context.Context, id string, approve bool) (*Payment, error)
+	ExportPersonalData(ctx context.Context, userID string) (interface{}, error)
+	ErasePersonalData(ctx context.Context, userID string) error
+}
+
+// service implements Service
+type service struct {
+	repo     Repository
+	provider Provider
+	notifier OrderNotifier
+	logger   *zap.Logger
+	// mutex serializes state transitions so webhooks and API calls cannot race
+	mutex sync.Mutex
+}
+
+// NewService creates a new payment service. notifier may be nil until an order domain is wired in.
+func NewService(repo Repository, provider Provider, notifier OrderNotifier, logger *zap.Logger) Service {
+	return &service{
+		repo:     repo,
+		provider: provider,
+		notifier: notifier,
+		logger:   logger,
+	}
+}
+
+// Authorize creates a payment for an order and authorizes it with the provider
+func (s *service) Authorize(ctx context.Context, userID string, req CreatePaymentRequest) (*Payment, error) {
+	s.logger.Info("Authorizing payment", zap.String("order_id", req.OrderID), zap.String("user_id", userID))
+
+	now := time.Now()
+	payment := &Payment{
+		ID:        uuid.New().String(),
+		OrderID:   req.OrderID,
+		UserID:    userID,
+		Provider:  s.provider.Name(),
+		Amount:    req.Amount,
+		Currency:  strings.ToUpper(req.Currency),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	result, err := s.provider.Authorize(ctx, AuthorizeRequest{
+		PaymentID:     payment.ID,
+		Amount:        payment.Amount,
+		Currency:      payment.Currency,
+		PaymentMethod: req.PaymentMethod,
+	})
+	if err != nil {
+		s.logger.Error("Provider authorization failed", zap.String("payment_id", payment.ID), zap.Error(err))
+		return nil, err
+	}
+
+	payment.ProviderRef = result.Reference
+	payment.Status = result.Status
+	payment.DeclineCode = result.DeclineCode
+	payment.NextAction = result.NextAction
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.repo.Create(ctx, payment); err != nil {
+		s.logger.Error("Failed to create payment", zap.Error(err))
+		return nil, err
+	}
+
+	if payment.Status == StatusFailed {
+		s.logger.Warn("Payment declined",
+			zap.String("payment_id", payment.ID),
+			zap.String("decline_code", payment.DeclineCode),
+		)
+		return payment, ErrPaymentDeclined
+	}
+
+	s.logger.Info("Payment authorized", zap.String("payment_id", payment.ID), zap.String("status", string(payment.Status)))
+	return payment, nil
+}
+
+// GetByID retrieves a payment by ID
+func (s *service) GetByID(ctx context.Context, id string) (*Payment, error) {
+	s.logger.Debug("Getting payment", zap.String("payment_id", id))
+
+	payment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get payment", zap.String("payment_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Capture captures an authorized payment. The order is only marked paid once the
+// provider confirms the capture, either synchronously or through a verified webhook.
+func (s *service) Capture(ctx context.Context, id string) (*Payment, error) {
+	s.logger.Info("Capturing payment", zap.String("payment_id", id))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	payment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != StatusAuthorized {
+		return nil, ErrInvalidPaymentState
+	}
+
+	result, err := s.provider.Capture(ctx, payment.ProviderRef, payment.Amount)
+	if err != nil {
+		s.logger.Error("Provider capture failed", zap.String("payment_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	payment.Status = result.Status
+	payment.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, payment); err != nil {
+		s.logger.Error("Failed to update payment", zap.Error(err))
+		return nil, err
+	}
+
+	if payment.Status == StatusCaptured {
+		s.markOrderPaid(ctx, payment)
+	}
+
+	s.logger.Info("Payment capture requested", zap.String("payment_id", id), zap.String("status", string(payment.Status)))
+	return payment, nil
+}
+
+// Void releases an authorization that has not been captured
+func (s *service) Void(ctx context.Context, id string) (*Payment, error) {
+	s.logger.Info("Voiding payment", zap.String("payment_id", id))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	payment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != StatusAuthorized && payment.Status != StatusRequiresAction {
+		return nil, ErrInvalidPaymentState
+	}
+
+	result, err := s.provider.Void(ctx, payment.ProviderRef)
+	if err != nil {
+		s.logger.Error("Provider void failed", zap.String("payment_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	payment.Status = result.Status
+	payment.NextAction = nil
+	payment.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, payment); err != nil {
+		s.logger.Error("Failed to update payment", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Payment voided", zap.String("payment_id", id))
+	return payment, nil
+}
+
+// Refund returns captured funds. A zero amount refunds the remaining balance. The
+// idempotency key is passed on to the provider, so a refund that was made but not saved
+// is not made twice when it is retried.
+func (s *service) Refund(ctx context.Context, id string, req RefundRequest) (*Payment, error) {
+	s.logger.Info("Refunding payment", zap.String("payment_id", id), zap.Float64("amount", req.Amount))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	payment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.IdempotencyKey != "" && payment.FindRefund(req.IdempotencyKey) != nil {
+		s.logger.Info("Refund already made", zap.String("payment_id", id), zap.String("idempotency_key", req.IdempotencyKey))
+		return payment, nil
+	}
+	if payment.Status != StatusCaptured && payment.Status != StatusPartiallyRefunded {
+		return nil, ErrInvalidPaymentState
+	}
+
+	refundable := payment.Amount - payment.RefundedAmount
+	amount := req.Amount
+	if amount == 0 {
+		amount = refundable
+	}
+	if amount <= 0 || amount > refundable {
+		return nil, ErrInvalidRefundAmount
+	}
+
+	result, err := s.provider.Refund(ctx, payment.ProviderRef, amount, req.IdempotencyKey)
+	if err != nil {
+		s.logger.Error("Provider refund failed", zap.String("payment_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	now := time.Now()
+	payment.RefundedAmount += amount
+	payment.Refunds = append(payment.Refunds, Refund{Amount: amount, IdempotencyKey: req.IdempotencyKey, CreatedAt: now})
+	payment.Status = result.Status
+	payment.UpdatedAt = now
+	if err := s.repo.Update(ctx, payment); err != nil {
+		s.logger.Error("Failed to update payment", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Payment refunded", zap.String("payment_id", id), zap.Float64("amount", amount))
+	return payment, nil
+}
+
+// HandleWebhook verifies and applies a provider webhook. Events are applied idempotently
+// based on the payment's current status, so redelivered events are harmless.
+func (s *service) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	event, err := s.provider.VerifyWebhook(payload, signature)
+	if err != nil {
+		s.logger.Warn("Rejected webhook with invalid signature", zap.Error(err))
+		return ErrInvalidWebhookSignature
+	}
+
+	s.logger.Info("Processing payment webhook",
+		zap.String("event_id", event.ID),
+		zap.String("event_type", event.Type),
+		zap.String("provider_ref", event.ProviderRef),
+	)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	payment, err := s.repo.FindByProviderRef(ctx, event.ProviderRef)
+	if err != nil {
+		s.logger.Warn("Webhook for unknown payment", zap.String("provider_ref", event.ProviderRef))
+		return err
+	}
+
+	changed := false
+	switch event.Type {
+	case EventAuthorized:
+		if payment.Status == StatusRequiresAction {
+			payment.Status = StatusAuthorized
+			payment.NextAction = nil
+			changed = true
+		}
+	case EventFailed:
+		if payment.Status == StatusRequiresAction || payment.Status == StatusAuthorized {
+			payment.Status = StatusFailed
+			payment.DeclineCode = event.DeclineCode
+			payment.NextAction = nil
+			changed = true
+		}
+	case EventCaptured:
+		if payment.Status == StatusAuthorized || payment.Status == StatusCapturePending {
+			payment.Status = StatusCaptured
+			changed = true
+		}
+	case EventVoided, EventRefunded:
+		// Voids and refunds are applied synchronously; the webhook is a confirmation only
+	default:
+		s.logger.Debug("Ignoring unhandled webhook event", zap.String("event_type", event.Type))
+	}
+
+	if !changed {
+		return nil
+	}
+
+	payment.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, payment); err != nil {
+		s.logger.Error("Failed to update payment", zap.Error(err))
+		return err
+	}
+
+	if payment.Status == StatusCaptured {
+		s.markOrderPaid(ctx, payment)
+	}
+
+	return nil
+}
+
+// SimulateChallenge completes a pending authentication challenge on providers that support simulation
+func (s *service) SimulateChallenge(ctx context.Context, id string, approve bool) (*Payment, error) {
+	simulator, ok := s.provider.(ChallengeSimulator)
+	if !ok {
+		return nil, ErrChallengeNotSupported
+	}
+
+	payment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != StatusRequiresAction || payment.NextAction == nil {
+		return nil, ErrInvalidPaymentState
+	}
+
+	if err := simulator.CompleteChallenge(ctx, payment.NextAction.ChallengeID, approve); err != nil {
+		s.logger.Error("Failed to complete challenge", zap.String("payment_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Challenge completed, awaiting webhook", zap.String("payment_id", id), zap.Bool("approved", approve))
+	return payment, nil
+}
+
+// ExportPersonalData returns the payments of a user
+func (s *service) ExportPersonalData(ctx context.Context, userID string) (interface{}, error) {
+	payments, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list payments", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return payments, nil
+}
+
+// ErasePersonalData unlinks the payments of a user from them. The payments themselves are
+// kept, as accounting records must be, but no longer name the user.
+func (s *service) ErasePersonalData(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	payments, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list payments", zap.String("user_id", userID), zap.Error(err))
+		return err
+	}
+
+	now := time.Now()
+	for _, payment := range payments {
+		payment.UserID = ""
+		payment.UpdatedAt = now
+		if err := s.repo.Update(ctx, payment); err != nil {
+			s.logger.Error("Failed to anonymize payment", zap.String("payment_id", payment.ID), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// markOrderPaid notifies the order domain that a payment has been captured.
+// Must be called with s.mutex held after the captured status has been persisted.
+func (s *service) markOrderPaid(ctx context.Context, payment *Payment) {
+	if s.notifier == nil {
+		s.logger.Warn("No order domain to mark paid", zap.String("order_id", payment.OrderID), zap.String("payment_id", payment.ID))
+		return
+	}
+
+	if err := s.notifier.MarkPaid(ctx, *payment); err != nil {
+		s.logger.Error("Failed to mark order as paid",
+			zap.String("order_id", payment.OrderID),
+			zap.String("payment_id", payment.ID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	s.logger.Info("Order marked as paid", zap.String("order_id", payment.OrderID), zap.String("payment_id", payment.ID))
+}
@@ -0,0 +1,293 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingNotifier records the orders marked as paid
+type recordingNotifier struct {
+	mutex sync.Mutex
+	paid  []string
+}
+
+func (n *recordingNotifier) MarkPaid(ctx context.Context, payment Payment) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.paid = append(n.paid, payment.OrderID)
+	return nil
+}
+
+func (n *recordingNotifier) paidOrders() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return append([]string(nil), n.paid...)
+}
+
+func setupTestService() (Service, *MockProvider, *recordingNotifier) {
+	repo := NewInMemoryRepository()
+	provider := NewMockProvider("test-webhook-secret", 0)
+	notifier := &recordingNotifier{}
+	logger, _ := zap.NewDevelopment()
+	service := NewService(repo, provider, notifier, logger)
+	provider.SetWebhookSink(func(payload []byte, signature string) {
+		service.HandleWebhook(context.Background(), payload, signature)
+	})
+	return service, provider, notifier
+}
+
+func paymentRequest(method string) CreatePaymentRequest {
+	return CreatePaymentRequest{
+		OrderID:       "order-1",
+		Amount:        49.99,
+		Currency:      "usd",
+		PaymentMethod: method,
+	}
+}
+
+func TestService_Authorize(t *testing.T) {
+	tests := []struct {
+		name            string
+		method          string
+		wantErr         error
+		wantStatus      Status
+		wantDeclineCode string
+	}{
+		{
+			name:       "successful authorization",
+			method:     MockMethodSuccess,
+			wantStatus: StatusAuthorized,
+		},
+		{
+			name:            "card declined",
+			method:          MockMethodDeclined,
+			wantErr:         ErrPaymentDeclined,
+			wantStatus:      StatusFailed,
+			wantDeclineCode: "card_declined",
+		},
+		{
+			name:            "insufficient funds",
+			method:          MockMethodInsufficientFunds,
+			wantErr:         ErrPaymentDeclined,
+			wantStatus:      StatusFailed,
+			wantDeclineCode: "insufficient_funds",
+		},
+		{
+			name:       "3-D Secure challenge",
+			method:     MockMethodChallenge,
+			wantStatus: StatusRequiresAction,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := setupTestService()
+
+			payment, err := service.Authorize(context.Background(), "user-1", paymentRequest(tt.method))
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NotNil(t, payment)
+			assert.Equal(t, tt.wantStatus, payment.Status)
+			assert.Equal(t, tt.wantDeclineCode, payment.DeclineCode)
+			assert.Equal(t, "USD", payment.Currency)
+			assert.Equal(t, MockProviderName, payment.Provider)
+			if tt.wantStatus == StatusRequiresAction {
+				require.NotNil(t, payment.NextAction)
+				assert.NotEmpty(t, payment.NextAction.ChallengeID)
+			}
+		})
+	}
+}
+
+func TestService_CaptureMarksOrderPaid(t *testing.T) {
+	service, _, notifier := setupTestService()
+	ctx := context.Background()
+
+	payment, err := service.Authorize(ctx, "user-1", paymentRequest(MockMethodSuccess))
+	require.NoError(t, err)
+	assert.Empty(t, notifier.paidOrders())
+
+	captured, err := service.Capture(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCaptured, captured.Status)
+	assert.Equal(t, []string{"order-1"}, notifier.paidOrders())
+
+	// The confirming webhook must not mark the order paid a second time
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"order-1"}, notifier.paidOrders())
+
+	_, err = service.Capture(ctx, payment.ID)
+	assert.Equal(t, ErrInvalidPaymentState, err)
+}
+
+func TestService_DelayedCaptureWaitsForWebhook(t *testing.T) {
+	repo := NewInMemoryRepository()
+	provider := NewMockProvider("test-webhook-secret", 0)
+	notifier := &recordingNotifier{}
+	logger, _ := zap.NewDevelopment()
+	service := NewService(repo, provider, notifier, logger)
+
+	// Hold webhooks until the test releases them
+	deliveries := make(chan func(), 4)
+	provider.SetWebhookSink(func(payload []byte, signature string) {
+		deliveries <- func() { service.HandleWebhook(context.Background(), payload, signature) }
+	})
+
+	ctx := context.Background()
+	payment, err := service.Authorize(ctx, "user-1", paymentRequest(MockMethodDelayedCapture))
+	require.NoError(t, err)
+
+	pending, err := service.Capture(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCapturePending, pending.Status)
+	assert.Empty(t, notifier.paidOrders())
+
+	deliver := <-deliveries
+	deliver()
+
+	stored, err := service.GetByID(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCaptured, stored.Status)
+	assert.Equal(t, []string{"order-1"}, notifier.paidOrders())
+}
+
+func TestService_Challenge(t *testing.T) {
+	tests := []struct {
+		name       string
+		approve    bool
+		wantStatus Status
+	}{
+		{name: "approved challenge authorizes payment", approve: true, wantStatus: StatusAuthorized},
+		{name: "failed challenge fails payment", approve: false, wantStatus: StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _, _ := setupTestService()
+			ctx := context.Background()
+
+			payment, err := service.Authorize(ctx, "user-1", paymentRequest(MockMethodChallenge))
+			require.NoError(t, err)
+
+			_, err = service.Capture(ctx, payment.ID)
+			assert.Equal(t, ErrInvalidPaymentState, err)
+
+			_, err = service.SimulateChallenge(ctx, payment.ID, tt.approve)
+			require.NoError(t, err)
+
+			assert.Eventually(t, func() bool {
+				stored, err := service.GetByID(ctx, payment.ID)
+				return err == nil && stored.Status == tt.wantStatus
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestService_VoidAndRefund(t *testing.T) {
+	service, _, _ := setupTestService()
+	ctx := context.Background()
+
+	// Void an authorization
+	payment, err := service.Authorize(ctx, "user-1", paymentRequest(MockMethodSuccess))
+	require.NoError(t, err)
+	voided, err := service.Void(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusVoided, voided.Status)
+
+	_, err = service.Refund(ctx, payment.ID, RefundRequest{})
+	assert.Equal(t, ErrInvalidPaymentState, err)
+
+	// Partial then full refund of a captured payment
+	payment, err = service.Authorize(ctx, "user-1", paymentRequest(MockMethodSuccess))
+	require.NoError(t, err)
+	_, err = service.Capture(ctx, payment.ID)
+	require.NoError(t, err)
+
+	refunded, err := service.Refund(ctx, payment.ID, RefundRequest{Amount: 10, IdempotencyKey: "return:1"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPartiallyRefunded, refunded.Status)
+	assert.InDelta(t, 10, refunded.RefundedAmount, 0.001)
+
+	// Repeating a refund with its idempotency key does not refund again
+	refunded, err = service.Refund(ctx, payment.ID, RefundRequest{Amount: 10, IdempotencyKey: "return:1"})
+	require.NoError(t, err)
+	assert.InDelta(t, 10, refunded.RefundedAmount, 0.001)
+	require.Len(t, refunded.Refunds, 1)
+	assert.Equal(t, "return:1", refunded.Refunds[0].IdempotencyKey)
+
+	_, err = service.Refund(ctx, payment.ID, RefundRequest{Amount: 100})
+	assert.Equal(t, ErrInvalidRefundAmount, err)
+
+	refunded, err = service.Refund(ctx, payment.ID, RefundRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, StatusRefunded, refunded.Status)
+	assert.InDelta(t, 49.99, refunded.RefundedAmount, 0.001)
+}
+
+func TestService_HandleWebhook_RejectsInvalidSignature(t *testing.T) {
+	service, provider, notifier := setupTestService()
+	ctx := context.Background()
+
+	payment, err := service.Authorize(ctx, "user-1", paymentRequest(MockMethodSuccess))
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(WebhookEvent{ID: "evt-forged", Type: EventCaptured, ProviderRef: payment.ProviderRef})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{name: "missing signature", signature: ""},
+		{name: "wrong secret", signature: NewMockProvider("other-secret", 0).SignWebhook(payload)},
+		{name: "stale timestamp", signature: "t=1000,v1=" + provider.sign("1000", payload)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.HandleWebhook(ctx, payload, tt.signature)
+			assert.Equal(t, ErrInvalidWebhookSignature, err)
+		})
+	}
+
+	stored, err := service.GetByID(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusAuthorized, stored.Status)
+	assert.Empty(t, notifier.paidOrders())
+
+	// A correctly signed event is accepted
+	err = service.HandleWebhook(ctx, payload, provider.SignWebhook(payload))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order-1"}, notifier.paidOrders())
+}
+
+func TestService_PersonalData(t *testing.T) {
+	service, _, _ := setupTestService()
+	ctx := context.Background()
+
+	payment, err := service.Authorize(ctx, "user-1", paymentRequest(MockMethodSuccess))
+	require.NoError(t, err)
+
+	data, err := service.ExportPersonalData(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Len(t, data, 1)
+
+	// Payments are kept for accounting but no longer name the user
+	require.NoError(t, service.ErasePersonalData(ctx, "user-1"))
+	kept, err := service.GetByID(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Empty(t, kept.UserID)
+	data, err = service.ExportPersonalData(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
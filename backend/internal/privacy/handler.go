@@ -0,0 +1,106 @@
+package privacy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+	"go.uber.org/zap"
+)
+
+// Handler handles HTTP requests for personal data export and erasure
+type Handler struct {
+	service Service
+	logger  *zap.Logger
+}
+
+// NewHandler creates a new privacy handler
+func NewHandler(service Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Export handles a user downloading the personal data kept about them. The archive is
+// written as a JSON attachment rather than in the usual response envelope.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	export, err := h.service.Export(r.Context(), userID)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to export personal data")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="personal-data.json"`)
+	response.WriteJSON(w, http.StatusOK, export)
+}
+
+// RequestDeletion handles a user asking for their account and personal data to be
+// erased once the grace period has passed
+func (h *Handler) RequestDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	deletion, err := h.service.RequestDeletion(r.Context(), userID)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to request deletion")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusAccepted, deletion)
+}
+
+// GetDeletion handles a user checking when their personal data will be erased
+func (h *Handler) GetDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	deletion, err := h.service.GetDeletion(r.Context(), userID)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get deletion")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, deletion)
+}
+
+// CancelDeletion handles a user changing their mind during the grace period
+func (h *Handler) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.CancelDeletion(r.Context(), userID); err != nil {
+		h.writeServiceError(w, err, "Failed to cancel deletion")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeServiceError maps service errors to HTTP responses
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error, logMessage string) {
+	switch {
+	case errors.Is(err, ErrDeletionNotFound):
+		response.WriteError(w, http.StatusNotFound, "DELETION_NOT_FOUND", "Account deletion has not been requested", "")
+	case errors.Is(err, ErrDeletionNotAllowed):
+		response.WriteError(w, http.StatusConflict, "DELETION_NOT_ALLOWED", "The account cannot be deleted, for example because it is the only admin", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
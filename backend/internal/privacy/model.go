@@ -0,0 +1,46 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrDeletionNotFound is returned when the user has not asked for their data to be deleted
+	ErrDeletionNotFound = errors.New("deletion not requested")
+	// ErrDeletionNotAllowed is returned when a module refuses to erase the user, such as the
+	// account module for the only admin
+	ErrDeletionNotAllowed = errors.New("deletion not allowed")
+)
+
+// Module is implemented by every part of the application that keeps personal data, and
+// registered with the service under the name of its section in exports
+type Module interface {
+	// ExportPersonalData returns the personal data kept about a user, to be encoded as JSON
+	ExportPersonalData(ctx context.Context, userID string) (interface{}, error)
+	// ErasePersonalData deletes or anonymizes the personal data kept about a user. It may
+	// be called again for the same user after failing, or after succeeding.
+	ErasePersonalData(ctx context.Context, userID string) error
+}
+
+// ErasureChecker is implemented by modules that can refuse to erase a user
+type ErasureChecker interface {
+	CheckErasure(ctx context.Context, userID string) error
+}
+
+// Export is a machine-readable archive of the personal data kept about a user, with one
+// section per registered module
+type Export struct {
+	UserID     string                 `json:"user_id"`
+	ExportedAt time.Time              `json:"exported_at"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// Deletion is a user's request to have their personal data erased. It is carried out once
+// ScheduledFor has passed, unless the user cancels it before.
+type Deletion struct {
+	UserID       string    `json:"user_id"`
+	RequestedAt  time.Time `json:"requested_at"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
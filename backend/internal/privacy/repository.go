@@ -0,0 +1,84 @@
+package privacy
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Repository defines the interface for pending deletion data access
+type Repository interface {
+	Save(ctx context.Context, deletion *Deletion) error
+	Find(ctx context.Context, userID string) (*Deletion, error)
+	Delete(ctx context.Context, userID string) error
+	// ListDue lists the deletions scheduled for at or before at, oldest first
+	ListDue(ctx context.Context, at time.Time) ([]*Deletion, error)
+}
+
+// InMemoryRepository implements Repository using in-memory storage
+type InMemoryRepository struct {
+	deletions map[string]*Deletion
+	mutex     sync.RWMutex
+}
+
+// NewInMemoryRepository creates a new in-memory deletion repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		deletions: make(map[string]*Deletion),
+	}
+}
+
+// Save creates or replaces the deletion of a user
+func (r *InMemoryRepository) Save(ctx context.Context, deletion *Deletion) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *deletion
+	r.deletions[deletion.UserID] = &stored
+	return nil
+}
+
+// Find finds the deletion of a user
+func (r *InMemoryRepository) Find(ctx context.Context, userID string) (*Deletion, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	deletion, exists := r.deletions[userID]
+	if !exists {
+		return nil, ErrDeletionNotFound
+	}
+
+	found := *deletion
+	return &found, nil
+}
+
+// Delete deletes the deletion of a user
+func (r *InMemoryRepository) Delete(ctx context.Context, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.deletions[userID]; !exists {
+		return ErrDeletionNotFound
+	}
+	delete(r.deletions, userID)
+	return nil
+}
+
+// ListDue lists the deletions scheduled for at or before at, oldest first
+func (r *InMemoryRepository) ListDue(ctx context.Context, at time.Time) ([]*Deletion, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	deletions := make([]*Deletion, 0)
+	for _, deletion := range r.deletions {
+		if !deletion.ScheduledFor.After(at) {
+			found := *deletion
+			deletions = append(deletions, &found)
+		}
+	}
+	sort.Slice(deletions, func(i, j int) bool {
+		return deletions[i].ScheduledFor.Before(deletions[j].ScheduledFor)
+	})
+	return deletions, nil
+}
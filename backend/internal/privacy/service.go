@@ -0,0 +1,256 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	"go.uber.org/zap"
+)
+
+// Service defines the interface for personal data export and erasure
+type Service interface {
+	// Register adds a module keeping personal data. Modules are erased in the order they
+	// are registered, so the account itself should be registered last.
+	Register(name string, module Module)
+	Export(ctx context.Context, userID string) (*Export, error)
+	RequestDeletion(ctx context.Context, userID string) (*Deletion, error)
+	GetDeletion(ctx context.Context, userID string) (*Deletion, error)
+	CancelDeletion(ctx context.Context, userID string) error
+	// EraseNow erases a user straight away, without a grace period, such as when an admin
+	// deletes them
+	EraseNow(ctx context.Context, userID, adminID string) error
+	// ProcessDueDeletions erases the users whose grace period has passed and returns how
+	// many were erased
+	ProcessDueDeletions(ctx context.Context) (int, error)
+	StartDeletionWorker(interval time.Duration, onProcess func(erased int, err error)) (stop func())
+}
+
+type registeredModule struct {
+	name   string
+	module Module
+}
+
+type service struct {
+	repo        Repository
+	gracePeriod time.Duration
+	logger      *zap.Logger
+	modules     []registeredModule
+	// mutex guards modules and serializes erasures
+	mutex sync.Mutex
+}
+
+// NewService creates a new privacy service. Deletions are carried out gracePeriod after
+// they are requested; a zero grace period erases users the next time deletions are processed.
+func NewService(repo Repository, gracePeriod time.Duration, logger *zap.Logger) Service {
+	return &service{
+		repo:        repo,
+		gracePeriod: gracePeriod,
+		logger:      logger,
+	}
+}
+
+// Register adds a module keeping personal data under name
+func (s *service) Register(name string, module Module) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.modules = append(s.modules, registeredModule{name: name, module: module})
+}
+
+// Export collects the personal data every module keeps about a user
+func (s *service) Export(ctx context.Context, userID string) (*Export, error) {
+	export := &Export{
+		UserID:     userID,
+		ExportedAt: time.Now(),
+		Data:       make(map[string]interface{}),
+	}
+	for _, m := range s.registered() {
+		data, err := m.module.ExportPersonalData(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", m.name, err)
+		}
+		export.Data[m.name] = data
+	}
+	return export, nil
+}
+
+// RequestDeletion schedules a user's personal data to be erased once the grace period has
+// passed. Requesting again returns the deletion already scheduled.
+func (s *service) RequestDeletion(ctx context.Context, userID string) (*Deletion, error) {
+	if deletion, err := s.repo.Find(ctx, userID); err == nil {
+		return deletion, nil
+	} else if err != ErrDeletionNotFound {
+		return nil, err
+	}
+
+	if err := s.checkErasure(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deletion := &Deletion{
+		UserID:       userID,
+		RequestedAt:  now,
+		ScheduledFor: now.Add(s.gracePeriod),
+	}
+	if err := s.repo.Save(ctx, deletion); err != nil {
+		return nil, err
+	}
+
+	audit.SecurityEvent(s.logger, "deletion_requested", zap.String("user_id", userID), zap.Time("scheduled_for", deletion.ScheduledFor))
+	return deletion, nil
+}
+
+// GetDeletion returns the deletion scheduled for a user
+func (s *service) GetDeletion(ctx context.Context, userID string) (*Deletion, error) {
+	return s.repo.Find(ctx, userID)
+}
+
+// CancelDeletion cancels the deletion scheduled for a user
+func (s *service) CancelDeletion(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.repo.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	audit.SecurityEvent(s.logger, "deletion_cancelled", zap.String("user_id", userID))
+	return nil
+}
+
+// ProcessDueDeletions erases the users whose grace period has passed. A deletion that
+// fails stays scheduled and is retried the next time deletions are processed.
+func (s *service) ProcessDueDeletions(ctx context.Context) (int, error) {
+	deletions, err := s.repo.ListDue(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	erased := 0
+	var errs []error
+	for _, deletion := range deletions {
+		if err := s.erase(ctx, deletion.UserID); err != nil {
+			if err == ErrDeletionNotFound {
+				// Cancelled since it was listed
+				continue
+			}
+			s.logger.Error("Failed to erase personal data", zap.String("user_id", deletion.UserID), zap.Error(err))
+			errs = append(errs, err)
+			continue
+		}
+		erased++
+	}
+	return erased, errors.Join(errs...)
+}
+
+// StartDeletionWorker processes due deletions every interval until stop is called. The
+// outcome of each run is reported to onProcess, which may be nil.
+func (s *service) StartDeletionWorker(interval time.Duration, onProcess func(erased int, err error)) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				erased, err := s.ProcessDueDeletions(context.Background())
+				if onProcess != nil {
+					onProcess(erased, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// erase has every module erase a user, in registration order, then forgets the deletion.
+// It returns ErrDeletionNotFound when the deletion has been cancelled.
+func (s *service) erase(ctx context.Context, userID string) error {
+	if err := s.checkErasure(ctx, userID); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.repo.Find(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.eraseModules(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	audit.SecurityEvent(s.logger, "personal_data_erased", zap.String("user_id", userID))
+	return nil
+}
+
+// EraseNow has every module erase a user straight away, like a deletion whose grace
+// period has passed, and forgets any deletion the user had scheduled
+func (s *service) EraseNow(ctx context.Context, userID, adminID string) error {
+	if err := s.checkErasure(ctx, userID); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.eraseModules(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, userID); err != nil && err != ErrDeletionNotFound {
+		return err
+	}
+
+	audit.SecurityEvent(s.logger, "personal_data_erased", zap.String("user_id", userID), zap.String("admin_id", adminID))
+	return nil
+}
+
+// eraseModules has every module erase a user, in registration order. The caller holds
+// the mutex.
+func (s *service) eraseModules(ctx context.Context, userID string) error {
+	for _, m := range s.modules {
+		if err := m.module.ErasePersonalData(ctx, userID); err != nil {
+			return fmt.Errorf("failed to erase %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// checkErasure asks every module able to refuse whether a user may be erased
+func (s *service) checkErasure(ctx context.Context, userID string) error {
+	for _, m := range s.registered() {
+		checker, ok := m.module.(ErasureChecker)
+		if !ok {
+			continue
+		}
+		if err := checker.CheckErasure(ctx, userID); err != nil {
+			return fmt.Errorf("%w: %v", ErrDeletionNotAllowed, err)
+		}
+	}
+	return nil
+}
+
+// registered returns a snapshot of the registered modules
+func (s *service) registered() []registeredModule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]registeredModule(nil), s.modules...)
+}
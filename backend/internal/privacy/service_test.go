@@ -0,0 +1,169 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubModule keeps a string of personal data per user
+type stubModule struct {
+	data    map[string]string
+	refuse  map[string]bool
+	failing bool
+	erased  *[]string
+	name    string
+}
+
+func (m *stubModule) ExportPersonalData(ctx context.Context, userID string) (interface{}, error) {
+	return m.data[userID], nil
+}
+
+func (m *stubModule) ErasePersonalData(ctx context.Context, userID string) error {
+	if m.failing {
+		return errors.New("storage unavailable")
+	}
+	delete(m.data, userID)
+	*m.erased = append(*m.erased, m.name)
+	return nil
+}
+
+func (m *stubModule) CheckErasure(ctx context.Context, userID string) error {
+	if m.refuse[userID] {
+		return errors.New("cannot remove the last admin")
+	}
+	return nil
+}
+
+func setupTestService(t *testing.T, gracePeriod time.Duration) (Service, *stubModule, *stubModule, *[]string) {
+	t.Helper()
+	logger, _ := zap.NewDevelopment()
+
+	var erased []string
+	orders := &stubModule{name: "orders", data: map[string]string{"user-1": "order-1"}, erased: &erased}
+	account := &stubModule{name: "account", data: map[string]string{"user-1": "alice@example.com"}, refuse: map[string]bool{}, erased: &erased}
+
+	service := NewService(NewInMemoryRepository(), gracePeriod, logger)
+	service.Register("orders", orders)
+	service.Register("account", account)
+	return service, orders, account, &erased
+}
+
+func TestService_Export(t *testing.T) {
+	service, _, _, _ := setupTestService(t, time.Hour)
+
+	export, err := service.Export(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", export.UserID)
+	assert.Equal(t, map[string]interface{}{"orders": "order-1", "account": "alice@example.com"}, export.Data)
+}
+
+func TestService_DeletionGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	service, orders, _, erased := setupTestService(t, time.Hour)
+
+	_, err := service.GetDeletion(ctx, "user-1")
+	assert.Equal(t, ErrDeletionNotFound, err)
+
+	deletion, err := service.RequestDeletion(ctx, "user-1")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deletion.ScheduledFor, time.Minute)
+
+	again, err := service.RequestDeletion(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, deletion.ScheduledFor, again.ScheduledFor, "requesting again keeps the original schedule")
+
+	n, err := service.ProcessDueDeletions(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "nothing is erased during the grace period")
+	assert.Contains(t, orders.data, "user-1")
+
+	require.NoError(t, service.CancelDeletion(ctx, "user-1"))
+	assert.Equal(t, ErrDeletionNotFound, service.CancelDeletion(ctx, "user-1"))
+	assert.Empty(t, *erased)
+}
+
+func TestService_ProcessDueDeletions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("erases modules in registration order", func(t *testing.T) {
+		service, orders, account, erased := setupTestService(t, 0)
+		_, err := service.RequestDeletion(ctx, "user-1")
+		require.NoError(t, err)
+
+		n, err := service.ProcessDueDeletions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"orders", "account"}, *erased)
+		assert.NotContains(t, orders.data, "user-1")
+		assert.NotContains(t, account.data, "user-1")
+
+		_, err = service.GetDeletion(ctx, "user-1")
+		assert.Equal(t, ErrDeletionNotFound, err)
+	})
+
+	t.Run("keeps failed deletions scheduled", func(t *testing.T) {
+		service, orders, _, _ := setupTestService(t, 0)
+		_, err := service.RequestDeletion(ctx, "user-1")
+		require.NoError(t, err)
+
+		orders.failing = true
+		n, err := service.ProcessDueDeletions(ctx)
+		assert.Error(t, err)
+		assert.Zero(t, n)
+		_, err = service.GetDeletion(ctx, "user-1")
+		assert.NoError(t, err)
+
+		orders.failing = false
+		n, err = service.ProcessDueDeletions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("modules can refuse", func(t *testing.T) {
+		service, _, account, erased := setupTestService(t, 0)
+		account.refuse["user-1"] = true
+
+		_, err := service.RequestDeletion(ctx, "user-1")
+		assert.ErrorIs(t, err, ErrDeletionNotAllowed)
+
+		// A module refusing after the request leaves the deletion scheduled untouched
+		account.refuse["user-1"] = false
+		_, err = service.RequestDeletion(ctx, "user-1")
+		require.NoError(t, err)
+		account.refuse["user-1"] = true
+
+		_, err = service.ProcessDueDeletions(ctx)
+		assert.ErrorIs(t, err, ErrDeletionNotAllowed)
+		assert.Empty(t, *erased)
+	})
+}
+
+func TestService_EraseNow(t *testing.T) {
+	ctx := context.Background()
+	service, orders, account, erased := setupTestService(t, time.Hour)
+	_, err := service.RequestDeletion(ctx, "user-1")
+	require.NoError(t, err)
+
+	account.refuse["user-1"] = true
+	assert.ErrorIs(t, service.EraseNow(ctx, "user-1", "admin-1"), ErrDeletionNotAllowed)
+	assert.Empty(t, *erased)
+
+	// The grace period is skipped and the deletion scheduled is forgotten
+	account.refuse["user-1"] = false
+	require.NoError(t, service.EraseNow(ctx, "user-1", "admin-1"))
+	assert.Equal(t, []string{"orders", "account"}, *erased)
+	assert.NotContains(t, orders.data, "user-1")
+	_, err = service.GetDeletion(ctx, "user-1")
+	assert.Equal(t, ErrDeletionNotFound, err)
+
+	// Users without a deletion scheduled are erased too
+	orders.data["user-2"] = "order-2"
+	require.NoError(t, service.EraseNow(ctx, "user-2", "admin-1"))
+	assert.NotContains(t, orders.data, "user-2")
+}
@@ -3,6 +3,7 @@ package product
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
@@ -82,46 +83,54 @@ func (h *Handler) GetByID(w http.ResponseWriter, r *http.Request) {
 
 // List handles listing products with filters
 func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
+	filters := FiltersFromQuery(r.URL.Query())
+
+	productList, err := h.service.List(r.Context(), filters)
+	if err != nil {
+		h.logger.Error("Failed to list products", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, productList)
+}
+
+// FiltersFromQuery parses product list filters from query parameters, ignoring malformed
+// values
+func FiltersFromQuery(query url.Values) ProductFilters {
 	filters := ProductFilters{
-		CategoryID: r.URL.Query().Get("category_id"),
-		Search:     r.URL.Query().Get("search"),
+		CategoryID: query.Get("category_id"),
+		SellerID:   query.Get("seller_id"),
+		Search:     query.Get("search"),
 		Page:       1,
 		PageSize:   10,
 	}
 
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+	if pageStr := query.Get("page"); pageStr != "" {
 		if page, err := strconv.Atoi(pageStr); err == nil && page > 0 {
 			filters.Page = page
 		}
 	}
 
-	if pageSizeStr := r.URL.Query().Get("page_size"); pageSizeStr != "" {
+	if pageSizeStr := query.Get("page_size"); pageSizeStr != "" {
 		if pageSize, err := strconv.Atoi(pageSizeStr); err == nil && pageSize > 0 {
 			filters.PageSize = pageSize
 		}
 	}
 
-	if minPriceStr := r.URL.Query().Get("min_price"); minPriceStr != "" {
+	if minPriceStr := query.Get("min_price"); minPriceStr != "" {
 		if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil && minPrice >= 0 {
 			filters.MinPrice = minPrice
 		}
 	}
 
-	if maxPriceStr := r.URL.Query().Get("max_price"); maxPriceStr != "" {
+	if maxPriceStr := query.Get("max_price"); maxPriceStr != "" {
 		if maxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil && maxPrice >= 0 {
 			filters.MaxPrice = maxPrice
 		}
 	}
 
-	productList, err := h.service.List(r.Context(), filters)
-	if err != nil {
-		h.logger.Error("Failed to list products", zap.Error(err))
-		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
-		return
-	}
-
-	response.WriteSuccess(w, http.StatusOK, productList)
+	return filters
 }
 
 // Update handles updating a product
@@ -8,44 +8,67 @@ import (
 var (
 	// ErrProductNotFound is returned when a product is not found
 	ErrProductNotFound = errors.New("product not found")
+	// ErrInsufficientStock is returned when a stock adjustment would make stock negative
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrNotProductOwner is returned when a seller changes a product they do not sell
+	ErrNotProductOwner = errors.New("product belongs to another seller")
 )
 
-// Product represents a product entity
+// Product represents a product entity. Weight is in kilograms. SellerID is the user ID of
+// the seller who lists the product, and empty for products the store sells itself.
 type Product struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Stock       int       `json:"stock"`
-	CategoryID  string    `json:"category_id"`
-	ImageURL    string    `json:"image_url,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       float64     `json:"price"`
+	Stock       int         `json:"stock"`
+	CategoryID  string      `json:"category_id"`
+	ImageURL    string      `json:"image_url,omitempty"`
+	TaxClass    string      `json:"tax_class,omitempty"`
+	Weight      float64     `json:"weight,omitempty"`
+	Dimensions  *Dimensions `json:"dimensions,omitempty"`
+	SellerID    string      `json:"seller_id,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Dimensions represents the packed size of a product in centimetres
+type Dimensions struct {
+	Length float64 `json:"length" validate:"gt=0"`
+	Width  float64 `json:"width" validate:"gt=0"`
+	Height float64 `json:"height" validate:"gt=0"`
 }
 
 // CreateProductRequest represents a product creation request
 type CreateProductRequest struct {
-	Name        string  `json:"name" validate:"required,min=3,max=255"`
-	Description string  `json:"description" validate:"max=2000"`
-	Price       float64 `json:"price" validate:"required,gt=0"`
-	Stock       int     `json:"stock" validate:"required,gte=0"`
-	CategoryID  string  `json:"category_id" validate:"required"`
-	ImageURL    string  `json:"image_url" validate:"omitempty,url"`
+	Name        string      `json:"name" validate:"required,min=3,max=255"`
+	Description string      `json:"description" validate:"max=2000"`
+	Price       float64     `json:"price" validate:"required,gt=0"`
+	Stock       int         `json:"stock" validate:"required,gte=0"`
+	CategoryID  string      `json:"category_id" validate:"required"`
+	ImageURL    string      `json:"image_url" validate:"omitempty,url"`
+	TaxClass    string      `json:"tax_class" validate:"omitempty,max=32"`
+	Weight      float64     `json:"weight" validate:"gte=0"`
+	Dimensions  *Dimensions `json:"dimensions"`
 }
 
 // UpdateProductRequest represents a product update request
 type UpdateProductRequest struct {
-	Name        string  `json:"name" validate:"omitempty,min=3,max=255"`
-	Description string  `json:"description" validate:"omitempty,max=2000"`
-	Price       float64 `json:"price" validate:"omitempty,gt=0"`
-	Stock       int     `json:"stock" validate:"omitempty,gte=0"`
-	CategoryID  string  `json:"category_id" validate:"omitempty"`
-	ImageURL    string  `json:"image_url" validate:"omitempty,url"`
+	Name        string      `json:"name" validate:"omitempty,min=3,max=255"`
+	Description string      `json:"description" validate:"omitempty,max=2000"`
+	Price       float64     `json:"price" validate:"omitempty,gt=0"`
+	Stock       int         `json:"stock" validate:"omitempty,gte=0"`
+	CategoryID  string      `json:"category_id" validate:"omitempty"`
+	ImageURL    string      `json:"image_url" validate:"omitempty,url"`
+	TaxClass    string      `json:"tax_class" validate:"omitempty,max=32"`
+	Weight      float64     `json:"weight" validate:"omitempty,gte=0"`
+	Dimensions  *Dimensions `json:"dimensions"`
 }
 
 // ProductFilters represents filters for listing products
 type ProductFilters struct {
 	CategoryID string  `json:"category_id,omitempty"`
+	SellerID   string  `json:"seller_id,omitempty"`
 	MinPrice   float64 `json:"min_price,omitempty"`
 	MaxPrice   float64 `json:"max_price,omitempty"`
 	Search     string  `json:"search,omitempty"`
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Repository defines the interface for product data access
@@ -13,6 +14,7 @@ type Repository interface {
 	List(ctx context.Context, filters ProductFilters) ([]*Product, int, error)
 	Update(ctx context.Context, product *Product) error
 	Delete(ctx context.Context, id string) error
+	AdjustStock(ctx context.Context, id string, delta int) (*Product, error)
 }
 
 // InMemoryRepository implements Repository using in-memory storage
@@ -69,6 +71,11 @@ func (r *InMemoryRepository) List(ctx context.Context, filters ProductFilters) (
 			continue
 		}
 
+		// Seller filter
+		if filters.SellerID != "" && product.SellerID != filters.SellerID {
+			continue
+		}
+
 		// Price filters
 		if filters.MinPrice > 0 && product.Price < filters.MinPrice {
 			continue
@@ -140,3 +147,21 @@ func (r *InMemoryRepository) Delete(ctx context.Context, id string) error {
 	delete(r.products, id)
 	return nil
 }
+
+// AdjustStock atomically adds delta to a product's stock, refusing to go below zero
+func (r *InMemoryRepository) AdjustStock(ctx context.Context, id string, delta int) (*Product, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	product, exists := r.products[id]
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+	if product.Stock+delta < 0 {
+		return nil, ErrInsufficientStock
+	}
+
+	product.Stock += delta
+	product.UpdatedAt = time.Now()
+	return product, nil
+}
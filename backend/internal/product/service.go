@@ -15,6 +15,10 @@ type Service interface {
 	List(ctx context.Context, filters ProductFilters) (*ProductList, error)
 	Update(ctx context.Context, id string, req UpdateProductRequest) (*Product, error)
 	Delete(ctx context.Context, id string) error
+	AdjustStock(ctx context.Context, id string, delta int) (*Product, error)
+	CreateForSeller(ctx context.Context, sellerID string, req CreateProductRequest) (*Product, error)
+	UpdateForSeller(ctx context.Context, sellerID, id string, req UpdateProductRequest) (*Product, error)
+	DeleteForSeller(ctx context.Context, sellerID, id string) error
 }
 
 // service implements Service
@@ -31,9 +35,14 @@ func NewService(repo Repository, logger *zap.Logger) Service {
 	}
 }
 
-// Create creates a new product
+// Create creates a new product sold by the store itself
 func (s *service) Create(ctx context.Context, req CreateProductRequest) (*Product, error) {
-	s.logger.Info("Creating new product", zap.String("name", req.Name))
+	return s.CreateForSeller(ctx, "", req)
+}
+
+// CreateForSeller creates a new product listed by a seller
+func (s *service) CreateForSeller(ctx context.Context, sellerID string, req CreateProductRequest) (*Product, error) {
+	s.logger.Info("Creating new product", zap.String("name", req.Name), zap.String("seller_id", sellerID))
 
 	now := time.Now()
 	product := &Product{
@@ -44,6 +53,10 @@ func (s *service) Create(ctx context.Context, req CreateProductRequest) (*Produc
 		Stock:       req.Stock,
 		CategoryID:  req.CategoryID,
 		ImageURL:    req.ImageURL,
+		TaxClass:    req.TaxClass,
+		Weight:      req.Weight,
+		Dimensions:  req.Dimensions,
+		SellerID:    sellerID,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
@@ -112,6 +125,24 @@ func (s *service) Update(ctx context.Context, id string, req UpdateProductReques
 		return nil, err
 	}
 
+	return s.update(ctx, product, req)
+}
+
+// UpdateForSeller updates a product the seller lists, refusing other sellers' products
+// and the store's own
+func (s *service) UpdateForSeller(ctx context.Context, sellerID, id string, req UpdateProductRequest) (*Product, error) {
+	s.logger.Info("Updating seller product", zap.String("product_id", id), zap.String("seller_id", sellerID))
+
+	product, err := s.findOwned(ctx, sellerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, product, req)
+}
+
+// update applies the provided fields of req to product and stores it
+func (s *service) update(ctx context.Context, product *Product, req UpdateProductRequest) (*Product, error) {
 	// Update only provided fields
 	if req.Name != "" {
 		product.Name = req.Name
@@ -131,6 +162,15 @@ func (s *service) Update(ctx context.Context, id string, req UpdateProductReques
 	if req.ImageURL != "" {
 		product.ImageURL = req.ImageURL
 	}
+	if req.TaxClass != "" {
+		product.TaxClass = req.TaxClass
+	}
+	if req.Weight > 0 {
+		product.Weight = req.Weight
+	}
+	if req.Dimensions != nil {
+		product.Dimensions = req.Dimensions
+	}
 
 	product.UpdatedAt = time.Now()
 
@@ -155,3 +195,38 @@ func (s *service) Delete(ctx context.Context, id string) error {
 	s.logger.Info("Product deleted successfully", zap.String("product_id", id))
 	return nil
 }
+
+// DeleteForSeller deletes a product the seller lists, refusing other sellers' products
+// and the store's own
+func (s *service) DeleteForSeller(ctx context.Context, sellerID, id string) error {
+	if _, err := s.findOwned(ctx, sellerID, id); err != nil {
+		return err
+	}
+
+	return s.Delete(ctx, id)
+}
+
+// findOwned finds a product listed by sellerID
+func (s *service) findOwned(ctx context.Context, sellerID, id string) (*Product, error) {
+	product, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sellerID == "" || product.SellerID != sellerID {
+		return nil, ErrNotProductOwner
+	}
+	return product, nil
+}
+
+// AdjustStock adds delta to a product's stock, e.g. to restock returned items
+func (s *service) AdjustStock(ctx context.Context, id string, delta int) (*Product, error) {
+	s.logger.Info("Adjusting product stock", zap.String("product_id", id), zap.Int("delta", delta))
+
+	product, err := s.repo.AdjustStock(ctx, id, delta)
+	if err != nil {
+		s.logger.Error("Failed to adjust product stock", zap.String("product_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return product, nil
+}
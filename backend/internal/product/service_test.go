@@ -283,3 +283,75 @@ func TestService_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestService_AdjustStock(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, CreateProductRequest{
+		Name:       "Test Product",
+		Price:      10,
+		Stock:      5,
+		CategoryID: "category-1",
+	})
+	require.NoError(t, err)
+
+	product, err := service.AdjustStock(ctx, created.ID, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 8, product.Stock)
+
+	product, err = service.AdjustStock(ctx, created.ID, -8)
+	require.NoError(t, err)
+	assert.Equal(t, 0, product.Stock)
+
+	_, err = service.AdjustStock(ctx, created.ID, -1)
+	assert.Equal(t, ErrInsufficientStock, err)
+
+	_, err = service.AdjustStock(ctx, "missing", 1)
+	assert.Equal(t, ErrProductNotFound, err)
+}
+
+func TestService_SellerProducts(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+
+	req := CreateProductRequest{
+		Name:       "Handmade Mug",
+		Price:      12,
+		Stock:      4,
+		CategoryID: "category-1",
+	}
+	storeProduct, err := service.Create(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, storeProduct.SellerID)
+	owned, err := service.CreateForSeller(ctx, "seller-1", req)
+	require.NoError(t, err)
+	assert.Equal(t, "seller-1", owned.SellerID)
+
+	list, err := service.List(ctx, ProductFilters{SellerID: "seller-1"})
+	require.NoError(t, err)
+	require.Len(t, list.Products, 1)
+	assert.Equal(t, owned.ID, list.Products[0].ID)
+
+	updated, err := service.UpdateForSeller(ctx, "seller-1", owned.ID, UpdateProductRequest{Price: 15, Stock: 4})
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, updated.Price)
+	assert.Equal(t, "seller-1", updated.SellerID)
+
+	// Sellers cannot change other sellers' products or the store's own
+	_, err = service.UpdateForSeller(ctx, "seller-2", owned.ID, UpdateProductRequest{Price: 1})
+	assert.Equal(t, ErrNotProductOwner, err)
+	_, err = service.UpdateForSeller(ctx, "seller-1", storeProduct.ID, UpdateProductRequest{Price: 1})
+	assert.Equal(t, ErrNotProductOwner, err)
+	assert.Equal(t, ErrNotProductOwner, service.DeleteForSeller(ctx, "seller-2", owned.ID))
+	assert.Equal(t, ErrProductNotFound, service.DeleteForSeller(ctx, "seller-1", "missing"))
+
+	// Admins can change every product, without taking it from its seller
+	updated, err = service.Update(ctx, owned.ID, UpdateProductRequest{Name: "Handmade Cup", Stock: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "seller-1", updated.SellerID)
+
+	require.NoError(t, service.DeleteForSeller(ctx, "seller-1", owned.ID))
+	_, err = service.GetByID(ctx, owned.ID)
+	assert.Equal(t, ErrProductNotFound, err)
+}
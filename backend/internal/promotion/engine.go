@@ -0,0 +1,338 @@
+package promotion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/money"
+)
+
+// UsageLookup returns how many times the cart's user has redeemed a promotion
+type UsageLookup func(promotionID string) int
+
+// candidate is an eligible promotion together with its standalone discount
+type candidate struct {
+	promotion *Promotion
+	amount    float64
+}
+
+// Evaluate applies promotions to a cart and explains which rules fired.
+//
+// Automatic promotions (no code) and promotions whose code is in the cart are considered.
+// Stackable promotions combine with each other; a non-stackable promotion is exclusive and
+// is only chosen when its discount beats the combination of all stackable promotions.
+// Promotions are applied in descending priority against the amount left on each line,
+// so a line can never be discounted below zero.
+func Evaluate(promotions []*Promotion, cart Cart, usage UsageLookup, now time.Time) *Result {
+	result := &Result{
+		LineDiscounts: make([]float64, len(cart.Lines)),
+		Rules:         make([]RuleOutcome, 0),
+	}
+	for _, line := range cart.Lines {
+		result.Subtotal += line.UnitPrice * float64(line.Quantity)
+	}
+	result.Subtotal = money.Round(result.Subtotal)
+
+	requested := make(map[string]bool, len(cart.Codes))
+	for _, code := range cart.Codes {
+		requested[strings.ToUpper(strings.TrimSpace(code))] = true
+	}
+
+	lineAmounts := make([]float64, len(cart.Lines))
+	for i, line := range cart.Lines {
+		lineAmounts[i] = line.UnitPrice * float64(line.Quantity)
+	}
+
+	var stackable, exclusive []candidate
+	for _, p := range promotions {
+		if p.Code != "" && !requested[p.Code] {
+			continue
+		}
+		isRequested := p.Code != ""
+		delete(requested, p.Code)
+
+		if reason := ineligibleReason(p, cart, result.Subtotal, usage, now); reason != "" {
+			// Expired or disabled automatic promotions are noise; only explain what the customer asked for
+			if isRequested || (p.Active && inWindow(p, now)) {
+				result.Rules = append(result.Rules, outcome(p, false, 0, reason))
+			}
+			continue
+		}
+
+		lineDiscounts, shippingDiscount := discountFor(p, cart, lineAmounts)
+		c := candidate{promotion: p, amount: sum(lineDiscounts) + shippingDiscount}
+		if p.Stackable {
+			stackable = append(stackable, c)
+		} else {
+			exclusive = append(exclusive, c)
+		}
+	}
+
+	unknown := make([]string, 0, len(requested))
+	for code := range requested {
+		if code != "" {
+			unknown = append(unknown, code)
+		}
+	}
+	sort.Strings(unknown)
+	for _, code := range unknown {
+		result.Rules = append(result.Rules, RuleOutcome{Code: code, Reason: "unknown promotion code"})
+	}
+
+	chosen, rejected, rejectReason := choose(stackable, exclusive, cart, lineAmounts)
+	for _, c := range rejected {
+		result.Rules = append(result.Rules, outcome(c.promotion, false, 0, rejectReason(c)))
+	}
+
+	// Apply the chosen promotions against the remaining line amounts
+	remaining := append([]float64(nil), lineAmounts...)
+	shippingLeft := cart.ShippingCost
+	for _, c := range chosen {
+		lineDiscounts, shippingDiscount := discountFor(c.promotion, Cart{Lines: cart.Lines, ShippingCost: shippingLeft}, remaining)
+		for i, d := range lineDiscounts {
+			remaining[i] -= d
+			result.LineDiscounts[i] = money.Round(result.LineDiscounts[i] + d)
+		}
+		shippingLeft -= shippingDiscount
+		result.ShippingDiscount = money.Round(result.ShippingDiscount + shippingDiscount)
+		if c.promotion.Type == TypeFreeShipping {
+			result.FreeShipping = true
+		}
+		result.Rules = append(result.Rules, outcome(c.promotion, true, money.Round(sum(lineDiscounts)+shippingDiscount), describe(c.promotion)))
+	}
+
+	result.DiscountTotal = money.Round(sum(result.LineDiscounts))
+	return result
+}
+
+// choose decides between the best exclusive promotion and the stack of stackable promotions
+func choose(stackable, exclusive []candidate, cart Cart, lineAmounts []float64) ([]candidate, []candidate, func(candidate) string) {
+	byPriority(stackable)
+	byPriority(exclusive)
+
+	stackTotal := stackedAmount(stackable, cart, lineAmounts)
+
+	var best *candidate
+	for i := range exclusive {
+		if best == nil || exclusive[i].amount > best.amount {
+			best = &exclusive[i]
+		}
+	}
+
+	if best == nil || stackTotal >= best.amount {
+		return stackable, exclusive, func(candidate) string {
+			return "not combinable with other promotions; a better combination was applied"
+		}
+	}
+
+	rejected := make([]candidate, 0, len(stackable)+len(exclusive)-1)
+	rejected = append(rejected, stackable...)
+	for _, c := range exclusive {
+		if c.promotion != best.promotion {
+			rejected = append(rejected, c)
+		}
+	}
+	name := best.promotion.Name
+	return []candidate{*best}, rejected, func(candidate) string {
+		return fmt.Sprintf("not combinable with %q, which gives a larger discount", name)
+	}
+}
+
+// stackedAmount computes the total discount of applying candidates in order
+func stackedAmount(candidates []candidate, cart Cart, lineAmounts []float64) float64 {
+	remaining := append([]float64(nil), lineAmounts...)
+	shippingLeft := cart.ShippingCost
+	var total float64
+	for _, c := range candidates {
+		lineDiscounts, shippingDiscount := discountFor(c.promotion, Cart{Lines: cart.Lines, ShippingCost: shippingLeft}, remaining)
+		for i, d := range lineDiscounts {
+			remaining[i] -= d
+		}
+		shippingLeft -= shippingDiscount
+		total += sum(lineDiscounts) + shippingDiscount
+	}
+	return total
+}
+
+// ineligibleReason returns why a promotion cannot apply to the cart, or "" if it can
+func ineligibleReason(p *Promotion, cart Cart, subtotal float64, usage UsageLookup, now time.Time) string {
+	switch {
+	case !p.Active:
+		return "promotion is not active"
+	case p.StartsAt != nil && now.Before(*p.StartsAt):
+		return "promotion has not started yet"
+	case p.EndsAt != nil && now.After(*p.EndsAt):
+		return "promotion has expired"
+	case p.MaxUses > 0 && p.UsageCount >= p.MaxUses:
+		return "promotion usage limit reached"
+	case p.MaxUsesPerUser > 0 && cart.UserID == "":
+		return "sign in to use this promotion"
+	case p.MaxUsesPerUser > 0 && usage != nil && usage(p.ID) >= p.MaxUsesPerUser:
+		return "you have already used this promotion the maximum number of times"
+	case subtotal < p.MinCartValue:
+		return fmt.Sprintf("cart subtotal must be at least %.2f", p.MinCartValue)
+	}
+
+	for _, line := range cart.Lines {
+		if matches(p, line) {
+			return ""
+		}
+	}
+	return "no eligible items in cart"
+}
+
+// discountFor computes per-line and shipping discounts for p against the given line amounts
+func discountFor(p *Promotion, cart Cart, lineAmounts []float64) ([]float64, float64) {
+	discounts := make([]float64, len(cart.Lines))
+
+	switch p.Type {
+	case TypePercentage:
+		for i, line := range cart.Lines {
+			if matches(p, line) {
+				discounts[i] = capAt(money.Round(lineAmounts[i]*p.Value/100), lineAmounts[i])
+			}
+		}
+
+	case TypeFixedAmount:
+		weights := make([]float64, len(cart.Lines))
+		var eligible float64
+		for i, line := range cart.Lines {
+			if matches(p, line) && lineAmounts[i] > 0 {
+				weights[i] = lineAmounts[i]
+				eligible += lineAmounts[i]
+			}
+		}
+		amount := p.Value
+		if amount > eligible {
+			amount = eligible
+		}
+		for i, share := range money.Allocate(amount, weights) {
+			discounts[i] = capAt(share, lineAmounts[i])
+		}
+
+	case TypeFreeShipping:
+		if cart.ShippingCost > 0 {
+			return discounts, cart.ShippingCost
+		}
+
+	case TypeBuyXGetY:
+		discounts = buyXGetYDiscounts(p, cart, lineAmounts)
+	}
+
+	return discounts, 0
+}
+
+// buyXGetYDiscounts makes the cheapest eligible units free (or discounted by Value percent)
+func buyXGetYDiscounts(p *Promotion, cart Cart, lineAmounts []float64) []float64 {
+	discounts := make([]float64, len(cart.Lines))
+	if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+		return discounts
+	}
+
+	type unit struct {
+		line  int
+		price float64
+	}
+	units := make([]unit, 0)
+	for i, line := range cart.Lines {
+		if !matches(p, line) || line.Quantity < 1 {
+			continue
+		}
+		price := lineAmounts[i] / float64(line.Quantity)
+		for q := 0; q < line.Quantity; q++ {
+			units = append(units, unit{line: i, price: price})
+		}
+	}
+
+	free := len(units) / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+	sort.SliceStable(units, func(a, b int) bool { return units[a].price < units[b].price })
+
+	percent := p.Value
+	if percent <= 0 || percent > 100 {
+		percent = 100
+	}
+	for _, u := range units[:free] {
+		discounts[u.line] += u.price * percent / 100
+	}
+	for i := range discounts {
+		discounts[i] = capAt(money.Round(discounts[i]), lineAmounts[i])
+	}
+	return discounts
+}
+
+// matches reports whether a line is targeted by the promotion
+func matches(p *Promotion, line Line) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == line.ProductID {
+			return true
+		}
+	}
+	for _, id := range p.CategoryIDs {
+		if id == line.CategoryID {
+			return true
+		}
+	}
+	return false
+}
+
+func inWindow(p *Promotion, now time.Time) bool {
+	return (p.StartsAt == nil || !now.Before(*p.StartsAt)) && (p.EndsAt == nil || !now.After(*p.EndsAt))
+}
+
+// describe explains what a promotion that fired did
+func describe(p *Promotion) string {
+	switch p.Type {
+	case TypePercentage:
+		return fmt.Sprintf("%g%% off eligible items", p.Value)
+	case TypeFixedAmount:
+		return fmt.Sprintf("%.2f off eligible items", p.Value)
+	case TypeFreeShipping:
+		return "free shipping"
+	case TypeBuyXGetY:
+		return fmt.Sprintf("buy %d get %d on eligible items", p.BuyQuantity, p.GetQuantity)
+	}
+	return string(p.Type)
+}
+
+func outcome(p *Promotion, applied bool, amount float64, reason string) RuleOutcome {
+	return RuleOutcome{
+		PromotionID: p.ID,
+		Code:        p.Code,
+		Name:        p.Name,
+		Applied:     applied,
+		Amount:      amount,
+		Reason:      reason,
+	}
+}
+
+func byPriority(candidates []candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].promotion.Priority != candidates[j].promotion.Priority {
+			return candidates[i].promotion.Priority > candidates[j].promotion.Priority
+		}
+		return candidates[i].promotion.ID < candidates[j].promotion.ID
+	})
+}
+
+func capAt(value, limit float64) float64 {
+	if value > limit {
+		return limit
+	}
+	if value < 0 {
+		return 0
+	}
+	return value
+}
+
+func sum(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
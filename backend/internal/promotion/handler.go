@@ -0,0 +1,130 @@
+package promotion
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+	"go.uber.org/zap"
+)
+
+// Handler handles HTTP requests for promotion management
+type Handler struct {
+	service   Service
+	validator *validator.Validate
+	logger    *zap.Logger
+}
+
+// NewHandler creates a new promotion handler
+func NewHandler(service Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service:   service,
+		validator: validator.New(),
+		logger:    logger,
+	}
+}
+
+// Create handles promotion creation
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	promotion, err := h.service.Create(r.Context(), req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to create promotion")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, promotion)
+}
+
+// GetByID handles getting a promotion by ID
+func (h *Handler) GetByID(w http.ResponseWriter, r *http.Request) {
+	promotion, err := h.service.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get promotion")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, promotion)
+}
+
+// List handles listing promotions
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.service.List(r.Context())
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to list promotions")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, promotions)
+}
+
+// Update handles replacing a promotion's rules
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	promotion, err := h.service.Update(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to update promotion")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, promotion)
+}
+
+// Delete handles deleting a promotion
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		h.writeServiceError(w, err, "Failed to delete promotion")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeRequest decodes and validates a promotion request body
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request) (PromotionRequest, bool) {
+	var req PromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return req, false
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return req, false
+	}
+
+	return req, true
+}
+
+// writeServiceError maps service errors to HTTP responses
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error, logMessage string) {
+	switch err {
+	case ErrPromotionNotFound:
+		response.WriteError(w, http.StatusNotFound, "PROMOTION_NOT_FOUND", "Promotion not found", "")
+	case ErrCodeAlreadyExists:
+		response.WriteError(w, http.StatusConflict, "PROMOTION_CODE_EXISTS", "Promotion code already exists", "")
+	case ErrInvalidPromotion:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_PROMOTION", "Promotion rules are inconsistent for its type", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
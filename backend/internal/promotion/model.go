@@ -0,0 +1,130 @@
+package promotion
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPromotionNotFound is returned when a promotion is not found
+	ErrPromotionNotFound = errors.New("promotion not found")
+	// ErrCodeAlreadyExists is returned when a promotion code is already in use
+	ErrCodeAlreadyExists = errors.New("promotion code already exists")
+	// ErrInvalidPromotion is returned when a promotion's rule parameters are inconsistent
+	ErrInvalidPromotion = errors.New("invalid promotion rules")
+	// ErrUsageLimitReached is returned when redeeming a promotion that has no uses left
+	ErrUsageLimitReached = errors.New("promotion usage limit reached")
+)
+
+// Type identifies how a promotion computes its discount
+type Type string
+
+const (
+	// TypePercentage discounts a percentage of the eligible line amounts
+	TypePercentage Type = "percentage"
+	// TypeFixedAmount discounts a fixed amount spread across eligible lines
+	TypeFixedAmount Type = "fixed_amount"
+	// TypeFreeShipping waives the shipping cost
+	TypeFreeShipping Type = "free_shipping"
+	// TypeBuyXGetY gives GetQuantity units free for every BuyQuantity units purchased
+	TypeBuyXGetY Type = "buy_x_get_y"
+)
+
+// Promotion represents a discount rule. Promotions without a code apply automatically.
+type Promotion struct {
+	ID             string     `json:"id"`
+	Code           string     `json:"code,omitempty"`
+	Name           string     `json:"name"`
+	Description    string     `json:"description,omitempty"`
+	Type           Type       `json:"type"`
+	Value          float64    `json:"value"`
+	BuyQuantity    int        `json:"buy_quantity,omitempty"`
+	GetQuantity    int        `json:"get_quantity,omitempty"`
+	MinCartValue   float64    `json:"min_cart_value,omitempty"`
+	ProductIDs     []string   `json:"product_ids,omitempty"`
+	CategoryIDs    []string   `json:"category_ids,omitempty"`
+	MaxUses        int        `json:"max_uses,omitempty"`
+	MaxUsesPerUser int        `json:"max_uses_per_user,omitempty"`
+	UsageCount     int        `json:"usage_count"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Stackable      bool       `json:"stackable"`
+	Priority       int        `json:"priority"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// PromotionRequest represents a promotion creation or replacement request
+type PromotionRequest struct {
+	Code           string     `json:"code" validate:"omitempty,alphanum,min=3,max=32"`
+	Name           string     `json:"name" validate:"required,min=3,max=100"`
+	Description    string     `json:"description" validate:"max=500"`
+	Type           Type       `json:"type" validate:"required,oneof=percentage fixed_amount free_shipping buy_x_get_y"`
+	Value          float64    `json:"value" validate:"gte=0"`
+	BuyQuantity    int        `json:"buy_quantity" validate:"gte=0"`
+	GetQuantity    int        `json:"get_quantity" validate:"gte=0"`
+	MinCartValue   float64    `json:"min_cart_value" validate:"gte=0"`
+	ProductIDs     []string   `json:"product_ids"`
+	CategoryIDs    []string   `json:"category_ids"`
+	MaxUses        int        `json:"max_uses" validate:"gte=0"`
+	MaxUsesPerUser int        `json:"max_uses_per_user" validate:"gte=0"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	Stackable      bool       `json:"stackable"`
+	Priority       int        `json:"priority"`
+	Active         bool       `json:"active"`
+}
+
+// Redemption records how many times a user redeemed a promotion
+type Redemption struct {
+	PromotionID string `json:"promotion_id"`
+	Count       int    `json:"count"`
+}
+
+// Cart is the input to promotion evaluation
+type Cart struct {
+	UserID       string
+	Lines        []Line
+	ShippingCost float64
+	Codes        []string
+}
+
+// Line is a single cart line
+type Line struct {
+	ProductID  string
+	CategoryID string
+	UnitPrice  float64
+	Quantity   int
+}
+
+// Result is the outcome of applying promotions to a cart
+type Result struct {
+	Subtotal         float64       `json:"subtotal"`
+	LineDiscounts    []float64     `json:"line_discounts"`
+	DiscountTotal    float64       `json:"discount_total"`
+	ShippingDiscount float64       `json:"shipping_discount"`
+	FreeShipping     bool          `json:"free_shipping"`
+	Rules            []RuleOutcome `json:"rules"`
+}
+
+// AppliedPromotionIDs returns the IDs of the promotions that fired
+func (r *Result) AppliedPromotionIDs() []string {
+	ids := make([]string, 0, len(r.Rules))
+	for _, rule := range r.Rules {
+		if rule.Applied {
+			ids = append(ids, rule.PromotionID)
+		}
+	}
+	return ids
+}
+
+// RuleOutcome explains whether a promotion fired and why
+type RuleOutcome struct {
+	PromotionID string  `json:"promotion_id,omitempty"`
+	Code        string  `json:"code,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	Applied     bool    `json:"applied"`
+	Amount      float64 `json:"amount"`
+	Reason      string  `json:"reason"`
+}
@@ -0,0 +1,237 @@
+package promotion
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository defines the interface for promotion data access
+type Repository interface {
+	Create(ctx context.Context, promotion *Promotion) error
+	FindByID(ctx context.Context, id string) (*Promotion, error)
+	List(ctx context.Context) ([]*Promotion, error)
+	Update(ctx context.Context, promotion *Promotion) error
+	Delete(ctx context.Context, id string) error
+	CountRedemptions(ctx context.Context, promotionID, userID string) (int, error)
+	RecordRedemptions(ctx context.Context, userID string, promotionIDs []string) error
+	ReleaseRedemptions(ctx context.Context, userID string, promotionIDs []string) error
+	ListUserRedemptions(ctx context.Context, userID string) ([]Redemption, error)
+	DeleteUserRedemptions(ctx context.Context, userID string) error
+}
+
+// InMemoryRepository implements Repository using in-memory storage. It stores and returns
+// copies, so recording a redemption never changes a promotion being evaluated or updated.
+// The usage count belongs to the repository: only redemptions change it, never Update.
+type InMemoryRepository struct {
+	promotions  map[string]*Promotion
+	redemptions map[string]map[string]int
+	mutex       sync.RWMutex
+}
+
+// NewInMemoryRepository creates a new in-memory repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		promotions:  make(map[string]*Promotion),
+		redemptions: make(map[string]map[string]int),
+	}
+}
+
+// Create creates a new promotion
+func (r *InMemoryRepository) Create(ctx context.Context, promotion *Promotion) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.codeTaken(promotion.Code, promotion.ID) {
+		return ErrCodeAlreadyExists
+	}
+
+	r.promotions[promotion.ID] = copyPromotion(promotion)
+	return nil
+}
+
+// FindByID finds a promotion by ID
+func (r *InMemoryRepository) FindByID(ctx context.Context, id string) (*Promotion, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	promotion, exists := r.promotions[id]
+	if !exists {
+		return nil, ErrPromotionNotFound
+	}
+
+	return copyPromotion(promotion), nil
+}
+
+// List lists all promotions ordered by creation time
+func (r *InMemoryRepository) List(ctx context.Context) ([]*Promotion, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	promotions := make([]*Promotion, 0, len(r.promotions))
+	for _, promotion := range r.promotions {
+		promotions = append(promotions, copyPromotion(promotion))
+	}
+	sort.Slice(promotions, func(i, j int) bool {
+		return promotions[i].CreatedAt.Before(promotions[j].CreatedAt)
+	})
+
+	return promotions, nil
+}
+
+// Update updates a promotion
+func (r *InMemoryRepository) Update(ctx context.Context, promotion *Promotion) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.promotions[promotion.ID]
+	if !exists {
+		return ErrPromotionNotFound
+	}
+	if r.codeTaken(promotion.Code, promotion.ID) {
+		return ErrCodeAlreadyExists
+	}
+
+	// Keep the usage counted so far; the caller's copy may predate recent redemptions
+	stored := copyPromotion(promotion)
+	stored.UsageCount = existing.UsageCount
+	promotion.UsageCount = existing.UsageCount
+	r.promotions[promotion.ID] = stored
+	return nil
+}
+
+// Delete deletes a promotion
+func (r *InMemoryRepository) Delete(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.promotions[id]; !exists {
+		return ErrPromotionNotFound
+	}
+
+	delete(r.promotions, id)
+	delete(r.redemptions, id)
+	return nil
+}
+
+// CountRedemptions returns how many times a user has redeemed a promotion
+func (r *InMemoryRepository) CountRedemptions(ctx context.Context, promotionID, userID string) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.redemptions[promotionID][userID], nil
+}
+
+// RecordRedemptions atomically checks the usage limits of the promotions and records a
+// redemption of each by the user. Either all are recorded or, if any has no uses left, none.
+func (r *InMemoryRepository) RecordRedemptions(ctx context.Context, userID string, promotionIDs []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	redeeming := make(map[string]int, len(promotionIDs))
+	for _, id := range promotionIDs {
+		promotion, exists := r.promotions[id]
+		if !exists {
+			return ErrPromotionNotFound
+		}
+		redeeming[id]++
+		if promotion.MaxUses > 0 && promotion.UsageCount+redeeming[id] > promotion.MaxUses {
+			return ErrUsageLimitReached
+		}
+		if promotion.MaxUsesPerUser > 0 && r.redemptions[id][userID]+redeeming[id] > promotion.MaxUsesPerUser {
+			return ErrUsageLimitReached
+		}
+	}
+
+	for _, id := range promotionIDs {
+		if r.redemptions[id] == nil {
+			r.redemptions[id] = make(map[string]int)
+		}
+		r.redemptions[id][userID]++
+		r.promotions[id].UsageCount++
+	}
+	return nil
+}
+
+// ReleaseRedemptions takes back redemptions recorded by RecordRedemptions. Promotions deleted
+// since are skipped.
+func (r *InMemoryRepository) ReleaseRedemptions(ctx context.Context, userID string, promotionIDs []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, id := range promotionIDs {
+		promotion, exists := r.promotions[id]
+		if !exists {
+			continue
+		}
+		if promotion.UsageCount > 0 {
+			promotion.UsageCount--
+		}
+		if r.redemptions[id][userID] > 0 {
+			r.redemptions[id][userID]--
+		}
+	}
+	return nil
+}
+
+// ListUserRedemptions lists the promotions a user redeemed, ordered by promotion ID
+func (r *InMemoryRepository) ListUserRedemptions(ctx context.Context, userID string) ([]Redemption, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	redemptions := make([]Redemption, 0)
+	for promotionID, counts := range r.redemptions {
+		if count := counts[userID]; count > 0 {
+			redemptions = append(redemptions, Redemption{PromotionID: promotionID, Count: count})
+		}
+	}
+	sort.Slice(redemptions, func(i, j int) bool {
+		return redemptions[i].PromotionID < redemptions[j].PromotionID
+	})
+	return redemptions, nil
+}
+
+// DeleteUserRedemptions forgets the redemptions of a user. Promotions keep counting them
+// towards their overall usage.
+func (r *InMemoryRepository) DeleteUserRedemptions(ctx context.Context, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, counts := range r.redemptions {
+		delete(counts, userID)
+	}
+	return nil
+}
+
+// codeTaken reports whether another promotion already uses code. Must be called with the lock held.
+func (r *InMemoryRepository) codeTaken(code, id string) bool {
+	if code == "" {
+		return false
+	}
+	for _, existing := range r.promotions {
+		if existing.Code == code && existing.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// copyPromotion returns a copy of promotion that shares no slices or times with it
+func copyPromotion(promotion *Promotion) *Promotion {
+	copied := *promotion
+	if promotion.ProductIDs != nil {
+		copied.ProductIDs = append(make([]string, 0, len(promotion.ProductIDs)), promotion.ProductIDs...)
+	}
+	if promotion.CategoryIDs != nil {
+		copied.CategoryIDs = append(make([]string, 0, len(promotion.CategoryIDs)), promotion.CategoryIDs...)
+	}
+	if promotion.StartsAt != nil {
+		startsAt := *promotion.StartsAt
+		copied.StartsAt = &startsAt
+	}
+	if promotion.EndsAt != nil {
+		endsAt := *promotion.EndsAt
+		copied.EndsAt = &endsAt
+	}
+	return &copied
+}
@@ -0,0 +1,250 @@
+package promotion
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Service defines the interface for promotion business logic
+type Service interface {
+	Create(ctx context.Context, req PromotionRequest) (*Promotion, error)
+	GetByID(ctx context.Context, id string) (*Promotion, error)
+	List(ctx context.Context) ([]*Promotion, error)
+	Update(ctx context.Context, id string, req PromotionRequest) (*Promotion, error)
+	Delete(ctx context.Context, id string) error
+	Apply(ctx context.Context, cart Cart) (*Result, error)
+	Redeem(ctx context.Context, userID string, promotionIDs []string) error
+	Release(ctx context.Context, userID string, promotionIDs []string) error
+	ExportPersonalData(ctx context.Context, userID string) (interface{}, error)
+	ErasePersonalData(ctx context.Context, userID string) error
+}
+
+// service implements Service
+type service struct {
+	repo   Repository
+	logger *zap.Logger
+}
+
+// NewService creates a new promotion service
+func NewService(repo Repository, logger *zap.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Create creates a new promotion
+func (s *service) Create(ctx context.Context, req PromotionRequest) (*Promotion, error) {
+	s.logger.Info("Creating promotion", zap.String("name", req.Name), zap.String("code", req.Code))
+
+	if err := validateRules(req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	promotion := &Promotion{
+		ID:        uuid.New().String(),
+		CreatedAt: now,
+	}
+	applyRequest(promotion, req, now)
+
+	if err := s.repo.Create(ctx, promotion); err != nil {
+		s.logger.Error("Failed to create promotion", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Promotion created successfully", zap.String("promotion_id", promotion.ID))
+	return promotion, nil
+}
+
+// GetByID retrieves a promotion by ID
+func (s *service) GetByID(ctx context.Context, id string) (*Promotion, error) {
+	s.logger.Debug("Getting promotion", zap.String("promotion_id", id))
+
+	promotion, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get promotion", zap.String("promotion_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return promotion, nil
+}
+
+// List retrieves all promotions
+func (s *service) List(ctx context.Context) ([]*Promotion, error) {
+	s.logger.Debug("Listing promotions")
+
+	promotions, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list promotions", zap.Error(err))
+		return nil, err
+	}
+
+	return promotions, nil
+}
+
+// Update replaces a promotion's rules. The repository keeps its usage count, so redemptions
+// made while updating are not lost.
+func (s *service) Update(ctx context.Context, id string, req PromotionRequest) (*Promotion, error) {
+	s.logger.Info("Updating promotion", zap.String("promotion_id", id))
+
+	if err := validateRules(req); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to find promotion", zap.String("promotion_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	// Work on a copy so a rejected update does not leak into the stored promotion
+	promotion := *existing
+	applyRequest(&promotion, req, time.Now())
+
+	if err := s.repo.Update(ctx, &promotion); err != nil {
+		s.logger.Error("Failed to update promotion", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Promotion updated successfully", zap.String("promotion_id", id))
+	return &promotion, nil
+}
+
+// Delete deletes a promotion
+func (s *service) Delete(ctx context.Context, id string) error {
+	s.logger.Info("Deleting promotion", zap.String("promotion_id", id))
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		s.logger.Error("Failed to delete promotion", zap.String("promotion_id", id), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Promotion deleted successfully", zap.String("promotion_id", id))
+	return nil
+}
+
+// Apply evaluates all promotions against a cart
+func (s *service) Apply(ctx context.Context, cart Cart) (*Result, error) {
+	promotions, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list promotions", zap.Error(err))
+		return nil, err
+	}
+
+	usage := func(promotionID string) int {
+		count, err := s.repo.CountRedemptions(ctx, promotionID, cart.UserID)
+		if err != nil {
+			s.logger.Error("Failed to count redemptions", zap.String("promotion_id", promotionID), zap.Error(err))
+		}
+		return count
+	}
+
+	result := Evaluate(promotions, cart, usage, time.Now())
+	s.logger.Debug("Promotions evaluated",
+		zap.String("user_id", cart.UserID),
+		zap.Float64("discount_total", result.DiscountTotal),
+		zap.Strings("applied", result.AppliedPromotionIDs()),
+	)
+	return result, nil
+}
+
+// Redeem records that a user used the given promotions when placing an order. The
+// promotions are redeemed together: if any has reached its usage limits, none is redeemed
+// and ErrUsageLimitReached is returned.
+func (s *service) Redeem(ctx context.Context, userID string, promotionIDs []string) error {
+	if len(promotionIDs) == 0 {
+		return nil
+	}
+
+	if err := s.repo.RecordRedemptions(ctx, userID, promotionIDs); err != nil {
+		s.logger.Warn("Failed to redeem promotions", zap.String("user_id", userID), zap.Strings("promotion_ids", promotionIDs), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Promotions redeemed", zap.String("user_id", userID), zap.Strings("promotion_ids", promotionIDs))
+	return nil
+}
+
+// Release takes back a redemption of the given promotions, for an order that could not be
+// placed after redeeming them
+func (s *service) Release(ctx context.Context, userID string, promotionIDs []string) error {
+	if len(promotionIDs) == 0 {
+		return nil
+	}
+
+	if err := s.repo.ReleaseRedemptions(ctx, userID, promotionIDs); err != nil {
+		s.logger.Error("Failed to release promotions", zap.String("user_id", userID), zap.Strings("promotion_ids", promotionIDs), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Promotions released", zap.String("user_id", userID), zap.Strings("promotion_ids", promotionIDs))
+	return nil
+}
+
+// ExportPersonalData returns the promotions a user redeemed
+func (s *service) ExportPersonalData(ctx context.Context, userID string) (interface{}, error) {
+	redemptions, err := s.repo.ListUserRedemptions(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list redemptions", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return redemptions, nil
+}
+
+// ErasePersonalData forgets which promotions a user redeemed
+func (s *service) ErasePersonalData(ctx context.Context, userID string) error {
+	if err := s.repo.DeleteUserRedemptions(ctx, userID); err != nil {
+		s.logger.Error("Failed to delete redemptions", zap.String("user_id", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// validateRules checks the rule parameters that depend on the promotion type
+func validateRules(req PromotionRequest) error {
+	switch req.Type {
+	case TypePercentage:
+		if req.Value <= 0 || req.Value > 100 {
+			return ErrInvalidPromotion
+		}
+	case TypeFixedAmount:
+		if req.Value <= 0 {
+			return ErrInvalidPromotion
+		}
+	case TypeBuyXGetY:
+		if req.BuyQuantity < 1 || req.GetQuantity < 1 || req.Value > 100 {
+			return ErrInvalidPromotion
+		}
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return ErrInvalidPromotion
+	}
+	return nil
+}
+
+// applyRequest copies request fields onto a promotion
+func applyRequest(promotion *Promotion, req PromotionRequest, now time.Time) {
+	promotion.Code = strings.ToUpper(req.Code)
+	promotion.Name = req.Name
+	promotion.Description = req.Description
+	promotion.Type = req.Type
+	promotion.Value = req.Value
+	promotion.BuyQuantity = req.BuyQuantity
+	promotion.GetQuantity = req.GetQuantity
+	promotion.MinCartValue = req.MinCartValue
+	promotion.ProductIDs = req.ProductIDs
+	promotion.CategoryIDs = req.CategoryIDs
+	promotion.MaxUses = req.MaxUses
+	promotion.MaxUsesPerUser = req.MaxUsesPerUser
+	promotion.StartsAt = req.StartsAt
+	promotion.EndsAt = req.EndsAt
+	promotion.Stackable = req.Stackable
+	promotion.Priority = req.Priority
+	promotion.Active = req.Active
+	promotion.UpdatedAt = now
+}
@@ -0,0 +1,355 @@
+package promotion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestService() Service {
+	repo := NewInMemoryRepository()
+	logger, _ := zap.NewDevelopment()
+	return NewService(repo, logger)
+}
+
+func testCart(codes ...string) Cart {
+	return Cart{
+		UserID: "user-1",
+		Lines: []Line{
+			{ProductID: "shirt", CategoryID: "apparel", UnitPrice: 20, Quantity: 3},
+			{ProductID: "mug", CategoryID: "kitchen", UnitPrice: 10, Quantity: 2},
+		},
+		ShippingCost: 5,
+		Codes:        codes,
+	}
+}
+
+func findRule(t *testing.T, result *Result, name string) RuleOutcome {
+	t.Helper()
+	for _, rule := range result.Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	t.Fatalf("no rule outcome for %q in %+v", name, result.Rules)
+	return RuleOutcome{}
+}
+
+func TestEvaluate_DiscountTypes(t *testing.T) {
+	tests := []struct {
+		name             string
+		promotion        Promotion
+		wantLines        []float64
+		wantShipping     float64
+		wantFreeShipping bool
+	}{
+		{
+			name:      "percentage off whole cart",
+			promotion: Promotion{Type: TypePercentage, Value: 10},
+			wantLines: []float64{6, 2},
+		},
+		{
+			name:      "percentage off category",
+			promotion: Promotion{Type: TypePercentage, Value: 50, CategoryIDs: []string{"kitchen"}},
+			wantLines: []float64{0, 10},
+		},
+		{
+			name:      "fixed amount spread proportionally",
+			promotion: Promotion{Type: TypeFixedAmount, Value: 8},
+			wantLines: []float64{6, 2},
+		},
+		{
+			name:      "fixed amount capped at eligible total",
+			promotion: Promotion{Type: TypeFixedAmount, Value: 50, ProductIDs: []string{"mug"}},
+			wantLines: []float64{0, 20},
+		},
+		{
+			name:             "free shipping",
+			promotion:        Promotion{Type: TypeFreeShipping},
+			wantLines:        []float64{0, 0},
+			wantShipping:     5,
+			wantFreeShipping: true,
+		},
+		{
+			name:      "buy two get one free makes cheapest unit free",
+			promotion: Promotion{Type: TypeBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+			wantLines: []float64{0, 10},
+		},
+		{
+			name:      "buy one get one half price on targeted product",
+			promotion: Promotion{Type: TypeBuyXGetY, BuyQuantity: 1, GetQuantity: 1, Value: 50, ProductIDs: []string{"shirt"}},
+			wantLines: []float64{10, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.promotion
+			p.ID = "promo"
+			p.Name = tt.name
+			p.Active = true
+
+			result := Evaluate([]*Promotion{&p}, testCart(), nil, time.Now())
+
+			assert.Equal(t, 80.0, result.Subtotal)
+			assert.Equal(t, tt.wantLines, result.LineDiscounts)
+			assert.Equal(t, tt.wantLines[0]+tt.wantLines[1], result.DiscountTotal)
+			assert.Equal(t, tt.wantShipping, result.ShippingDiscount)
+			assert.Equal(t, tt.wantFreeShipping, result.FreeShipping)
+			assert.True(t, findRule(t, result, tt.name).Applied)
+		})
+	}
+}
+
+func TestEvaluate_Eligibility(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		promotion  Promotion
+		cart       Cart
+		usage      UsageLookup
+		wantReason string
+	}{
+		{
+			name:       "minimum cart value not met",
+			promotion:  Promotion{Code: "BIG", MinCartValue: 100},
+			cart:       testCart("big"),
+			wantReason: "cart subtotal must be at least 100.00",
+		},
+		{
+			name:       "not started",
+			promotion:  Promotion{Code: "SOON", StartsAt: &future},
+			cart:       testCart("SOON"),
+			wantReason: "promotion has not started yet",
+		},
+		{
+			name:       "expired",
+			promotion:  Promotion{Code: "OLD", EndsAt: &past},
+			cart:       testCart("OLD"),
+			wantReason: "promotion has expired",
+		},
+		{
+			name:       "global usage limit reached",
+			promotion:  Promotion{Code: "LIMITED", MaxUses: 5, UsageCount: 5},
+			cart:       testCart("LIMITED"),
+			wantReason: "promotion usage limit reached",
+		},
+		{
+			name:       "per-user usage limit reached",
+			promotion:  Promotion{Code: "ONCE", MaxUsesPerUser: 1},
+			cart:       testCart("ONCE"),
+			usage:      func(string) int { return 1 },
+			wantReason: "you have already used this promotion the maximum number of times",
+		},
+		{
+			name:       "no targeted items",
+			promotion:  Promotion{Code: "TOYS", CategoryIDs: []string{"toys"}},
+			cart:       testCart("TOYS"),
+			wantReason: "no eligible items in cart",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.promotion
+			p.ID = "promo"
+			p.Name = tt.name
+			p.Type = TypePercentage
+			p.Value = 10
+			p.Active = true
+
+			result := Evaluate([]*Promotion{&p}, tt.cart, tt.usage, now)
+
+			rule := findRule(t, result, tt.name)
+			assert.False(t, rule.Applied)
+			assert.Equal(t, tt.wantReason, rule.Reason)
+			assert.Zero(t, result.DiscountTotal)
+		})
+	}
+}
+
+func TestEvaluate_UnknownAndUnrequestedCodes(t *testing.T) {
+	p := &Promotion{ID: "promo", Code: "SECRET", Name: "Secret", Type: TypePercentage, Value: 10, Active: true}
+
+	result := Evaluate([]*Promotion{p}, testCart(), nil, time.Now())
+	assert.Zero(t, result.DiscountTotal)
+	assert.Empty(t, result.Rules)
+
+	result = Evaluate([]*Promotion{p}, testCart("nope"), nil, time.Now())
+	require.Len(t, result.Rules, 1)
+	assert.Equal(t, "NOPE", result.Rules[0].Code)
+	assert.Equal(t, "unknown promotion code", result.Rules[0].Reason)
+}
+
+func TestEvaluate_Stacking(t *testing.T) {
+	tenPercent := &Promotion{ID: "a", Name: "Ten percent", Type: TypePercentage, Value: 10, Stackable: true, Active: true, Priority: 2}
+	fiveOff := &Promotion{ID: "b", Name: "Five off", Type: TypeFixedAmount, Value: 5, Stackable: true, Active: true, Priority: 1}
+
+	t.Run("stackable promotions combine in priority order", func(t *testing.T) {
+		result := Evaluate([]*Promotion{fiveOff, tenPercent}, testCart(), nil, time.Now())
+
+		assert.Equal(t, 13.0, result.DiscountTotal)
+		assert.Equal(t, "Ten percent", result.Rules[0].Name)
+		assert.Equal(t, 8.0, result.Rules[0].Amount)
+		assert.Equal(t, "Five off", result.Rules[1].Name)
+		assert.Equal(t, 5.0, result.Rules[1].Amount)
+	})
+
+	t.Run("exclusive promotion wins when larger", func(t *testing.T) {
+		half := &Promotion{ID: "c", Code: "HALF", Name: "Half off", Type: TypePercentage, Value: 50, Active: true}
+		result := Evaluate([]*Promotion{tenPercent, fiveOff, half}, testCart("HALF"), nil, time.Now())
+
+		assert.Equal(t, 40.0, result.DiscountTotal)
+		assert.True(t, findRule(t, result, "Half off").Applied)
+		rejected := findRule(t, result, "Ten percent")
+		assert.False(t, rejected.Applied)
+		assert.Contains(t, rejected.Reason, "not combinable")
+	})
+
+	t.Run("stack wins over smaller exclusive promotion", func(t *testing.T) {
+		small := &Promotion{ID: "d", Code: "SMALL", Name: "Small", Type: TypeFixedAmount, Value: 1, Active: true}
+		result := Evaluate([]*Promotion{tenPercent, fiveOff, small}, testCart("SMALL"), nil, time.Now())
+
+		assert.Equal(t, 13.0, result.DiscountTotal)
+		assert.False(t, findRule(t, result, "Small").Applied)
+	})
+
+	t.Run("discounts never exceed line amounts", func(t *testing.T) {
+		all := &Promotion{ID: "e", Name: "Everything", Type: TypePercentage, Value: 100, Stackable: true, Active: true, Priority: 3}
+		result := Evaluate([]*Promotion{all, tenPercent, fiveOff}, testCart(), nil, time.Now())
+
+		assert.Equal(t, 80.0, result.DiscountTotal)
+		assert.Equal(t, []float64{60, 20}, result.LineDiscounts)
+	})
+}
+
+func TestService_CreateAndApply(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, PromotionRequest{
+		Code:           "welcome10",
+		Name:           "Welcome",
+		Type:           TypePercentage,
+		Value:          10,
+		MaxUsesPerUser: 1,
+		Active:         true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "WELCOME10", created.Code)
+
+	_, err = service.Create(ctx, PromotionRequest{Code: "WELCOME10", Name: "Duplicate", Type: TypeFreeShipping, Active: true})
+	assert.Equal(t, ErrCodeAlreadyExists, err)
+
+	result, err := service.Apply(ctx, testCart("Welcome10"))
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, result.DiscountTotal)
+	assert.Equal(t, []string{created.ID}, result.AppliedPromotionIDs())
+
+	require.NoError(t, service.Redeem(ctx, "user-1", result.AppliedPromotionIDs()))
+	assert.Equal(t, ErrUsageLimitReached, service.Redeem(ctx, "user-1", result.AppliedPromotionIDs()))
+
+	result, err = service.Apply(ctx, testCart("WELCOME10"))
+	require.NoError(t, err)
+	assert.Zero(t, result.DiscountTotal)
+	assert.False(t, result.Rules[0].Applied)
+
+	// Another user can still use the code
+	other := testCart("WELCOME10")
+	other.UserID = "user-2"
+	result, err = service.Apply(ctx, other)
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, result.DiscountTotal)
+}
+
+func TestService_InvalidRules(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		req  PromotionRequest
+	}{
+		{name: "percentage over 100", req: PromotionRequest{Name: "Bad", Type: TypePercentage, Value: 150}},
+		{name: "fixed amount without value", req: PromotionRequest{Name: "Bad", Type: TypeFixedAmount}},
+		{name: "buy x get y without quantities", req: PromotionRequest{Name: "Bad", Type: TypeBuyXGetY}},
+		{name: "window ends before it starts", req: PromotionRequest{Name: "Bad", Type: TypeFreeShipping, StartsAt: &now, EndsAt: &earlier}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Create(ctx, tt.req)
+			assert.Equal(t, ErrInvalidPromotion, err)
+		})
+	}
+}
+
+func TestService_UsageLimits(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+
+	limited, err := service.Create(ctx, PromotionRequest{Name: "Limited", Type: TypeFreeShipping, MaxUses: 2, Active: true})
+	require.NoError(t, err)
+	once, err := service.Create(ctx, PromotionRequest{Name: "Once", Type: TypeFixedAmount, Value: 5, MaxUsesPerUser: 1, Stackable: true, Active: true})
+	require.NoError(t, err)
+
+	require.NoError(t, service.Redeem(ctx, "user-1", []string{limited.ID}))
+
+	// Redeeming is all or nothing: once no longer had uses left for user-1, so limited is
+	// not counted either
+	require.NoError(t, service.Redeem(ctx, "user-1", []string{once.ID}))
+	assert.Equal(t, ErrUsageLimitReached, service.Redeem(ctx, "user-1", []string{limited.ID, once.ID}))
+	found, err := service.GetByID(ctx, limited.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, found.UsageCount)
+
+	// Updating the rules keeps the usage count
+	updated, err := service.Update(ctx, limited.ID, PromotionRequest{Name: "Limited", Type: TypeFreeShipping, MaxUses: 2, Active: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated.UsageCount)
+
+	result, err := service.Apply(ctx, testCart())
+	require.NoError(t, err)
+	assert.True(t, findRule(t, result, "Limited").Applied)
+	assert.False(t, findRule(t, result, "Once").Applied)
+
+	// A released redemption can be used again, a used up promotion no longer applies
+	require.NoError(t, service.Release(ctx, "user-1", []string{once.ID}))
+	require.NoError(t, service.Redeem(ctx, "user-2", []string{limited.ID}))
+	result, err = service.Apply(ctx, testCart())
+	require.NoError(t, err)
+	assert.False(t, findRule(t, result, "Limited").Applied)
+	assert.True(t, findRule(t, result, "Once").Applied)
+}
+
+func TestInMemoryRepository_ReturnsCopies(t *testing.T) {
+	repo := NewInMemoryRepository()
+	ctx := context.Background()
+
+	promotion := &Promotion{ID: "promo-1", Name: "Kitchen", ProductIDs: []string{"mug"}, CategoryIDs: []string{"kitchen"}}
+	require.NoError(t, repo.Create(ctx, promotion))
+	promotion.ProductIDs[0] = "changed"
+
+	found, err := repo.FindByID(ctx, "promo-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mug"}, found.ProductIDs)
+	found.CategoryIDs[0] = "changed"
+	require.NoError(t, repo.RecordRedemptions(ctx, "user-1", []string{"promo-1"}))
+
+	// A stale copy does not undo the redemption, and the stored rules are still its own
+	require.NoError(t, repo.Update(ctx, found))
+	found.CategoryIDs[0] = "kitchen"
+	stored, err := repo.FindByID(ctx, "promo-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.UsageCount)
+	assert.Equal(t, []string{"changed"}, stored.CategoryIDs)
+}
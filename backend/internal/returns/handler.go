@@ -0,0 +1,224 @@
+package returns
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+	"go.uber.org/zap"
+)
+
+// Handler handles HTTP requests for returns
+type Handler struct {
+	service   Service
+	validator *validator.Validate
+	logger    *zap.Logger
+}
+
+// NewHandler creates a new return handler
+func NewHandler(service Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service:   service,
+		validator: validator.New(),
+		logger:    logger,
+	}
+}
+
+// Create handles a customer requesting a return
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req CreateReturnRequest
+	if !h.decodeRequest(w, r, &req, false) {
+		return
+	}
+
+	ret, err := h.service.Create(r.Context(), userID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to create return")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, ret)
+}
+
+// List handles listing returns; staff who may read returns see every return, customers
+// only their own
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+	if authz.HasPermission(r.Context(), authz.ReturnRead) {
+		userID = r.URL.Query().Get("user_id")
+	}
+
+	returns, err := h.service.List(r.Context(), userID, r.URL.Query().Get("status"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to list returns")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, returns)
+}
+
+// GetByID handles getting a return with its status history
+func (h *Handler) GetByID(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+	ret, err := h.service.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get return")
+		return
+	}
+
+	// Hide other users' returns behind a 404 rather than revealing they exist
+	if ret.UserID != userID && !authz.HasPermission(r.Context(), authz.ReturnRead) {
+		response.WriteError(w, http.StatusNotFound, "RETURN_NOT_FOUND", "Return not found", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, ret)
+}
+
+// Cancel handles a customer withdrawing their return request
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Cancel, "Failed to cancel return")
+}
+
+// Approve handles an admin approving a return
+func (h *Handler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Approve, "Failed to approve return")
+}
+
+// Reject handles an admin rejecting a return
+func (h *Handler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Reject, "Failed to reject return")
+}
+
+// Receive handles an admin recording that returned items arrived
+func (h *Handler) Receive(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req ReceiveRequest
+	if !h.decodeRequest(w, r, &req, true) {
+		return
+	}
+
+	ret, err := h.service.Receive(r.Context(), chi.URLParam(r, "id"), adminID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to receive return")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, ret)
+}
+
+// Refund handles an admin refunding a received return
+func (h *Handler) Refund(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req RefundRequest
+	if !h.decodeRequest(w, r, &req, true) {
+		return
+	}
+
+	ret, err := h.service.Refund(r.Context(), chi.URLParam(r, "id"), adminID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to refund return")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, ret)
+}
+
+// decide handles status transitions that only carry an optional note
+func (h *Handler) decide(w http.ResponseWriter, r *http.Request, transition func(ctx context.Context, id, actorID string, req DecisionRequest) (*Return, error), logMessage string) {
+	actorID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req DecisionRequest
+	if !h.decodeRequest(w, r, &req, true) {
+		return
+	}
+
+	ret, err := transition(r.Context(), chi.URLParam(r, "id"), actorID, req)
+	if err != nil {
+		h.writeServiceError(w, err, logMessage)
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, ret)
+}
+
+// decodeRequest decodes and validates a request body into req. Bodies that only carry
+// optional fields may be omitted when allowEmpty is set.
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}, allowEmpty bool) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !(allowEmpty && err == io.EOF) {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return false
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return false
+	}
+
+	return true
+}
+
+// writeServiceError maps service errors to HTTP responses
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error, logMessage string) {
+	switch err {
+	case ErrReturnNotFound:
+		response.WriteError(w, http.StatusNotFound, "RETURN_NOT_FOUND", "Return not found", "")
+	case ErrOrderNotFound:
+		response.WriteError(w, http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found", "")
+	case ErrOrderNotReturnable:
+		response.WriteError(w, http.StatusConflict, "ORDER_NOT_RETURNABLE", "Order has not been delivered or its return window has closed", "")
+	case ErrInvalidReturnLine:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_RETURN_LINE", "Return lines must be on the order and not exceed the quantity left to return", "")
+	case ErrInvalidReturnState:
+		response.WriteError(w, http.StatusConflict, "INVALID_RETURN_STATE", "Operation not allowed in current return state", "")
+	case ErrInvalidRefundAmount, payment.ErrInvalidRefundAmount:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REFUND_AMOUNT", "Refund amount exceeds refundable amount", "")
+	case payment.ErrPaymentNotFound, payment.ErrInvalidPaymentState:
+		response.WriteError(w, http.StatusConflict, "PAYMENT_NOT_REFUNDABLE", "The order's payment cannot be refunded", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
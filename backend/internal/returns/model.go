@@ -0,0 +1,105 @@
+package returns
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrReturnNotFound is returned when a return is not found
+	ErrReturnNotFound = errors.New("return not found")
+	// ErrOrderNotFound is returned when the order being returned does not exist or belongs to someone else
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrOrderNotReturnable is returned when an order has not been delivered or its return window has closed
+	ErrOrderNotReturnable = errors.New("order is not eligible for return")
+	// ErrInvalidReturnLine is returned when a line is not on the order or more units are returned than remain
+	ErrInvalidReturnLine = errors.New("invalid return line")
+	// ErrInvalidReturnState is returned when a return cannot make the requested transition
+	ErrInvalidReturnState = errors.New("invalid return state")
+	// ErrInvalidRefundAmount is returned when a refund exceeds the value of the returned items
+	ErrInvalidRefundAmount = errors.New("invalid refund amount")
+)
+
+// Return statuses
+const (
+	StatusRequested = "requested"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusCancelled = "cancelled"
+	StatusReceived  = "received"
+	StatusRefunded  = "refunded"
+)
+
+// Actors recorded in the status history
+const (
+	ActorCustomer = "customer"
+	ActorAdmin    = "admin"
+)
+
+// Return represents a return merchandise authorization
+type Return struct {
+	ID             string         `json:"id"`
+	OrderID        string         `json:"order_id"`
+	UserID         string         `json:"user_id"`
+	Status         string         `json:"status"`
+	Lines          []Line         `json:"lines"`
+	ItemsValue     float64        `json:"items_value"`
+	RefundedAmount float64        `json:"refunded_amount"`
+	PaymentID      string         `json:"payment_id,omitempty"`
+	History        []StatusChange `json:"history"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// Line represents the units of one order line being returned
+type Line struct {
+	OrderLineID string  `json:"order_line_id"`
+	ProductID   string  `json:"product_id"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Reason      string  `json:"reason"`
+	Comment     string  `json:"comment,omitempty"`
+	Restocked   bool    `json:"restocked"`
+}
+
+// StatusChange represents an entry in a return's status history
+type StatusChange struct {
+	Status    string    `json:"status"`
+	Actor     string    `json:"actor"`
+	ActorID   string    `json:"actor_id"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateReturnRequest represents a customer's request to return order lines
+type CreateReturnRequest struct {
+	OrderID string        `json:"order_id" validate:"required"`
+	Lines   []LineRequest `json:"lines" validate:"required,min=1,max=100,dive"`
+}
+
+// LineRequest represents units of an order line to return
+type LineRequest struct {
+	OrderLineID string `json:"order_line_id" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"required,min=1"`
+	Reason      string `json:"reason" validate:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+	Comment     string `json:"comment" validate:"max=500"`
+}
+
+// DecisionRequest represents a note attached to a status transition
+type DecisionRequest struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+// ReceiveRequest represents the receipt of returned items. Lines listed in SkipRestock
+// are not put back into inventory, e.g. because they arrived damaged.
+type ReceiveRequest struct {
+	Note        string   `json:"note" validate:"max=500"`
+	SkipRestock []string `json:"skip_restock" validate:"max=100"`
+}
+
+// RefundRequest represents a refund for a received return. A zero amount refunds the
+// full value of the returned items.
+type RefundRequest struct {
+	Amount float64 `json:"amount" validate:"gte=0"`
+	Note   string  `json:"note" validate:"max=500"`
+}
@@ -0,0 +1,29 @@
+package returns
+
+import (
+	"context"
+	"time"
+)
+
+// OrderReader looks up the orders that returns are raised against. It is implemented by
+// the order domain and returns ErrOrderNotFound for unknown orders.
+type OrderReader interface {
+	GetOrder(ctx context.Context, orderID string) (*Order, error)
+}
+
+// Order represents the parts of an order a return needs
+type Order struct {
+	ID          string
+	UserID      string
+	PaymentID   string
+	DeliveredAt *time.Time
+	Lines       []OrderLine
+}
+
+// OrderLine represents a purchased product on an order
+type OrderLine struct {
+	ID        string
+	ProductID string
+	Quantity  int
+	UnitPrice float64
+}
@@ -0,0 +1,101 @@
+package returns
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository defines the interface for return data access
+type Repository interface {
+	Create(ctx context.Context, ret *Return) error
+	FindByID(ctx context.Context, id string) (*Return, error)
+	List(ctx context.Context, userID, status string) ([]*Return, error)
+	ListByOrder(ctx context.Context, orderID string) ([]*Return, error)
+	Update(ctx context.Context, ret *Return) error
+}
+
+// InMemoryRepository implements Repository using in-memory storage
+type InMemoryRepository struct {
+	returns map[string]*Return
+	mutex   sync.RWMutex
+}
+
+// NewInMemoryRepository creates a new in-memory repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		returns: make(map[string]*Return),
+	}
+}
+
+// Create creates a new return
+func (r *InMemoryRepository) Create(ctx context.Context, ret *Return) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.returns[ret.ID] = ret
+	return nil
+}
+
+// FindByID finds a return by ID
+func (r *InMemoryRepository) FindByID(ctx context.Context, id string) (*Return, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ret, exists := r.returns[id]
+	if !exists {
+		return nil, ErrReturnNotFound
+	}
+
+	return ret, nil
+}
+
+// List lists returns newest first, optionally filtered by user and status
+func (r *InMemoryRepository) List(ctx context.Context, userID, status string) ([]*Return, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	returns := make([]*Return, 0)
+	for _, ret := range r.returns {
+		if userID != "" && ret.UserID != userID {
+			continue
+		}
+		if status != "" && ret.Status != status {
+			continue
+		}
+		returns = append(returns, ret)
+	}
+	sort.Slice(returns, func(i, j int) bool {
+		return returns[i].CreatedAt.After(returns[j].CreatedAt)
+	})
+
+	return returns, nil
+}
+
+// ListByOrder lists all returns raised against an order
+func (r *InMemoryRepository) ListByOrder(ctx context.Context, orderID string) ([]*Return, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	returns := make([]*Return, 0)
+	for _, ret := range r.returns {
+		if ret.OrderID == orderID {
+			returns = append(returns, ret)
+		}
+	}
+
+	return returns, nil
+}
+
+// Update updates a return
+func (r *InMemoryRepository) Update(ctx context.Context, ret *Return) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.returns[ret.ID]; !exists {
+		return ErrReturnNotFound
+	}
+
+	r.returns[ret.ID] = ret
+	return nil
+}
@@ -0,0 +1,385 @@
+package returns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/money"
+	"go.uber.org/zap"
+)
+
+// Service defines the interface for return business logic
+type Service interface {
+	Create(ctx context.Context, userID string, req CreateReturnRequest) (*Return, error)
+	GetByID(ctx context.Context, id string) (*Return, error)
+	List(ctx context.Context, userID, status string) ([]*Return, error)
+	Cancel(ctx context.Context, id, userID string, req DecisionRequest) (*Return, error)
+	Approve(ctx context.Context, id, adminID string, req DecisionRequest) (*Return, error)
+	Reject(ctx context.Context, id, adminID string, req DecisionRequest) (*Return, error)
+	Receive(ctx context.Context, id, adminID string, req ReceiveRequest) (*Return, error)
+	Refund(ctx context.Context, id, adminID string, req RefundRequest) (*Return, error)
+	ExportPersonalData(ctx context.Context, userID string) (interface{}, error)
+	ErasePersonalData(ctx context.Context, userID string) error
+}
+
+// service implements Service
+type service struct {
+	repo           Repository
+	orders         OrderReader
+	productService product.Service
+	paymentService payment.Service
+	window         time.Duration
+	logger         *zap.Logger
+	// mutex serializes status transitions and the returnable quantity check
+	mutex sync.Mutex
+}
+
+// NewService creates a new return service. Orders can be returned for window after delivery;
+// a zero window never closes.
+func NewService(repo Repository, orders OrderReader, productService product.Service, paymentService payment.Service, window time.Duration, logger *zap.Logger) Service {
+	return &service{
+		repo:           repo,
+		orders:         orders,
+		productService: productService,
+		paymentService: paymentService,
+		window:         window,
+		logger:         logger,
+	}
+}
+
+// Create opens a return for lines of a delivered order
+func (s *service) Create(ctx context.Context, userID string, req CreateReturnRequest) (*Return, error) {
+	s.logger.Info("Creating return", zap.String("order_id", req.OrderID), zap.String("user_id", userID))
+
+	order, err := s.orders.GetOrder(ctx, req.OrderID)
+	if err != nil {
+		s.logger.Debug("Failed to get order", zap.String("order_id", req.OrderID), zap.Error(err))
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, ErrOrderNotFound
+	}
+
+	now := time.Now()
+	if order.DeliveredAt == nil || (s.window > 0 && now.After(order.DeliveredAt.Add(s.window))) {
+		return nil, ErrOrderNotReturnable
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	remaining, err := s.returnableQuantities(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	orderLines := make(map[string]OrderLine, len(order.Lines))
+	for _, line := range order.Lines {
+		orderLines[line.ID] = line
+	}
+
+	ret := &Return{
+		ID:        uuid.New().String(),
+		OrderID:   order.ID,
+		UserID:    userID,
+		Status:    StatusRequested,
+		Lines:     make([]Line, 0, len(req.Lines)),
+		PaymentID: order.PaymentID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, requested := range req.Lines {
+		orderLine, exists := orderLines[requested.OrderLineID]
+		if !exists || requested.Quantity > remaining[requested.OrderLineID] {
+			return nil, ErrInvalidReturnLine
+		}
+		// Guard against the same line being listed twice in one request
+		remaining[requested.OrderLineID] -= requested.Quantity
+
+		ret.Lines = append(ret.Lines, Line{
+			OrderLineID: orderLine.ID,
+			ProductID:   orderLine.ProductID,
+			Quantity:    requested.Quantity,
+			UnitPrice:   orderLine.UnitPrice,
+			Reason:      requested.Reason,
+			Comment:     requested.Comment,
+		})
+		ret.ItemsValue += orderLine.UnitPrice * float64(requested.Quantity)
+	}
+	ret.ItemsValue = money.Round(ret.ItemsValue)
+	ret.History = []StatusChange{{Status: StatusRequested, Actor: ActorCustomer, ActorID: userID, CreatedAt: now}}
+
+	if err := s.repo.Create(ctx, ret); err != nil {
+		s.logger.Error("Failed to create return", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Return created successfully", zap.String("return_id", ret.ID))
+	return ret, nil
+}
+
+// GetByID retrieves a return by ID
+func (s *service) GetByID(ctx context.Context, id string) (*Return, error) {
+	s.logger.Debug("Getting return", zap.String("return_id", id))
+
+	ret, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Debug("Failed to get return", zap.String("return_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// List retrieves returns, optionally filtered by user and status
+func (s *service) List(ctx context.Context, userID, status string) ([]*Return, error) {
+	s.logger.Debug("Listing returns", zap.String("user_id", userID), zap.String("status", status))
+
+	returns, err := s.repo.List(ctx, userID, status)
+	if err != nil {
+		s.logger.Error("Failed to list returns", zap.Error(err))
+		return nil, err
+	}
+
+	return returns, nil
+}
+
+// Cancel withdraws a return the customer has not yet had approved
+func (s *service) Cancel(ctx context.Context, id, userID string, req DecisionRequest) (*Return, error) {
+	return s.transition(ctx, id, func(ret *Return) error {
+		if ret.UserID != userID {
+			return ErrReturnNotFound
+		}
+		if ret.Status != StatusRequested {
+			return ErrInvalidReturnState
+		}
+		ret.record(StatusCancelled, ActorCustomer, userID, req.Note)
+		return nil
+	})
+}
+
+// Approve authorizes the customer to send the items back
+func (s *service) Approve(ctx context.Context, id, adminID string, req DecisionRequest) (*Return, error) {
+	return s.transition(ctx, id, func(ret *Return) error {
+		if ret.Status != StatusRequested {
+			return ErrInvalidReturnState
+		}
+		ret.record(StatusApproved, ActorAdmin, adminID, req.Note)
+		return nil
+	})
+}
+
+// Reject declines a return request
+func (s *service) Reject(ctx context.Context, id, adminID string, req DecisionRequest) (*Return, error) {
+	return s.transition(ctx, id, func(ret *Return) error {
+		if ret.Status != StatusRequested {
+			return ErrInvalidReturnState
+		}
+		ret.record(StatusRejected, ActorAdmin, adminID, req.Note)
+		return nil
+	})
+}
+
+// Receive records that the items arrived and puts them back into inventory. Stock added
+// for a return that then fails to save is taken out again, so receiving can be retried.
+func (s *service) Receive(ctx context.Context, id, adminID string, req ReceiveRequest) (*Return, error) {
+	skip := make(map[string]bool, len(req.SkipRestock))
+	for _, lineID := range req.SkipRestock {
+		skip[lineID] = true
+	}
+
+	var restocked []Line
+	ret, err := s.transition(ctx, id, func(ret *Return) error {
+		if ret.Status != StatusApproved {
+			return ErrInvalidReturnState
+		}
+
+		for i := range ret.Lines {
+			line := &ret.Lines[i]
+			if skip[line.OrderLineID] {
+				continue
+			}
+			if _, err := s.productService.AdjustStock(ctx, line.ProductID, line.Quantity); err != nil {
+				// The product may have been removed from the catalog since it was sold
+				s.logger.Warn("Failed to restock returned item", zap.String("return_id", ret.ID), zap.String("product_id", line.ProductID), zap.Error(err))
+				continue
+			}
+			line.Restocked = true
+			restocked = append(restocked, *line)
+		}
+
+		ret.record(StatusReceived, ActorAdmin, adminID, req.Note)
+		return nil
+	})
+	if err != nil {
+		for _, line := range restocked {
+			if _, err := s.productService.AdjustStock(ctx, line.ProductID, -line.Quantity); err != nil {
+				s.logger.Error("Failed to undo restocking returned item", zap.String("return_id", id), zap.String("product_id", line.ProductID), zap.Error(err))
+			}
+		}
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Refund refunds a received return through the payment provider. The return's ID is the
+// refund's idempotency key: if the refund is made but the return then fails to save, it
+// stays received and refunding it again records the refund already made rather than
+// making another.
+func (s *service) Refund(ctx context.Context, id, adminID string, req RefundRequest) (*Return, error) {
+	refunded := false
+	ret, err := s.transition(ctx, id, func(ret *Return) error {
+		if ret.Status != StatusReceived || ret.PaymentID == "" {
+			return ErrInvalidReturnState
+		}
+
+		amount := req.Amount
+		if amount == 0 {
+			amount = ret.ItemsValue
+		}
+		if amount > ret.ItemsValue {
+			return ErrInvalidRefundAmount
+		}
+
+		key := refundKey(ret.ID)
+		paid, err := s.paymentService.Refund(ctx, ret.PaymentID, payment.RefundRequest{Amount: amount, IdempotencyKey: key})
+		if err != nil {
+			s.logger.Error("Failed to refund return", zap.String("return_id", ret.ID), zap.String("payment_id", ret.PaymentID), zap.Error(err))
+			return err
+		}
+		// A retried refund may have been made for another amount the first time
+		if refund := paid.FindRefund(key); refund != nil {
+			amount = refund.Amount
+		}
+
+		refunded = true
+		ret.RefundedAmount = money.Round(amount)
+		ret.record(StatusRefunded, ActorAdmin, adminID, req.Note)
+		return nil
+	})
+	if err != nil {
+		if refunded {
+			s.logger.Error("Return refunded but not saved; refunding it again records the refund", zap.String("return_id", id))
+		}
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ExportPersonalData returns the returns a user requested, with their history
+func (s *service) ExportPersonalData(ctx context.Context, userID string) (interface{}, error) {
+	returns, err := s.repo.List(ctx, userID, "")
+	if err != nil {
+		s.logger.Error("Failed to list returns", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return returns, nil
+}
+
+// ErasePersonalData unlinks the returns of a user from them and drops the comments and
+// notes they wrote. The returns themselves are kept as records of the refunds made.
+func (s *service) ErasePersonalData(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	returns, err := s.repo.List(ctx, userID, "")
+	if err != nil {
+		s.logger.Error("Failed to list returns", zap.String("user_id", userID), zap.Error(err))
+		return err
+	}
+
+	for _, found := range returns {
+		ret := *found
+		ret.UserID = ""
+		ret.Lines = append([]Line(nil), found.Lines...)
+		for i := range ret.Lines {
+			ret.Lines[i].Comment = ""
+		}
+		ret.History = append([]StatusChange(nil), found.History...)
+		for i, change := range ret.History {
+			if change.ActorID == userID {
+				ret.History[i].ActorID = ""
+				ret.History[i].Note = ""
+			}
+		}
+		ret.UpdatedAt = time.Now()
+		if err := s.repo.Update(ctx, &ret); err != nil {
+			s.logger.Error("Failed to anonymize return", zap.String("return_id", ret.ID), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// transition applies change to a copy of a return and stores it if change succeeds
+func (s *service) transition(ctx context.Context, id string, change func(ret *Return) error) (*Return, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := *existing
+	ret.Lines = append([]Line(nil), existing.Lines...)
+	ret.History = append([]StatusChange(nil), existing.History...)
+	if err := change(&ret); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, &ret); err != nil {
+		s.logger.Error("Failed to update return", zap.String("return_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Return status changed", zap.String("return_id", id), zap.String("status", ret.Status))
+	return &ret, nil
+}
+
+// returnableQuantities returns how many units of each order line can still be returned.
+// Must be called with the mutex held.
+func (s *service) returnableQuantities(ctx context.Context, order *Order) (map[string]int, error) {
+	remaining := make(map[string]int, len(order.Lines))
+	for _, line := range order.Lines {
+		remaining[line.ID] += line.Quantity
+	}
+
+	existing, err := s.repo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ret := range existing {
+		if ret.Status == StatusRejected || ret.Status == StatusCancelled {
+			continue
+		}
+		for _, line := range ret.Lines {
+			remaining[line.OrderLineID] -= line.Quantity
+		}
+	}
+
+	return remaining, nil
+}
+
+// refundKey returns the idempotency key of the refund for the return with the given ID
+func refundKey(returnID string) string {
+	return "return:" + returnID
+}
+
+// record moves a return to status and appends the change to its history
+func (r *Return) record(status, actor, actorID, note string) {
+	now := time.Now()
+	r.Status = status
+	r.UpdatedAt = now
+	r.History = append(r.History, StatusChange{
+		Status:    status,
+		Actor:     actor,
+		ActorID:   actorID,
+		Note:      note,
+		CreatedAt: now,
+	})
+}
@@ -0,0 +1,285 @@
+package returns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+)
+
+// stubOrders serves orders from a map
+type stubOrders map[string]*Order
+
+func (o stubOrders) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+	order, exists := o[orderID]
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// flakyRepository fails updates while failing is set
+type flakyRepository struct {
+	Repository
+	failing bool
+}
+
+func (r *flakyRepository) Update(ctx context.Context, ret *Return) error {
+	if r.failing {
+		return errors.New("storage unavailable")
+	}
+	return r.Repository.Update(ctx, ret)
+}
+
+type testFixture struct {
+	service        Service
+	repo           *flakyRepository
+	orders         stubOrders
+	productService product.Service
+	paymentService payment.Service
+	product        *product.Product
+}
+
+func setupTestService(t *testing.T) testFixture {
+	t.Helper()
+	logger, _ := zap.NewDevelopment()
+	ctx := context.Background()
+
+	productService := product.NewService(product.NewInMemoryRepository(), logger)
+	paymentService := payment.NewService(payment.NewInMemoryRepository(), payment.NewMockProvider("test-webhook-secret", 0), nil, logger)
+
+	mug, err := productService.Create(ctx, product.CreateProductRequest{Name: "Mug", Price: 12.5, Stock: 3, CategoryID: "kitchen"})
+	require.NoError(t, err)
+
+	paid, err := paymentService.Authorize(ctx, "user-1", payment.CreatePaymentRequest{
+		OrderID:       "order-1",
+		Amount:        62.5,
+		Currency:      "usd",
+		PaymentMethod: payment.MockMethodSuccess,
+	})
+	require.NoError(t, err)
+	_, err = paymentService.Capture(ctx, paid.ID)
+	require.NoError(t, err)
+
+	delivered := time.Now().Add(-48 * time.Hour)
+	longAgo := time.Now().Add(-60 * 24 * time.Hour)
+	orders := stubOrders{
+		"order-1": {
+			ID:          "order-1",
+			UserID:      "user-1",
+			PaymentID:   paid.ID,
+			DeliveredAt: &delivered,
+			Lines: []OrderLine{
+				{ID: "line-1", ProductID: mug.ID, Quantity: 3, UnitPrice: 12.5},
+				{ID: "line-2", ProductID: "discontinued", Quantity: 1, UnitPrice: 25},
+			},
+		},
+		"undelivered": {ID: "undelivered", UserID: "user-1", Lines: []OrderLine{{ID: "line-1", ProductID: mug.ID, Quantity: 1}}},
+		"expired":     {ID: "expired", UserID: "user-1", DeliveredAt: &longAgo, Lines: []OrderLine{{ID: "line-1", ProductID: mug.ID, Quantity: 1}}},
+	}
+
+	repo := &flakyRepository{Repository: NewInMemoryRepository()}
+	return testFixture{
+		service:        NewService(repo, orders, productService, paymentService, 30*24*time.Hour, logger),
+		repo:           repo,
+		orders:         orders,
+		productService: productService,
+		paymentService: paymentService,
+		product:        mug,
+	}
+}
+
+func returnRequest(orderID string, lines ...LineRequest) CreateReturnRequest {
+	return CreateReturnRequest{OrderID: orderID, Lines: lines}
+}
+
+func TestService_Create(t *testing.T) {
+	f := setupTestService(t)
+	ctx := context.Background()
+
+	ret, err := f.service.Create(ctx, "user-1", returnRequest("order-1",
+		LineRequest{OrderLineID: "line-1", Quantity: 2, Reason: "damaged", Comment: "Chipped"},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, StatusRequested, ret.Status)
+	assert.Equal(t, 25.0, ret.ItemsValue)
+	require.Len(t, ret.History, 1)
+	assert.Equal(t, ActorCustomer, ret.History[0].Actor)
+
+	tests := []struct {
+		name    string
+		userID  string
+		req     CreateReturnRequest
+		wantErr error
+	}{
+		{
+			name:    "order of another user",
+			userID:  "user-2",
+			req:     returnRequest("order-1", LineRequest{OrderLineID: "line-1", Quantity: 1, Reason: "other"}),
+			wantErr: ErrOrderNotFound,
+		},
+		{
+			name:    "unknown order",
+			userID:  "user-1",
+			req:     returnRequest("missing", LineRequest{OrderLineID: "line-1", Quantity: 1, Reason: "other"}),
+			wantErr: ErrOrderNotFound,
+		},
+		{
+			name:    "not delivered",
+			userID:  "user-1",
+			req:     returnRequest("undelivered", LineRequest{OrderLineID: "line-1", Quantity: 1, Reason: "other"}),
+			wantErr: ErrOrderNotReturnable,
+		},
+		{
+			name:    "return window closed",
+			userID:  "user-1",
+			req:     returnRequest("expired", LineRequest{OrderLineID: "line-1", Quantity: 1, Reason: "other"}),
+			wantErr: ErrOrderNotReturnable,
+		},
+		{
+			name:    "unknown line",
+			userID:  "user-1",
+			req:     returnRequest("order-1", LineRequest{OrderLineID: "line-9", Quantity: 1, Reason: "other"}),
+			wantErr: ErrInvalidReturnLine,
+		},
+		{
+			name:    "more than remains after earlier return",
+			userID:  "user-1",
+			req:     returnRequest("order-1", LineRequest{OrderLineID: "line-1", Quantity: 2, Reason: "other"}),
+			wantErr: ErrInvalidReturnLine,
+		},
+		{
+			name:   "same line listed twice",
+			userID: "user-1",
+			req: returnRequest("order-1",
+				LineRequest{OrderLineID: "line-1", Quantity: 1, Reason: "other"},
+				LineRequest{OrderLineID: "line-1", Quantity: 1, Reason: "other"},
+			),
+			wantErr: ErrInvalidReturnLine,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ret, err := f.service.Create(ctx, tt.userID, tt.req)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Nil(t, ret)
+		})
+	}
+
+	// Cancelling the first return frees its units again
+	_, err = f.service.Cancel(ctx, ret.ID, "user-1", DecisionRequest{})
+	require.NoError(t, err)
+	_, err = f.service.Create(ctx, "user-1", returnRequest("order-1", LineRequest{OrderLineID: "line-1", Quantity: 3, Reason: "other"}))
+	assert.NoError(t, err)
+}
+
+func TestService_Workflow(t *testing.T) {
+	f := setupTestService(t)
+	ctx := context.Background()
+
+	ret, err := f.service.Create(ctx, "user-1", returnRequest("order-1",
+		LineRequest{OrderLineID: "line-1", Quantity: 2, Reason: "no_longer_needed"},
+		LineRequest{OrderLineID: "line-2", Quantity: 1, Reason: "defective"},
+	))
+	require.NoError(t, err)
+
+	_, err = f.service.Receive(ctx, ret.ID, "admin-1", ReceiveRequest{})
+	assert.Equal(t, ErrInvalidReturnState, err)
+
+	ret, err = f.service.Approve(ctx, ret.ID, "admin-1", DecisionRequest{Note: "Ship it back"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusApproved, ret.Status)
+
+	_, err = f.service.Cancel(ctx, ret.ID, "user-1", DecisionRequest{})
+	assert.Equal(t, ErrInvalidReturnState, err)
+
+	// A return that cannot be saved as received does not restock anything
+	f.repo.failing = true
+	_, err = f.service.Receive(ctx, ret.ID, "admin-1", ReceiveRequest{})
+	assert.Error(t, err)
+	f.repo.failing = false
+	mug, err := f.productService.GetByID(ctx, f.product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, mug.Stock)
+
+	ret, err = f.service.Receive(ctx, ret.ID, "admin-1", ReceiveRequest{})
+	require.NoError(t, err)
+	assert.True(t, ret.Lines[0].Restocked)
+	// The second line's product no longer exists, so it cannot be restocked
+	assert.False(t, ret.Lines[1].Restocked)
+
+	mug, err = f.productService.GetByID(ctx, f.product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 5, mug.Stock)
+
+	_, err = f.service.Refund(ctx, ret.ID, "admin-1", RefundRequest{Amount: 60})
+	assert.Equal(t, ErrInvalidRefundAmount, err)
+
+	// A refund made for a return that then fails to save is not made again on retry,
+	// even for another amount
+	f.repo.failing = true
+	_, err = f.service.Refund(ctx, ret.ID, "admin-1", RefundRequest{Amount: 40, Note: "Restocking fee"})
+	assert.Error(t, err)
+	f.repo.failing = false
+	ret, err = f.service.GetByID(ctx, ret.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusReceived, ret.Status)
+
+	ret, err = f.service.Refund(ctx, ret.ID, "admin-1", RefundRequest{Amount: 45, Note: "Restocking fee"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusRefunded, ret.Status)
+	assert.Equal(t, 40.0, ret.RefundedAmount)
+
+	paid, err := f.paymentService.GetByID(ctx, ret.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusPartiallyRefunded, paid.Status)
+	assert.Equal(t, 40.0, paid.RefundedAmount)
+	assert.Len(t, paid.Refunds, 1)
+
+	statuses := make([]string, len(ret.History))
+	for i, change := range ret.History {
+		statuses[i] = change.Status
+	}
+	assert.Equal(t, []string{StatusRequested, StatusApproved, StatusReceived, StatusRefunded}, statuses)
+	assert.Equal(t, "Restocking fee", ret.History[3].Note)
+}
+
+func TestService_SkipRestockAndReject(t *testing.T) {
+	f := setupTestService(t)
+	ctx := context.Background()
+
+	ret, err := f.service.Create(ctx, "user-1", returnRequest("order-1", LineRequest{OrderLineID: "line-1", Quantity: 1, Reason: "damaged"}))
+	require.NoError(t, err)
+	_, err = f.service.Approve(ctx, ret.ID, "admin-1", DecisionRequest{})
+	require.NoError(t, err)
+
+	ret, err = f.service.Receive(ctx, ret.ID, "admin-1", ReceiveRequest{SkipRestock: []string{"line-1"}})
+	require.NoError(t, err)
+	assert.False(t, ret.Lines[0].Restocked)
+
+	mug, err := f.productService.GetByID(ctx, f.product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, mug.Stock)
+
+	other, err := f.service.Create(ctx, "user-1", returnRequest("order-1", LineRequest{OrderLineID: "line-2", Quantity: 1, Reason: "other"}))
+	require.NoError(t, err)
+	other, err = f.service.Reject(ctx, other.ID, "admin-1", DecisionRequest{Note: "Final sale"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusRejected, other.Status)
+
+	_, err = f.service.Refund(ctx, other.ID, "admin-1", RefundRequest{})
+	assert.Equal(t, ErrInvalidReturnState, err)
+
+	mine, err := f.service.List(ctx, "user-1", StatusRejected)
+	require.NoError(t, err)
+	require.Len(t, mine, 1)
+	assert.Equal(t, other.ID, mine[0].ID)
+}
@@ -0,0 +1,314 @@
+package seller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+	"go.uber.org/zap"
+)
+
+// Handler handles HTTP requests for seller profiles, their review and the products
+// sellers manage
+type Handler struct {
+	service   Service
+	validator *validator.Validate
+	logger    *zap.Logger
+}
+
+// NewHandler creates a new seller handler
+func NewHandler(service Service, logger *zap.Logger) *Handler {
+	return &Handler{
+		service:   service,
+		validator: validator.New(),
+		logger:    logger,
+	}
+}
+
+// Apply handles a user applying to sell
+func (h *Handler) Apply(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req ProfileRequest
+	if !h.decodeRequest(w, r, &req, false) {
+		return
+	}
+
+	seller, err := h.service.Apply(r.Context(), userID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to apply to sell")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, seller)
+}
+
+// GetProfile handles a seller viewing their profile and the status of their application
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	seller, err := h.service.Get(r.Context(), userID)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get seller")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, seller)
+}
+
+// UpdateProfile handles a seller changing their profile
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req ProfileRequest
+	if !h.decodeRequest(w, r, &req, false) {
+		return
+	}
+
+	seller, err := h.service.UpdateProfile(r.Context(), userID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to update seller")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, seller)
+}
+
+// ListOwnProducts handles a seller listing their products, taking the filters of the
+// product list
+func (h *Handler) ListOwnProducts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	products, err := h.service.ListProducts(r.Context(), userID, product.FiltersFromQuery(r.URL.Query()))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to list seller products")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, products)
+}
+
+// CreateProduct handles a seller listing a new product
+func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req product.CreateProductRequest
+	if !h.decodeRequest(w, r, &req, false) {
+		return
+	}
+
+	created, err := h.service.CreateProduct(r.Context(), userID, req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to create seller product")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, created)
+}
+
+// UpdateProduct handles a seller changing one of their products
+func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req product.UpdateProductRequest
+	if !h.decodeRequest(w, r, &req, false) {
+		return
+	}
+
+	updated, err := h.service.UpdateProduct(r.Context(), userID, chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to update seller product")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, updated)
+}
+
+// DeleteProduct handles a seller removing one of their products
+func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.DeleteProduct(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		h.writeServiceError(w, err, "Failed to delete seller product")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetStorefront handles anyone viewing an approved seller
+func (h *Handler) GetStorefront(w http.ResponseWriter, r *http.Request) {
+	storefront, err := h.service.GetStorefront(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get storefront")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, storefront)
+}
+
+// ListStorefrontProducts handles anyone listing the products of an approved seller
+func (h *Handler) ListStorefrontProducts(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := h.service.GetStorefront(r.Context(), id); err != nil {
+		h.writeServiceError(w, err, "Failed to get storefront")
+		return
+	}
+
+	products, err := h.service.ListProducts(r.Context(), id, product.FiltersFromQuery(r.URL.Query()))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to list seller products")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, products)
+}
+
+// List handles staff listing sellers, optionally by status, e.g. to review pending ones
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	sellers, err := h.service.List(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to list sellers")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, sellers)
+}
+
+// GetByID handles staff viewing a seller profile
+func (h *Handler) GetByID(w http.ResponseWriter, r *http.Request) {
+	seller, err := h.service.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to get seller")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, seller)
+}
+
+// Approve handles an admin approving a seller application or reinstating a suspended seller
+func (h *Handler) Approve(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	seller, err := h.service.Approve(r.Context(), chi.URLParam(r, "id"), adminID)
+	if err != nil {
+		h.writeServiceError(w, err, "Failed to approve seller")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, seller)
+}
+
+// Reject handles an admin declining a seller application
+func (h *Handler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Reject, "Failed to reject seller")
+}
+
+// Suspend handles an admin stopping a seller from selling
+func (h *Handler) Suspend(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Suspend, "Failed to suspend seller")
+}
+
+// decide handles status transitions that carry an optional reason
+func (h *Handler) decide(w http.ResponseWriter, r *http.Request, transition func(ctx context.Context, id, adminID string, req DecisionRequest) (*Seller, error), logMessage string) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req DecisionRequest
+	if !h.decodeRequest(w, r, &req, true) {
+		return
+	}
+
+	seller, err := transition(r.Context(), chi.URLParam(r, "id"), adminID, req)
+	if err != nil {
+		h.writeServiceError(w, err, logMessage)
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, seller)
+}
+
+// decodeRequest decodes and validates a request body into req. Bodies that only carry
+// optional fields may be omitted when allowEmpty is set.
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}, allowEmpty bool) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !(allowEmpty && err == io.EOF) {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return false
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return false
+	}
+
+	return true
+}
+
+// writeServiceError maps service errors to HTTP responses
+func (h *Handler) writeServiceError(w http.ResponseWriter, err error, logMessage string) {
+	switch err {
+	case ErrSellerNotFound:
+		response.WriteError(w, http.StatusNotFound, "SELLER_NOT_FOUND", "Seller not found", "")
+	case ErrSellerExists:
+		response.WriteError(w, http.StatusConflict, "SELLER_EXISTS", "A seller application is already pending or approved", "")
+	case ErrInvalidSellerState:
+		response.WriteError(w, http.StatusConflict, "INVALID_SELLER_STATE", "Operation not allowed in current seller state", "")
+	case ErrSellerNotApproved:
+		response.WriteError(w, http.StatusForbidden, "SELLER_NOT_APPROVED", "Only approved sellers can manage products", "")
+	case product.ErrProductNotFound:
+		response.WriteError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND", "Product not found", "")
+	case product.ErrNotProductOwner:
+		response.WriteError(w, http.StatusForbidden, "NOT_PRODUCT_OWNER", "Sellers can only change their own products", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
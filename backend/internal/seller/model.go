@@ -0,0 +1,64 @@
+package seller
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSellerNotFound is returned when a user has not applied to sell, or when a storefront
+	// is looked up for a seller who is not approved
+	ErrSellerNotFound = errors.New("seller not found")
+	// ErrSellerExists is returned when a user applies to sell again while their application
+	// is pending or after it was approved
+	ErrSellerExists = errors.New("seller already exists")
+	// ErrInvalidSellerState is returned when a seller cannot make the requested transition
+	ErrInvalidSellerState = errors.New("invalid seller state")
+	// ErrSellerNotApproved is returned when a seller who is not approved manages products
+	ErrSellerNotApproved = errors.New("seller not approved")
+)
+
+// Seller statuses. Applications start pending and are approved or rejected by an admin;
+// approved sellers can be suspended and reinstated, and rejected ones may apply again.
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusSuspended = "suspended"
+)
+
+// Seller represents a user's seller profile. ID is the seller's user ID, which their
+// products carry as SellerID.
+type Seller struct {
+	ID           string     `json:"id"`
+	StoreName    string     `json:"store_name"`
+	Description  string     `json:"description,omitempty"`
+	ContactEmail string     `json:"contact_email"`
+	Phone        string     `json:"phone,omitempty"`
+	Status       string     `json:"status"`
+	StatusReason string     `json:"status_reason,omitempty"`
+	ReviewedBy   string     `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Storefront is the public view of an approved seller
+type Storefront struct {
+	ID          string `json:"id"`
+	StoreName   string `json:"store_name"`
+	Description string `json:"description,omitempty"`
+}
+
+// ProfileRequest represents a seller application or a change to a seller profile
+type ProfileRequest struct {
+	StoreName    string `json:"store_name" validate:"required,min=2,max=100"`
+	Description  string `json:"description" validate:"max=2000"`
+	ContactEmail string `json:"contact_email" validate:"required,email"`
+	Phone        string `json:"phone" validate:"omitempty,max=32"`
+}
+
+// DecisionRequest represents the reason given for rejecting or suspending a seller
+type DecisionRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
@@ -0,0 +1,104 @@
+package seller
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Repository defines the interface for seller data access
+type Repository interface {
+	Create(ctx context.Context, seller *Seller) error
+	FindByID(ctx context.Context, id string) (*Seller, error)
+	List(ctx context.Context, status string) ([]*Seller, error)
+	Update(ctx context.Context, seller *Seller) error
+	Delete(ctx context.Context, id string) error
+}
+
+// InMemoryRepository implements Repository using in-memory storage. Sellers are stored
+// and returned as copies.
+type InMemoryRepository struct {
+	sellers map[string]*Seller
+	mutex   sync.RWMutex
+}
+
+// NewInMemoryRepository creates a new in-memory repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		sellers: make(map[string]*Seller),
+	}
+}
+
+// Create stores a new seller
+func (r *InMemoryRepository) Create(ctx context.Context, seller *Seller) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.sellers[seller.ID]; exists {
+		return ErrSellerExists
+	}
+
+	stored := *seller
+	r.sellers[seller.ID] = &stored
+	return nil
+}
+
+// FindByID finds a seller by their user ID
+func (r *InMemoryRepository) FindByID(ctx context.Context, id string) (*Seller, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	seller, exists := r.sellers[id]
+	if !exists {
+		return nil, ErrSellerNotFound
+	}
+
+	found := *seller
+	return &found, nil
+}
+
+// List lists sellers oldest first, optionally filtered by status
+func (r *InMemoryRepository) List(ctx context.Context, status string) ([]*Seller, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	sellers := make([]*Seller, 0)
+	for _, seller := range r.sellers {
+		if status != "" && seller.Status != status {
+			continue
+		}
+		found := *seller
+		sellers = append(sellers, &found)
+	}
+	sort.Slice(sellers, func(i, j int) bool {
+		return sellers[i].CreatedAt.Before(sellers[j].CreatedAt)
+	})
+
+	return sellers, nil
+}
+
+// Update replaces a stored seller
+func (r *InMemoryRepository) Update(ctx context.Context, seller *Seller) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.sellers[seller.ID]; !exists {
+		return ErrSellerNotFound
+	}
+
+	stored := *seller
+	r.sellers[seller.ID] = &stored
+	return nil
+}
+
+// Delete deletes a seller
+func (r *InMemoryRepository) Delete(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.sellers[id]; !exists {
+		return ErrSellerNotFound
+	}
+	delete(r.sellers, id)
+	return nil
+}
@@ -0,0 +1,305 @@
+package seller
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/user"
+	"go.uber.org/zap"
+)
+
+// Service defines the interface for seller business logic
+type Service interface {
+	Apply(ctx context.Context, userID string, req ProfileRequest) (*Seller, error)
+	Get(ctx context.Context, id string) (*Seller, error)
+	UpdateProfile(ctx context.Context, userID string, req ProfileRequest) (*Seller, error)
+	List(ctx context.Context, status string) ([]*Seller, error)
+	GetStorefront(ctx context.Context, id string) (*Storefront, error)
+	Approve(ctx context.Context, id, adminID string) (*Seller, error)
+	Reject(ctx context.Context, id, adminID string, req DecisionRequest) (*Seller, error)
+	Suspend(ctx context.Context, id, adminID string, req DecisionRequest) (*Seller, error)
+	ListProducts(ctx context.Context, id string, filters product.ProductFilters) (*product.ProductList, error)
+	CreateProduct(ctx context.Context, id string, req product.CreateProductRequest) (*product.Product, error)
+	UpdateProduct(ctx context.Context, id, productID string, req product.UpdateProductRequest) (*product.Product, error)
+	DeleteProduct(ctx context.Context, id, productID string) error
+	ExportPersonalData(ctx context.Context, userID string) (interface{}, error)
+	ErasePersonalData(ctx context.Context, userID string) error
+}
+
+// service implements Service
+type service struct {
+	repo           Repository
+	roles          RoleGranter
+	productService product.Service
+	logger         *zap.Logger
+	// mutex serializes applications and status transitions
+	mutex sync.Mutex
+}
+
+// NewService creates a new seller service
+func NewService(repo Repository, roles RoleGranter, productService product.Service, logger *zap.Logger) Service {
+	return &service{
+		repo:           repo,
+		roles:          roles,
+		productService: productService,
+		logger:         logger,
+	}
+}
+
+// Apply submits a user's application to sell for review. A rejected seller may apply
+// again, which puts their application back in review with the new details.
+func (s *service) Apply(ctx context.Context, userID string, req ProfileRequest) (*Seller, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	seller, err := s.repo.FindByID(ctx, userID)
+	if err != nil && err != ErrSellerNotFound {
+		return nil, err
+	}
+
+	now := time.Now()
+	if seller == nil {
+		seller = &Seller{ID: userID, Status: StatusPending, CreatedAt: now}
+		applyProfileRequest(seller, req, now)
+		err = s.repo.Create(ctx, seller)
+	} else {
+		if seller.Status != StatusRejected {
+			return nil, ErrSellerExists
+		}
+		applyProfileRequest(seller, req, now)
+		seller.Status = StatusPending
+		seller.StatusReason = ""
+		seller.ReviewedBy = ""
+		seller.ReviewedAt = nil
+		err = s.repo.Update(ctx, seller)
+	}
+	if err != nil {
+		s.logger.Error("Failed to store seller application", zap.String("seller_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Seller application submitted", zap.String("seller_id", userID))
+	return seller, nil
+}
+
+// Get retrieves a seller profile
+func (s *service) Get(ctx context.Context, id string) (*Seller, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// UpdateProfile changes the details of a seller profile without affecting its status
+func (s *service) UpdateProfile(ctx context.Context, userID string, req ProfileRequest) (*Seller, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	seller, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	applyProfileRequest(seller, req, time.Now())
+
+	if err := s.repo.Update(ctx, seller); err != nil {
+		s.logger.Error("Failed to update seller", zap.String("seller_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return seller, nil
+}
+
+// List lists sellers oldest first, optionally filtered by status
+func (s *service) List(ctx context.Context, status string) ([]*Seller, error) {
+	sellers, err := s.repo.List(ctx, status)
+	if err != nil {
+		s.logger.Error("Failed to list sellers", zap.Error(err))
+		return nil, err
+	}
+	return sellers, nil
+}
+
+// GetStorefront returns the public view of a seller. Sellers who are not approved look
+// the same as unknown ones.
+func (s *service) GetStorefront(ctx context.Context, id string) (*Storefront, error) {
+	seller, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if seller.Status != StatusApproved {
+		return nil, ErrSellerNotFound
+	}
+
+	return &Storefront{
+		ID:          seller.ID,
+		StoreName:   seller.StoreName,
+		Description: seller.Description,
+	}, nil
+}
+
+// Approve lets a pending or suspended seller sell, granting them the seller role
+func (s *service) Approve(ctx context.Context, id, adminID string) (*Seller, error) {
+	return s.transition(ctx, id, adminID, "", func(seller *Seller) error {
+		if seller.Status != StatusPending && seller.Status != StatusSuspended {
+			return ErrInvalidSellerState
+		}
+		if err := s.roles.GrantRole(ctx, seller.ID, adminID, user.RoleSeller); err != nil {
+			s.logger.Error("Failed to grant seller role", zap.String("seller_id", seller.ID), zap.Error(err))
+			return err
+		}
+		seller.Status = StatusApproved
+		return nil
+	})
+}
+
+// Reject declines a pending seller application
+func (s *service) Reject(ctx context.Context, id, adminID string, req DecisionRequest) (*Seller, error) {
+	return s.transition(ctx, id, adminID, req.Reason, func(seller *Seller) error {
+		if seller.Status != StatusPending {
+			return ErrInvalidSellerState
+		}
+		seller.Status = StatusRejected
+		return nil
+	})
+}
+
+// Suspend stops an approved seller from selling, revoking the seller role. Their products
+// stay in the catalog, where admins can remove them.
+func (s *service) Suspend(ctx context.Context, id, adminID string, req DecisionRequest) (*Seller, error) {
+	return s.transition(ctx, id, adminID, req.Reason, func(seller *Seller) error {
+		if seller.Status != StatusApproved {
+			return ErrInvalidSellerState
+		}
+		if err := s.roles.RevokeRole(ctx, seller.ID, adminID, user.RoleSeller); err != nil {
+			s.logger.Error("Failed to revoke seller role", zap.String("seller_id", seller.ID), zap.Error(err))
+			return err
+		}
+		seller.Status = StatusSuspended
+		return nil
+	})
+}
+
+// ListProducts lists the products a seller lists
+func (s *service) ListProducts(ctx context.Context, id string, filters product.ProductFilters) (*product.ProductList, error) {
+	filters.SellerID = id
+	return s.productService.List(ctx, filters)
+}
+
+// CreateProduct lists a new product for an approved seller
+func (s *service) CreateProduct(ctx context.Context, id string, req product.CreateProductRequest) (*product.Product, error) {
+	if err := s.ensureApproved(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.productService.CreateForSeller(ctx, id, req)
+}
+
+// UpdateProduct changes one of the products an approved seller lists
+func (s *service) UpdateProduct(ctx context.Context, id, productID string, req product.UpdateProductRequest) (*product.Product, error) {
+	if err := s.ensureApproved(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.productService.UpdateForSeller(ctx, id, productID, req)
+}
+
+// DeleteProduct removes one of the products an approved seller lists
+func (s *service) DeleteProduct(ctx context.Context, id, productID string) error {
+	if err := s.ensureApproved(ctx, id); err != nil {
+		return err
+	}
+	return s.productService.DeleteForSeller(ctx, id, productID)
+}
+
+// ExportPersonalData returns the seller profile of a user, if they have one
+func (s *service) ExportPersonalData(ctx context.Context, userID string) (interface{}, error) {
+	seller, err := s.repo.FindByID(ctx, userID)
+	if err == ErrSellerNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to find seller", zap.String("seller_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return seller, nil
+}
+
+// ErasePersonalData removes the products a user lists and then their seller profile, so
+// that a failed erasure can be retried while the profile still exists
+func (s *service) ErasePersonalData(ctx context.Context, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for {
+		// Deleting shifts the remaining products onto the first page
+		products, err := s.productService.List(ctx, product.ProductFilters{SellerID: userID, Page: 1, PageSize: 100})
+		if err != nil {
+			return err
+		}
+		if len(products.Products) == 0 {
+			break
+		}
+		for _, p := range products.Products {
+			if err := s.productService.Delete(ctx, p.ID); err != nil && err != product.ErrProductNotFound {
+				return err
+			}
+		}
+	}
+
+	if err := s.repo.Delete(ctx, userID); err != nil && err != ErrSellerNotFound {
+		s.logger.Error("Failed to delete seller", zap.String("seller_id", userID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ensureApproved returns ErrSellerNotApproved unless the seller is approved. The seller
+// role is revoked on suspension too, but access tokens issued before keep it until they
+// expire.
+func (s *service) ensureApproved(ctx context.Context, id string) error {
+	seller, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if seller.Status != StatusApproved {
+		return ErrSellerNotApproved
+	}
+	return nil
+}
+
+// transition applies change to a seller and records the admin's review
+func (s *service) transition(ctx context.Context, id, adminID, reason string, change func(seller *Seller) error) (*Seller, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	seller, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(seller); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	seller.StatusReason = strings.TrimSpace(reason)
+	seller.ReviewedBy = adminID
+	seller.ReviewedAt = &now
+	seller.UpdatedAt = now
+
+	if err := s.repo.Update(ctx, seller); err != nil {
+		s.logger.Error("Failed to update seller", zap.String("seller_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Seller status changed",
+		zap.String("seller_id", id),
+		zap.String("status", seller.Status),
+		zap.String("admin_id", adminID))
+	return seller, nil
+}
+
+// applyProfileRequest copies the details of req onto seller
+func applyProfileRequest(seller *Seller, req ProfileRequest, now time.Time) {
+	seller.StoreName = strings.TrimSpace(req.StoreName)
+	seller.Description = strings.TrimSpace(req.Description)
+	seller.ContactEmail = strings.TrimSpace(req.ContactEmail)
+	seller.Phone = strings.TrimSpace(req.Phone)
+	seller.UpdatedAt = now
+}
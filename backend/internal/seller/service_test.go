@@ -0,0 +1,202 @@
+package seller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"go.uber.org/zap"
+)
+
+// stubRoles records the roles granted to each user
+type stubRoles struct {
+	roles   map[string][]string
+	failing bool
+}
+
+func (r *stubRoles) GrantRole(ctx context.Context, userID, adminID, role string) error {
+	if r.failing {
+		return errors.New("storage unavailable")
+	}
+	r.roles[userID] = append(r.roles[userID], role)
+	return nil
+}
+
+func (r *stubRoles) RevokeRole(ctx context.Context, userID, adminID, role string) error {
+	kept := make([]string, 0)
+	for _, held := range r.roles[userID] {
+		if held != role {
+			kept = append(kept, held)
+		}
+	}
+	r.roles[userID] = kept
+	return nil
+}
+
+func setupTestService() (Service, product.Service, *stubRoles) {
+	logger, _ := zap.NewDevelopment()
+	roles := &stubRoles{roles: make(map[string][]string)}
+	productService := product.NewService(product.NewInMemoryRepository(), logger)
+	return NewService(NewInMemoryRepository(), roles, productService, logger), productService, roles
+}
+
+func testProfile() ProfileRequest {
+	return ProfileRequest{
+		StoreName:    " Potter's Wheel ",
+		Description:  "Handmade ceramics",
+		ContactEmail: "shop@example.com",
+	}
+}
+
+func TestService_Onboarding(t *testing.T) {
+	ctx := context.Background()
+	service, _, roles := setupTestService()
+
+	_, err := service.Get(ctx, "user-1")
+	assert.Equal(t, ErrSellerNotFound, err)
+
+	seller, err := service.Apply(ctx, "user-1", testProfile())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", seller.ID)
+	assert.Equal(t, StatusPending, seller.Status)
+	assert.Equal(t, "Potter's Wheel", seller.StoreName)
+
+	_, err = service.Apply(ctx, "user-1", testProfile())
+	assert.Equal(t, ErrSellerExists, err)
+	_, err = service.GetStorefront(ctx, "user-1")
+	assert.Equal(t, ErrSellerNotFound, err, "pending sellers have no storefront")
+
+	pending, err := service.List(ctx, StatusPending)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	// Rejected sellers can apply again
+	seller, err = service.Reject(ctx, "user-1", "admin-1", DecisionRequest{Reason: "Missing details"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusRejected, seller.Status)
+	assert.Equal(t, "Missing details", seller.StatusReason)
+	assert.Equal(t, "admin-1", seller.ReviewedBy)
+	_, err = service.Suspend(ctx, "user-1", "admin-1", DecisionRequest{})
+	assert.Equal(t, ErrInvalidSellerState, err)
+	seller, err = service.Apply(ctx, "user-1", testProfile())
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, seller.Status)
+	assert.Empty(t, seller.StatusReason)
+	assert.Nil(t, seller.ReviewedAt)
+
+	seller, err = service.Approve(ctx, "user-1", "admin-1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusApproved, seller.Status)
+	assert.Equal(t, []string{"seller"}, roles.roles["user-1"])
+	_, err = service.Approve(ctx, "user-1", "admin-1")
+	assert.Equal(t, ErrInvalidSellerState, err)
+
+	storefront, err := service.GetStorefront(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Storefront{ID: "user-1", StoreName: "Potter's Wheel", Description: "Handmade ceramics"}, storefront)
+
+	// Suspension takes the role away until the seller is reinstated
+	_, err = service.Suspend(ctx, "user-1", "admin-1", DecisionRequest{Reason: "Counterfeit goods"})
+	require.NoError(t, err)
+	assert.Empty(t, roles.roles["user-1"])
+	_, err = service.GetStorefront(ctx, "user-1")
+	assert.Equal(t, ErrSellerNotFound, err)
+	_, err = service.Approve(ctx, "user-1", "admin-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"seller"}, roles.roles["user-1"])
+
+	_, err = service.Approve(ctx, "missing", "admin-1")
+	assert.Equal(t, ErrSellerNotFound, err)
+}
+
+func TestService_ApproveFailure(t *testing.T) {
+	ctx := context.Background()
+	service, _, roles := setupTestService()
+	_, err := service.Apply(ctx, "user-1", testProfile())
+	require.NoError(t, err)
+
+	// A seller is only approved once they hold the role
+	roles.failing = true
+	_, err = service.Approve(ctx, "user-1", "admin-1")
+	assert.Error(t, err)
+	seller, err := service.Get(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, seller.Status)
+}
+
+func TestService_SellerProducts(t *testing.T) {
+	ctx := context.Background()
+	service, productService, _ := setupTestService()
+	req := product.CreateProductRequest{Name: "Glazed Bowl", Price: 20, Stock: 3, CategoryID: "ceramics"}
+
+	_, err := service.CreateProduct(ctx, "user-1", req)
+	assert.Equal(t, ErrSellerNotFound, err)
+	_, err = service.Apply(ctx, "user-1", testProfile())
+	require.NoError(t, err)
+	_, err = service.CreateProduct(ctx, "user-1", req)
+	assert.Equal(t, ErrSellerNotApproved, err)
+
+	_, err = service.Approve(ctx, "user-1", "admin-1")
+	require.NoError(t, err)
+	created, err := service.CreateProduct(ctx, "user-1", req)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", created.SellerID)
+	_, err = productService.Create(ctx, req)
+	require.NoError(t, err)
+
+	list, err := service.ListProducts(ctx, "user-1", product.ProductFilters{})
+	require.NoError(t, err)
+	require.Len(t, list.Products, 1)
+	assert.Equal(t, created.ID, list.Products[0].ID)
+
+	updated, err := service.UpdateProduct(ctx, "user-1", created.ID, product.UpdateProductRequest{Price: 25, Stock: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 25.0, updated.Price)
+
+	// Suspended sellers keep the role in access tokens issued before, but not their access
+	_, err = service.Suspend(ctx, "user-1", "admin-1", DecisionRequest{})
+	require.NoError(t, err)
+	_, err = service.UpdateProduct(ctx, "user-1", created.ID, product.UpdateProductRequest{Price: 1})
+	assert.Equal(t, ErrSellerNotApproved, err)
+	assert.Equal(t, ErrSellerNotApproved, service.DeleteProduct(ctx, "user-1", created.ID))
+
+	_, err = service.Approve(ctx, "user-1", "admin-1")
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteProduct(ctx, "user-1", created.ID))
+}
+
+func TestService_PersonalData(t *testing.T) {
+	ctx := context.Background()
+	service, productService, _ := setupTestService()
+
+	data, err := service.ExportPersonalData(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	_, err = service.Apply(ctx, "user-1", testProfile())
+	require.NoError(t, err)
+	_, err = service.Approve(ctx, "user-1", "admin-1")
+	require.NoError(t, err)
+	for i := 0; i < 120; i++ {
+		_, err := service.CreateProduct(ctx, "user-1", product.CreateProductRequest{Name: "Glazed Bowl", Price: 20, Stock: 3, CategoryID: "ceramics"})
+		require.NoError(t, err)
+	}
+	kept, err := productService.Create(ctx, product.CreateProductRequest{Name: "Store Bowl", Price: 20, Stock: 3, CategoryID: "ceramics"})
+	require.NoError(t, err)
+
+	data, err = service.ExportPersonalData(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "shop@example.com", data.(*Seller).ContactEmail)
+
+	require.NoError(t, service.ErasePersonalData(ctx, "user-1"))
+	require.NoError(t, service.ErasePersonalData(ctx, "user-1"), "erasing again succeeds")
+	_, err = service.Get(ctx, "user-1")
+	assert.Equal(t, ErrSellerNotFound, err)
+	list, err := productService.List(ctx, product.ProductFilters{})
+	require.NoError(t, err)
+	require.Len(t, list.Products, 1)
+	assert.Equal(t, kept.ID, list.Products[0].ID)
+}
@@ -0,0 +1,12 @@
+package seller
+
+import "context"
+
+// RoleGranter grants and revokes the seller role as sellers are approved and suspended.
+// It is implemented by the user domain.
+type RoleGranter interface {
+	// GrantRole adds role to the roles of a user, on behalf of adminID
+	GrantRole(ctx context.Context, userID, adminID, role string) error
+	// RevokeRole removes role from the roles of a user, on behalf of adminID
+	RevokeRole(ctx context.Context, userID, adminID, role string) error
+}
@@ -0,0 +1,164 @@
+package shipping
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/money"
+)
+
+// Calculator prices configured shipping methods for a destination and parcel
+type Calculator struct {
+	config Config
+}
+
+// NewCalculator creates a new shipping calculator
+func NewCalculator(config Config) *Calculator {
+	return &Calculator{config: config}
+}
+
+// Quote returns the methods available for a destination, cheapest first
+func (c *Calculator) Quote(destination Destination, parcel Parcel) *Quote {
+	weight := c.BillableWeight(parcel)
+	quote := &Quote{
+		BillableWeight: weight,
+		Options:        make([]Option, 0),
+	}
+
+	for _, method := range c.config.Methods {
+		if option, ok := c.option(method, destination, parcel.Subtotal, weight); ok {
+			quote.Options = append(quote.Options, option)
+		}
+	}
+	sort.SliceStable(quote.Options, func(i, j int) bool {
+		return quote.Options[i].Price < quote.Options[j].Price
+	})
+
+	return quote
+}
+
+// Price returns the price of a single method for a destination and parcel
+func (c *Calculator) Price(destination Destination, parcel Parcel, methodID string) (*Option, error) {
+	for _, method := range c.config.Methods {
+		if method.ID != methodID {
+			continue
+		}
+		option, ok := c.option(method, destination, parcel.Subtotal, c.BillableWeight(parcel))
+		if !ok {
+			return nil, ErrMethodUnavailable
+		}
+		return &option, nil
+	}
+	return nil, ErrMethodUnavailable
+}
+
+// BillableWeight returns the parcel weight in kilograms, using the volumetric weight of
+// items whose packed size outweighs their actual weight
+func (c *Calculator) BillableWeight(parcel Parcel) float64 {
+	var total float64
+	for _, item := range parcel.Items {
+		weight := item.Weight
+		if c.config.VolumetricDivisor > 0 {
+			volumetric := item.Length * item.Width * item.Height / c.config.VolumetricDivisor
+			weight = math.Max(weight, volumetric)
+		}
+		total += weight * float64(item.Quantity)
+	}
+	return math.Round(total*1000) / 1000
+}
+
+// option prices a method, reporting false when it does not serve the destination or parcel
+func (c *Calculator) option(method Method, destination Destination, subtotal, weight float64) (Option, bool) {
+	option := Option{
+		MethodID: method.ID,
+		Name:     method.Name,
+		Type:     method.Type,
+	}
+
+	if len(method.Zones) > 0 {
+		zone := c.matchZone(method.Zones, destination)
+		if zone == nil {
+			return option, false
+		}
+		option.Zone = zone.Name
+	}
+
+	switch method.Type {
+	case TypeFlatRate, TypeLocalPickup:
+		option.Price = method.Rate
+	case TypeFreeOverThreshold:
+		if subtotal < method.Threshold {
+			return option, false
+		}
+	case TypeWeightBased:
+		tier := tierFor(method.Tiers, weight)
+		if tier == nil {
+			return option, false
+		}
+		option.Price = tier.Rate
+	default:
+		return option, false
+	}
+
+	option.Price = money.Round(option.Price)
+	return option, true
+}
+
+// matchZone returns the first of the given zones that contains the destination
+func (c *Calculator) matchZone(zoneIDs []string, destination Destination) *Zone {
+	for _, id := range zoneIDs {
+		for i := range c.config.Zones {
+			zone := &c.config.Zones[i]
+			if zone.ID == id && zone.contains(destination) {
+				return zone
+			}
+		}
+	}
+	return nil
+}
+
+// contains reports whether a destination falls within the zone
+func (z *Zone) contains(destination Destination) bool {
+	countryMatch := false
+	for _, country := range z.Countries {
+		if strings.EqualFold(country, destination.Country) {
+			countryMatch = true
+			break
+		}
+	}
+	if !countryMatch {
+		return false
+	}
+	if len(z.PostalCodes) == 0 {
+		return true
+	}
+
+	postalCode := normalizePostalCode(destination.PostalCode)
+	for _, prefix := range z.PostalCodes {
+		if postalCode != "" && strings.HasPrefix(postalCode, normalizePostalCode(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// tierFor returns the lightest tier that can carry weight
+func tierFor(tiers []Tier, weight float64) *Tier {
+	var best *Tier
+	for i := range tiers {
+		tier := &tiers[i]
+		if tier.MaxWeight > 0 && weight > tier.MaxWeight {
+			continue
+		}
+		if best == nil || (tier.MaxWeight > 0 && (best.MaxWeight == 0 || tier.MaxWeight < best.MaxWeight)) {
+			best = tier
+		}
+	}
+	return best
+}
+
+// normalizePostalCode uppercases a postal code and strips spaces for prefix matching
+func normalizePostalCode(postalCode string) string {
+	return strings.ToUpper(strings.ReplaceAll(postalCode, " ", ""))
+}
@@ -0,0 +1,124 @@
+package shipping
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() Config {
+	return Config{
+		VolumetricDivisor: 5000,
+		Zones: []Zone{
+			{ID: "us", Name: "United States", Countries: []string{"US"}},
+			{ID: "sf", Name: "San Francisco", Countries: []string{"US"}, PostalCodes: []string{"941"}},
+			{ID: "london", Name: "London", Countries: []string{"GB"}, PostalCodes: []string{"EC", "SW1"}},
+		},
+		Methods: []Method{
+			{ID: "standard", Name: "Standard", Type: TypeFlatRate, Zones: []string{"us"}, Rate: 5.99},
+			{ID: "parcel", Name: "Parcel", Type: TypeWeightBased, Tiers: []Tier{{MaxWeight: 5, Rate: 9}, {MaxWeight: 1, Rate: 4.5}, {MaxWeight: 20, Rate: 18}}},
+			{ID: "freight", Name: "Freight", Type: TypeWeightBased, Zones: []string{"us"}, Tiers: []Tier{{Rate: 60}}},
+			{ID: "free", Name: "Free", Type: TypeFreeOverThreshold, Zones: []string{"us"}, Threshold: 100},
+			{ID: "pickup", Name: "Pickup", Type: TypeLocalPickup, Zones: []string{"sf", "london"}},
+		},
+	}
+}
+
+func methodIDs(quote *Quote) []string {
+	ids := make([]string, len(quote.Options))
+	for i, option := range quote.Options {
+		ids[i] = option.MethodID
+	}
+	return ids
+}
+
+func TestCalculator_Quote(t *testing.T) {
+	calculator := NewCalculator(testConfig())
+	light := Parcel{Subtotal: 40, Items: []Item{{Quantity: 1, Weight: 0.5}}}
+
+	tests := []struct {
+		name        string
+		destination Destination
+		parcel      Parcel
+		wantMethods []string
+	}{
+		{
+			name:        "domestic light parcel, cheapest first",
+			destination: Destination{Country: "us", PostalCode: "10001"},
+			parcel:      light,
+			wantMethods: []string{"parcel", "standard", "freight"},
+		},
+		{
+			name:        "local pickup matches postal code prefix",
+			destination: Destination{Country: "US", PostalCode: "94107"},
+			parcel:      light,
+			wantMethods: []string{"pickup", "parcel", "standard", "freight"},
+		},
+		{
+			name:        "postal prefix ignores case and spaces",
+			destination: Destination{Country: "GB", PostalCode: "sw1a 1aa"},
+			parcel:      light,
+			wantMethods: []string{"pickup", "parcel"},
+		},
+		{
+			name:        "free shipping over threshold",
+			destination: Destination{Country: "US"},
+			parcel:      Parcel{Subtotal: 100, Items: []Item{{Quantity: 1, Weight: 0.5}}},
+			wantMethods: []string{"free", "parcel", "standard", "freight"},
+		},
+		{
+			name:        "too heavy for weight tiers",
+			destination: Destination{Country: "FR"},
+			parcel:      Parcel{Subtotal: 40, Items: []Item{{Quantity: 3, Weight: 8}}},
+			wantMethods: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote := calculator.Quote(tt.destination, tt.parcel)
+			assert.Equal(t, tt.wantMethods, methodIDs(quote))
+		})
+	}
+}
+
+func TestCalculator_WeightTiers(t *testing.T) {
+	calculator := NewCalculator(testConfig())
+
+	tests := []struct {
+		name      string
+		items     []Item
+		wantPrice float64
+	}{
+		{name: "lightest tier", items: []Item{{Quantity: 2, Weight: 0.5}}, wantPrice: 4.5},
+		{name: "tier boundary is inclusive", items: []Item{{Quantity: 1, Weight: 5}}, wantPrice: 9},
+		{name: "heavier tier", items: []Item{{Quantity: 3, Weight: 2}}, wantPrice: 18},
+		// 40x30x30 cm is 7.2 kg volumetric
+		{name: "volumetric weight applies to bulky items", items: []Item{{Quantity: 1, Weight: 1, Length: 40, Width: 30, Height: 30}}, wantPrice: 18},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			option, err := calculator.Price(Destination{Country: "GB"}, Parcel{Items: tt.items}, "parcel")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPrice, option.Price)
+		})
+	}
+}
+
+func TestCalculator_Price_Unavailable(t *testing.T) {
+	calculator := NewCalculator(testConfig())
+	parcel := Parcel{Subtotal: 40, Items: []Item{{Quantity: 1, Weight: 1}}}
+
+	_, err := calculator.Price(Destination{Country: "GB"}, parcel, "standard")
+	assert.Equal(t, ErrMethodUnavailable, err)
+
+	_, err = calculator.Price(Destination{Country: "US"}, parcel, "missing")
+	assert.Equal(t, ErrMethodUnavailable, err)
+
+	option, err := calculator.Price(Destination{Country: "US"}, parcel, "standard")
+	require.NoError(t, err)
+	assert.Equal(t, "United States", option.Zone)
+	assert.Equal(t, 5.99, option.Price)
+}
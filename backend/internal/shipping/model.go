@@ -0,0 +1,86 @@
+package shipping
+
+import "errors"
+
+var (
+	// ErrMethodUnavailable is returned when a shipping method does not serve a destination or parcel
+	ErrMethodUnavailable = errors.New("shipping method not available")
+)
+
+// Method types
+const (
+	TypeFlatRate          = "flat_rate"
+	TypeWeightBased       = "weight_based"
+	TypeFreeOverThreshold = "free_over_threshold"
+	TypeLocalPickup       = "local_pickup"
+)
+
+// Config holds the shipping zones and methods used by the calculator
+type Config struct {
+	// VolumetricDivisor converts cubic centimetres into kilograms of billable weight;
+	// zero disables volumetric weight
+	VolumetricDivisor float64
+	Zones             []Zone
+	Methods           []Method
+}
+
+// Zone groups destinations by country, optionally narrowed to postal code prefixes
+type Zone struct {
+	ID          string
+	Name        string
+	Countries   []string
+	PostalCodes []string
+}
+
+// Method represents a configured shipping method. A method without zones ships everywhere.
+type Method struct {
+	ID        string
+	Name      string
+	Type      string
+	Zones     []string
+	Rate      float64
+	Threshold float64
+	Tiers     []Tier
+}
+
+// Tier represents a weight band of a weight-based method. A zero MaxWeight has no upper limit.
+type Tier struct {
+	MaxWeight float64
+	Rate      float64
+}
+
+// Destination represents where a parcel is shipped to
+type Destination struct {
+	Country    string
+	PostalCode string
+}
+
+// Parcel represents the contents of a cart to be shipped
+type Parcel struct {
+	Subtotal float64
+	Items    []Item
+}
+
+// Item represents a product in a parcel. Weight is in kilograms and dimensions in centimetres.
+type Item struct {
+	Quantity int
+	Weight   float64
+	Length   float64
+	Width    float64
+	Height   float64
+}
+
+// Option represents a shipping method available for a destination and its price
+type Option struct {
+	MethodID string  `json:"method_id"`
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Zone     string  `json:"zone,omitempty"`
+	Price    float64 `json:"price"`
+}
+
+// Quote represents the shipping options for a cart
+type Quote struct {
+	BillableWeight float64  `json:"billable_weight"`
+	Options        []Option `json:"options"`
+}
@@ -0,0 +1,177 @@
+package tax
+
+import (
+	"strings"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/money"
+)
+
+// Calculator computes tax from a set of configured regional rate tables
+type Calculator struct {
+	config Config
+}
+
+// NewCalculator creates a new tax calculator
+func NewCalculator(config Config) *Calculator {
+	if config.Rounding == "" {
+		config.Rounding = RoundingLine
+	}
+	return &Calculator{config: config}
+}
+
+// PricesIncludeTax reports whether catalog prices already contain tax
+func (c *Calculator) PricesIncludeTax() bool {
+	return c.config.PricesIncludeTax
+}
+
+// Calculate computes the tax on lines shipped to address. When no region matches the
+// address the breakdown reports zero tax.
+func (c *Calculator) Calculate(address Address, lines []Line) *Breakdown {
+	breakdown := &Breakdown{
+		PricesIncludeTax: c.config.PricesIncludeTax,
+		Rounding:         c.config.Rounding,
+		Lines:            make([]LineTax, len(lines)),
+		Rates:            []RateTotal{},
+	}
+
+	region := c.match(address)
+	if region != nil {
+		breakdown.Jurisdiction = region.Name
+	}
+
+	raw := make([]float64, len(lines))
+	for i, line := range lines {
+		class := line.TaxClass
+		if class == "" {
+			class = c.config.DefaultClass
+		}
+		rate := c.rateFor(region, class)
+		raw[i] = c.taxOn(line.Amount, rate)
+		breakdown.Lines[i] = LineTax{
+			ProductID: line.ProductID,
+			TaxClass:  class,
+			Rate:      rate,
+		}
+	}
+
+	if c.config.Rounding == RoundingOrder {
+		// Round once per tax class, then spread the rounded amount back over the lines
+		for _, indexes := range groupByClass(breakdown.Lines) {
+			weights := make([]float64, len(indexes))
+			var sum float64
+			for j, i := range indexes {
+				weights[j] = raw[i]
+				sum += raw[i]
+			}
+			for j, share := range money.Allocate(sum, weights) {
+				breakdown.Lines[indexes[j]].Tax = share
+			}
+		}
+	} else {
+		for i := range breakdown.Lines {
+			breakdown.Lines[i].Tax = money.Round(raw[i])
+		}
+	}
+
+	var total float64
+	for i, line := range lines {
+		taxable := line.Amount
+		if c.config.PricesIncludeTax {
+			taxable -= breakdown.Lines[i].Tax
+		}
+		breakdown.Lines[i].TaxableAmount = money.Round(taxable)
+		total += breakdown.Lines[i].Tax
+	}
+	breakdown.TotalTax = money.Round(total)
+
+	for _, indexes := range groupByClass(breakdown.Lines) {
+		first := breakdown.Lines[indexes[0]]
+		summary := RateTotal{TaxClass: first.TaxClass, Rate: first.Rate}
+		for _, i := range indexes {
+			summary.TaxableAmount += breakdown.Lines[i].TaxableAmount
+			summary.Tax += breakdown.Lines[i].Tax
+		}
+		summary.TaxableAmount = money.Round(summary.TaxableAmount)
+		summary.Tax = money.Round(summary.Tax)
+		breakdown.Rates = append(breakdown.Rates, summary)
+	}
+
+	return breakdown
+}
+
+// match returns the most specific region for an address. A postal code match is more
+// specific than a region match, which is more specific than a country-wide entry.
+func (c *Calculator) match(address Address) *Region {
+	var best *Region
+	bestScore := -1
+	for i := range c.config.Regions {
+		region := &c.config.Regions[i]
+		if !strings.EqualFold(region.Country, address.Country) {
+			continue
+		}
+		score := 0
+		if region.Region != "" {
+			if !strings.EqualFold(region.Region, address.Region) {
+				continue
+			}
+			score++
+		}
+		if len(region.PostalCodes) > 0 {
+			if !hasPostalPrefix(region.PostalCodes, address.PostalCode) {
+				continue
+			}
+			score += 2
+		}
+		if score > bestScore {
+			best = region
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// rateFor returns the percentage rate of a tax class, falling back to the default class
+func (c *Calculator) rateFor(region *Region, class string) float64 {
+	if region == nil {
+		return 0
+	}
+	if rate, exists := region.Rates[class]; exists {
+		return rate
+	}
+	return region.Rates[c.config.DefaultClass]
+}
+
+// taxOn returns the unrounded tax contained in or added to amount
+func (c *Calculator) taxOn(amount, rate float64) float64 {
+	if c.config.PricesIncludeTax {
+		return amount * rate / (100 + rate)
+	}
+	return amount * rate / 100
+}
+
+// hasPostalPrefix reports whether postalCode starts with any of the prefixes
+func hasPostalPrefix(prefixes []string, postalCode string) bool {
+	postalCode = strings.ToUpper(strings.ReplaceAll(postalCode, " ", ""))
+	for _, prefix := range prefixes {
+		if postalCode != "" && strings.HasPrefix(postalCode, strings.ToUpper(strings.ReplaceAll(prefix, " ", ""))) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupByClass returns line indexes grouped by tax class in first-seen order
+func groupByClass(lines []LineTax) [][]int {
+	groups := make([][]int, 0)
+	index := make(map[string]int)
+	for i, line := range lines {
+		g, exists := index[line.TaxClass]
+		if !exists {
+			g = len(groups)
+			index[line.TaxClass] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
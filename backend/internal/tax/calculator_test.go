@@ -0,0 +1,112 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() Config {
+	return Config{
+		Rounding:     RoundingLine,
+		DefaultClass: "standard",
+		Regions: []Region{
+			{Name: "United States", Country: "US", Rates: map[string]float64{"standard": 0}},
+			{Name: "California", Country: "US", Region: "CA", Rates: map[string]float64{"standard": 7.25, "food": 0}},
+			{Name: "San Francisco", Country: "US", Region: "CA", PostalCodes: []string{"941"}, Rates: map[string]float64{"standard": 8.625}},
+			{Name: "United Kingdom", Country: "GB", Rates: map[string]float64{"standard": 20, "reduced": 5}},
+		},
+	}
+}
+
+func TestCalculator_Jurisdiction(t *testing.T) {
+	calculator := NewCalculator(testConfig())
+
+	tests := []struct {
+		name             string
+		address          Address
+		wantJurisdiction string
+		wantTax          float64
+	}{
+		{name: "country only", address: Address{Country: "gb"}, wantJurisdiction: "United Kingdom", wantTax: 20},
+		{name: "region beats country", address: Address{Country: "US", Region: "ca"}, wantJurisdiction: "California", wantTax: 7.25},
+		{name: "postal code beats region", address: Address{Country: "US", Region: "CA", PostalCode: "94107"}, wantJurisdiction: "San Francisco", wantTax: 8.63},
+		{name: "unlisted region falls back to country", address: Address{Country: "US", Region: "OR"}, wantJurisdiction: "United States", wantTax: 0},
+		{name: "unknown country is untaxed", address: Address{Country: "FR"}, wantJurisdiction: "", wantTax: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breakdown := calculator.Calculate(tt.address, []Line{{ProductID: "p1", Amount: 100}})
+
+			assert.Equal(t, tt.wantJurisdiction, breakdown.Jurisdiction)
+			assert.Equal(t, tt.wantTax, breakdown.TotalTax)
+		})
+	}
+}
+
+func TestCalculator_TaxClasses(t *testing.T) {
+	calculator := NewCalculator(testConfig())
+
+	breakdown := calculator.Calculate(Address{Country: "GB"}, []Line{
+		{ProductID: "book", TaxClass: "reduced", Amount: 10},
+		{ProductID: "shirt", Amount: 25},
+		{ProductID: "gift", TaxClass: "unknown", Amount: 5},
+	})
+
+	require.Len(t, breakdown.Lines, 3)
+	assert.Equal(t, LineTax{ProductID: "book", TaxClass: "reduced", Rate: 5, TaxableAmount: 10, Tax: 0.5}, breakdown.Lines[0])
+	assert.Equal(t, LineTax{ProductID: "shirt", TaxClass: "standard", Rate: 20, TaxableAmount: 25, Tax: 5}, breakdown.Lines[1])
+	assert.Equal(t, "unknown", breakdown.Lines[2].TaxClass)
+	assert.Equal(t, 20.0, breakdown.Lines[2].Rate)
+	assert.Equal(t, 6.5, breakdown.TotalTax)
+
+	require.Len(t, breakdown.Rates, 3)
+	assert.Equal(t, RateTotal{TaxClass: "reduced", Rate: 5, TaxableAmount: 10, Tax: 0.5}, breakdown.Rates[0])
+}
+
+func TestCalculator_PricesIncludeTax(t *testing.T) {
+	config := testConfig()
+	config.PricesIncludeTax = true
+	calculator := NewCalculator(config)
+
+	breakdown := calculator.Calculate(Address{Country: "GB"}, []Line{{ProductID: "shirt", Amount: 24}})
+
+	assert.True(t, breakdown.PricesIncludeTax)
+	assert.Equal(t, 4.0, breakdown.TotalTax)
+	assert.Equal(t, 20.0, breakdown.Lines[0].TaxableAmount)
+}
+
+func TestCalculator_Rounding(t *testing.T) {
+	lines := []Line{
+		{ProductID: "a", Amount: 1},
+		{ProductID: "b", Amount: 1},
+		{ProductID: "c", Amount: 1},
+	}
+
+	// Each line carries 0.0725 of tax at 7.25%
+	tests := []struct {
+		name      string
+		rounding  string
+		wantTotal float64
+		wantLines []float64
+	}{
+		{name: "per line", rounding: RoundingLine, wantTotal: 0.21, wantLines: []float64{0.07, 0.07, 0.07}},
+		{name: "per order", rounding: RoundingOrder, wantTotal: 0.22, wantLines: []float64{0.08, 0.07, 0.07}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Rounding = tt.rounding
+			breakdown := NewCalculator(config).Calculate(Address{Country: "US", Region: "CA"}, lines)
+
+			assert.Equal(t, tt.rounding, breakdown.Rounding)
+			assert.Equal(t, tt.wantTotal, breakdown.TotalTax)
+			for i, want := range tt.wantLines {
+				assert.Equal(t, want, breakdown.Lines[i].Tax)
+			}
+		})
+	}
+}
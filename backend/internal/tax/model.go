@@ -0,0 +1,66 @@
+package tax
+
+// Rounding modes
+const (
+	RoundingLine  = "line"
+	RoundingOrder = "order"
+)
+
+// Config holds the tax rules used by the calculator
+type Config struct {
+	PricesIncludeTax bool
+	Rounding         string
+	DefaultClass     string
+	Regions          []Region
+}
+
+// Region holds the tax rates of a country, narrowed to a region or postal code prefixes
+// when those are set. Rates are percentages keyed by product tax class.
+type Region struct {
+	Name        string
+	Country     string
+	Region      string
+	PostalCodes []string
+	Rates       map[string]float64
+}
+
+// Address represents the destination that determines which rates apply
+type Address struct {
+	Country    string
+	Region     string
+	PostalCode string
+}
+
+// Line represents a taxable amount. Amount is net or gross depending on the pricing mode.
+type Line struct {
+	ProductID string
+	TaxClass  string
+	Amount    float64
+}
+
+// Breakdown represents the tax calculated for a cart or order
+type Breakdown struct {
+	PricesIncludeTax bool        `json:"prices_include_tax"`
+	Rounding         string      `json:"rounding"`
+	Jurisdiction     string      `json:"jurisdiction,omitempty"`
+	Lines            []LineTax   `json:"lines"`
+	Rates            []RateTotal `json:"rates"`
+	TotalTax         float64     `json:"total_tax"`
+}
+
+// LineTax represents the tax on a single line
+type LineTax struct {
+	ProductID     string  `json:"product_id"`
+	TaxClass      string  `json:"tax_class"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount float64 `json:"taxable_amount"`
+	Tax           float64 `json:"tax"`
+}
+
+// RateTotal represents the tax collected for one tax class
+type RateTotal struct {
+	TaxClass      string  `json:"tax_class"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount float64 `json:"taxable_amount"`
+	Tax           float64 `json:"tax"`
+}
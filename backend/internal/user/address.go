@@ -0,0 +1,269 @@
+package user
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// maxAddresses is the most addresses a user can save
+const maxAddresses = 20
+
+// addressFormat describes the addresses of a country
+type addressFormat struct {
+	// postalCode matches the country's postal codes, in upper case
+	postalCode         *regexp.Regexp
+	postalCodeRequired bool
+	// regionRequired is set for countries whose addresses name a state, province or prefecture
+	regionRequired bool
+}
+
+// addressFormats holds the formats of the countries most orders ship to. Addresses in
+// other countries only need a well-formed postal code, if they have one.
+var addressFormats = map[string]addressFormat{
+	"US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), postalCodeRequired: true, regionRequired: true},
+	"CA": {postalCode: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`), postalCodeRequired: true, regionRequired: true},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`), postalCodeRequired: true},
+	"IE": {postalCode: regexp.MustCompile(`^[A-Z]\d[\dW] ?[A-Z\d]{4}$`)},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`), postalCodeRequired: true},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`), postalCodeRequired: true},
+	"ES": {postalCode: regexp.MustCompile(`^\d{5}$`), postalCodeRequired: true},
+	"IT": {postalCode: regexp.MustCompile(`^\d{5}$`), postalCodeRequired: true},
+	"NL": {postalCode: regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`), postalCodeRequired: true},
+	"AU": {postalCode: regexp.MustCompile(`^\d{4}$`), postalCodeRequired: true, regionRequired: true},
+	"IN": {postalCode: regexp.MustCompile(`^\d{6}$`), postalCodeRequired: true, regionRequired: true},
+	"JP": {postalCode: regexp.MustCompile(`^\d{3}-?\d{4}$`), postalCodeRequired: true, regionRequired: true},
+}
+
+var (
+	// genericPostalCode matches postal codes of countries without a known format
+	genericPostalCode = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]*$`)
+	phoneNumber       = regexp.MustCompile(`^\+?[0-9][0-9 ().-]{3,}$`)
+	// countryCodes checks country codes against ISO 3166-1
+	countryCodes = validator.New()
+)
+
+// CreateAddress saves an address for a user. A user's first address becomes their
+// default for both shipping and billing.
+func (s *service) CreateAddress(ctx context.Context, userID string, req AddressRequest) (*Address, error) {
+	if _, err := s.findUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	req, err := checkAddress(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.addressMutex.Lock()
+	defer s.addressMutex.Unlock()
+
+	addresses, err := s.ListAddresses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) >= maxAddresses {
+		return nil, ErrAddressLimitReached
+	}
+
+	now := time.Now()
+	address := &Address{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		CreatedAt: now,
+	}
+	applyAddressRequest(address, req, now)
+	if len(addresses) == 0 {
+		address.DefaultShipping = true
+		address.DefaultBilling = true
+	}
+
+	if err := s.addresses.Create(ctx, address); err != nil {
+		s.logger.Error("Failed to store address", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	if err := s.clearAddressDefaults(ctx, addresses, address); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// ListAddresses lists a user's addresses, oldest first
+func (s *service) ListAddresses(ctx context.Context, userID string) ([]*Address, error) {
+	addresses, err := s.addresses.ListByUser(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list addresses", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// GetAddress returns one of a user's addresses
+func (s *service) GetAddress(ctx context.Context, userID, addressID string) (*Address, error) {
+	address, err := s.addresses.FindByID(ctx, addressID)
+	if err != nil {
+		return nil, err
+	}
+	// Other users' addresses look the same as unknown ones
+	if address.UserID != userID {
+		return nil, ErrAddressNotFound
+	}
+	return address, nil
+}
+
+// UpdateAddress replaces one of a user's addresses. Making it a default takes the default
+// from the user's other addresses; unsetting a default leaves the user without one.
+func (s *service) UpdateAddress(ctx context.Context, userID, addressID string, req AddressRequest) (*Address, error) {
+	req, err := checkAddress(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.addressMutex.Lock()
+	defer s.addressMutex.Unlock()
+
+	address, err := s.GetAddress(ctx, userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+	applyAddressRequest(address, req, time.Now())
+
+	if err := s.addresses.Update(ctx, address); err != nil {
+		s.logger.Error("Failed to update address", zap.String("address_id", address.ID), zap.Error(err))
+		return nil, err
+	}
+	addresses, err := s.ListAddresses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.clearAddressDefaults(ctx, addresses, address); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// DeleteAddress deletes one of a user's addresses
+func (s *service) DeleteAddress(ctx context.Context, userID, addressID string) error {
+	s.addressMutex.Lock()
+	defer s.addressMutex.Unlock()
+
+	address, err := s.GetAddress(ctx, userID, addressID)
+	if err != nil {
+		return err
+	}
+	if err := s.addresses.Delete(ctx, address.ID); err != nil {
+		s.logger.Error("Failed to delete address", zap.String("address_id", address.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// clearAddressDefaults takes the defaults kept has from the other addresses. Must be
+// called with s.addressMutex held.
+func (s *service) clearAddressDefaults(ctx context.Context, addresses []*Address, kept *Address) error {
+	for _, address := range addresses {
+		if address.ID == kept.ID {
+			continue
+		}
+		changed := false
+		if kept.DefaultShipping && address.DefaultShipping {
+			address.DefaultShipping = false
+			changed = true
+		}
+		if kept.DefaultBilling && address.DefaultBilling {
+			address.DefaultBilling = false
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+
+		address.UpdatedAt = kept.UpdatedAt
+		if err := s.addresses.Update(ctx, address); err != nil {
+			s.logger.Error("Failed to update address", zap.String("address_id", address.ID), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// applyAddressRequest copies a checked request onto address
+func applyAddressRequest(address *Address, req AddressRequest, now time.Time) {
+	address.Label = req.Label
+	address.Name = req.Name
+	address.Company = req.Company
+	address.Line1 = req.Line1
+	address.Line2 = req.Line2
+	address.City = req.City
+	address.Region = req.Region
+	address.PostalCode = req.PostalCode
+	address.Country = req.Country
+	address.Phone = req.Phone
+	address.DefaultShipping = req.DefaultShipping
+	address.DefaultBilling = req.DefaultBilling
+	address.UpdatedAt = now
+}
+
+// checkAddress trims the fields of req and upper-cases its country and postal code, and
+// returns an AddressError when they do not fit the format of the country
+func checkAddress(req AddressRequest) (AddressRequest, error) {
+	req.Label = strings.TrimSpace(req.Label)
+	req.Name = strings.TrimSpace(req.Name)
+	req.Company = strings.TrimSpace(req.Company)
+	req.Line1 = strings.TrimSpace(req.Line1)
+	req.Line2 = strings.TrimSpace(req.Line2)
+	req.City = strings.TrimSpace(req.City)
+	req.Region = strings.TrimSpace(req.Region)
+	req.PostalCode = strings.ToUpper(strings.Join(strings.Fields(req.PostalCode), " "))
+	req.Country = strings.ToUpper(strings.TrimSpace(req.Country))
+	req.Phone = strings.TrimSpace(req.Phone)
+
+	var violations []AddressViolation
+	violate := func(field, message string) {
+		violations = append(violations, AddressViolation{Field: field, Message: message})
+	}
+
+	// Fields the validator required may have held only spaces
+	required := []struct{ field, value string }{
+		{"Label", req.Label}, {"Name", req.Name}, {"Line1", req.Line1}, {"City", req.City},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			violate(r.field, "required")
+		}
+	}
+
+	if countryCodes.Var(req.Country, "iso3166_1_alpha2") != nil {
+		violate("Country", "country")
+	} else {
+		format := addressFormats[req.Country]
+		if format.regionRequired && req.Region == "" {
+			violate("Region", "required")
+		}
+		switch {
+		case req.PostalCode == "":
+			if format.postalCodeRequired {
+				violate("PostalCode", "required")
+			}
+		case format.postalCode != nil:
+			if !format.postalCode.MatchString(req.PostalCode) {
+				violate("PostalCode", "postal_code")
+			}
+		case !genericPostalCode.MatchString(req.PostalCode):
+			violate("PostalCode", "postal_code")
+		}
+	}
+
+	if req.Phone != "" && !phoneNumber.MatchString(req.Phone) {
+		violate("Phone", "phone")
+	}
+
+	if len(violations) > 0 {
+		return req, &AddressError{Violations: violations}
+	}
+	return req, nil
+}
@@ -0,0 +1,113 @@
+package user
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// AddressRepository defines the interface for address book data access
+type AddressRepository interface {
+	Create(ctx context.Context, address *Address) error
+	FindByID(ctx context.Context, id string) (*Address, error)
+	ListByUser(ctx context.Context, userID string) ([]*Address, error)
+	Update(ctx context.Context, address *Address) error
+	Delete(ctx context.Context, id string) error
+	DeleteUserAddresses(ctx context.Context, userID string) error
+}
+
+// InMemoryAddressRepository implements AddressRepository using in-memory storage.
+// Addresses are stored and returned as copies.
+type InMemoryAddressRepository struct {
+	addresses map[string]*Address
+	mutex     sync.RWMutex
+}
+
+// NewInMemoryAddressRepository creates a new in-memory address repository
+func NewInMemoryAddressRepository() *InMemoryAddressRepository {
+	return &InMemoryAddressRepository{
+		addresses: make(map[string]*Address),
+	}
+}
+
+// Create stores a new address
+func (r *InMemoryAddressRepository) Create(ctx context.Context, address *Address) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := *address
+	r.addresses[address.ID] = &stored
+	return nil
+}
+
+// FindByID finds an address by ID
+func (r *InMemoryAddressRepository) FindByID(ctx context.Context, id string) (*Address, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	address, exists := r.addresses[id]
+	if !exists {
+		return nil, ErrAddressNotFound
+	}
+
+	found := *address
+	return &found, nil
+}
+
+// ListByUser lists a user's addresses, oldest first
+func (r *InMemoryAddressRepository) ListByUser(ctx context.Context, userID string) ([]*Address, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	addresses := make([]*Address, 0)
+	for _, address := range r.addresses {
+		if address.UserID == userID {
+			found := *address
+			addresses = append(addresses, &found)
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].CreatedAt.Before(addresses[j].CreatedAt)
+	})
+
+	return addresses, nil
+}
+
+// Update replaces a stored address
+func (r *InMemoryAddressRepository) Update(ctx context.Context, address *Address) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.addresses[address.ID]; !exists {
+		return ErrAddressNotFound
+	}
+
+	stored := *address
+	r.addresses[address.ID] = &stored
+	return nil
+}
+
+// Delete deletes an address
+func (r *InMemoryAddressRepository) Delete(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.addresses[id]; !exists {
+		return ErrAddressNotFound
+	}
+	delete(r.addresses, id)
+	return nil
+}
+
+// DeleteUserAddresses deletes every address of a user
+func (r *InMemoryAddressRepository) DeleteUserAddresses(ctx context.Context, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, address := range r.addresses {
+		if address.UserID == userID {
+			delete(r.addresses, id)
+		}
+	}
+	return nil
+}
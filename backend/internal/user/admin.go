@@ -0,0 +1,198 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	"go.uber.org/zap"
+)
+
+// ListUsers lists users matching filters for admins, oldest first
+func (s *service) ListUsers(ctx context.Context, filters UserFilters) (*UserList, error) {
+	s.logger.Debug("Listing users", zap.Any("filters", filters))
+
+	// Set defaults
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 10
+	}
+	if filters.PageSize > 100 {
+		filters.PageSize = 100
+	}
+
+	users, totalCount, err := s.repo.List(ctx, filters)
+	if err != nil {
+		s.logger.Error("Failed to list users", zap.Error(err))
+		return nil, err
+	}
+
+	totalPages := (totalCount + filters.PageSize - 1) / filters.PageSize
+
+	return &UserList{
+		Users:      users,
+		TotalCount: totalCount,
+		Page:       filters.Page,
+		PageSize:   filters.PageSize,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// DisableUser stops a user from logging in or using the API and signs them out everywhere
+func (s *service) DisableUser(ctx context.Context, userID, adminID string) (*User, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return user, nil
+	}
+	if err := s.ensureOtherAdmin(ctx, user); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.Disabled = true
+	user.DisabledAt = &now
+	// A login waiting for its second factor must not complete
+	user.MFAChallengeID = ""
+	user.UpdatedAt = now
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
+	if err := s.sessions.RevokeUserSessions(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to revoke sessions", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
+
+	audit.SecurityEvent(s.logger, "user_disabled", zap.String("user_id", user.ID), zap.String("admin_id", adminID))
+	return user, nil
+}
+
+// EnableUser lets a disabled user log in again
+func (s *service) EnableUser(ctx context.Context, userID, adminID string) (*User, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Disabled {
+		return user, nil
+	}
+
+	user.Disabled = false
+	user.DisabledAt = nil
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
+
+	audit.SecurityEvent(s.logger, "user_enabled", zap.String("user_id", user.ID), zap.String("admin_id", adminID))
+	return user, nil
+}
+
+// ForcePasswordReset signs a user out everywhere and keeps them out until they choose a
+// new password through the reset link emailed to them, e.g. after a suspected compromise
+func (s *service) ForcePasswordReset(ctx context.Context, userID, adminID string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordResetRequired = true
+	user.MFAChallengeID = ""
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	if err := s.sessions.RevokeUserSessions(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to revoke sessions", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	// Keys may have been created by whoever is suspected of using the account
+	if err := s.apiKeys.RevokeUserKeys(ctx, user.ID, time.Now()); err != nil {
+		s.logger.Error("Failed to revoke API keys", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	audit.SecurityEvent(s.logger, "password_reset_forced", zap.String("user_id", user.ID), zap.String("admin_id", adminID))
+
+	link, err := s.newResetLink(ctx, user)
+	if err != nil {
+		return err
+	}
+	s.notify(ctx, user.Email, "Choose a new password",
+		fmt.Sprintf("Hi %s,\n\nAn administrator has signed you out and asked you to choose a new password before logging in again. Use this link to choose one:\n\n%s\n\nThis link expires in %s and can be used once. If it expires, use \"Forgot password\" to get a new one.\n",
+			user.Name, link, s.cfg.PasswordResetTokenTTL))
+	return nil
+}
+
+// deleteAccount deletes a user along with their sessions, reset tokens, API keys,
+// addresses, failed logins and wrong MFA codes. The user goes last, so a deletion that fails part way can
+// be retried.
+func (s *service) deleteAccount(ctx context.Context, user *User) error {
+	if err := s.sessions.DeleteUserSessions(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to delete sessions", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	if err := s.resets.DeleteUserTokens(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to delete password reset tokens", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	if err := s.apiKeys.DeleteUserKeys(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to delete API keys", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	if err := s.addresses.DeleteUserAddresses(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to delete addresses", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	for _, key := range []string{accountAttemptKey(user.Email), mfaAttemptKey(user.ID)} {
+		if err := s.attempts.Reset(ctx, key); err != nil {
+			s.logger.Error("Failed to reset login attempts", zap.String("user_id", user.ID), zap.Error(err))
+			return err
+		}
+	}
+	if err := s.repo.Delete(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to delete user", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// EnsureActive returns ErrAccountDisabled or ErrPasswordResetRequired when the user may
+// no longer use the access tokens they hold, and ErrUserNotFound once they are deleted
+func (s *service) EnsureActive(ctx context.Context, userID string) error {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		if err != ErrUserNotFound {
+			s.logger.Error("Failed to find user", zap.String("user_id", userID), zap.Error(err))
+		}
+		return err
+	}
+	return checkActive(user)
+}
+
+// findUser finds a user by ID, logging failures other than the user not existing
+func (s *service) findUser(ctx context.Context, userID string) (*User, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil && err != ErrUserNotFound {
+		s.logger.Error("Failed to find user", zap.String("user_id", userID), zap.Error(err))
+	}
+	return user, err
+}
+
+// checkActive returns why user may not sign in, if anything
+func checkActive(user *User) error {
+	if user.Disabled {
+		return ErrAccountDisabled
+	}
+	if user.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
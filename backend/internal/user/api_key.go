@@ -0,0 +1,202 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAPIKeyTTL = 90 * 24 * time.Hour
+	// apiKeyPrefixTag starts every API key, so leaked keys are easy to recognize
+	apiKeyPrefixTag = "angidi_"
+	// apiKeyPrefixBytes is the randomness in the visible prefix keys are looked up by, and
+	// apiKeySecretBytes in the secret part after it
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+)
+
+// apiKeyPrefixLength is the length of the visible prefix of an API key
+var apiKeyPrefixLength = len(apiKeyPrefixTag) + hex.EncodedLen(apiKeyPrefixBytes)
+
+// CreateAPIKey creates an API key for a user. It needs at least one scope, and scopes must
+// be permissions the user has. The key is returned only this once.
+func (s *service) CreateAPIKey(ctx context.Context, userID string, req CreateAPIKeyRequest) (*NewAPIKey, error) {
+	if len(req.Scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := s.permissionsFor(user.RoleNames())
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !containsString(permissions, scope) {
+			return nil, ErrInvalidScope
+		}
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	prefix, secret, err := newAPIKeySecret()
+	if err != nil {
+		s.logger.Error("Failed to generate API key", zap.Error(err))
+		return nil, err
+	}
+
+	ttl := defaultAPIKeyTTL
+	if req.ExpiresInDays > 0 {
+		ttl = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	now := time.Now()
+	key := &APIKey{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(secret),
+		Scopes:    scopes,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.apiKeys.Create(ctx, key); err != nil {
+		s.logger.Error("Failed to store API key", zap.Error(err))
+		return nil, err
+	}
+
+	audit.SecurityEvent(s.logger, "api_key_created", zap.String("user_id", user.ID), zap.String("api_key_id", key.ID), zap.Strings("scopes", scopes))
+	return &NewAPIKey{APIKey: key, Key: secret}, nil
+}
+
+// ListAPIKeys lists a user's API keys, newest first, including expired and revoked ones
+func (s *service) ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	keys, err := s.apiKeys.ListByUser(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list API keys", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of a user's API keys. actorID is the user or admin revoking it.
+func (s *service) RevokeAPIKey(ctx context.Context, userID, keyID, actorID string) error {
+	key, err := s.apiKeys.FindByID(ctx, keyID)
+	if err != nil {
+		return err
+	}
+	// Other users' keys look the same as unknown ones
+	if key.UserID != userID {
+		return ErrAPIKeyNotFound
+	}
+
+	if err := s.apiKeys.Revoke(ctx, key.ID, time.Now()); err != nil {
+		s.logger.Error("Failed to revoke API key", zap.String("api_key_id", key.ID), zap.Error(err))
+		return err
+	}
+
+	audit.SecurityEvent(s.logger, "api_key_revoked", zap.String("user_id", key.UserID), zap.String("api_key_id", key.ID), zap.String("actor_id", actorID))
+	return nil
+}
+
+// AuthenticateAPIKey returns the claims a request made with an API key acts with: those
+// of its user, with permissions narrowed to the key's scopes and AuthMethodAPIKey as the
+// only authentication method, so routes requiring MFA refuse the key. The use is recorded
+// against the key. Disabled users are left to the same checks as access tokens.
+func (s *service) AuthenticateAPIKey(ctx context.Context, secret, ipAddress string) (*jwtPkg.Claims, error) {
+	if len(secret) <= apiKeyPrefixLength || !strings.HasPrefix(secret, apiKeyPrefixTag) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeys.FindByPrefix(ctx, secret[:apiKeyPrefixLength])
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || !now.Before(key.ExpiresAt) {
+		s.logger.Warn("Expired or revoked API key presented", zap.String("api_key_id", key.ID))
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.repo.FindByID(ctx, key.UserID)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, ErrInvalidAPIKey
+		}
+		s.logger.Error("Failed to find user", zap.String("user_id", key.UserID), zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.apiKeys.RecordUse(ctx, key.ID, now, ipAddress); err != nil {
+		s.logger.Error("Failed to record API key use", zap.String("api_key_id", key.ID), zap.Error(err))
+	}
+
+	// Roles may have changed since the key was created, so scopes only ever narrow them
+	return &jwtPkg.Claims{
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+		Roles:         user.RoleNames(),
+		Permissions:   narrowPermissions(s.permissionsFor(user.RoleNames()), key.Scopes),
+		AMR:           []string{jwtPkg.AuthMethodAPIKey},
+		APIKeyID:      key.ID,
+	}, nil
+}
+
+// ScopedClaims returns the access token claims of an active user with their permissions
+// narrowed to scopes, for tokens issued to third-party applications on their behalf.
+// Without scopes the user acts without any permissions.
+func (s *service) ScopedClaims(ctx context.Context, userID string, scopes []string) (*jwtPkg.Claims, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkActive(user); err != nil {
+		return nil, err
+	}
+
+	return &jwtPkg.Claims{
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+		Roles:         user.RoleNames(),
+		Permissions:   narrowPermissions(s.permissionsFor(user.RoleNames()), scopes),
+	}, nil
+}
+
+// narrowPermissions returns the permissions that are also scopes
+func narrowPermissions(permissions, scopes []string) []string {
+	scoped := make([]string, 0, len(scopes))
+	for _, permission := range permissions {
+		if containsString(scopes, permission) {
+			scoped = append(scoped, permission)
+		}
+	}
+	return scoped
+}
+
+// newAPIKeySecret returns a random API key and its visible prefix
+func newAPIKeySecret() (prefix, secret string, err error) {
+	b := make([]byte, apiKeyPrefixBytes+apiKeySecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = apiKeyPrefixTag + hex.EncodeToString(b[:apiKeyPrefixBytes])
+	return prefix, prefix + "_" + base64.RawURLEncoding.EncodeToString(b[apiKeyPrefixBytes:]), nil
+}
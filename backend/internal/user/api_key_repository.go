@@ -0,0 +1,158 @@
+package user
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// APIKeyRepository defines the interface for API key data access. Keys are looked up by
+// their prefix, which is unique.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	FindByID(ctx context.Context, id string) (*APIKey, error)
+	FindByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	ListByUser(ctx context.Context, userID string) ([]*APIKey, error)
+	RecordUse(ctx context.Context, id string, at time.Time, ipAddress string) error
+	Revoke(ctx context.Context, id string, at time.Time) error
+	RevokeUserKeys(ctx context.Context, userID string, at time.Time) error
+	DeleteUserKeys(ctx context.Context, userID string) error
+}
+
+// InMemoryAPIKeyRepository implements APIKeyRepository using in-memory storage. Keys are
+// stored and returned as copies.
+type InMemoryAPIKeyRepository struct {
+	keys     map[string]*APIKey
+	byPrefix map[string]*APIKey
+	mutex    sync.RWMutex
+}
+
+// NewInMemoryAPIKeyRepository creates a new in-memory API key repository
+func NewInMemoryAPIKeyRepository() *InMemoryAPIKeyRepository {
+	return &InMemoryAPIKeyRepository{
+		keys:     make(map[string]*APIKey),
+		byPrefix: make(map[string]*APIKey),
+	}
+}
+
+// Create stores a new API key
+func (r *InMemoryAPIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.byPrefix[key.Prefix]; exists {
+		return ErrInvalidAPIKey
+	}
+
+	stored := *key
+	r.keys[key.ID] = &stored
+	r.byPrefix[key.Prefix] = &stored
+	return nil
+}
+
+// FindByID finds an API key by ID
+func (r *InMemoryAPIKeyRepository) FindByID(ctx context.Context, id string) (*APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	found := *key
+	return &found, nil
+}
+
+// FindByPrefix finds an API key by its visible prefix
+func (r *InMemoryAPIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	key, exists := r.byPrefix[prefix]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	found := *key
+	return &found, nil
+}
+
+// ListByUser lists a user's API keys, newest first
+func (r *InMemoryAPIKeyRepository) ListByUser(ctx context.Context, userID string) ([]*APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]*APIKey, 0)
+	for _, key := range r.keys {
+		if key.UserID == userID {
+			found := *key
+			keys = append(keys, &found)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+// RecordUse records when and from where an API key was last used
+func (r *InMemoryAPIKeyRepository) RecordUse(ctx context.Context, id string, at time.Time, ipAddress string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return ErrAPIKeyNotFound
+	}
+
+	key.LastUsedAt = &at
+	key.LastUsedIP = ipAddress
+	return nil
+}
+
+// Revoke revokes an API key. Revoking an already revoked key keeps its first revocation time.
+func (r *InMemoryAPIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+	}
+	return nil
+}
+
+// RevokeUserKeys revokes every API key of a user
+func (r *InMemoryAPIKeyRepository) RevokeUserKeys(ctx context.Context, userID string, at time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, key := range r.keys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			revokedAt := at
+			key.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+// DeleteUserKeys deletes every API key of a user
+func (r *InMemoryAPIKeyRepository) DeleteUserKeys(ctx context.Context, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, key := range r.keys {
+		if key.UserID == userID {
+			delete(r.keys, id)
+			delete(r.byPrefix, key.Prefix)
+		}
+	}
+	return nil
+}
@@ -2,13 +2,12 @@ package user
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // BootstrapAdmin creates the initial admin user if no admin exists.
@@ -44,15 +43,15 @@ func (s *service) BootstrapAdmin(ctx context.Context) error {
 	}
 
 	// Validate password strength
-	if len(password) < 12 {
-		s.logger.Error("Admin password does not meet minimum requirements (12 characters)")
-		return errors.New("admin password must be at least 12 characters")
+	if err := s.checkPassword(ctx, password, []string{RoleAdmin}, email, name); err != nil {
+		s.logger.Error("Admin password does not meet the password policy", zap.Error(err))
+		return fmt.Errorf("admin password: %w", err)
 	}
 
 	s.logger.Info("Creating initial admin user", zap.String("email", email))
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	hashedPassword, err := s.cfg.PasswordHashers.Hash(password)
 	if err != nil {
 		s.logger.Error("Failed to hash admin password", zap.Error(err))
 		return err
@@ -63,11 +62,14 @@ func (s *service) BootstrapAdmin(ctx context.Context) error {
 	admin := &User{
 		ID:           uuid.New().String(),
 		Email:        email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 		Name:         name,
-		Role:         "admin",
-		CreatedAt:    now,
-		UpdatedAt:    now,
+		Role:         RoleAdmin,
+		Roles:        []string{RoleAdmin},
+		// The address comes from trusted configuration rather than user input
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 
 	if err := s.repo.Create(ctx, admin); err != nil {
@@ -6,10 +6,8 @@ import (
 	"testing"
 	"time"
 
-	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -74,9 +72,7 @@ func TestService_BootstrapAdmin(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup
 			repo := NewInMemoryRepository()
-			jwtService := jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour)
-			logger, _ := zap.NewDevelopment()
-			service := NewService(repo, jwtService, logger)
+			service := newTestService(repo, &captureMailer{}, Config{})
 
 			// Create existing admin if needed
 			if tt.existingAdmin {
@@ -0,0 +1,230 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/mailer"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/password"
+	"go.uber.org/zap"
+)
+
+// ChangePassword changes the password of a user who knows their current one. Every other
+// session is signed out; the returned tokens start a new session for the calling client.
+func (s *service) ChangePassword(ctx context.Context, userID string, req ChangePasswordRequest, client ClientInfo) (*AuthResponse, error) {
+	user, err := s.findWithPassword(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPassword(ctx, req.NewPassword, user.RoleNames(), user.Email, user.Name); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := s.cfg.PasswordHashers.Hash(req.NewPassword)
+	if err != nil {
+		s.logger.Error("Failed to hash password", zap.Error(err))
+		return nil, err
+	}
+
+	user.PasswordHash = passwordHash
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.sessions.RevokeUserSessions(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to revoke sessions", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
+	// Only the password was proven here, so the new session is not MFA authenticated
+	authResp, err := s.issueTokens(ctx, user, uuid.New().String(), "", []string{jwtPkg.AuthMethodPassword}, client)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(ctx, user.Email, "Your password was changed",
+		fmt.Sprintf("Hi %s,\n\nThe password of your account was just changed and your other sessions were signed out. If this was not you, reset your password right away.\n", user.Name))
+
+	s.logger.Info("Password changed", zap.String("user_id", user.ID))
+	return authResp, nil
+}
+
+// RequestEmailChange emails a confirmation link to the new address. The account keeps its
+// current address until the link is followed.
+func (s *service) RequestEmailChange(ctx context.Context, userID string, req ChangeEmailRequest) (*User, error) {
+	user, err := s.findWithPassword(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.FindByEmail(ctx, req.NewEmail); err == nil {
+		return nil, ErrEmailAlreadyExists
+	} else if err != ErrUserNotFound {
+		s.logger.Error("Failed to check existing user", zap.Error(err))
+		return nil, err
+	}
+
+	token, claims, err := s.jwtService.GenerateActionToken(jwtPkg.TokenTypeEmailChange, user.ID, req.NewEmail, s.cfg.VerificationTokenTTL)
+	if err != nil {
+		s.logger.Error("Failed to generate email change token", zap.Error(err))
+		return nil, err
+	}
+
+	user.PendingEmail = req.NewEmail
+	user.EmailChangeID = claims.ID
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return nil, err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      req.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that you want to use this address for your account:\n\n%s\n\nThis link expires in %s. If you did not ask for this change, you can ignore this email.\n",
+			user.Name, tokenLink(s.cfg.ConfirmEmailChangeURL, token), s.cfg.VerificationTokenTTL),
+	})
+	if err != nil {
+		s.logger.Error("Failed to send email change confirmation", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Email change requested", zap.String("user_id", user.ID))
+	return user, nil
+}
+
+// ConfirmEmailChange switches a user to the address an email change token was sent to
+func (s *service) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	claims, err := s.jwtService.ValidateActionToken(token, jwtPkg.TokenTypeEmailChange)
+	if err != nil {
+		s.logger.Warn("Invalid email change token", zap.Error(err))
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := s.repo.FindByID(ctx, claims.Subject)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, ErrInvalidVerificationToken
+		}
+		s.logger.Error("Failed to find user", zap.String("user_id", claims.Subject), zap.Error(err))
+		return nil, err
+	}
+	if user.EmailChangeID == "" || user.EmailChangeID != claims.ID || user.PendingEmail != claims.Email {
+		s.logger.Warn("Stale email change token presented", zap.String("user_id", user.ID))
+		return nil, ErrInvalidVerificationToken
+	}
+
+	previousEmail := user.Email
+	user.Email = user.PendingEmail
+	user.EmailVerified = true
+	user.EmailVerificationID = ""
+	user.PendingEmail = ""
+	user.EmailChangeID = ""
+	user.UpdatedAt = time.Now()
+	// The repository rejects the change if someone registered the address in the meantime
+	if err := s.repo.Update(ctx, user); err != nil {
+		if err != ErrEmailAlreadyExists {
+			s.logger.Error("Failed to update user", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	s.notify(ctx, previousEmail, "Your email address was changed",
+		fmt.Sprintf("Hi %s,\n\nYour account now uses %s instead of this address. If this was not you, contact support right away.\n", user.Name, user.Email))
+
+	s.logger.Info("Email changed", zap.String("user_id", user.ID))
+	return user, nil
+}
+
+// findWithPassword finds a user and checks their current password. Wrong passwords count
+// towards the same lockout as failed logins, so a stolen access token cannot be used to
+// guess the password.
+func (s *service) findWithPassword(ctx context.Context, userID, password string) (*User, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to find user", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	accountKey := accountAttemptKey(user.Email)
+	if err := s.checkLoginThrottle(ctx, accountKey, "", time.Now()); err != nil {
+		s.logger.Warn("Throttled account change", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	if !s.verifyPassword(ctx, user, password) {
+		s.logger.Warn("Account change with incorrect password", zap.String("user_id", userID))
+		s.recordLoginFailure(ctx, user, user.Email, accountKey, "", ClientInfo{})
+		return nil, ErrIncorrectPassword
+	}
+	if err := s.attempts.Reset(ctx, accountKey); err != nil {
+		s.logger.Error("Failed to reset login attempts", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// verifyPassword checks the password of a user. A stored hash made with another algorithm
+// or outdated parameters is replaced while the password is at hand; only the hash is
+// written, so changes made to the user since it was read are kept.
+func (s *service) verifyPassword(ctx context.Context, user *User, password string) bool {
+	// Users created by an identity provider login have no password until they reset it
+	if user.PasswordHash == "" {
+		s.compareDummyPassword(password)
+		return false
+	}
+	ok, rehash, err := s.cfg.PasswordHashers.Verify(user.PasswordHash, password)
+	if err != nil {
+		s.logger.Error("Failed to verify password hash", zap.String("user_id", user.ID), zap.Error(err))
+		return false
+	}
+	if !ok || !rehash {
+		return ok
+	}
+
+	// The password was right, so failing to upgrade its hash must not fail the caller
+	passwordHash, err := s.cfg.PasswordHashers.Hash(password)
+	if err != nil {
+		s.logger.Error("Failed to rehash password", zap.String("user_id", user.ID), zap.Error(err))
+		return true
+	}
+	if err := s.repo.UpdatePasswordHash(ctx, user.ID, user.PasswordHash, passwordHash); err != nil {
+		if err == ErrPasswordChanged {
+			// The password that matched is no longer the user's
+			s.logger.Warn("Password changed while verifying it", zap.String("user_id", user.ID))
+			return false
+		}
+		s.logger.Error("Failed to store rehashed password", zap.String("user_id", user.ID), zap.Error(err))
+		return true
+	}
+	user.PasswordHash = passwordHash
+
+	s.logger.Info("Password hash upgraded", zap.String("user_id", user.ID))
+	return true
+}
+
+// checkPassword checks a new password for an account against the password policy,
+// returning a password.PolicyError listing its violations
+func (s *service) checkPassword(ctx context.Context, newPassword string, roles []string, email, name string) error {
+	err := s.cfg.PasswordPolicy.Check(ctx, newPassword, containsString(roles, RoleAdmin), email, name)
+	if err != nil {
+		var policyErr *password.PolicyError
+		if !errors.As(err, &policyErr) {
+			s.logger.Error("Failed to check password policy", zap.Error(err))
+		}
+	}
+	return err
+}
+
+// notify sends a security notice. The change it reports has already happened, so failures
+// are only logged.
+func (s *service) notify(ctx context.Context, to, subject, body string) {
+	if err := s.mailer.Send(ctx, mailer.Message{To: to, Subject: subject, Body: body}); err != nil {
+		s.logger.Error("Failed to send notification", zap.String("subject", subject), zap.Error(err))
+	}
+}
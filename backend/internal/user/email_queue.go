@@ -0,0 +1,107 @@
+package user
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// emailWorkers is the number of account emails sent at the same time
+	emailWorkers = 4
+	// emailQueueSize is the number of account emails that can wait for a worker
+	emailQueueSize = 256
+)
+
+// emailJob prepares and sends one account email
+type emailJob struct {
+	ctx  context.Context
+	name string
+	run  func(ctx context.Context) error
+}
+
+// emailQueue sends account emails after the request asking for them has been answered,
+// so that requests for known and unknown addresses take the same time. A fixed number of
+// workers take jobs from a bounded queue; when the queue is full further jobs are dropped
+// and logged rather than holding up the request.
+type emailQueue struct {
+	jobs   chan emailJob
+	logger *zap.Logger
+
+	// mutex guards closed, which stops jobs being queued once close has been called
+	mutex  sync.Mutex
+	closed bool
+	// pending tracks queued jobs until they finish, workers the running workers
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+}
+
+func newEmailQueue(logger *zap.Logger) *emailQueue {
+	q := &emailQueue{
+		jobs:   make(chan emailJob, emailQueueSize),
+		logger: logger,
+	}
+	q.workers.Add(emailWorkers)
+	for i := 0; i < emailWorkers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// enqueue queues run to be called with a context carrying the values of ctx but not its
+// cancellation, as the request may be over before the job runs. name describes the email
+// in logs.
+func (q *emailQueue) enqueue(ctx context.Context, name string, run func(ctx context.Context) error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		q.logger.Error("Account email dropped after shutdown", zap.String("email_type", name))
+		return
+	}
+
+	q.pending.Add(1)
+	select {
+	case q.jobs <- emailJob{ctx: context.WithoutCancel(ctx), name: name, run: run}:
+	default:
+		q.pending.Done()
+		q.logger.Error("Account email dropped, queue is full", zap.String("email_type", name))
+	}
+}
+
+func (q *emailQueue) work() {
+	defer q.workers.Done()
+	for job := range q.jobs {
+		if err := job.run(job.ctx); err != nil {
+			q.logger.Error("Failed to send account email", zap.String("email_type", job.name), zap.Error(err))
+		}
+		q.pending.Done()
+	}
+}
+
+// wait blocks until every queued job has finished
+func (q *emailQueue) wait() {
+	q.pending.Wait()
+}
+
+// close stops accepting jobs and waits for the queued ones to finish, or for ctx to be done
+func (q *emailQueue) close(ctx context.Context) error {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
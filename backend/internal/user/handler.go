@@ -1,25 +1,43 @@
 package user
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"path"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/password"
 	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
 	"go.uber.org/zap"
 )
 
+// Eraser erases a user's personal data from every part of the application keeping some,
+// implemented by privacy.Service
+type Eraser interface {
+	EraseNow(ctx context.Context, userID, adminID string) error
+}
+
 // Handler handles HTTP requests for user operations
 type Handler struct {
 	service   Service
+	eraser    Eraser
 	validator *validator.Validate
 	logger    *zap.Logger
 }
 
-// NewHandler creates a new user handler
-func NewHandler(service Service, logger *zap.Logger) *Handler {
+// NewHandler creates a new user handler. Users deleted by admins are erased through
+// eraser, so that the other modules forget them too.
+func NewHandler(service Service, eraser Eraser, logger *zap.Logger) *Handler {
 	return &Handler{
 		service:   service,
+		eraser:    eraser,
 		validator: validator.New(),
 		logger:    logger,
 	}
@@ -52,6 +70,9 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 			response.WriteError(w, http.StatusConflict, "EMAIL_EXISTS", "Email already registered", "")
 			return
 		}
+		if writePasswordPolicyError(w, "Password", err) {
+			return
+		}
 		h.logger.Error("Failed to register user", zap.Error(err))
 		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
 		return
@@ -81,12 +102,22 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authResp, err := h.service.Login(r.Context(), req)
+	authResp, err := h.service.Login(r.Context(), req, clientInfo(r))
 	if err != nil {
 		if err == ErrInvalidCredentials {
 			response.WriteError(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid email or password", "")
 			return
 		}
+		if writeInactiveAccountError(w, http.StatusForbidden, err) {
+			return
+		}
+		if err == ErrEmailNotVerified {
+			response.WriteError(w, http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Email address has not been verified", "")
+			return
+		}
+		if writeThrottledError(w, err) {
+			return
+		}
 		h.logger.Error("Failed to login user", zap.Error(err))
 		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
 		return
@@ -95,6 +126,104 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	response.WriteSuccess(w, http.StatusOK, authResp)
 }
 
+// ListOIDCProviders handles listing the identity providers users can log in with
+func (h *Handler) ListOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	response.WriteSuccess(w, http.StatusOK, h.service.OIDCProviders())
+}
+
+// StartOIDCLogin handles starting a login with an identity provider by redirecting to its
+// login page
+func (h *Handler) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, browserKey, err := h.service.StartOIDCLogin(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		h.writeOIDCError(w, err)
+		return
+	}
+
+	// Only the callback of this provider, next to this endpoint, needs the key back
+	http.SetCookie(w, oidcLoginCookie(r, browserKey, int(oidcLoginTTL/time.Second)))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// CompleteOIDCLogin handles the authorization code and state an identity provider sent
+// the user back to the frontend with, logging them in like Login
+func (h *Handler) CompleteOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	var req OIDCCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	// The key of the browser that started the login is needed once, whatever the outcome
+	var browserKey string
+	if cookie, err := r.Cookie(oidcLoginCookieName); err == nil {
+		browserKey = cookie.Value
+	}
+	http.SetCookie(w, oidcLoginCookie(r, "", -1))
+
+	authResp, err := h.service.CompleteOIDCLogin(r.Context(), chi.URLParam(r, "provider"), req, browserKey, clientInfo(r))
+	if err != nil {
+		if writeInactiveAccountError(w, http.StatusForbidden, err) {
+			return
+		}
+		if err == ErrEmailNotVerified {
+			response.WriteError(w, http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Email address has not been verified", "")
+			return
+		}
+		h.writeOIDCError(w, err)
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, authResp)
+}
+
+// oidcLoginCookie returns the cookie keeping the browser key of an identity provider login,
+// scoped to the provider's endpoints, which the request is made to. A negative maxAge
+// deletes it.
+func oidcLoginCookie(r *http.Request, browserKey string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcLoginCookieName,
+		Value:    browserKey,
+		Path:     path.Dir(r.URL.Path),
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// writeOIDCError writes the response for errors of identity provider logins
+func (h *Handler) writeOIDCError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrUnknownProvider:
+		response.WriteError(w, http.StatusNotFound, "UNKNOWN_PROVIDER", "Identity provider not found", "")
+	case ErrProviderUnavailable:
+		response.WriteError(w, http.StatusBadGateway, "PROVIDER_UNAVAILABLE", "Identity provider is unavailable, try again later", "")
+	case ErrInvalidOIDCState:
+		response.WriteError(w, http.StatusBadRequest, "INVALID_STATE", "Login expired or was already completed, start again", "")
+	case ErrOIDCLoginFailed:
+		response.WriteError(w, http.StatusUnauthorized, "EXTERNAL_LOGIN_FAILED", "Identity provider did not confirm the login", "")
+	case ErrAccountExists:
+		response.WriteError(w, http.StatusConflict, "ACCOUNT_EXISTS", "An account with this email already exists, log in with your password", "")
+	default:
+		h.logger.Error("Failed to log in with identity provider", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
+
 // GetProfile handles getting user profile
 func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context (set by auth middleware)
@@ -181,11 +310,1323 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authResp, err := h.service.RefreshToken(r.Context(), req.RefreshToken)
+	authResp, err := h.service.RefreshToken(r.Context(), req.RefreshToken, clientInfo(r))
 	if err != nil {
-		response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or expired refresh token", "")
+		if writeInactiveAccountError(w, http.StatusUnauthorized, err) {
+			return
+		}
+		switch err {
+		case ErrRefreshTokenReused:
+			response.WriteError(w, http.StatusUnauthorized, "TOKEN_REUSED", "Refresh token has already been used; the session has been revoked", "")
+		case ErrRefreshTokenRevoked:
+			response.WriteError(w, http.StatusUnauthorized, "TOKEN_REVOKED", "Refresh token has been revoked", "")
+		case jwtPkg.ErrWrongTokenType:
+			response.WriteError(w, http.StatusUnauthorized, "WRONG_TOKEN_TYPE", "An access token cannot be used as a refresh token", "")
+		default:
+			response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or expired refresh token", "")
+		}
 		return
 	}
 
 	response.WriteSuccess(w, http.StatusOK, authResp)
 }
+
+// Logout handles ending the session a refresh token belongs to
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), req.RefreshToken); err != nil {
+		if err == jwtPkg.ErrWrongTokenType {
+			response.WriteError(w, http.StatusUnauthorized, "WRONG_TOKEN_TYPE", "An access token cannot be used as a refresh token", "")
+			return
+		}
+		if err == jwtPkg.ErrInvalidToken {
+			response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or expired refresh token", "")
+			return
+		}
+		h.logger.Error("Failed to log out", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll handles revoking every session of the current user
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.LogoutAll(r.Context(), userID); err != nil {
+		h.logger.Error("Failed to log out everywhere", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSessions handles listing the current user's active sessions
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	sessions, err := h.service.ListSessions(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to list sessions", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, sessions)
+}
+
+// RevokeSession handles signing out one of the current user's sessions
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.RevokeSession(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		if err == ErrSessionNotFound {
+			response.WriteError(w, http.StatusNotFound, "SESSION_NOT_FOUND", "Session not found", "")
+			return
+		}
+		h.logger.Error("Failed to revoke session", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateAPIKey handles creating an API key for the current user. The key is only
+// included in this response.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(r.Context(), userID, req)
+	if err != nil {
+		if err == ErrInvalidScope {
+			response.WriteError(w, http.StatusBadRequest, "INVALID_SCOPE", "Scopes must be permissions you have, and at least one is needed", "")
+			return
+		}
+		h.logger.Error("Failed to create API key", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, key)
+}
+
+// ListAPIKeys handles listing the current user's API keys
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	h.listAPIKeys(w, r, userID)
+}
+
+// RevokeAPIKey handles revoking one of the current user's API keys
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	h.revokeAPIKey(w, r, userID, chi.URLParam(r, "id"), userID)
+}
+
+// ListUserAPIKeys handles an admin listing the API keys of a user
+func (h *Handler) ListUserAPIKeys(w http.ResponseWriter, r *http.Request) {
+	h.listAPIKeys(w, r, chi.URLParam(r, "id"))
+}
+
+// RevokeUserAPIKey handles an admin revoking an API key of a user
+func (h *Handler) RevokeUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	h.revokeAPIKey(w, r, chi.URLParam(r, "id"), chi.URLParam(r, "keyId"), adminID)
+}
+
+// listAPIKeys writes the API keys of userID
+func (h *Handler) listAPIKeys(w http.ResponseWriter, r *http.Request, userID string) {
+	keys, err := h.service.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to list API keys", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, keys)
+}
+
+// revokeAPIKey revokes the API key keyID of userID on behalf of actorID
+func (h *Handler) revokeAPIKey(w http.ResponseWriter, r *http.Request, userID, keyID, actorID string) {
+	if err := h.service.RevokeAPIKey(r.Context(), userID, keyID, actorID); err != nil {
+		if err == ErrAPIKeyNotFound {
+			response.WriteError(w, http.StatusNotFound, "API_KEY_NOT_FOUND", "API key not found", "")
+			return
+		}
+		h.logger.Error("Failed to revoke API key", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateAddress handles saving an address to the current user's address book
+func (h *Handler) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	req, ok := h.decodeAddressRequest(w, r)
+	if !ok {
+		return
+	}
+
+	address, err := h.service.CreateAddress(r.Context(), userID, req)
+	if err != nil {
+		h.writeAddressError(w, err, "Failed to create address")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, address)
+}
+
+// ListAddresses handles listing the current user's addresses
+func (h *Handler) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	addresses, err := h.service.ListAddresses(r.Context(), userID)
+	if err != nil {
+		h.writeAddressError(w, err, "Failed to list addresses")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, addresses)
+}
+
+// GetAddress handles getting one of the current user's addresses
+func (h *Handler) GetAddress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	address, err := h.service.GetAddress(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeAddressError(w, err, "Failed to get address")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, address)
+}
+
+// UpdateAddress handles replacing one of the current user's addresses
+func (h *Handler) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	req, ok := h.decodeAddressRequest(w, r)
+	if !ok {
+		return
+	}
+
+	address, err := h.service.UpdateAddress(r.Context(), userID, chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.writeAddressError(w, err, "Failed to update address")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, address)
+}
+
+// DeleteAddress handles deleting one of the current user's addresses
+func (h *Handler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.DeleteAddress(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		h.writeAddressError(w, err, "Failed to delete address")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeAddressRequest decodes and validates an address request, writing the error
+// response and reporting false when it is invalid
+func (h *Handler) decodeAddressRequest(w http.ResponseWriter, r *http.Request) (AddressRequest, bool) {
+	var req AddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return req, false
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return req, false
+	}
+	return req, true
+}
+
+// writeAddressError maps errors of address book actions to HTTP responses. Addresses that
+// do not fit the format of their country are reported as validation errors.
+func (h *Handler) writeAddressError(w http.ResponseWriter, err error, logMessage string) {
+	var addressErr *AddressError
+	if errors.As(err, &addressErr) {
+		validationErrors := make([]response.ValidationError, 0, len(addressErr.Violations))
+		for _, violation := range addressErr.Violations {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   violation.Field,
+				Message: violation.Message,
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	switch err {
+	case ErrAddressNotFound:
+		response.WriteError(w, http.StatusNotFound, "ADDRESS_NOT_FOUND", "Address not found", "")
+	case ErrAddressLimitReached:
+		response.WriteError(w, http.StatusConflict, "ADDRESS_LIMIT_REACHED", "Delete an address before saving another", "")
+	case ErrUserNotFound:
+		response.WriteError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
+
+// UnlockUser handles an admin ending the lockout of an account
+func (h *Handler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.UnlockUser(r.Context(), chi.URLParam(r, "id"), adminID); err != nil {
+		if err == ErrUserNotFound {
+			response.WriteError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+			return
+		}
+		h.logger.Error("Failed to unlock user", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRoles handles listing the defined roles and their permissions
+func (h *Handler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	response.WriteSuccess(w, http.StatusOK, h.service.ListRoles(r.Context()))
+}
+
+// GetUserRoles handles an admin looking up the roles of a user
+func (h *Handler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.GetUserRoles(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeAdminUserError(w, err, "Failed to get user roles")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, roles)
+}
+
+// SetUserRoles handles an admin replacing the roles of a user
+func (h *Handler) SetUserRoles(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req SetRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	roles, err := h.service.SetUserRoles(r.Context(), chi.URLParam(r, "id"), adminID, req.Roles)
+	if err != nil {
+		if err == ErrUnknownRole {
+			response.WriteError(w, http.StatusBadRequest, "UNKNOWN_ROLE", "Unknown role", "")
+			return
+		}
+		h.writeAdminUserError(w, err, "Failed to set user roles")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, roles)
+}
+
+// ListUsers handles an admin listing users, filtered by ?search=, ?role= and ?disabled=
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	filters := UserFilters{
+		Search:   r.URL.Query().Get("search"),
+		Role:     r.URL.Query().Get("role"),
+		Page:     1,
+		PageSize: 10,
+	}
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if page, err := strconv.Atoi(pageStr); err == nil && page > 0 {
+			filters.Page = page
+		}
+	}
+
+	if pageSizeStr := r.URL.Query().Get("page_size"); pageSizeStr != "" {
+		if pageSize, err := strconv.Atoi(pageSizeStr); err == nil && pageSize > 0 {
+			filters.PageSize = pageSize
+		}
+	}
+
+	if disabledStr := r.URL.Query().Get("disabled"); disabledStr != "" {
+		if disabled, err := strconv.ParseBool(disabledStr); err == nil {
+			filters.Disabled = &disabled
+		}
+	}
+
+	userList, err := h.service.ListUsers(r.Context(), filters)
+	if err != nil {
+		h.logger.Error("Failed to list users", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, userList)
+}
+
+// GetUser handles an admin viewing a user
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.service.GetProfile(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeAdminUserError(w, err, "Failed to get user")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, user)
+}
+
+// DisableUser handles an admin disabling a user
+func (h *Handler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUserStatus(w, r, h.service.DisableUser, "Failed to disable user")
+}
+
+// EnableUser handles an admin enabling a disabled user
+func (h *Handler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUserStatus(w, r, h.service.EnableUser, "Failed to enable user")
+}
+
+// changeUserStatus runs an admin action that returns the changed user
+func (h *Handler) changeUserStatus(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, userID, adminID string) (*User, error), logMessage string) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	user, err := action(r.Context(), chi.URLParam(r, "id"), adminID)
+	if err != nil {
+		h.writeAdminUserError(w, err, logMessage)
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, user)
+}
+
+// ForcePasswordReset handles an admin making a user choose a new password
+func (h *Handler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	if err := h.service.ForcePasswordReset(r.Context(), chi.URLParam(r, "id"), adminID); err != nil {
+		h.writeAdminUserError(w, err, "Failed to force password reset")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteUser handles an admin deleting a user, which erases their personal data straight
+// away like a deletion they requested whose grace period has passed
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	// Erasing skips unknown users and refuses the only admin without saying why, so both
+	// are checked first to answer with the usual errors
+	userID := chi.URLParam(r, "id")
+	if _, err := h.service.GetProfile(r.Context(), userID); err != nil {
+		h.writeAdminUserError(w, err, "Failed to delete user")
+		return
+	}
+	if err := h.service.CheckErasure(r.Context(), userID); err != nil {
+		h.writeAdminUserError(w, err, "Failed to delete user")
+		return
+	}
+	if err := h.eraser.EraseNow(r.Context(), userID, adminID); err != nil {
+		h.writeAdminUserError(w, err, "Failed to delete user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Impersonate handles an admin asking for a token to act as a user
+func (h *Handler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	impersonation, err := h.service.Impersonate(r.Context(), chi.URLParam(r, "id"), adminID, req)
+	if err != nil {
+		if err == ErrCannotImpersonate {
+			response.WriteError(w, http.StatusForbidden, "CANNOT_IMPERSONATE", "This user cannot be impersonated", "")
+			return
+		}
+		if writeInactiveAccountError(w, http.StatusForbidden, err) {
+			return
+		}
+		h.writeAdminUserError(w, err, "Failed to impersonate user")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusCreated, impersonation)
+}
+
+// writeAdminUserError maps errors of admin user actions to HTTP responses
+func (h *Handler) writeAdminUserError(w http.ResponseWriter, err error, logMessage string) {
+	switch err {
+	case ErrUserNotFound:
+		response.WriteError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+	case ErrLastAdmin:
+		response.WriteError(w, http.StatusConflict, "LAST_ADMIN", "The only admin cannot be disabled, deleted or lose the admin role", "")
+	default:
+		h.logger.Error(logMessage, zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+	}
+}
+
+// VerifyEmail handles confirming an email address with a verification token
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	user, err := h.service.VerifyEmail(r.Context(), req.Token)
+	if err != nil {
+		if err == ErrInvalidVerificationToken {
+			response.WriteError(w, http.StatusBadRequest, "INVALID_TOKEN", "Invalid or expired verification token", "")
+			return
+		}
+		h.logger.Error("Failed to verify email", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, user)
+}
+
+// ResendVerification handles sending a new verification email. It always responds 202 so
+// that the response does not reveal whether an account exists.
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	if err := h.service.ResendVerification(r.Context(), req.Email); err != nil {
+		h.logger.Error("Failed to resend verification email", zap.Error(err))
+	}
+
+	response.WriteSuccess(w, http.StatusAccepted, map[string]interface{}{
+		"message": "If the address belongs to an unverified account, a verification email has been sent",
+	})
+}
+
+// ChangePassword handles changing the password of the current user
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	authResp, err := h.service.ChangePassword(r.Context(), userID, req, clientInfo(r))
+	if err != nil {
+		if err == ErrIncorrectPassword {
+			response.WriteError(w, http.StatusForbidden, "INCORRECT_PASSWORD", "Current password is incorrect", "")
+			return
+		}
+		if writeThrottledError(w, err) {
+			return
+		}
+		if err == ErrUserNotFound {
+			response.WriteError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+			return
+		}
+		if writePasswordPolicyError(w, "NewPassword", err) {
+			return
+		}
+		h.logger.Error("Failed to change password", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, authResp)
+}
+
+// ChangeEmail handles requesting a new email address for the current user
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	user, err := h.service.RequestEmailChange(r.Context(), userID, req)
+	if err != nil {
+		if err == ErrIncorrectPassword {
+			response.WriteError(w, http.StatusForbidden, "INCORRECT_PASSWORD", "Current password is incorrect", "")
+			return
+		}
+		if writeThrottledError(w, err) {
+			return
+		}
+		if err == ErrEmailAlreadyExists {
+			response.WriteError(w, http.StatusConflict, "EMAIL_EXISTS", "Email already registered", "")
+			return
+		}
+		if err == ErrUserNotFound {
+			response.WriteError(w, http.StatusNotFound, "USER_NOT_FOUND", "User not found", "")
+			return
+		}
+		h.logger.Error("Failed to request email change", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusAccepted, user)
+}
+
+// ConfirmEmailChange handles confirming a new email address with the emailed token
+func (h *Handler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	var req ConfirmEmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	user, err := h.service.ConfirmEmailChange(r.Context(), req.Token)
+	if err != nil {
+		if err == ErrInvalidVerificationToken {
+			response.WriteError(w, http.StatusBadRequest, "INVALID_TOKEN", "Invalid or expired email change token", "")
+			return
+		}
+		if err == ErrEmailAlreadyExists {
+			response.WriteError(w, http.StatusConflict, "EMAIL_EXISTS", "Email already registered", "")
+			return
+		}
+		h.logger.Error("Failed to confirm email change", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, user)
+}
+
+// ForgotPassword handles requesting a password reset email. It always responds 202 so that
+// the response does not reveal whether an account exists.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	if err := h.service.ForgotPassword(r.Context(), req.Email); err != nil {
+		h.logger.Error("Failed to start password reset", zap.Error(err))
+	}
+
+	response.WriteSuccess(w, http.StatusAccepted, map[string]interface{}{
+		"message": "If the address belongs to an account, a password reset email has been sent",
+	})
+}
+
+// ResetPassword handles setting a new password with a reset token
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	if err := h.service.ResetPassword(r.Context(), req); err != nil {
+		if err == ErrInvalidResetToken {
+			response.WriteError(w, http.StatusBadRequest, "INVALID_TOKEN", "Invalid or expired password reset token", "")
+			return
+		}
+		if writePasswordPolicyError(w, "Password", err) {
+			return
+		}
+		h.logger.Error("Failed to reset password", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EnrollTOTP handles starting TOTP enrollment for the current user
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req EnrollTOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	enrollment, err := h.service.EnrollTOTP(r.Context(), userID, req)
+	if err != nil {
+		if err == ErrIncorrectPassword {
+			response.WriteError(w, http.StatusForbidden, "INCORRECT_PASSWORD", "Current password is incorrect", "")
+			return
+		}
+		if writeThrottledError(w, err) {
+			return
+		}
+		if err == ErrMFAAlreadyEnabled {
+			response.WriteError(w, http.StatusConflict, "MFA_ALREADY_ENABLED", "Multi-factor authentication is already enabled", "")
+			return
+		}
+		h.logger.Error("Failed to start TOTP enrollment", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP handles enabling MFA with the first code from the enrolled authenticator
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		if err == ErrMFANotEnrolled {
+			response.WriteError(w, http.StatusConflict, "MFA_NOT_ENROLLED", "Multi-factor authentication is not set up", "")
+			return
+		}
+		if err == ErrInvalidMFACode {
+			response.WriteError(w, http.StatusForbidden, "INVALID_MFA_CODE", "Invalid or already used code", "")
+			return
+		}
+		h.logger.Error("Failed to confirm TOTP enrollment", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, codes)
+}
+
+// DisableMFA handles turning MFA off for the current user
+func (h *Handler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req DisableMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	if err := h.service.DisableMFA(r.Context(), userID, req); err != nil {
+		if err == ErrIncorrectPassword {
+			response.WriteError(w, http.StatusForbidden, "INCORRECT_PASSWORD", "Current password is incorrect", "")
+			return
+		}
+		if writeThrottledError(w, err) {
+			return
+		}
+		if err == ErrMFANotEnrolled {
+			response.WriteError(w, http.StatusConflict, "MFA_NOT_ENROLLED", "Multi-factor authentication is not set up", "")
+			return
+		}
+		if err == ErrInvalidMFACode {
+			response.WriteError(w, http.StatusForbidden, "INVALID_MFA_CODE", "Invalid or already used code", "")
+			return
+		}
+		if err == ErrMFARequired {
+			response.WriteError(w, http.StatusForbidden, "MFA_REQUIRED", "Multi-factor authentication is mandatory for this account", "")
+			return
+		}
+		h.logger.Error("Failed to disable MFA", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes handles replacing the recovery codes of the current user
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(r.Context(), userID, req.Code)
+	if err != nil {
+		if err == ErrMFANotEnrolled {
+			response.WriteError(w, http.StatusConflict, "MFA_NOT_ENROLLED", "Multi-factor authentication is not set up", "")
+			return
+		}
+		if err == ErrInvalidMFACode {
+			response.WriteError(w, http.StatusForbidden, "INVALID_MFA_CODE", "Invalid or already used code", "")
+			return
+		}
+		if writeThrottledError(w, err) {
+			return
+		}
+		h.logger.Error("Failed to regenerate recovery codes", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, codes)
+}
+
+// LoginMFA handles the second step of a login with MFA
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", "")
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		validationErrors := make([]response.ValidationError, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			validationErrors = append(validationErrors, response.ValidationError{
+				Field:   err.Field(),
+				Message: err.Tag(),
+			})
+		}
+		response.WriteValidationError(w, validationErrors, "")
+		return
+	}
+
+	authResp, err := h.service.CompleteMFALogin(r.Context(), req, clientInfo(r))
+	if err != nil {
+		if err == ErrInvalidMFAChallenge {
+			response.WriteError(w, http.StatusUnauthorized, "INVALID_MFA_TOKEN", "Invalid or expired MFA token, log in again", "")
+			return
+		}
+		if err == ErrInvalidMFACode {
+			response.WriteError(w, http.StatusUnauthorized, "INVALID_MFA_CODE", "Invalid or already used code", "")
+			return
+		}
+		if writeThrottledError(w, err) {
+			return
+		}
+		h.logger.Error("Failed to complete MFA login", zap.Error(err))
+		response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+		return
+	}
+
+	response.WriteSuccess(w, http.StatusOK, authResp)
+}
+
+// RequireMFA is middleware that rejects users whose roles require MFA unless their access
+// token was issued after an MFA login. Tokens OAuth clients got for themselves have no
+// user to require it of. It must run after authentication.
+func (h *Handler) RequireMFA(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("user_id").(string); !ok {
+			if _, isClient := r.Context().Value("client_id").(string); isClient {
+				next.ServeHTTP(w, r)
+				return
+			}
+			response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+			return
+		}
+
+		roles, _ := r.Context().Value("user_roles").([]string)
+		if h.service.MFARequired(roles) {
+			methods, _ := r.Context().Value("user_amr").([]string)
+			if !containsString(methods, jwtPkg.AuthMethodOTP) {
+				response.WriteError(w, http.StatusForbidden, "MFA_REQUIRED", "Log in with multi-factor authentication to continue", "")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireActiveUser is middleware that rejects access tokens of users who have since been
+// disabled, deleted or made to reset their password, and impersonation tokens of admins
+// who have. Tokens OAuth clients got for themselves have no user and pass. It must run
+// after authentication.
+func (h *Handler) RequireActiveUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("user_id").(string)
+		if !ok {
+			if _, isClient := r.Context().Value("client_id").(string); isClient {
+				next.ServeHTTP(w, r)
+				return
+			}
+			response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+			return
+		}
+
+		if err := h.service.EnsureActive(r.Context(), userID); err != nil {
+			if writeInactiveAccountError(w, http.StatusUnauthorized, err) {
+				return
+			}
+			if err == ErrUserNotFound {
+				response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token", "")
+				return
+			}
+			h.logger.Error("Failed to check account status", zap.Error(err))
+			response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+			return
+		}
+
+		if impersonatorID, ok := r.Context().Value("impersonator_id").(string); ok {
+			if err := h.service.EnsureActive(r.Context(), impersonatorID); err != nil {
+				if err == ErrUserNotFound || err == ErrAccountDisabled || err == ErrPasswordResetRequired {
+					response.WriteError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token", "")
+					return
+				}
+				h.logger.Error("Failed to check impersonator status", zap.Error(err))
+				response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireVerifiedEmail is middleware that rejects users who have not verified their email
+// address when the configuration requires it. It must run after authentication.
+func (h *Handler) RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value("user_id").(string)
+		if !ok {
+			response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+			return
+		}
+
+		if err := h.service.EnsureVerified(r.Context(), userID); err != nil {
+			if err == ErrEmailNotVerified {
+				response.WriteError(w, http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Email address has not been verified", "")
+				return
+			}
+			if err == ErrUserNotFound {
+				response.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", "")
+				return
+			}
+			h.logger.Error("Failed to check email verification", zap.Error(err))
+			response.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error", "")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxUserAgentLength caps the user agent stored with a session
+const maxUserAgentLength = 512
+
+// clientInfo describes the client making a request for session tracking
+func clientInfo(r *http.Request) ClientInfo {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return ClientInfo{UserAgent: userAgent, IPAddress: ip}
+}
+
+// writeInactiveAccountError writes the response for ErrAccountDisabled and
+// ErrPasswordResetRequired with status, and reports whether err was one of them
+func writeInactiveAccountError(w http.ResponseWriter, status int, err error) bool {
+	switch err {
+	case ErrAccountDisabled:
+		response.WriteError(w, status, "ACCOUNT_DISABLED", "Account has been disabled", "")
+	case ErrPasswordResetRequired:
+		response.WriteError(w, status, "PASSWORD_RESET_REQUIRED", "Choose a new password using the link emailed to you", "")
+	default:
+		return false
+	}
+	return true
+}
+
+// writeThrottledError writes the response for a LoginThrottledError, and reports whether
+// err was one
+func writeThrottledError(w http.ResponseWriter, err error) bool {
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	// Round up so that clients retrying exactly on time are not throttled again
+	w.Header().Set("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+	if throttled.Locked {
+		response.WriteError(w, http.StatusTooManyRequests, "ACCOUNT_LOCKED", "Account is temporarily locked after too many failed logins", "")
+		return true
+	}
+	response.WriteError(w, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "Too many failed logins, try again later", "")
+	return true
+}
+
+// writePasswordPolicyError writes the violations of a password.PolicyError as validation
+// errors for field, and reports whether err was one
+func writePasswordPolicyError(w http.ResponseWriter, field string, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	validationErrors := make([]response.ValidationError, 0, len(policyErr.Violations))
+	for _, violation := range policyErr.Violations {
+		validationErrors = append(validationErrors, response.ValidationError{
+			Field:   field,
+			Message: violation,
+		})
+	}
+	response.WriteValidationError(w, validationErrors, "")
+	return true
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+)
+
+const defaultImpersonationTTL = 15 * time.Minute
+
+// Impersonate issues an admin a short-lived access token acting as a user, for example to
+// reproduce an issue the user reported. The token names the admin in its act claim; it
+// carries the user's roles and permissions but no authentication methods, and cannot be
+// refreshed. Admins cannot impersonate themselves or staff, so impersonating never grants
+// more than the built-in customer roles do.
+func (s *service) Impersonate(ctx context.Context, userID, adminID string, req ImpersonateRequest) (*Impersonation, error) {
+	if userID == adminID {
+		return nil, ErrCannotImpersonate
+	}
+
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkActive(user); err != nil {
+		return nil, err
+	}
+
+	permissions := s.permissionsFor(user.RoleNames())
+	if isStaff(permissions) {
+		audit.SecurityEvent(s.logger, "impersonation_refused", zap.String("user_id", user.ID), zap.String("admin_id", adminID))
+		return nil, ErrCannotImpersonate
+	}
+
+	claims := jwtPkg.Claims{
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+		Roles:         user.RoleNames(),
+		Permissions:   permissions,
+	}
+	claims.ID = uuid.New().String()
+	expiresAt := time.Now().Add(s.cfg.ImpersonationTTL)
+
+	accessToken, err := s.jwtService.IssueImpersonationToken(claims, adminID, s.cfg.ImpersonationTTL)
+	if err != nil {
+		s.logger.Error("Failed to generate impersonation token", zap.Error(err))
+		return nil, err
+	}
+
+	audit.SecurityEvent(s.logger, "impersonation_started",
+		zap.String("user_id", user.ID),
+		zap.String("admin_id", adminID),
+		zap.String("token_id", claims.ID),
+		zap.String("reason", req.Reason),
+		zap.Time("expires_at", expiresAt),
+	)
+	return &Impersonation{
+		AccessToken: accessToken,
+		ExpiresIn:   int(s.cfg.ImpersonationTTL / time.Second),
+		ExpiresAt:   expiresAt,
+		User:        user,
+	}, nil
+}
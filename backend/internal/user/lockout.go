@@ -0,0 +1,164 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLockoutThreshold   = 10
+	defaultLockoutDuration    = 15 * time.Minute
+	defaultLoginBackoffAfter  = 3
+	defaultLoginBackoffBase   = time.Second
+	defaultIPFailureThreshold = 20
+)
+
+// UnlockUser clears the failed logins and MFA codes of a user, ending a lockout early
+func (s *service) UnlockUser(ctx context.Context, userID, adminID string) error {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		if err != ErrUserNotFound {
+			s.logger.Error("Failed to find user", zap.String("user_id", userID), zap.Error(err))
+		}
+		return err
+	}
+
+	for _, key := range []string{accountAttemptKey(user.Email), mfaAttemptKey(user.ID)} {
+		if err := s.attempts.Reset(ctx, key); err != nil {
+			s.logger.Error("Failed to reset login attempts", zap.String("user_id", user.ID), zap.Error(err))
+			return err
+		}
+	}
+
+	audit.SecurityEvent(s.logger, "account_unlocked", zap.String("user_id", user.ID), zap.String("admin_id", adminID))
+	return nil
+}
+
+// checkLoginThrottle returns a LoginThrottledError when the account or the client address
+// of a login attempt has to wait before trying again
+func (s *service) checkLoginThrottle(ctx context.Context, accountKey, ipKey string, now time.Time) error {
+	since := now.Add(-s.cfg.LockoutDuration)
+
+	attempts, err := s.attempts.Get(ctx, accountKey, since)
+	if err != nil {
+		return err
+	}
+	if wait := attempts.LastFailureAt.Add(s.accountDelay(attempts.Failures)).Sub(now); wait > 0 {
+		return &LoginThrottledError{Locked: attempts.Failures >= s.cfg.LockoutThreshold, RetryAfter: wait}
+	}
+
+	if ipKey == "" {
+		return nil
+	}
+	attempts, err = s.attempts.Get(ctx, ipKey, since)
+	if err != nil {
+		return err
+	}
+	if wait := attempts.LastFailureAt.Add(s.ipDelay(attempts.Failures)).Sub(now); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login against the account and the client address,
+// reporting when either starts to be throttled. user is nil for unknown emails.
+func (s *service) recordLoginFailure(ctx context.Context, user *User, email, accountKey, ipKey string, client ClientInfo) {
+	now := time.Now()
+	since := now.Add(-s.cfg.LockoutDuration)
+
+	attempts, err := s.attempts.RecordFailure(ctx, accountKey, now, since)
+	if err != nil {
+		s.logger.Error("Failed to record login failure", zap.Error(err))
+	} else if attempts.Failures == s.cfg.LockoutThreshold {
+		audit.SecurityEvent(s.logger, "account_locked",
+			zap.String("email", email),
+			zap.String("ip_address", client.IPAddress),
+			zap.Int("failures", attempts.Failures),
+			zap.Time("locked_until", now.Add(s.cfg.LockoutDuration)))
+		// Unknown emails lock the same way, so lockouts do not reveal which accounts exist
+		if user != nil {
+			s.notify(ctx, user.Email, "Your account was locked",
+				fmt.Sprintf("Hi %s,\n\nThere were %d failed attempts to log in to your account, so logging in is blocked for %s. If this was not you, consider changing your password once you can log in again.\n",
+					user.Name, attempts.Failures, s.cfg.LockoutDuration))
+		}
+	}
+
+	if ipKey == "" {
+		return
+	}
+	attempts, err = s.attempts.RecordFailure(ctx, ipKey, now, since)
+	if err != nil {
+		s.logger.Error("Failed to record login failure", zap.Error(err))
+	} else if attempts.Failures == s.cfg.IPFailureThreshold+1 {
+		audit.SecurityEvent(s.logger, "client_throttled",
+			zap.String("ip_address", client.IPAddress),
+			zap.Int("failures", attempts.Failures))
+	}
+}
+
+// accountDelay returns how long an account must wait after its latest failed login
+func (s *service) accountDelay(failures int) time.Duration {
+	if failures >= s.cfg.LockoutThreshold {
+		return s.cfg.LockoutDuration
+	}
+	return s.backoff(failures - s.cfg.LoginBackoffAfter)
+}
+
+// ipDelay returns how long a client address must wait after its latest failed login
+func (s *service) ipDelay(failures int) time.Duration {
+	return s.backoff(failures - s.cfg.IPFailureThreshold)
+}
+
+// backoff doubles the wait for every failure beyond those allowed, up to LockoutDuration
+func (s *service) backoff(excess int) time.Duration {
+	if excess <= 0 {
+		return 0
+	}
+	delay := s.cfg.LoginBackoffBase
+	for i := 1; i < excess && delay < s.cfg.LockoutDuration; i++ {
+		delay *= 2
+	}
+	if delay > s.cfg.LockoutDuration {
+		return s.cfg.LockoutDuration
+	}
+	return delay
+}
+
+// compareDummyPassword spends as long as checking a real password, so that logins for
+// unknown emails cannot be told apart by their response time. The dummy hash is made with
+// the preferred hasher, which real hashes are upgraded to on login.
+func (s *service) compareDummyPassword(password string) {
+	s.dummyHashOnce.Do(func() {
+		hash, err := s.cfg.PasswordHashers.Hash("angidi-dummy-password")
+		if err != nil {
+			s.logger.Error("Failed to hash dummy password", zap.Error(err))
+		}
+		s.dummyHash = hash
+	})
+	s.cfg.PasswordHashers.Verify(s.dummyHash, password)
+}
+
+// accountAttemptKey returns the key failed logins for an email are tracked under. Emails
+// are tracked whether or not an account exists for them.
+func accountAttemptKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+// mfaAttemptKey returns the key wrong MFA codes of a user are tracked under
+func mfaAttemptKey(userID string) string {
+	return "mfa:" + userID
+}
+
+// ipAttemptKey returns the key failed logins from a client address are tracked under, or
+// an empty key when the address is unknown
+func ipAttemptKey(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
@@ -0,0 +1,84 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LoginAttemptRepository defines the interface for failed login tracking. Keys identify
+// what is being tracked, such as an account email or a client address.
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, key string, since time.Time) (*LoginAttempts, error)
+	RecordFailure(ctx context.Context, key string, at, since time.Time) (*LoginAttempts, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// attemptPurgeInterval is how often stale failures are dropped from the in-memory
+// repository
+const attemptPurgeInterval = time.Minute
+
+// InMemoryLoginAttemptRepository implements LoginAttemptRepository using in-memory storage
+type InMemoryLoginAttemptRepository struct {
+	attempts  map[string]*LoginAttempts
+	nextPurge time.Time
+	mutex     sync.Mutex
+}
+
+// NewInMemoryLoginAttemptRepository creates a new in-memory login attempt repository
+func NewInMemoryLoginAttemptRepository() *InMemoryLoginAttemptRepository {
+	return &InMemoryLoginAttemptRepository{
+		attempts: make(map[string]*LoginAttempts),
+	}
+}
+
+// Get returns the failures recorded for key since the given time, which may be none
+func (r *InMemoryLoginAttemptRepository) Get(ctx context.Context, key string, since time.Time) (*LoginAttempts, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.attempts[key]
+	if !exists || existing.LastFailureAt.Before(since) {
+		return &LoginAttempts{Key: key}, nil
+	}
+	attempts := *existing
+	return &attempts, nil
+}
+
+// RecordFailure counts a failed login for key in one step, so that concurrent attempts
+// are all counted. Failures before since are forgotten.
+func (r *InMemoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, at, since time.Time) (*LoginAttempts, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Stale failures of other keys are only swept now and then, so that recording a
+	// failure does not scan every key
+	if at.After(r.nextPurge) {
+		for k, existing := range r.attempts {
+			if existing.LastFailureAt.Before(since) {
+				delete(r.attempts, k)
+			}
+		}
+		r.nextPurge = at.Add(attemptPurgeInterval)
+	}
+
+	existing, exists := r.attempts[key]
+	if !exists || existing.LastFailureAt.Before(since) {
+		existing = &LoginAttempts{Key: key}
+		r.attempts[key] = existing
+	}
+	existing.Failures++
+	existing.LastFailureAt = at
+
+	attempts := *existing
+	return &attempts, nil
+}
+
+// Reset forgets the failures recorded for key
+func (r *InMemoryLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
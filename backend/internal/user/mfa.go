@@ -0,0 +1,356 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/totp"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMFAIssuer = "Angidi"
+	// mfaChallengeTTL is how long a user has to enter their code after the password step
+	mfaChallengeTTL = 5 * time.Minute
+	// maxMFAFailures is how many wrong codes lock the second factor of an account for
+	// LockoutDuration
+	maxMFAFailures = 5
+	// totpSkew is how many 30 second steps codes may be off by, to allow for clock drift
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+	// recoveryCodeBytes is the amount of randomness in a recovery code
+	recoveryCodeBytes = 10
+)
+
+// EnrollTOTP starts TOTP enrollment with a new secret. MFA is enabled once ConfirmTOTP
+// receives a code generated from it.
+func (s *service) EnrollTOTP(ctx context.Context, userID string, req EnrollTOTPRequest) (*TOTPEnrollment, error) {
+	user, err := s.findWithPassword(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		s.logger.Error("Failed to generate TOTP secret", zap.Error(err))
+		return nil, err
+	}
+
+	user.MFAPendingSecret = secret
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("TOTP enrollment started", zap.String("user_id", user.ID))
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.cfg.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables MFA once the user proves their authenticator generates the right
+// codes, and returns their recovery codes
+func (s *service) ConfirmTOTP(ctx context.Context, userID, code string) (*RecoveryCodes, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to find user", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	if user.MFAPendingSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	step, ok := totp.Validate(user.MFAPendingSecret, strings.TrimSpace(code), time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		s.logger.Error("Failed to generate recovery codes", zap.Error(err))
+		return nil, err
+	}
+
+	user.MFAEnabled = true
+	user.MFASecret = user.MFAPendingSecret
+	user.MFAPendingSecret = ""
+	user.MFALastStep = step
+	user.RecoveryCodeHashes = hashes
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return nil, err
+	}
+
+	s.notify(ctx, user.Email, "Two-factor authentication turned on",
+		"Hi "+user.Name+",\n\nTwo-factor authentication is now required to log in to your account. Keep your recovery codes somewhere safe.\n")
+
+	s.logger.Info("MFA enabled", zap.String("user_id", user.ID))
+	return &RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableMFA turns MFA off for a user who confirms with their password and a current code
+func (s *service) DisableMFA(ctx context.Context, userID string, req DisableMFARequest) error {
+	user, err := s.findWithPassword(ctx, userID, req.CurrentPassword)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled {
+		return ErrMFANotEnrolled
+	}
+	if s.MFARequired(user.RoleNames()) {
+		return ErrMFARequired
+	}
+	if err := s.checkMFACode(ctx, user, req.Code); err != nil {
+		return err
+	}
+
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	user.MFALastStep = 0
+	user.RecoveryCodeHashes = nil
+	user.MFAChallengeID = ""
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return err
+	}
+
+	s.notify(ctx, user.Email, "Two-factor authentication turned off",
+		"Hi "+user.Name+",\n\nTwo-factor authentication was turned off for your account. If this was not you, reset your password right away.\n")
+
+	s.logger.Info("MFA disabled", zap.String("user_id", user.ID))
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes, e.g. after they used most of them
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID, code string) (*RecoveryCodes, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to find user", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	if !user.MFAEnabled {
+		return nil, ErrMFANotEnrolled
+	}
+	if err := s.checkMFACode(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		s.logger.Error("Failed to generate recovery codes", zap.Error(err))
+		return nil, err
+	}
+
+	user.RecoveryCodeHashes = hashes
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Recovery codes regenerated", zap.String("user_id", user.ID))
+	return &RecoveryCodes{Codes: codes}, nil
+}
+
+// CompleteMFALogin finishes a login that Login answered with an MFA challenge
+func (s *service) CompleteMFALogin(ctx context.Context, req MFALoginRequest, client ClientInfo) (*AuthResponse, error) {
+	claims, err := s.jwtService.ValidateActionToken(req.MFAToken, jwtPkg.TokenTypeMFAChallenge)
+	if err != nil {
+		s.logger.Warn("Invalid MFA challenge token", zap.Error(err))
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.repo.FindByID(ctx, claims.Subject)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, ErrInvalidMFAChallenge
+		}
+		s.logger.Error("Failed to find user", zap.String("user_id", claims.Subject), zap.Error(err))
+		return nil, err
+	}
+	if !user.MFAEnabled || user.MFAChallengeID == "" || user.MFAChallengeID != claims.ID {
+		s.logger.Warn("Stale MFA challenge presented", zap.String("user_id", user.ID))
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	if err := s.checkMFACode(ctx, user, req.Code); err != nil {
+		return nil, err
+	}
+
+	methods := append(append([]string(nil), user.MFAChallengeMethods...), jwtPkg.AuthMethodOTP)
+	user.MFAChallengeID = ""
+	user.MFAChallengeMethods = nil
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return nil, err
+	}
+
+	authResp, err := s.issueTokens(ctx, user, uuid.New().String(), "", methods, client)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User logged in with MFA", zap.String("user_id", user.ID))
+	return authResp, nil
+}
+
+// startMFAChallenge answers the first step of a login, passed with methods, with a
+// challenge for the second factor. Only the latest challenge of a user is valid.
+func (s *service) startMFAChallenge(ctx context.Context, user *User, methods []string) (*AuthResponse, error) {
+	token, claims, err := s.jwtService.GenerateActionToken(jwtPkg.TokenTypeMFAChallenge, user.ID, user.Email, mfaChallengeTTL)
+	if err != nil {
+		s.logger.Error("Failed to generate MFA challenge", zap.Error(err))
+		return nil, err
+	}
+
+	user.MFAChallengeID = claims.ID
+	user.MFAChallengeMethods = methods
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("MFA challenge issued", zap.String("user_id", user.ID))
+	return &AuthResponse{
+		ExpiresIn:   int(mfaChallengeTTL / time.Second),
+		MFARequired: true,
+		MFAToken:    token,
+	}, nil
+}
+
+// checkMFACode checks a code for the second factor of a user, returning ErrInvalidMFACode
+// when it is wrong. Wrong codes are counted per account and are only forgotten once a code
+// is accepted, so logging in again does not allow more guesses. Every code is counted
+// before it is checked, so that concurrent guesses cannot exceed maxMFAFailures either.
+func (s *service) checkMFACode(ctx context.Context, user *User, code string) error {
+	key := mfaAttemptKey(user.ID)
+	now := time.Now()
+	since := now.Add(-s.cfg.LockoutDuration)
+
+	attempts, err := s.attempts.Get(ctx, key, since)
+	if err != nil {
+		s.logger.Error("Failed to get MFA attempts", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	if attempts.Failures >= maxMFAFailures {
+		s.logger.Warn("Throttled MFA code", zap.String("user_id", user.ID))
+		return &LoginThrottledError{Locked: true, RetryAfter: attempts.LastFailureAt.Add(s.cfg.LockoutDuration).Sub(now)}
+	}
+	attempts, err = s.attempts.RecordFailure(ctx, key, now, since)
+	if err != nil {
+		s.logger.Error("Failed to record MFA attempt", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	if attempts.Failures > maxMFAFailures {
+		s.logger.Warn("Throttled MFA code", zap.String("user_id", user.ID))
+		return &LoginThrottledError{Locked: true, RetryAfter: s.cfg.LockoutDuration}
+	}
+
+	if !s.verifyMFACode(ctx, user, code) {
+		s.logger.Warn("Invalid MFA code", zap.String("user_id", user.ID), zap.Int("failures", attempts.Failures))
+		if attempts.Failures == maxMFAFailures {
+			audit.SecurityEvent(s.logger, "mfa_locked",
+				zap.String("user_id", user.ID),
+				zap.Int("failures", attempts.Failures),
+				zap.Time("locked_until", now.Add(s.cfg.LockoutDuration)))
+			s.notify(ctx, user.Email, "Your account was locked",
+				fmt.Sprintf("Hi %s,\n\nThere were %d failed attempts to enter a two-factor authentication code for your account, so logging in is blocked for %s. Someone may know your password; consider changing it once you can log in again.\n",
+					user.Name, attempts.Failures, s.cfg.LockoutDuration))
+		}
+		return ErrInvalidMFACode
+	}
+
+	if err := s.attempts.Reset(ctx, key); err != nil {
+		s.logger.Error("Failed to reset MFA attempts", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// verifyMFACode checks a TOTP or recovery code of a user with MFA enabled. Accepted codes
+// are used up in the repository at once, so that concurrent requests cannot both accept
+// the same code, and on user, so that saving it keeps them used up.
+func (s *service) verifyMFACode(ctx context.Context, user *User, code string) bool {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.MFASecret, code, time.Now(), totpSkew)
+		// A code seen before may have been observed by someone else
+		if !ok || step <= user.MFALastStep {
+			return false
+		}
+		if err := s.repo.AdvanceMFAStep(ctx, user.ID, step); err != nil {
+			if err != ErrInvalidMFACode {
+				s.logger.Error("Failed to record MFA code", zap.String("user_id", user.ID), zap.Error(err))
+			}
+			return false
+		}
+		user.MFALastStep = step
+		return true
+	}
+
+	hash := hashToken(normalizeRecoveryCode(code))
+	for i, stored := range user.RecoveryCodeHashes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) != 1 {
+			continue
+		}
+		if err := s.repo.UseRecoveryCode(ctx, user.ID, stored); err != nil {
+			if err != ErrInvalidMFACode {
+				s.logger.Error("Failed to use recovery code", zap.String("user_id", user.ID), zap.Error(err))
+			}
+			return false
+		}
+		// Build a new slice; the previous one may be shared with the stored user
+		remaining := make([]string, 0, len(user.RecoveryCodeHashes)-1)
+		remaining = append(remaining, user.RecoveryCodeHashes[:i]...)
+		user.RecoveryCodeHashes = append(remaining, user.RecoveryCodeHashes[i+1:]...)
+		s.logger.Info("Recovery code used", zap.String("user_id", user.ID), zap.Int("remaining", len(user.RecoveryCodeHashes)))
+		return true
+	}
+	return false
+}
+
+// newRecoveryCodes returns a set of recovery codes and the hashes to store for them
+func newRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		// Group in fours so codes are easier to copy by hand
+		groups := make([]string, 0, len(raw)/4)
+		for j := 0; j < len(raw); j += 4 {
+			groups = append(groups, raw[j:j+4])
+		}
+		codes[i] = strings.Join(groups, "-")
+		hashes[i] = hashToken(raw)
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in a recovery code
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
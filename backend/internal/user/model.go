@@ -2,33 +2,406 @@ package user
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/password"
 )
 
 var (
 	// ErrUserNotFound is returned when a user is not found
 	ErrUserNotFound = errors.New("user not found")
+	// ErrPasswordChanged is returned when a password hash is replaced after the user's
+	// password was changed by someone else
+	ErrPasswordChanged = errors.New("password changed concurrently")
 	// ErrEmailAlreadyExists is returned when email is already registered
 	ErrEmailAlreadyExists = errors.New("email already exists")
 	// ErrInvalidCredentials is returned when login credentials are invalid
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrRefreshTokenNotFound is returned when a refresh token was never issued or has been purged
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	// ErrRefreshTokenRevoked is returned when a refresh token belongs to a family that has been logged out
+	ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrSessionNotFound is returned when a session does not exist or belongs to another user
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrInvalidVerificationToken is returned when an email verification token is invalid, expired or already used
+	ErrInvalidVerificationToken = errors.New("invalid verification token")
+	// ErrEmailNotVerified is returned when an unverified user attempts something that requires a verified email
+	ErrEmailNotVerified = errors.New("email not verified")
+	// ErrInvalidResetToken is returned when a password reset token is unknown, expired or already used
+	ErrInvalidResetToken = errors.New("invalid password reset token")
+	// ErrIncorrectPassword is returned when the current password given to confirm an account change is wrong
+	ErrIncorrectPassword = errors.New("incorrect password")
+	// ErrMFAAlreadyEnabled is returned when enrolling a user who already has MFA enabled
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	// ErrMFANotEnrolled is returned when confirming, disabling or using MFA that has not been set up
+	ErrMFANotEnrolled = errors.New("mfa not enrolled")
+	// ErrInvalidMFACode is returned when a TOTP or recovery code is wrong or has already been used
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+	// ErrInvalidMFAChallenge is returned when an MFA challenge token is invalid, expired or exhausted
+	ErrInvalidMFAChallenge = errors.New("invalid mfa challenge")
+	// ErrMFARequired is returned when MFA is mandatory for the user's role
+	ErrMFARequired = errors.New("mfa required")
+	// ErrAccountLocked is returned when an account is locked after too many failed logins
+	ErrAccountLocked = errors.New("account locked")
+	// ErrTooManyLoginAttempts is returned when an account or client must wait before trying to log in again
+	ErrTooManyLoginAttempts = errors.New("too many login attempts")
+	// ErrUnknownRole is returned when assigning a role that is not defined
+	ErrUnknownRole = errors.New("unknown role")
+	// ErrLastAdmin is returned when taking the admin role from the only admin
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+	// ErrAccountDisabled is returned when a disabled user logs in or uses their tokens
+	ErrAccountDisabled = errors.New("account disabled")
+	// ErrPasswordResetRequired is returned when a user must reset their password before signing in again
+	ErrPasswordResetRequired = errors.New("password reset required")
+	// ErrInvalidAPIKey is returned when an API key is unknown, expired or revoked
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrAPIKeyNotFound is returned when an API key does not exist or belongs to another user
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidScope is returned when an API key is created with a scope its user does not have
+	ErrInvalidScope = errors.New("invalid api key scope")
+	// ErrUnknownProvider is returned when a login names an identity provider that is not configured
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	// ErrProviderUnavailable is returned when an identity provider cannot be reached or
+	// misbehaves
+	ErrProviderUnavailable = errors.New("identity provider unavailable")
+	// ErrInvalidOIDCState is returned when a provider login comes back with an unknown,
+	// expired or already used state, or to another browser than the one that started it
+	ErrInvalidOIDCState = errors.New("invalid oidc login state")
+	// ErrOIDCLoginFailed is returned when a provider does not confirm who logged in
+	ErrOIDCLoginFailed = errors.New("external login failed")
+	// ErrAccountExists is returned when a provider login has the email address of an
+	// account it may not be linked to automatically
+	ErrAccountExists = errors.New("account with this email already exists")
+	// ErrCannotImpersonate is returned when an admin tries to impersonate themselves or a
+	// staff member
+	ErrCannotImpersonate = errors.New("cannot impersonate user")
+	// ErrAddressNotFound is returned when an address does not exist or belongs to another user
+	ErrAddressNotFound = errors.New("address not found")
+	// ErrAddressLimitReached is returned when a user with the most addresses allowed saves another
+	ErrAddressLimitReached = errors.New("address limit reached")
+	// ErrInvalidAddress is returned when an address does not fit the format of its country
+	ErrInvalidAddress = errors.New("invalid address")
+)
+
+// LoginThrottledError is returned by Login, by account changes confirmed with the password
+// and by MFA codes while an account or client address has to wait before trying again. It matches ErrAccountLocked or ErrTooManyLoginAttempts with errors.Is.
+type LoginThrottledError struct {
+	// Locked is set when the account reached the lockout threshold, rather than backing off
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Unwrap(), e.RetryAfter)
+}
+
+// Unwrap returns the sentinel error describing the kind of throttling
+func (e *LoginThrottledError) Unwrap() error {
+	if e.Locked {
+		return ErrAccountLocked
+	}
+	return ErrTooManyLoginAttempts
+}
+
+// AddressError is returned when an address does not fit the format of its country. It
+// matches ErrInvalidAddress with errors.Is.
+type AddressError struct {
+	Violations []AddressViolation
+}
+
+// AddressViolation describes what is wrong with a field of an address request
+type AddressViolation struct {
+	Field   string
+	Message string
+}
+
+func (e *AddressError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Field+" "+violation.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidAddress, strings.Join(messages, ", "))
+}
+
+// Unwrap returns ErrInvalidAddress
+func (e *AddressError) Unwrap() error {
+	return ErrInvalidAddress
+}
+
+// Email verification enforcement modes
+const (
+	// VerificationNotRequired lets unverified users do everything
+	VerificationNotRequired = "none"
+	// VerificationRequiredForLogin refuses to log unverified users in
+	VerificationRequiredForLogin = "login"
+	// VerificationRequiredForActions lets unverified users log in but not use routes that require a verified email
+	VerificationRequiredForActions = "actions"
 )
 
 // User represents a user entity
 type User struct {
-	ID           string    `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // Never expose password hash in JSON
-	Name         string    `json:"name"`
-	Role         string    `json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"-"` // Never expose password hash in JSON
+	Name         string `json:"name"`
+	// Role is the primary role, kept for clients that know a single role: admin when the
+	// user holds it, otherwise the first of Roles
+	Role          string   `json:"role"`
+	Roles         []string `json:"roles"`
+	EmailVerified bool     `json:"email_verified"`
+	// EmailVerificationID is the ID of the only verification token that is still valid
+	EmailVerificationID string `json:"-"`
+	// PendingEmail is the address the user asked to change to, until they confirm it
+	PendingEmail string `json:"pending_email,omitempty"`
+	// EmailChangeID is the ID of the only email change token that is still valid
+	EmailChangeID string `json:"-"`
+	MFAEnabled    bool   `json:"mfa_enabled"`
+	// MFASecret is the base32 TOTP secret of an enabled authenticator, and MFAPendingSecret
+	// the one being enrolled until its first code is confirmed
+	MFASecret        string `json:"-"`
+	MFAPendingSecret string `json:"-"`
+	// MFALastStep is the TOTP time step of the last accepted code, so codes work only once
+	MFALastStep int64 `json:"-"`
+	// RecoveryCodeHashes holds the SHA-256 hashes of the unused recovery codes
+	RecoveryCodeHashes []string `json:"-"`
+	// MFAChallengeID is the ID of the only login challenge that is still valid, and
+	// MFAChallengeMethods how the user passed the first factor of that login
+	MFAChallengeID      string   `json:"-"`
+	MFAChallengeMethods []string `json:"-"`
+	// Disabled users cannot log in, refresh tokens or use the API until an admin enables them
+	Disabled   bool       `json:"disabled"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// PasswordResetRequired is set when an admin forces a password reset, and keeps the user
+	// out until they choose a new password through the emailed link
+	PasswordResetRequired bool `json:"password_reset_required"`
+	// Identities are the accounts at external identity providers the user logs in with.
+	// Users created by a provider login have no password until they reset it.
+	Identities []Identity `json:"identities,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Identity links a user to their account at an external identity provider, which is
+// identified by the provider's subject for it
+type Identity struct {
+	Provider string    `json:"provider"`
+	Subject  string    `json:"subject"`
+	Email    string    `json:"email,omitempty"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// UserFilters represents filters for listing users
+type UserFilters struct {
+	// Search matches part of the email address or name, ignoring case
+	Search string `json:"search,omitempty"`
+	Role   string `json:"role,omitempty"`
+	// Disabled lists only disabled users when true and only enabled ones when false
+	Disabled *bool `json:"disabled,omitempty"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+}
+
+// UserList represents a paginated list of users
+type UserList struct {
+	Users      []*User `json:"users"`
+	TotalCount int     `json:"total_count"`
+	Page       int     `json:"page"`
+	PageSize   int     `json:"page_size"`
+	TotalPages int     `json:"total_pages"`
+}
+
+// Config holds account policy settings
+type Config struct {
+	// EmailVerification is one of VerificationNotRequired, VerificationRequiredForLogin
+	// or VerificationRequiredForActions
+	EmailVerification    string
+	VerificationTokenTTL time.Duration
+	// VerifyEmailURL is the page verification links point to, with the token appended as
+	// the token query parameter. The bare token is sent when it is empty.
+	VerifyEmailURL string
+	// PasswordResetTokenTTL is how long password reset links stay valid
+	PasswordResetTokenTTL time.Duration
+	// ResetPasswordURL is the page password reset links point to, like VerifyEmailURL
+	ResetPasswordURL string
+	// ConfirmEmailChangeURL is the page email change links point to, like VerifyEmailURL
+	ConfirmEmailChangeURL string
+	// MFAIssuer names the service in authenticator apps
+	MFAIssuer string
+	// RequireMFAForAdmins keeps admins out of admin routes until they log in with MFA
+	RequireMFAForAdmins bool
+	// LockoutThreshold is how many failed logins in a row lock an account for
+	// LockoutDuration. Failures older than LockoutDuration are forgotten.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// LoginBackoffAfter is how many failed logins an account may have before every further
+	// attempt must wait, starting at LoginBackoffBase and doubling with each failure
+	LoginBackoffAfter int
+	LoginBackoffBase  time.Duration
+	// IPFailureThreshold is how many failed logins, for any accounts, a client address may
+	// have before it backs off the same way
+	IPFailureThreshold int
+	// PasswordPolicy is checked whenever a password is set
+	PasswordPolicy password.Policy
+	// PasswordHashers hashes new passwords and verifies stored hashes. Hashes it would no
+	// longer make are replaced when their user logs in.
+	PasswordHashers *password.Hashers
+	// Roles maps role names to the permissions they grant, adding to or replacing the
+	// default roles. The admin role always grants every permission.
+	Roles map[string][]string
+	// OIDCProviders are the external identity providers users can log in with
+	OIDCProviders []OIDCProvider
+	// ImpersonationTTL is how long the tokens admins get to act as a user stay valid
+	ImpersonationTTL time.Duration
+}
+
+// OIDCProvider is an external OpenID provider users can log in with
+type OIDCProvider struct {
+	// Name identifies the provider in URLs and linked identities, e.g. "google"
+	Name   string
+	Client *oidc.Client
+	// TrustEmail links a login to the existing account with the same email address when
+	// the provider reports the address as verified. Otherwise such logins fail with
+	// ErrAccountExists. Only set it for providers whose addresses can be relied on.
+	TrustEmail bool
+}
+
+// OIDCLogin is a login with an external provider, waiting for the user to come back from
+// it. It is stored under the hash of the state sent through the provider.
+type OIDCLogin struct {
+	StateHash string
+	// BrowserKeyHash is the hash of the key kept in a cookie of the browser that started
+	// the login, which must come back with the state
+	BrowserKeyHash string
+	Provider       string
+	Nonce          string
+	CodeVerifier   string
+	ExpiresAt      time.Time
+	CreatedAt      time.Time
+}
+
+// RoleDefinition describes a role and the permissions it grants
+type RoleDefinition struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserRoles describes the roles of a user and the permissions they grant together
+type UserRoles struct {
+	UserID      string   `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// RefreshToken tracks an issued refresh token server-side. Tokens rotated from the same
+// login share a FamilyID; each token may be exchanged exactly once.
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	FamilyID  string     `json:"family_id"`
+	UserID    string     `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Session represents a login on one device. Its ID is the family ID of the refresh
+// tokens rotated from that login, so revoking the session revokes them all.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// AuthMethods lists how the user authenticated when starting the session, and is
+	// carried into every access token issued for it
+	AuthMethods []string `json:"auth_methods"`
+}
+
+// OIDCCallbackRequest represents the authorization response a provider sent the user
+// back to the frontend with
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=256"`
+}
+
+// APIKey is a long-lived credential a user creates for scripts and integrations. Only the
+// SHA-256 hash of the key is stored; Prefix is kept in clear so users can tell keys apart.
+type APIKey struct {
+	ID      string `json:"id"`
+	UserID  string `json:"user_id"`
+	Name    string `json:"name"`
+	Prefix  string `json:"prefix"`
+	KeyHash string `json:"-"`
+	// Scopes restricts the key to these permissions of its user
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKey is a newly created API key along with its secret, which is shown only once
+type NewAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// Address is a saved shipping or billing address of a user. At most one address of a user
+// is their default for shipping, and one for billing.
+type Address struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"user_id"`
+	Label           string    `json:"label"`
+	Name            string    `json:"name"`
+	Company         string    `json:"company,omitempty"`
+	Line1           string    `json:"line1"`
+	Line2           string    `json:"line2,omitempty"`
+	City            string    `json:"city"`
+	Region          string    `json:"region,omitempty"`
+	PostalCode      string    `json:"postal_code,omitempty"`
+	Country         string    `json:"country"`
+	Phone           string    `json:"phone,omitempty"`
+	DefaultShipping bool      `json:"default_shipping"`
+	DefaultBilling  bool      `json:"default_billing"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// LoginAttempts tracks the recent failed logins for an account or a client address
+type LoginAttempts struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+}
+
+// ClientInfo describes the client a login or token refresh comes from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// PasswordResetToken represents an issued password reset token. Only the SHA-256 hash of
+// the token is stored, so a leaked store cannot be used to reset passwords.
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
 }
 
 // RegisterRequest represents a user registration request
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=8,max=128"`
+	Password string `json:"password" validate:"required"`
 	Name     string `json:"name" validate:"required,min=2,max=100"`
 }
 
@@ -45,13 +418,141 @@ type UpdateProfileRequest struct {
 
 // AuthResponse represents an authentication response with tokens
 type AuthResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 	ExpiresIn    int    `json:"expires_in"` // seconds
-	User         *User  `json:"user"`
+	User         *User  `json:"user,omitempty"`
+	// MFARequired is set instead of the tokens when the password was correct but a second
+	// factor is needed; MFAToken must then be sent with a code to complete the login
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// TOTPEnrollment holds a new TOTP secret for the user to add to their authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth URI to encode in a QR code
+	URI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes holds single-use codes that replace a TOTP code when the authenticator is lost.
+// They are only shown once.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
 }
 
 // RefreshTokenRequest represents a token refresh request
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
+
+// VerifyEmailRequest represents a request to confirm an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationRequest represents a request to send a new verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ForgotPasswordRequest represents a request to email a password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ChangePasswordRequest represents a request to change the password of the current user
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,nefield=CurrentPassword"`
+}
+
+// ChangeEmailRequest represents a request to change the email address of the current user
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email" validate:"required,email,max=255"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+// ConfirmEmailChangeRequest represents a request to confirm a new email address
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// EnrollTOTPRequest represents a request to start TOTP enrollment
+type EnrollTOTPRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+// MFACodeRequest represents a request confirmed with a TOTP or recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// DisableMFARequest represents a request to turn MFA off
+type DisableMFARequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Code            string `json:"code" validate:"required,max=32"`
+}
+
+// MFALoginRequest represents the second step of a login with MFA
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,max=20,dive,required,max=50"`
+	// ExpiresInDays defaults to 90 days
+	ExpiresInDays int `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// AddressRequest represents a request to save an address. Whether the region and postal
+// code are required, and the format of the postal code, depend on the country.
+type AddressRequest struct {
+	Label      string `json:"label" validate:"required,max=50"`
+	Name       string `json:"name" validate:"required,max=100"`
+	Company    string `json:"company" validate:"max=100"`
+	Line1      string `json:"line1" validate:"required,max=200"`
+	Line2      string `json:"line2" validate:"max=200"`
+	City       string `json:"city" validate:"required,max=100"`
+	Region     string `json:"region" validate:"max=64"`
+	PostalCode string `json:"postal_code" validate:"max=16"`
+	// Country is an ISO 3166-1 alpha-2 code
+	Country         string `json:"country" validate:"required,len=2,alpha"`
+	Phone           string `json:"phone" validate:"omitempty,max=32"`
+	DefaultShipping bool   `json:"default_shipping"`
+	DefaultBilling  bool   `json:"default_billing"`
+}
+
+// SetRolesRequest represents an admin replacing the roles of a user
+type SetRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,max=20,dive,required,max=50"`
+}
+
+// ImpersonateRequest represents an admin asking to act as a user
+type ImpersonateRequest struct {
+	// Reason is recorded in the audit log, e.g. the support ticket being worked on
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// Impersonation holds an access token that acts as a user on behalf of an admin. It
+// cannot be refreshed; the admin asks for a new one when it expires.
+type Impersonation struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int       `json:"expires_in"` // seconds
+	ExpiresAt   time.Time `json:"expires_at"`
+	User        *User     `json:"user"`
+}
+
+// LogoutRequest represents a request to end the session a refresh token belongs to
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
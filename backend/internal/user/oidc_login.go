@@ -0,0 +1,217 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+	"go.uber.org/zap"
+)
+
+const (
+	// oidcLoginTTL is how long users have to log in at a provider and come back
+	oidcLoginTTL = 10 * time.Minute
+	// oidcLoginCookieName is the cookie keeping the browser key of a provider login
+	oidcLoginCookieName = "oidc_login"
+)
+
+// OIDCProviders returns the names of the identity providers users can log in with
+func (s *service) OIDCProviders() []string {
+	names := make([]string, 0, len(s.cfg.OIDCProviders))
+	for _, provider := range s.cfg.OIDCProviders {
+		names = append(names, provider.Name)
+	}
+	return names
+}
+
+// StartOIDCLogin starts a login with an identity provider and returns the URL of its
+// login page. The provider sends the user back with a state identifying the login, which
+// is only accepted together with browserKey, to be kept by the browser starting the login.
+// Otherwise anyone could have a victim complete a login they started, signing the victim
+// in to the attacker's account.
+func (s *service) StartOIDCLogin(ctx context.Context, providerName string) (authURL, browserKey string, err error) {
+	provider, err := s.oidcProvider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	// State and nonce tie the provider's answer to this login
+	state, nonce, browserKey := rand.Text(), rand.Text(), rand.Text()
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		s.logger.Error("Failed to generate code verifier", zap.Error(err))
+		return "", "", err
+	}
+
+	authURL, err = provider.Client.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		s.logger.Error("Failed to discover identity provider", zap.String("provider", provider.Name), zap.Error(err))
+		return "", "", ErrProviderUnavailable
+	}
+
+	now := time.Now()
+	err = s.oidcLogins.Create(ctx, &OIDCLogin{
+		StateHash:      hashToken(state),
+		BrowserKeyHash: hashToken(browserKey),
+		Provider:       provider.Name,
+		Nonce:          nonce,
+		CodeVerifier:   verifier,
+		ExpiresAt:      now.Add(oidcLoginTTL),
+		CreatedAt:      now,
+	})
+	if err != nil {
+		s.logger.Error("Failed to store provider login", zap.Error(err))
+		return "", "", err
+	}
+
+	return authURL, browserKey, nil
+}
+
+// CompleteOIDCLogin finishes a login with an identity provider once it sent the user back
+// with an authorization code, in the browser holding the browserKey the login started
+// with. The user linked to the provider account is logged in; a new user is created when
+// there is none.
+func (s *service) CompleteOIDCLogin(ctx context.Context, providerName string, req OIDCCallbackRequest, browserKey string, client ClientInfo) (*AuthResponse, error) {
+	provider, err := s.oidcProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	login, err := s.oidcLogins.Consume(ctx, hashToken(req.State))
+	if err != nil {
+		s.logger.Warn("Provider login with invalid state", zap.String("provider", provider.Name))
+		return nil, ErrInvalidOIDCState
+	}
+	// A state from a login at another provider must not be answered by this one
+	if login.Provider != provider.Name {
+		s.logger.Warn("Provider login with state of another provider", zap.String("provider", provider.Name))
+		return nil, ErrInvalidOIDCState
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(browserKey)), []byte(login.BrowserKeyHash)) != 1 {
+		audit.SecurityEvent(s.logger, "oidc_login_from_other_browser", zap.String("provider", provider.Name), zap.String("ip_address", client.IPAddress))
+		return nil, ErrInvalidOIDCState
+	}
+
+	idToken, err := provider.Client.Exchange(ctx, req.Code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrExchangeFailed) || errors.Is(err, oidc.ErrInvalidIDToken) {
+			s.logger.Warn("Identity provider rejected login", zap.String("provider", provider.Name), zap.Error(err))
+			return nil, ErrOIDCLoginFailed
+		}
+		s.logger.Error("Failed to complete provider login", zap.String("provider", provider.Name), zap.Error(err))
+		return nil, ErrProviderUnavailable
+	}
+
+	user, err := s.oidcUser(ctx, provider, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkActive(user); err != nil {
+		s.logger.Warn("Provider login for inactive account", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
+	if !user.EmailVerified && s.cfg.EmailVerification == VerificationRequiredForLogin {
+		s.logger.Warn("Provider login with unverified email", zap.String("user_id", user.ID))
+		return nil, ErrEmailNotVerified
+	}
+	// The provider only replaces the password; the user's own second factor still applies
+	if user.MFAEnabled {
+		return s.startMFAChallenge(ctx, user, []string{jwtPkg.AuthMethodFederated})
+	}
+
+	authResp, err := s.issueTokens(ctx, user, uuid.New().String(), "", []string{jwtPkg.AuthMethodFederated}, client)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User logged in with identity provider", zap.String("user_id", user.ID), zap.String("provider", provider.Name))
+	return authResp, nil
+}
+
+// oidcUser returns the user linked to the provider account an ID token identifies. An
+// account with the same email address is linked when the provider is trusted with it,
+// and a new user is created when there is none.
+func (s *service) oidcUser(ctx context.Context, provider *OIDCProvider, idToken *oidc.IDToken) (*User, error) {
+	user, err := s.repo.FindByIdentity(ctx, provider.Name, idToken.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if err != ErrUserNotFound {
+		s.logger.Error("Failed to find user by identity", zap.Error(err))
+		return nil, err
+	}
+
+	if idToken.Email == "" {
+		s.logger.Warn("Provider login without email address", zap.String("provider", provider.Name))
+		return nil, ErrOIDCLoginFailed
+	}
+	identity := Identity{Provider: provider.Name, Subject: idToken.Subject, Email: idToken.Email, LinkedAt: time.Now()}
+
+	user, err = s.repo.FindByEmail(ctx, idToken.Email)
+	if err == nil {
+		if !provider.TrustEmail || !idToken.EmailVerified {
+			s.logger.Warn("Provider login for existing account", zap.String("provider", provider.Name), zap.String("user_id", user.ID))
+			return nil, ErrAccountExists
+		}
+		user.Identities = append(user.Identities, identity)
+		// The provider vouched for the address
+		user.EmailVerified = true
+		user.UpdatedAt = identity.LinkedAt
+		if err := s.repo.Update(ctx, user); err != nil {
+			s.logger.Error("Failed to update user", zap.String("user_id", user.ID), zap.Error(err))
+			return nil, err
+		}
+		audit.SecurityEvent(s.logger, "identity_linked", zap.String("user_id", user.ID), zap.String("provider", provider.Name))
+		return user, nil
+	}
+	if err != ErrUserNotFound {
+		s.logger.Error("Failed to check existing user", zap.Error(err))
+		return nil, err
+	}
+
+	name := strings.TrimSpace(idToken.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(idToken.Email, "@")
+	}
+	user = &User{
+		ID:            uuid.New().String(),
+		Email:         idToken.Email,
+		Name:          name,
+		Role:          RoleUser,
+		Roles:         []string{RoleUser},
+		EmailVerified: idToken.EmailVerified,
+		Identities:    []Identity{identity},
+		CreatedAt:     identity.LinkedAt,
+		UpdatedAt:     identity.LinkedAt,
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
+		s.logger.Error("Failed to create user", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("User registered with identity provider", zap.String("user_id", user.ID), zap.String("provider", provider.Name))
+	if !user.EmailVerified {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			s.logger.Error("Failed to send verification email", zap.String("user_id", user.ID), zap.Error(err))
+		}
+	}
+	return user, nil
+}
+
+// oidcProvider returns the configured identity provider called name
+func (s *service) oidcProvider(name string) (*OIDCProvider, error) {
+	for i := range s.cfg.OIDCProviders {
+		if s.cfg.OIDCProviders[i].Name == name {
+			return &s.cfg.OIDCProviders[i], nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
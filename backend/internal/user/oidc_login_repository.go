@@ -0,0 +1,61 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OIDCLoginRepository defines the interface for pending provider login data access.
+// Logins are looked up by the hash of their state.
+type OIDCLoginRepository interface {
+	Create(ctx context.Context, login *OIDCLogin) error
+	Consume(ctx context.Context, stateHash string) (*OIDCLogin, error)
+}
+
+// InMemoryOIDCLoginRepository implements OIDCLoginRepository using in-memory storage
+type InMemoryOIDCLoginRepository struct {
+	logins map[string]*OIDCLogin
+	mutex  sync.Mutex
+}
+
+// NewInMemoryOIDCLoginRepository creates a new in-memory pending provider login repository
+func NewInMemoryOIDCLoginRepository() *InMemoryOIDCLoginRepository {
+	return &InMemoryOIDCLoginRepository{
+		logins: make(map[string]*OIDCLogin),
+	}
+}
+
+// Create stores a new pending login, dropping expired ones
+func (r *InMemoryOIDCLoginRepository) Create(ctx context.Context, login *OIDCLogin) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for hash, existing := range r.logins {
+		if now.After(existing.ExpiresAt) {
+			delete(r.logins, hash)
+		}
+	}
+	stored := *login
+	r.logins[login.StateHash] = &stored
+	return nil
+}
+
+// Consume removes and returns an unexpired pending login in one step, so that a state
+// cannot be used twice even by concurrent requests
+func (r *InMemoryOIDCLoginRepository) Consume(ctx context.Context, stateHash string) (*OIDCLogin, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	login, exists := r.logins[stateHash]
+	if !exists {
+		return nil, ErrInvalidOIDCState
+	}
+	delete(r.logins, stateHash)
+
+	if time.Now().After(login.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	return login, nil
+}
@@ -0,0 +1,150 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/mailer"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPasswordResetTokenTTL = 30 * time.Minute
+	// resetTokenBytes is the amount of randomness in a password reset token
+	resetTokenBytes = 32
+)
+
+// ForgotPassword emails a password reset link to the user with the given address. Unknown
+// addresses are ignored so that callers cannot probe for accounts. Creating and sending
+// the link takes long enough for the response time to tell the two apart, so both happen
+// in the background and failures are only logged.
+func (s *service) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if err == ErrUserNotFound {
+			s.logger.Info("Password reset requested for unknown email", zap.String("email", email))
+			return nil
+		}
+		s.logger.Error("Failed to find user", zap.Error(err))
+		return err
+	}
+
+	s.emails.enqueue(ctx, "password_reset", func(ctx context.Context) error {
+		link, err := s.newResetLink(ctx, user)
+		if err != nil {
+			return err
+		}
+		err = s.mailer.Send(ctx, mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hi %s,\n\nUse this link to choose a new password:\n\n%s\n\nThis link expires in %s and can be used once. If you did not ask to reset your password, you can ignore this email.\n",
+				user.Name, link, s.cfg.PasswordResetTokenTTL),
+		})
+		if err != nil {
+			return err
+		}
+		s.logger.Info("Password reset email sent", zap.String("user_id", user.ID))
+		return nil
+	})
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere
+func (s *service) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	stored, err := s.resets.Find(ctx, hashToken(req.Token))
+	if err != nil {
+		s.logger.Warn("Invalid password reset token presented")
+		return err
+	}
+
+	user, err := s.repo.FindByID(ctx, stored.UserID)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return ErrInvalidResetToken
+		}
+		s.logger.Error("Failed to find user", zap.String("user_id", stored.UserID), zap.Error(err))
+		return err
+	}
+	// A rejected password leaves the token usable for another try
+	if err := s.checkPassword(ctx, req.Password, user.RoleNames(), user.Email, user.Name); err != nil {
+		return err
+	}
+	if _, err := s.resets.Consume(ctx, stored.TokenHash); err != nil {
+		s.logger.Warn("Password reset token used concurrently", zap.String("user_id", user.ID))
+		return err
+	}
+
+	passwordHash, err := s.cfg.PasswordHashers.Hash(req.Password)
+	if err != nil {
+		s.logger.Error("Failed to hash password", zap.Error(err))
+		return err
+	}
+
+	user.PasswordHash = passwordHash
+	user.PasswordResetRequired = false
+	// Following the emailed link proves the user controls the address
+	user.EmailVerified = true
+	user.EmailVerificationID = ""
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user", zap.Error(err))
+		return err
+	}
+
+	// Whoever knew the old password must not stay signed in
+	if err := s.sessions.RevokeUserSessions(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to revoke sessions", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	if err := s.resets.DeleteUserTokens(ctx, user.ID); err != nil {
+		s.logger.Error("Failed to delete password reset tokens", zap.String("user_id", user.ID), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Password reset", zap.String("user_id", user.ID))
+	return nil
+}
+
+// newResetLink stores a new password reset token for user and returns the link to send them
+func (s *service) newResetLink(ctx context.Context, user *User) (string, error) {
+	token, err := newResetToken()
+	if err != nil {
+		s.logger.Error("Failed to generate password reset token", zap.Error(err))
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.resets.Create(ctx, &PasswordResetToken{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(s.cfg.PasswordResetTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		s.logger.Error("Failed to store password reset token", zap.Error(err))
+		return "", err
+	}
+
+	return tokenLink(s.cfg.ResetPasswordURL, token), nil
+}
+
+// newResetToken returns a random URL-safe password reset token
+func newResetToken() (string, error) {
+	b := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash a random secret token, such as a password reset token or a
+// recovery code, is stored under
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PasswordResetRepository defines the interface for password reset token data access.
+// Tokens are looked up by the hash of the token sent to the user.
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *PasswordResetToken) error
+	Find(ctx context.Context, tokenHash string) (*PasswordResetToken, error)
+	Consume(ctx context.Context, tokenHash string) (*PasswordResetToken, error)
+	DeleteUserTokens(ctx context.Context, userID string) error
+}
+
+// InMemoryPasswordResetRepository implements PasswordResetRepository using in-memory storage
+type InMemoryPasswordResetRepository struct {
+	tokens map[string]*PasswordResetToken
+	mutex  sync.Mutex
+}
+
+// NewInMemoryPasswordResetRepository creates a new in-memory password reset repository
+func NewInMemoryPasswordResetRepository() *InMemoryPasswordResetRepository {
+	return &InMemoryPasswordResetRepository{
+		tokens: make(map[string]*PasswordResetToken),
+	}
+}
+
+// Create stores a new token, replacing any token issued to the same user before
+func (r *InMemoryPasswordResetRepository) Create(ctx context.Context, token *PasswordResetToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for hash, existing := range r.tokens {
+		if existing.UserID == token.UserID || now.After(existing.ExpiresAt) {
+			delete(r.tokens, hash)
+		}
+	}
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+// Find returns an unexpired token without using it up
+func (r *InMemoryPasswordResetRepository) Find(ctx context.Context, tokenHash string) (*PasswordResetToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, exists := r.tokens[tokenHash]
+	if !exists || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+	return token, nil
+}
+
+// Consume removes and returns an unexpired token in one step, so that a token cannot be
+// used twice even by concurrent requests
+func (r *InMemoryPasswordResetRepository) Consume(ctx context.Context, tokenHash string) (*PasswordResetToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, exists := r.tokens[tokenHash]
+	if !exists {
+		return nil, ErrInvalidResetToken
+	}
+	delete(r.tokens, tokenHash)
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+	return token, nil
+}
+
+// DeleteUserTokens removes every token issued to a user
+func (r *InMemoryPasswordResetRepository) DeleteUserTokens(ctx context.Context, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for hash, token := range r.tokens {
+		if token.UserID == userID {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
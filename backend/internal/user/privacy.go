@@ -0,0 +1,75 @@
+package user
+
+import (
+	"context"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	"go.uber.org/zap"
+)
+
+// PersonalData is the personal data kept about a user's account
+type PersonalData struct {
+	Profile   *User      `json:"profile"`
+	Addresses []*Address `json:"addresses"`
+	Sessions  []*Session `json:"sessions"`
+	APIKeys   []*APIKey  `json:"api_keys"`
+}
+
+// ExportPersonalData returns the profile, linked identities, saved addresses, active
+// sessions and API keys of a user
+func (s *service) ExportPersonalData(ctx context.Context, userID string) (interface{}, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := s.ListAddresses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PersonalData{Profile: user, Addresses: addresses, Sessions: sessions, APIKeys: keys}, nil
+}
+
+// CheckErasure returns ErrLastAdmin when erasing the user would leave no enabled admin.
+// Users who are already gone may be erased, so that an erasure failing after their account
+// was deleted can be retried.
+func (s *service) CheckErasure(ctx context.Context, userID string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil
+		}
+		return err
+	}
+	return s.ensureOtherAdmin(ctx, user)
+}
+
+// ErasePersonalData deletes a user's account along with their sessions, reset tokens, API
+// keys and addresses. Users who are already gone are skipped.
+func (s *service) ErasePersonalData(ctx context.Context, userID string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil
+		}
+		return err
+	}
+	if err := s.ensureOtherAdmin(ctx, user); err != nil {
+		return err
+	}
+	if err := s.deleteAccount(ctx, user); err != nil {
+		return err
+	}
+
+	audit.SecurityEvent(s.logger, "user_erased", zap.String("user_id", user.ID))
+	return nil
+}
@@ -0,0 +1,246 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/audit"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
+	"go.uber.org/zap"
+)
+
+// Built-in roles
+const (
+	// RoleAdmin grants every permission
+	RoleAdmin = "admin"
+	// RoleUser is given to everyone who registers and grants no staff permissions
+	RoleUser = "user"
+	// RoleCatalogEditor manages products and promotions
+	RoleCatalogEditor = "catalog_editor"
+	// RoleSupport looks up customers, orders, payments and returns without changing them
+	RoleSupport = "support"
+	// RoleSeller lists and manages products of their own. It is granted when an admin
+	// approves a seller application rather than assigned directly.
+	RoleSeller = "seller"
+)
+
+// DefaultRoles returns the built-in roles and the permissions they grant
+func DefaultRoles() map[string][]string {
+	return map[string][]string{
+		RoleAdmin:         authz.All,
+		RoleUser:          {},
+		RoleCatalogEditor: {authz.ProductWrite, authz.PromotionRead, authz.PromotionWrite},
+		RoleSupport:       {authz.UserRead, authz.OrderRead, authz.PaymentRead, authz.ReturnRead},
+		RoleSeller:        {authz.ProductSell},
+	}
+}
+
+// isStaff reports whether permissions go beyond those of the built-in user and seller
+// roles, which customers hold. Roles redefined in the configuration do not change what
+// counts as a customer.
+func isStaff(permissions []string) bool {
+	defaults := DefaultRoles()
+	customer := append(defaults[RoleUser], defaults[RoleSeller]...)
+	for _, permission := range permissions {
+		if !containsString(customer, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleNames returns the roles of the user. Users stored before they could hold several
+// roles only have Role.
+func (u *User) RoleNames() []string {
+	if len(u.Roles) == 0 && u.Role != "" {
+		return []string{u.Role}
+	}
+	return u.Roles
+}
+
+// HasRole reports whether the user holds role
+func (u *User) HasRole(role string) bool {
+	return containsString(u.RoleNames(), role)
+}
+
+// setRoles replaces the roles of the user, keeping Role in step with them
+func (u *User) setRoles(roles []string) {
+	u.Roles = roles
+	u.Role = primaryRole(roles)
+}
+
+// primaryRole returns the role reported to clients that know a single role
+func primaryRole(roles []string) string {
+	if containsString(roles, RoleAdmin) {
+		return RoleAdmin
+	}
+	if len(roles) == 0 {
+		return ""
+	}
+	return roles[0]
+}
+
+// ListRoles returns the defined roles and their permissions, sorted by name
+func (s *service) ListRoles(ctx context.Context) []RoleDefinition {
+	definitions := make([]RoleDefinition, 0, len(s.cfg.Roles))
+	for name := range s.cfg.Roles {
+		definitions = append(definitions, RoleDefinition{Name: name, Permissions: s.permissionsFor([]string{name})})
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+	return definitions
+}
+
+// GetUserRoles returns the roles of a user and the permissions they grant
+func (s *service) GetUserRoles(ctx context.Context, userID string) (*UserRoles, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.userRoles(user), nil
+}
+
+// SetUserRoles replaces the roles of a user. The change applies to access tokens issued
+// from then on, at the latest when the user's current ones are refreshed.
+func (s *service) SetUserRoles(ctx context.Context, userID, adminID string, roles []string) (*UserRoles, error) {
+	unique := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if _, defined := s.cfg.Roles[role]; !defined {
+			return nil, ErrUnknownRole
+		}
+		if !containsString(unique, role) {
+			unique = append(unique, role)
+		}
+	}
+
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !containsString(unique, RoleAdmin) {
+		if err := s.ensureOtherAdmin(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	previous := user.RoleNames()
+	user.setRoles(unique)
+	if err := s.repo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to update user roles", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
+
+	audit.SecurityEvent(s.logger, "roles_changed",
+		zap.String("user_id", user.ID),
+		zap.String("admin_id", adminID),
+		zap.Strings("previous_roles", previous),
+		zap.Strings("roles", unique))
+	return s.userRoles(user), nil
+}
+
+// GrantRole adds role to the roles of a user, keeping the ones they hold
+func (s *service) GrantRole(ctx context.Context, userID, adminID, role string) error {
+	roles, err := s.GetUserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if containsString(roles.Roles, role) {
+		return nil
+	}
+	_, err = s.SetUserRoles(ctx, userID, adminID, append(roles.Roles, role))
+	return err
+}
+
+// RevokeRole removes role from the roles of a user, keeping the others they hold
+func (s *service) RevokeRole(ctx context.Context, userID, adminID, role string) error {
+	roles, err := s.GetUserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !containsString(roles.Roles, role) {
+		return nil
+	}
+	kept := make([]string, 0, len(roles.Roles))
+	for _, held := range roles.Roles {
+		if held != role {
+			kept = append(kept, held)
+		}
+	}
+	_, err = s.SetUserRoles(ctx, userID, adminID, kept)
+	return err
+}
+
+// ensureOtherAdmin returns ErrLastAdmin when user is the only enabled admin, so that
+// taking their admin access away would leave nobody able to administer the system
+func (s *service) ensureOtherAdmin(ctx context.Context, user *User) error {
+	if !user.HasRole(RoleAdmin) || user.Disabled {
+		return nil
+	}
+
+	admins, err := s.repo.CountByRole(ctx, RoleAdmin)
+	if err != nil {
+		s.logger.Error("Failed to count admins", zap.Error(err))
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// MFARequired reports whether users holding roles must authenticate with MFA
+func (s *service) MFARequired(roles []string) bool {
+	return s.cfg.RequireMFAForAdmins && containsString(roles, RoleAdmin)
+}
+
+// userRoles describes the roles of user
+func (s *service) userRoles(user *User) *UserRoles {
+	roles := user.RoleNames()
+	return &UserRoles{UserID: user.ID, Roles: roles, Permissions: s.permissionsFor(roles)}
+}
+
+// permissionsFor returns the permissions granted by any of roles, sorted. Roles that are
+// no longer defined grant nothing.
+func (s *service) permissionsFor(roles []string) []string {
+	permissions := make([]string, 0)
+	for _, role := range roles {
+		for _, permission := range s.cfg.Roles[role] {
+			if !containsString(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// CheckRoles checks configured roles before they are passed in Config.Roles. The admin
+// role cannot be redefined and every permission must be one of authz.All.
+func CheckRoles(roles map[string][]string) error {
+	for name, permissions := range roles {
+		if name == "" {
+			return fmt.Errorf("role names cannot be empty")
+		}
+		if name == RoleAdmin {
+			return fmt.Errorf("the %s role cannot be redefined", RoleAdmin)
+		}
+		for _, permission := range permissions {
+			if !authz.IsKnown(permission) {
+				return fmt.Errorf("role %s grants unknown permission %q", name, permission)
+			}
+		}
+	}
+	return nil
+}
+
+// roleDefinitions merges configured roles over the default ones, keeping the admin role
+// able to do everything so that it cannot be configured out of reach
+func roleDefinitions(configured map[string][]string) map[string][]string {
+	roles := DefaultRoles()
+	for name, permissions := range configured {
+		roles[name] = permissions
+	}
+	roles[RoleAdmin] = authz.All
+	return roles
+}
@@ -2,6 +2,8 @@ package user
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -10,12 +12,25 @@ type Repository interface {
 	Create(ctx context.Context, user *User) error
 	FindByID(ctx context.Context, id string) (*User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
+	FindByIdentity(ctx context.Context, provider, subject string) (*User, error)
+	List(ctx context.Context, filters UserFilters) ([]*User, int, error)
 	Update(ctx context.Context, user *User) error
+	// UpdatePasswordHash replaces only the password hash of a user, provided it is still
+	// oldHash, and returns ErrPasswordChanged otherwise
+	UpdatePasswordHash(ctx context.Context, id, oldHash, newHash string) error
+	// AdvanceMFAStep records step as the TOTP time step of a user's last accepted code,
+	// returning ErrInvalidMFACode when a code of that or a later step was accepted before
+	AdvanceMFAStep(ctx context.Context, id string, step int64) error
+	// UseRecoveryCode removes the hash of a recovery code from a user, returning
+	// ErrInvalidMFACode when it is no longer there
+	UseRecoveryCode(ctx context.Context, id, hash string) error
 	Delete(ctx context.Context, id string) error
 	HasAdmin(ctx context.Context) (bool, error)
+	CountByRole(ctx context.Context, role string) (int, error)
 }
 
-// InMemoryRepository implements Repository using in-memory storage
+// InMemoryRepository implements Repository using in-memory storage. Users are stored and
+// returned as copies, so changes only take effect through Update.
 type InMemoryRepository struct {
 	users     map[string]*User
 	usersByEmail map[string]*User
@@ -40,8 +55,9 @@ func (r *InMemoryRepository) Create(ctx context.Context, user *User) error {
 		return ErrEmailAlreadyExists
 	}
 
-	r.users[user.ID] = user
-	r.usersByEmail[user.Email] = user
+	stored := *user
+	r.users[user.ID] = &stored
+	r.usersByEmail[user.Email] = &stored
 	return nil
 }
 
@@ -55,7 +71,8 @@ func (r *InMemoryRepository) FindByID(ctx context.Context, id string) (*User, er
 		return nil, ErrUserNotFound
 	}
 
-	return user, nil
+	found := *user
+	return &found, nil
 }
 
 // FindByEmail finds a user by email
@@ -68,23 +85,157 @@ func (r *InMemoryRepository) FindByEmail(ctx context.Context, email string) (*Us
 		return nil, ErrUserNotFound
 	}
 
-	return user, nil
+	found := *user
+	return &found, nil
 }
 
-// Update updates a user
+// FindByIdentity finds the user linked to the account subject at an identity provider
+func (r *InMemoryRepository) FindByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, user := range r.users {
+		for _, identity := range user.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				found := *user
+				return &found, nil
+			}
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// List lists users matching filters, oldest first, with pagination
+func (r *InMemoryRepository) List(ctx context.Context, filters UserFilters) ([]*User, int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	search := strings.ToLower(filters.Search)
+	filtered := make([]*User, 0)
+	for _, user := range r.users {
+		if search != "" && !strings.Contains(strings.ToLower(user.Email), search) && !strings.Contains(strings.ToLower(user.Name), search) {
+			continue
+		}
+		if filters.Role != "" && !user.HasRole(filters.Role) {
+			continue
+		}
+		if filters.Disabled != nil && user.Disabled != *filters.Disabled {
+			continue
+		}
+
+		found := *user
+		filtered = append(filtered, &found)
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		if filtered[i].CreatedAt.Equal(filtered[j].CreatedAt) {
+			return filtered[i].ID < filtered[j].ID
+		}
+		return filtered[i].CreatedAt.Before(filtered[j].CreatedAt)
+	})
+
+	totalCount := len(filtered)
+
+	// Pagination
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 10
+	}
+
+	start := (filters.Page - 1) * filters.PageSize
+	end := start + filters.PageSize
+
+	if start >= totalCount {
+		return []*User{}, totalCount, nil
+	}
+
+	if end > totalCount {
+		end = totalCount
+	}
+
+	return filtered[start:end], totalCount, nil
+}
+
+// Update updates a user. Changing the email moves the user to the new address, which
+// must not belong to another user.
 func (r *InMemoryRepository) Update(ctx context.Context, user *User) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, exists := r.users[user.ID]; !exists {
+	existing, exists := r.users[user.ID]
+	if !exists {
 		return ErrUserNotFound
 	}
+	if other, taken := r.usersByEmail[user.Email]; taken && other.ID != user.ID {
+		return ErrEmailAlreadyExists
+	}
 
-	r.users[user.ID] = user
-	r.usersByEmail[user.Email] = user
+	stored := *user
+	delete(r.usersByEmail, existing.Email)
+	r.users[user.ID] = &stored
+	r.usersByEmail[user.Email] = &stored
 	return nil
 }
 
+// UpdatePasswordHash replaces the password hash of a user if it is still oldHash, leaving
+// the rest of the user as stored
+func (r *InMemoryRepository) UpdatePasswordHash(ctx context.Context, id, oldHash, newHash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+	if user.PasswordHash != oldHash {
+		return ErrPasswordChanged
+	}
+
+	user.PasswordHash = newHash
+	return nil
+}
+
+// AdvanceMFAStep records step as the last accepted TOTP step of a user, unless it was
+// reached before, in one step so that a code is only ever accepted once
+func (r *InMemoryRepository) AdvanceMFAStep(ctx context.Context, id string, step int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+	if step <= user.MFALastStep {
+		return ErrInvalidMFACode
+	}
+
+	user.MFALastStep = step
+	return nil
+}
+
+// UseRecoveryCode removes a recovery code hash of a user in one step, so that a code is
+// only ever accepted once
+func (r *InMemoryRepository) UseRecoveryCode(ctx context.Context, id, hash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+	for i, stored := range user.RecoveryCodeHashes {
+		if stored == hash {
+			// Build a new slice; the previous one is shared with copies handed out
+			remaining := make([]string, 0, len(user.RecoveryCodeHashes)-1)
+			remaining = append(remaining, user.RecoveryCodeHashes[:i]...)
+			user.RecoveryCodeHashes = append(remaining, user.RecoveryCodeHashes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrInvalidMFACode
+}
+
 // Delete deletes a user
 func (r *InMemoryRepository) Delete(ctx context.Context, id string) error {
 	r.mutex.Lock()
@@ -106,10 +257,25 @@ func (r *InMemoryRepository) HasAdmin(ctx context.Context) (bool, error) {
 	defer r.mutex.RUnlock()
 
 	for _, user := range r.users {
-		if user.Role == "admin" {
+		if user.HasRole(RoleAdmin) {
 			return true, nil
 		}
 	}
 
 	return false, nil
 }
+
+// CountByRole counts the enabled users holding role
+func (r *InMemoryRepository) CountByRole(ctx context.Context, role string) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	count := 0
+	for _, user := range r.users {
+		if user.HasRole(role) && !user.Disabled {
+			count++
+		}
+	}
+
+	return count, nil
+}
@@ -2,42 +2,179 @@ package user
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/mailer"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/password"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
-const bcryptCost = 12
+const (
+	// defaultBcryptCost is the bcrypt cost used when the configuration sets no hashers
+	defaultBcryptCost = 12
+	// Password policy limits used when the configuration leaves them unset
+	defaultPasswordMinLength      = 8
+	defaultPasswordMaxLength      = 128
+	defaultAdminPasswordMinLength = 12
+)
 
 // Service defines the interface for user business logic
 type Service interface {
 	Register(ctx context.Context, req RegisterRequest) (*User, error)
-	Login(ctx context.Context, req LoginRequest) (*AuthResponse, error)
+	Login(ctx context.Context, req LoginRequest, client ClientInfo) (*AuthResponse, error)
 	GetProfile(ctx context.Context, userID string) (*User, error)
 	UpdateProfile(ctx context.Context, userID string, req UpdateProfileRequest) (*User, error)
-	RefreshToken(ctx context.Context, refreshToken string) (*AuthResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string, client ClientInfo) (*AuthResponse, error)
+	Logout(ctx context.Context, refreshToken string) error
+	LogoutAll(ctx context.Context, userID string) error
+	ListSessions(ctx context.Context, userID string) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	VerifyEmail(ctx context.Context, token string) (*User, error)
+	ResendVerification(ctx context.Context, email string) error
+	EnsureVerified(ctx context.Context, userID string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, req ResetPasswordRequest) error
+	ChangePassword(ctx context.Context, userID string, req ChangePasswordRequest, client ClientInfo) (*AuthResponse, error)
+	RequestEmailChange(ctx context.Context, userID string, req ChangeEmailRequest) (*User, error)
+	ConfirmEmailChange(ctx context.Context, token string) (*User, error)
+	EnrollTOTP(ctx context.Context, userID string, req EnrollTOTPRequest) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID, code string) (*RecoveryCodes, error)
+	DisableMFA(ctx context.Context, userID string, req DisableMFARequest) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string) (*RecoveryCodes, error)
+	CompleteMFALogin(ctx context.Context, req MFALoginRequest, client ClientInfo) (*AuthResponse, error)
+	MFARequired(roles []string) bool
+	UnlockUser(ctx context.Context, userID, adminID string) error
+	ListRoles(ctx context.Context) []RoleDefinition
+	GetUserRoles(ctx context.Context, userID string) (*UserRoles, error)
+	SetUserRoles(ctx context.Context, userID, adminID string, roles []string) (*UserRoles, error)
+	GrantRole(ctx context.Context, userID, adminID, role string) error
+	RevokeRole(ctx context.Context, userID, adminID, role string) error
+	ListUsers(ctx context.Context, filters UserFilters) (*UserList, error)
+	DisableUser(ctx context.Context, userID, adminID string) (*User, error)
+	EnableUser(ctx context.Context, userID, adminID string) (*User, error)
+	ForcePasswordReset(ctx context.Context, userID, adminID string) error
+	Impersonate(ctx context.Context, userID, adminID string, req ImpersonateRequest) (*Impersonation, error)
+	ExportPersonalData(ctx context.Context, userID string) (interface{}, error)
+	CheckErasure(ctx context.Context, userID string) error
+	ErasePersonalData(ctx context.Context, userID string) error
+	EnsureActive(ctx context.Context, userID string) error
+	CreateAPIKey(ctx context.Context, userID string, req CreateAPIKeyRequest) (*NewAPIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID, actorID string) error
+	AuthenticateAPIKey(ctx context.Context, secret, ipAddress string) (*jwtPkg.Claims, error)
+	ScopedClaims(ctx context.Context, userID string, scopes []string) (*jwtPkg.Claims, error)
+	CreateAddress(ctx context.Context, userID string, req AddressRequest) (*Address, error)
+	ListAddresses(ctx context.Context, userID string) ([]*Address, error)
+	GetAddress(ctx context.Context, userID, addressID string) (*Address, error)
+	UpdateAddress(ctx context.Context, userID, addressID string, req AddressRequest) (*Address, error)
+	DeleteAddress(ctx context.Context, userID, addressID string) error
+	OIDCProviders() []string
+	StartOIDCLogin(ctx context.Context, provider string) (authURL, browserKey string, err error)
+	CompleteOIDCLogin(ctx context.Context, provider string, req OIDCCallbackRequest, browserKey string, client ClientInfo) (*AuthResponse, error)
 	BootstrapAdmin(ctx context.Context) error
+	// Close waits for account emails still being sent, or for ctx to be done. The service
+	// sends no more emails afterwards.
+	Close(ctx context.Context) error
 }
 
 // service implements Service
 type service struct {
 	repo       Repository
+	sessions   SessionRepository
+	resets     PasswordResetRepository
+	attempts   LoginAttemptRepository
+	apiKeys    APIKeyRepository
+	oidcLogins OIDCLoginRepository
+	addresses  AddressRepository
 	jwtService *jwtPkg.Service
+	mailer     mailer.Mailer
+	cfg        Config
 	logger     *zap.Logger
+
+	// dummyHash is checked for logins with unknown emails, see compareDummyPassword
+	dummyHash     string
+	dummyHashOnce sync.Once
+	// addressMutex serializes address book changes, which keep one default per user
+	addressMutex sync.Mutex
+	// emails sends account emails after their request has been answered, see ForgotPassword
+	emails *emailQueue
 }
 
-// NewService creates a new user service
-func NewService(repo Repository, jwtService *jwtPkg.Service, logger *zap.Logger) Service {
+// NewService creates a new user service. Login sessions and their refresh tokens are
+// tracked in sessions so they can be listed, rotated and revoked, and password reset
+// tokens in resets, failed logins in attempts, API keys in apiKeys, logins waiting for an
+// identity provider in oidcLogins and saved shipping and billing addresses in addresses;
+// account emails such as address verification are delivered through m.
+func NewService(repo Repository, sessions SessionRepository, resets PasswordResetRepository, attempts LoginAttemptRepository, apiKeys APIKeyRepository, oidcLogins OIDCLoginRepository, addresses AddressRepository, jwtService *jwtPkg.Service, m mailer.Mailer, cfg Config, logger *zap.Logger) Service {
+	if cfg.EmailVerification == "" {
+		cfg.EmailVerification = VerificationNotRequired
+	}
+	if cfg.VerificationTokenTTL <= 0 {
+		cfg.VerificationTokenTTL = defaultVerificationTokenTTL
+	}
+	if cfg.PasswordResetTokenTTL <= 0 {
+		cfg.PasswordResetTokenTTL = defaultPasswordResetTokenTTL
+	}
+	if cfg.ImpersonationTTL <= 0 {
+		cfg.ImpersonationTTL = defaultImpersonationTTL
+	}
+	if cfg.MFAIssuer == "" {
+		cfg.MFAIssuer = defaultMFAIssuer
+	}
+	if cfg.LockoutThreshold <= 0 {
+		cfg.LockoutThreshold = defaultLockoutThreshold
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = defaultLockoutDuration
+	}
+	if cfg.LoginBackoffAfter <= 0 {
+		cfg.LoginBackoffAfter = defaultLoginBackoffAfter
+	}
+	if cfg.LoginBackoffBase <= 0 {
+		cfg.LoginBackoffBase = defaultLoginBackoffBase
+	}
+	if cfg.IPFailureThreshold <= 0 {
+		cfg.IPFailureThreshold = defaultIPFailureThreshold
+	}
+	if cfg.PasswordPolicy.MinLength <= 0 {
+		cfg.PasswordPolicy.MinLength = defaultPasswordMinLength
+	}
+	if cfg.PasswordPolicy.MaxLength <= 0 {
+		cfg.PasswordPolicy.MaxLength = defaultPasswordMaxLength
+	}
+	if cfg.PasswordPolicy.MinAdminLength <= 0 {
+		cfg.PasswordPolicy.MinAdminLength = defaultAdminPasswordMinLength
+	}
+	if cfg.PasswordHashers == nil {
+		// Argon2id hashes verify with the parameters stored in them
+		cfg.PasswordHashers = password.NewHashers(password.BcryptHasher{Cost: defaultBcryptCost}, password.Argon2idHasher{})
+	}
+	cfg.Roles = roleDefinitions(cfg.Roles)
+
 	return &service{
 		repo:       repo,
+		sessions:   sessions,
+		resets:     resets,
+		attempts:   attempts,
+		apiKeys:    apiKeys,
+		oidcLogins: oidcLogins,
+		addresses:  addresses,
 		jwtService: jwtService,
+		mailer:     m,
+		cfg:        cfg,
 		logger:     logger,
+		emails:     newEmailQueue(logger),
 	}
 }
 
+// Close waits for account emails still being sent, or for ctx to be done
+func (s *service) Close(ctx context.Context) error {
+	return s.emails.close(ctx)
+}
+
 // Register registers a new user
 func (s *service) Register(ctx context.Context, req RegisterRequest) (*User, error) {
 	s.logger.Info("Registering new user", zap.String("email", req.Email))
@@ -53,8 +190,12 @@ func (s *service) Register(ctx context.Context, req RegisterRequest) (*User, err
 		return nil, err
 	}
 
+	if err := s.checkPassword(ctx, req.Password, []string{RoleUser}, req.Email, req.Name); err != nil {
+		return nil, err
+	}
+
 	// Hash password
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcryptCost)
+	passwordHash, err := s.cfg.PasswordHashers.Hash(req.Password)
 	if err != nil {
 		s.logger.Error("Failed to hash password", zap.Error(err))
 		return nil, err
@@ -65,9 +206,10 @@ func (s *service) Register(ctx context.Context, req RegisterRequest) (*User, err
 	user := &User{
 		ID:           uuid.New().String(),
 		Email:        req.Email,
-		PasswordHash: string(passwordHash),
+		PasswordHash: passwordHash,
 		Name:         req.Name,
-		Role:         "user",
+		Role:         RoleUser,
+		Roles:        []string{RoleUser},
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
@@ -78,18 +220,31 @@ func (s *service) Register(ctx context.Context, req RegisterRequest) (*User, err
 	}
 
 	s.logger.Info("User registered successfully", zap.String("user_id", user.ID), zap.String("email", user.Email))
+
+	// The account exists either way; a lost email can be resent
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		s.logger.Error("Failed to send verification email", zap.String("user_id", user.ID), zap.Error(err))
+	}
 	return user, nil
 }
 
 // Login authenticates a user and returns JWT tokens
-func (s *service) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
+func (s *service) Login(ctx context.Context, req LoginRequest, client ClientInfo) (*AuthResponse, error) {
 	s.logger.Info("User login attempt", zap.String("email", req.Email))
 
+	accountKey, ipKey := accountAttemptKey(req.Email), ipAttemptKey(client.IPAddress)
+	if err := s.checkLoginThrottle(ctx, accountKey, ipKey, time.Now()); err != nil {
+		s.logger.Warn("Throttled login attempt", zap.String("email", req.Email), zap.String("ip_address", client.IPAddress), zap.Error(err))
+		return nil, err
+	}
+
 	// Find user by email
 	user, err := s.repo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		if err == ErrUserNotFound {
 			s.logger.Warn("Login attempt with non-existent email", zap.String("email", req.Email))
+			s.compareDummyPassword(req.Password)
+			s.recordLoginFailure(ctx, nil, req.Email, accountKey, ipKey, client)
 			return nil, ErrInvalidCredentials
 		}
 		s.logger.Error("Failed to find user", zap.Error(err))
@@ -97,32 +252,38 @@ func (s *service) Login(ctx context.Context, req LoginRequest) (*AuthResponse, e
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+	if !s.verifyPassword(ctx, user, req.Password) {
 		s.logger.Warn("Login attempt with invalid password", zap.String("email", req.Email))
+		s.recordLoginFailure(ctx, user, req.Email, accountKey, ipKey, client)
 		return nil, ErrInvalidCredentials
 	}
+	if err := s.attempts.Reset(ctx, accountKey); err != nil {
+		s.logger.Error("Failed to reset login attempts", zap.String("user_id", user.ID), zap.Error(err))
+		return nil, err
+	}
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email, user.Role)
-	if err != nil {
-		s.logger.Error("Failed to generate access token", zap.Error(err))
+	if err := checkActive(user); err != nil {
+		s.logger.Warn("Login attempt for inactive account", zap.String("user_id", user.ID), zap.Error(err))
 		return nil, err
 	}
 
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user.ID)
+	if !user.EmailVerified && s.cfg.EmailVerification == VerificationRequiredForLogin {
+		s.logger.Warn("Login attempt with unverified email", zap.String("user_id", user.ID))
+		return nil, ErrEmailNotVerified
+	}
+
+	if user.MFAEnabled {
+		return s.startMFAChallenge(ctx, user, []string{jwtPkg.AuthMethodPassword})
+	}
+
+	// Generate tokens, starting a new session and refresh token family
+	authResp, err := s.issueTokens(ctx, user, uuid.New().String(), "", []string{jwtPkg.AuthMethodPassword}, client)
 	if err != nil {
-		s.logger.Error("Failed to generate refresh token", zap.Error(err))
 		return nil, err
 	}
 
 	s.logger.Info("User logged in successfully", zap.String("user_id", user.ID))
-
-	return &AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    900, // 15 minutes in seconds
-		User:         user,
-	}, nil
+	return authResp, nil
 }
 
 // GetProfile retrieves a user's profile
@@ -160,43 +321,206 @@ func (s *service) UpdateProfile(ctx context.Context, userID string, req UpdatePr
 	return user, nil
 }
 
-// RefreshToken generates new tokens using a refresh token
-func (s *service) RefreshToken(ctx context.Context, refreshToken string) (*AuthResponse, error) {
+// RefreshToken exchanges a refresh token for new tokens. The presented token is rotated out;
+// presenting it again revokes its whole session, since one of the two holders must have
+// stolen it.
+func (s *service) RefreshToken(ctx context.Context, refreshToken string, client ClientInfo) (*AuthResponse, error) {
 	s.logger.Info("Refreshing token")
 
-	// Validate refresh token
-	userID, err := s.jwtService.ValidateRefreshToken(refreshToken)
+	stored, err := s.findRefreshToken(ctx, refreshToken)
 	if err != nil {
-		s.logger.Warn("Invalid refresh token", zap.Error(err))
+		return nil, err
+	}
+
+	session, err := s.sessions.FindSession(ctx, stored.FamilyID)
+	if err != nil {
+		s.logger.Warn("Refresh token without session", zap.String("family_id", stored.FamilyID))
 		return nil, jwtPkg.ErrInvalidToken
 	}
+	if stored.RevokedAt != nil || session.RevokedAt != nil {
+		s.logger.Warn("Revoked refresh token presented", zap.String("user_id", stored.UserID), zap.String("session_id", session.ID))
+		return nil, ErrRefreshTokenRevoked
+	}
+	if stored.RotatedAt != nil {
+		return nil, s.revokeReusedSession(ctx, stored)
+	}
 
 	// Get user
-	user, err := s.repo.FindByID(ctx, userID)
+	user, err := s.repo.FindByID(ctx, stored.UserID)
 	if err != nil {
-		s.logger.Error("Failed to find user", zap.String("user_id", userID), zap.Error(err))
+		s.logger.Error("Failed to find user", zap.String("user_id", stored.UserID), zap.Error(err))
+		return nil, err
+	}
+	if err := checkActive(user); err != nil {
+		s.logger.Warn("Token refresh for inactive account", zap.String("user_id", user.ID), zap.Error(err))
 		return nil, err
 	}
 
-	// Generate new tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email, user.Role)
+	authResp, err := s.issueTokens(ctx, user, session.ID, stored.ID, session.AuthMethods, client)
+	if err == ErrRefreshTokenReused {
+		// Lost a race against another refresh with the same token
+		return nil, s.revokeReusedSession(ctx, stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Token refreshed successfully", zap.String("user_id", user.ID))
+	return authResp, nil
+}
+
+// Logout revokes the session the given refresh token belongs to
+func (s *service) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.findRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	if err := s.sessions.RevokeSession(ctx, stored.FamilyID); err != nil {
+		s.logger.Error("Failed to revoke session", zap.String("session_id", stored.FamilyID), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("User logged out", zap.String("user_id", stored.UserID), zap.String("session_id", stored.FamilyID))
+	return nil
+}
+
+// LogoutAll revokes every session of a user
+func (s *service) LogoutAll(ctx context.Context, userID string) error {
+	if err := s.sessions.RevokeUserSessions(ctx, userID); err != nil {
+		s.logger.Error("Failed to revoke sessions", zap.String("user_id", userID), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("User logged out everywhere", zap.String("user_id", userID))
+	return nil
+}
+
+// ListSessions lists the active sessions of a user
+func (s *service) ListSessions(ctx context.Context, userID string) ([]*Session, error) {
+	s.logger.Debug("Listing sessions", zap.String("user_id", userID))
+
+	sessions, err := s.sessions.ListSessions(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list sessions", zap.String("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes one of the user's sessions, e.g. a lost device
+func (s *service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.sessions.FindSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// Other users' and already ended sessions look the same as unknown ones
+	if session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.sessions.RevokeSession(ctx, sessionID); err != nil {
+		s.logger.Error("Failed to revoke session", zap.String("session_id", sessionID), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Session revoked", zap.String("user_id", userID), zap.String("session_id", sessionID))
+	return nil
+}
+
+// findRefreshToken validates a refresh token and returns its server-side record
+func (s *service) findRefreshToken(ctx context.Context, refreshToken string) (*RefreshToken, error) {
+	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		s.logger.Warn("Invalid refresh token", zap.Error(err))
+		if err == jwtPkg.ErrWrongTokenType {
+			return nil, err
+		}
+		return nil, jwtPkg.ErrInvalidToken
+	}
+
+	stored, err := s.sessions.FindRefreshToken(ctx, claims.ID)
+	if err != nil || stored.UserID != claims.Subject || stored.FamilyID != claims.FamilyID {
+		s.logger.Warn("Unknown refresh token", zap.String("token_id", claims.ID))
+		return nil, jwtPkg.ErrInvalidToken
+	}
+
+	return stored, nil
+}
+
+// issueTokens generates an access token and a refresh token for session sessionID. When
+// previousID is set the new refresh token replaces it; otherwise a new session is started
+// for a user who authenticated with methods.
+func (s *service) issueTokens(ctx context.Context, user *User, sessionID, previousID string, methods []string, client ClientInfo) (*AuthResponse, error) {
+	accessToken, err := s.jwtService.IssueAccessToken(jwtPkg.Claims{
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+		Roles:         user.RoleNames(),
+		Permissions:   s.permissionsFor(user.RoleNames()),
+		AMR:           methods,
+	})
 	if err != nil {
 		s.logger.Error("Failed to generate access token", zap.Error(err))
 		return nil, err
 	}
 
-	newRefreshToken, err := s.jwtService.GenerateRefreshToken(user.ID)
+	refreshToken, claims, err := s.jwtService.GenerateRefreshToken(user.ID, sessionID)
 	if err != nil {
 		s.logger.Error("Failed to generate refresh token", zap.Error(err))
 		return nil, err
 	}
 
-	s.logger.Info("Token refreshed successfully", zap.String("user_id", user.ID))
+	stored := &RefreshToken{
+		ID:        claims.ID,
+		FamilyID:  sessionID,
+		UserID:    user.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+		CreatedAt: time.Now(),
+	}
+	if previousID == "" {
+		err = s.sessions.Create(ctx, &Session{
+			ID:          sessionID,
+			UserID:      user.ID,
+			UserAgent:   client.UserAgent,
+			IPAddress:   client.IPAddress,
+			CreatedAt:   stored.CreatedAt,
+			LastUsedAt:  stored.CreatedAt,
+			ExpiresAt:   stored.ExpiresAt,
+			AuthMethods: methods,
+		}, stored)
+	} else {
+		err = s.sessions.Rotate(ctx, previousID, stored, client.IPAddress)
+	}
+	if err != nil {
+		if err != ErrRefreshTokenReused && err != ErrRefreshTokenRevoked {
+			s.logger.Error("Failed to store refresh token", zap.Error(err))
+		}
+		return nil, err
+	}
 
 	return &AuthResponse{
 		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-		ExpiresIn:    900,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.jwtService.AccessTokenDuration() / time.Second),
 		User:         user,
 	}, nil
 }
+
+// revokeReusedSession handles an already rotated refresh token being presented again by
+// revoking its whole session, and returns the error to report to the caller
+func (s *service) revokeReusedSession(ctx context.Context, stored *RefreshToken) error {
+	s.logger.Warn("Refresh token reuse detected, revoking session",
+		zap.String("user_id", stored.UserID),
+		zap.String("session_id", stored.FamilyID),
+		zap.String("token_id", stored.ID),
+	)
+
+	if err := s.sessions.RevokeSession(ctx, stored.FamilyID); err != nil {
+		s.logger.Error("Failed to revoke session", zap.String("session_id", stored.FamilyID), zap.Error(err))
+		return err
+	}
+	return ErrRefreshTokenReused
+}
@@ -2,20 +2,71 @@ package user
 
 import (
 	"context"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/common/authz"
 	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/mailer"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc/oidctest"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/password"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/totp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
+// captureMailer records sent messages instead of delivering them
+type captureMailer struct {
+	mutex    sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *captureMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// last returns the most recently sent message
+func (m *captureMailer) last(t *testing.T) mailer.Message {
+	t.Helper()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	require.NotEmpty(t, m.messages)
+	return m.messages[len(m.messages)-1]
+}
+
+// waitForEmails waits for the emails the service sends in the background
+func waitForEmails(s Service) {
+	s.(*service).emails.wait()
+}
+
 func setupTestService() Service {
-	repo := NewInMemoryRepository()
+	service, _ := setupTestServiceWithConfig(Config{})
+	return service
+}
+
+func setupTestServiceWithConfig(cfg Config) (Service, *captureMailer) {
+	m := &captureMailer{}
+	return newTestService(NewInMemoryRepository(), m, cfg), m
+}
+
+// newTestService creates a service storing users in repo. Passwords are hashed with the
+// lowest bcrypt cost unless cfg says otherwise, as the production cost makes the tests
+// too slow to run with the race detector.
+func newTestService(repo Repository, m mailer.Mailer, cfg Config) Service {
+	if cfg.PasswordHashers == nil {
+		cfg.PasswordHashers = password.NewHashers(password.BcryptHasher{Cost: 4})
+	}
 	jwtService := jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour)
 	logger, _ := zap.NewDevelopment()
-	return NewService(repo, jwtService, logger)
+	return NewService(repo, NewInMemorySessionRepository(), NewInMemoryPasswordResetRepository(), NewInMemoryLoginAttemptRepository(), NewInMemoryAPIKeyRepository(), NewInMemoryOIDCLoginRepository(), NewInMemoryAddressRepository(), jwtService, m, cfg, logger)
 }
 
 func TestService_Register(t *testing.T) {
@@ -117,7 +168,7 @@ func TestService_Login(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			authResp, err := service.Login(ctx, tt.request)
+			authResp, err := service.Login(ctx, tt.request, ClientInfo{})
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -254,7 +305,7 @@ func TestService_RefreshToken(t *testing.T) {
 		Email:    "test@example.com",
 		Password: "SecurePass123!",
 	}
-	authResp, err := service.Login(ctx, loginReq)
+	authResp, err := service.Login(ctx, loginReq, ClientInfo{})
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -279,7 +330,7 @@ func TestService_RefreshToken(t *testing.T) {
 			// Add a delay to ensure new tokens have different timestamps (at least 1 second for JWT)
 			time.Sleep(1 * time.Second)
 			
-			newAuthResp, err := service.RefreshToken(ctx, tt.refreshToken)
+			newAuthResp, err := service.RefreshToken(ctx, tt.refreshToken, ClientInfo{})
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -294,3 +345,1292 @@ func TestService_RefreshToken(t *testing.T) {
 		})
 	}
 }
+
+func loginTestUser(t *testing.T, service Service) *AuthResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	_, err := service.Register(ctx, RegisterRequest{Email: "test@example.com", Password: "SecurePass123!", Name: "Test User"})
+	require.NoError(t, err)
+	authResp, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+	return authResp
+}
+
+func TestService_RefreshToken_Rotation(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	first := loginTestUser(t, service)
+
+	second, err := service.RefreshToken(ctx, first.RefreshToken, ClientInfo{})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	third, err := service.RefreshToken(ctx, second.RefreshToken, ClientInfo{})
+	require.NoError(t, err)
+
+	// Replaying a rotated token is treated as theft and revokes the whole family
+	_, err = service.RefreshToken(ctx, first.RefreshToken, ClientInfo{})
+	assert.Equal(t, ErrRefreshTokenReused, err)
+
+	_, err = service.RefreshToken(ctx, third.RefreshToken, ClientInfo{})
+	assert.Equal(t, ErrRefreshTokenRevoked, err)
+
+	// Other logins are unaffected
+	other, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+	_, err = service.RefreshToken(ctx, other.RefreshToken, ClientInfo{})
+	assert.NoError(t, err)
+}
+
+func TestService_Logout(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	session := loginTestUser(t, service)
+	other, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+
+	assert.Equal(t, jwtPkg.ErrInvalidToken, service.Logout(ctx, "invalid-token"))
+
+	require.NoError(t, service.Logout(ctx, session.RefreshToken))
+	_, err = service.RefreshToken(ctx, session.RefreshToken, ClientInfo{})
+	assert.Equal(t, ErrRefreshTokenRevoked, err)
+
+	// Logging out one session leaves the others signed in
+	other, err = service.RefreshToken(ctx, other.RefreshToken, ClientInfo{})
+	require.NoError(t, err)
+
+	require.NoError(t, service.LogoutAll(ctx, other.User.ID))
+	_, err = service.RefreshToken(ctx, other.RefreshToken, ClientInfo{})
+	assert.Equal(t, ErrRefreshTokenRevoked, err)
+}
+
+func TestService_RefreshToken_WrongTokenType(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	session := loginTestUser(t, service)
+
+	_, err := service.RefreshToken(ctx, session.AccessToken, ClientInfo{})
+	assert.Equal(t, jwtPkg.ErrWrongTokenType, err)
+	assert.Equal(t, jwtPkg.ErrWrongTokenType, service.Logout(ctx, session.AccessToken))
+}
+
+func TestService_Sessions(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	laptop := loginTestUser(t, service)
+	userID := laptop.User.ID
+	phone, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{UserAgent: "Phone/1.0", IPAddress: "203.0.113.7"})
+	require.NoError(t, err)
+
+	sessions, err := service.ListSessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	// Most recently used first
+	assert.Equal(t, "Phone/1.0", sessions[0].UserAgent)
+	assert.Equal(t, "203.0.113.7", sessions[0].IPAddress)
+	phoneSessionID := sessions[0].ID
+
+	// Refreshing records the new address and moves the session to the top
+	time.Sleep(10 * time.Millisecond)
+	laptop, err = service.RefreshToken(ctx, laptop.RefreshToken, ClientInfo{IPAddress: "198.51.100.2"})
+	require.NoError(t, err)
+	sessions, err = service.ListSessions(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "198.51.100.2", sessions[0].IPAddress)
+	assert.True(t, sessions[0].LastUsedAt.After(sessions[0].CreatedAt))
+
+	assert.Equal(t, ErrSessionNotFound, service.RevokeSession(ctx, "someone-else", phoneSessionID))
+	assert.Equal(t, ErrSessionNotFound, service.RevokeSession(ctx, userID, "missing"))
+
+	require.NoError(t, service.RevokeSession(ctx, userID, phoneSessionID))
+	assert.Equal(t, ErrSessionNotFound, service.RevokeSession(ctx, userID, phoneSessionID))
+
+	_, err = service.RefreshToken(ctx, phone.RefreshToken, ClientInfo{})
+	assert.Equal(t, ErrRefreshTokenRevoked, err)
+	_, err = service.RefreshToken(ctx, laptop.RefreshToken, ClientInfo{})
+	assert.NoError(t, err)
+
+	sessions, err = service.ListSessions(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+// verificationToken extracts the token from the link in a verification or password reset email
+func verificationToken(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+	start := strings.Index(msg.Body, "?token=")
+	require.GreaterOrEqual(t, start, 0, "no verification link in %q", msg.Body)
+	token := msg.Body[start+len("?token="):]
+	token = token[:strings.IndexAny(token, " \n")]
+	unescaped, err := url.QueryUnescape(token)
+	require.NoError(t, err)
+	return unescaped
+}
+
+func TestService_VerifyEmail(t *testing.T) {
+	service, sent := setupTestServiceWithConfig(Config{VerifyEmailURL: "https://shop.example.com/verify"})
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, RegisterRequest{Email: "test@example.com", Password: "SecurePass123!", Name: "Test User"})
+	require.NoError(t, err)
+	assert.False(t, user.EmailVerified)
+	msg := sent.last(t)
+	assert.Equal(t, "test@example.com", msg.To)
+	first := verificationToken(t, msg)
+
+	// Resending replaces the earlier token
+	require.NoError(t, service.ResendVerification(ctx, "test@example.com"))
+	waitForEmails(service)
+	second := verificationToken(t, sent.last(t))
+	_, err = service.VerifyEmail(ctx, first)
+	assert.Equal(t, ErrInvalidVerificationToken, err)
+
+	verified, err := service.VerifyEmail(ctx, second)
+	require.NoError(t, err)
+	assert.True(t, verified.EmailVerified)
+
+	// Tokens are single use, and verified or unknown addresses get no email
+	_, err = service.VerifyEmail(ctx, second)
+	assert.Equal(t, ErrInvalidVerificationToken, err)
+	_, err = service.VerifyEmail(ctx, "not-a-token")
+	assert.Equal(t, ErrInvalidVerificationToken, err)
+	require.NoError(t, service.ResendVerification(ctx, "test@example.com"))
+	require.NoError(t, service.ResendVerification(ctx, "nobody@example.com"))
+	waitForEmails(service)
+	assert.Len(t, sent.messages, 2)
+
+	// Access tokens carry the verification state
+	authResp, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+	claims, err := jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour).ValidateToken(authResp.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, claims.EmailVerified)
+}
+
+func TestService_EmailVerificationEnforcement(t *testing.T) {
+	ctx := context.Background()
+	register := RegisterRequest{Email: "test@example.com", Password: "SecurePass123!", Name: "Test User"}
+	login := LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}
+
+	t.Run("none", func(t *testing.T) {
+		service, _ := setupTestServiceWithConfig(Config{})
+		user, err := service.Register(ctx, register)
+		require.NoError(t, err)
+		_, err = service.Login(ctx, login, ClientInfo{})
+		assert.NoError(t, err)
+		assert.NoError(t, service.EnsureVerified(ctx, user.ID))
+	})
+
+	t.Run("login", func(t *testing.T) {
+		service, sent := setupTestServiceWithConfig(Config{EmailVerification: VerificationRequiredForLogin, VerifyEmailURL: "https://shop.example.com/verify"})
+		_, err := service.Register(ctx, register)
+		require.NoError(t, err)
+		_, err = service.Login(ctx, login, ClientInfo{})
+		assert.Equal(t, ErrEmailNotVerified, err)
+
+		_, err = service.VerifyEmail(ctx, verificationToken(t, sent.last(t)))
+		require.NoError(t, err)
+		_, err = service.Login(ctx, login, ClientInfo{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("actions", func(t *testing.T) {
+		service, sent := setupTestServiceWithConfig(Config{EmailVerification: VerificationRequiredForActions, VerifyEmailURL: "https://shop.example.com/verify"})
+		user, err := service.Register(ctx, register)
+		require.NoError(t, err)
+		_, err = service.Login(ctx, login, ClientInfo{})
+		assert.NoError(t, err)
+		assert.Equal(t, ErrEmailNotVerified, service.EnsureVerified(ctx, user.ID))
+
+		_, err = service.VerifyEmail(ctx, verificationToken(t, sent.last(t)))
+		require.NoError(t, err)
+		assert.NoError(t, service.EnsureVerified(ctx, user.ID))
+	})
+}
+
+func TestService_PasswordReset(t *testing.T) {
+	service, sent := setupTestServiceWithConfig(Config{ResetPasswordURL: "https://shop.example.com/reset"})
+	ctx := context.Background()
+	session := loginTestUser(t, service)
+
+	// Unknown addresses get no email
+	require.NoError(t, service.ForgotPassword(ctx, "nobody@example.com"))
+	waitForEmails(service)
+	sentBefore := len(sent.messages)
+
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	waitForEmails(service)
+	first := verificationToken(t, sent.last(t))
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	waitForEmails(service)
+	second := verificationToken(t, sent.last(t))
+	assert.Len(t, sent.messages, sentBefore+2)
+	assert.Equal(t, "Reset your password", sent.last(t).Subject)
+
+	// A newer email replaces the earlier link
+	assert.Equal(t, ErrInvalidResetToken, service.ResetPassword(ctx, ResetPasswordRequest{Token: first, Password: "NewSecurePass456!"}))
+	require.NoError(t, service.ResetPassword(ctx, ResetPasswordRequest{Token: second, Password: "NewSecurePass456!"}))
+	assert.Equal(t, ErrInvalidResetToken, service.ResetPassword(ctx, ResetPasswordRequest{Token: second, Password: "AnotherPass789!"}))
+
+	// Existing sessions are signed out and only the new password works
+	_, err := service.RefreshToken(ctx, session.RefreshToken, ClientInfo{})
+	assert.Equal(t, ErrRefreshTokenRevoked, err)
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+	authResp, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "NewSecurePass456!"}, ClientInfo{})
+	require.NoError(t, err)
+	assert.True(t, authResp.User.EmailVerified)
+}
+
+// heldMailer holds password reset emails until release is closed
+type heldMailer struct {
+	captureMailer
+	release chan struct{}
+}
+
+func (m *heldMailer) Send(ctx context.Context, msg mailer.Message) error {
+	if msg.Subject == "Reset your password" {
+		<-m.release
+	}
+	return m.captureMailer.Send(ctx, msg)
+}
+
+func TestService_CloseSendsQueuedEmails(t *testing.T) {
+	m := &heldMailer{release: make(chan struct{})}
+	service := newTestService(NewInMemoryRepository(), m, Config{})
+	ctx := context.Background()
+	loginTestUser(t, service)
+	sentBefore := len(m.messages)
+
+	// Requests are answered while their emails are still being sent
+	for i := 0; i < 3; i++ {
+		require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, service.Close(timeoutCtx))
+
+	// Closing waits for every queued email, and later requests send none
+	close(m.release)
+	require.NoError(t, service.Close(ctx))
+	assert.Len(t, m.messages, sentBefore+3)
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	require.NoError(t, service.Close(ctx))
+	assert.Len(t, m.messages, sentBefore+3)
+}
+
+func TestInMemoryPasswordResetRepository_Expiry(t *testing.T) {
+	repo := NewInMemoryPasswordResetRepository()
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, &PasswordResetToken{TokenHash: "expired", UserID: "user-1", ExpiresAt: time.Now().Add(-time.Minute)}))
+	_, err := repo.Consume(ctx, "expired")
+	assert.Equal(t, ErrInvalidResetToken, err)
+
+	require.NoError(t, repo.Create(ctx, &PasswordResetToken{TokenHash: "valid", UserID: "user-1", ExpiresAt: time.Now().Add(time.Minute)}))
+	token, err := repo.Consume(ctx, "valid")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", token.UserID)
+}
+
+func TestService_ChangePassword(t *testing.T) {
+	service, sent := setupTestServiceWithConfig(Config{})
+	ctx := context.Background()
+	other := loginTestUser(t, service)
+	userID := other.User.ID
+
+	_, err := service.ChangePassword(ctx, userID, ChangePasswordRequest{CurrentPassword: "WrongPass123!", NewPassword: "NewSecurePass456!"}, ClientInfo{})
+	assert.Equal(t, ErrIncorrectPassword, err)
+
+	authResp, err := service.ChangePassword(ctx, userID, ChangePasswordRequest{CurrentPassword: "SecurePass123!", NewPassword: "NewSecurePass456!"}, ClientInfo{UserAgent: "Laptop/1.0"})
+	require.NoError(t, err)
+	assert.Equal(t, "Your password was changed", sent.last(t).Subject)
+
+	// Other sessions are signed out while the caller gets a fresh one
+	_, err = service.RefreshToken(ctx, other.RefreshToken, ClientInfo{})
+	assert.Equal(t, ErrRefreshTokenRevoked, err)
+	_, err = service.RefreshToken(ctx, authResp.RefreshToken, ClientInfo{})
+	assert.NoError(t, err)
+
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "NewSecurePass456!"}, ClientInfo{})
+	assert.NoError(t, err)
+}
+
+func TestService_ChangeEmail(t *testing.T) {
+	service, sent := setupTestServiceWithConfig(Config{ConfirmEmailChangeURL: "https://shop.example.com/confirm-email"})
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	_, err := service.Register(ctx, RegisterRequest{Email: "taken@example.com", Password: "SecurePass123!", Name: "Other User"})
+	require.NoError(t, err)
+
+	_, err = service.RequestEmailChange(ctx, userID, ChangeEmailRequest{NewEmail: "new@example.com", CurrentPassword: "WrongPass123!"})
+	assert.Equal(t, ErrIncorrectPassword, err)
+	_, err = service.RequestEmailChange(ctx, userID, ChangeEmailRequest{NewEmail: "taken@example.com", CurrentPassword: "SecurePass123!"})
+	assert.Equal(t, ErrEmailAlreadyExists, err)
+
+	user, err := service.RequestEmailChange(ctx, userID, ChangeEmailRequest{NewEmail: "new@example.com", CurrentPassword: "SecurePass123!"})
+	require.NoError(t, err)
+	assert.Equal(t, "test@example.com", user.Email)
+	assert.Equal(t, "new@example.com", user.PendingEmail)
+	msg := sent.last(t)
+	assert.Equal(t, "new@example.com", msg.To)
+	token := verificationToken(t, msg)
+
+	// The old address keeps working until the new one is confirmed
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+
+	_, err = service.ConfirmEmailChange(ctx, "not-a-token")
+	assert.Equal(t, ErrInvalidVerificationToken, err)
+
+	user, err = service.ConfirmEmailChange(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", user.Email)
+	assert.Empty(t, user.PendingEmail)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, "test@example.com", sent.last(t).To)
+
+	_, err = service.ConfirmEmailChange(ctx, token)
+	assert.Equal(t, ErrInvalidVerificationToken, err)
+
+	// Only the new address logs in, and the old one is free to register again
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = service.Login(ctx, LoginRequest{Email: "new@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.NoError(t, err)
+	_, err = service.Register(ctx, RegisterRequest{Email: "test@example.com", Password: "SecurePass123!", Name: "Test User"})
+	assert.NoError(t, err)
+}
+
+func TestService_ChangeEmail_AddressTakenBeforeConfirmation(t *testing.T) {
+	service, sent := setupTestServiceWithConfig(Config{ConfirmEmailChangeURL: "https://shop.example.com/confirm-email"})
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+
+	_, err := service.RequestEmailChange(ctx, userID, ChangeEmailRequest{NewEmail: "new@example.com", CurrentPassword: "SecurePass123!"})
+	require.NoError(t, err)
+	token := verificationToken(t, sent.last(t))
+
+	_, err = service.Register(ctx, RegisterRequest{Email: "new@example.com", Password: "SecurePass123!", Name: "Quick User"})
+	require.NoError(t, err)
+
+	_, err = service.ConfirmEmailChange(ctx, token)
+	assert.Equal(t, ErrEmailAlreadyExists, err)
+	user, err := service.GetProfile(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "test@example.com", user.Email)
+}
+
+func TestService_MFA(t *testing.T) {
+	service, sent := setupTestServiceWithConfig(Config{MFAIssuer: "Angidi Test"})
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	login := LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}
+
+	_, err := service.EnrollTOTP(ctx, userID, EnrollTOTPRequest{CurrentPassword: "WrongPass123!"})
+	assert.Equal(t, ErrIncorrectPassword, err)
+	_, err = service.ConfirmTOTP(ctx, userID, "123456")
+	assert.Equal(t, ErrMFANotEnrolled, err)
+
+	enrollment, err := service.EnrollTOTP(ctx, userID, EnrollTOTPRequest{CurrentPassword: "SecurePass123!"})
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/Angidi%20Test:test@example.com?")
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	_, err = service.ConfirmTOTP(ctx, userID, "000000")
+	assert.Equal(t, ErrInvalidMFACode, err)
+	now := time.Now()
+	code, err := totp.Code(enrollment.Secret, now)
+	require.NoError(t, err)
+	recovery, err := service.ConfirmTOTP(ctx, userID, code)
+	require.NoError(t, err)
+	assert.Len(t, recovery.Codes, 10)
+	assert.Equal(t, "Two-factor authentication turned on", sent.last(t).Subject)
+
+	_, err = service.EnrollTOTP(ctx, userID, EnrollTOTPRequest{CurrentPassword: "SecurePass123!"})
+	assert.Equal(t, ErrMFAAlreadyEnabled, err)
+
+	// The password step now only yields a challenge
+	challenge, err := service.Login(ctx, login, ClientInfo{})
+	require.NoError(t, err)
+	assert.True(t, challenge.MFARequired)
+	assert.NotEmpty(t, challenge.MFAToken)
+	assert.Empty(t, challenge.AccessToken)
+	assert.Empty(t, challenge.RefreshToken)
+
+	// A code is accepted once, so the one used for enrollment cannot log in
+	_, err = service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: code}, ClientInfo{})
+	assert.Equal(t, ErrInvalidMFACode, err)
+	next, err := totp.Code(enrollment.Secret, now.Add(totp.Period))
+	require.NoError(t, err)
+	authResp, err := service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: next}, ClientInfo{})
+	require.NoError(t, err)
+	claims, err := jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour).ValidateToken(authResp.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{jwtPkg.AuthMethodPassword, jwtPkg.AuthMethodOTP}, claims.AMR)
+
+	// Refreshed tokens keep the authentication methods of the session
+	refreshed, err := service.RefreshToken(ctx, authResp.RefreshToken, ClientInfo{})
+	require.NoError(t, err)
+	claims, err = jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour).ValidateToken(refreshed.AccessToken)
+	require.NoError(t, err)
+	assert.Contains(t, claims.AMR, jwtPkg.AuthMethodOTP)
+
+	// Challenges are single use
+	_, err = service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: recovery.Codes[0]}, ClientInfo{})
+	assert.Equal(t, ErrInvalidMFAChallenge, err)
+
+	// Recovery codes work once, ignoring case and dashes
+	challenge, err = service.Login(ctx, login, ClientInfo{})
+	require.NoError(t, err)
+	_, err = service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: strings.ToUpper(strings.ReplaceAll(recovery.Codes[0], "-", ""))}, ClientInfo{})
+	require.NoError(t, err)
+	challenge, err = service.Login(ctx, login, ClientInfo{})
+	require.NoError(t, err)
+	_, err = service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: recovery.Codes[0]}, ClientInfo{})
+	assert.Equal(t, ErrInvalidMFACode, err)
+
+	// Too many wrong codes lock the second factor, and logging in again does not help
+	for i := 1; i < maxMFAFailures-1; i++ {
+		_, err = service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: "000000"}, ClientInfo{})
+		assert.Equal(t, ErrInvalidMFACode, err)
+	}
+	challenge, err = service.Login(ctx, login, ClientInfo{})
+	require.NoError(t, err)
+	_, err = service.RegenerateRecoveryCodes(ctx, userID, "000000")
+	assert.Equal(t, ErrInvalidMFACode, err)
+	assert.Equal(t, "Your account was locked", sent.last(t).Subject)
+	_, err = service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: recovery.Codes[1]}, ClientInfo{})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	_, err = service.RegenerateRecoveryCodes(ctx, userID, recovery.Codes[1])
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	require.NoError(t, service.UnlockUser(ctx, userID, "admin"))
+
+	regenerated, err := service.RegenerateRecoveryCodes(ctx, userID, recovery.Codes[1])
+	require.NoError(t, err)
+	assert.NotEqual(t, recovery.Codes, regenerated.Codes)
+
+	assert.Equal(t, ErrInvalidMFACode, service.DisableMFA(ctx, userID, DisableMFARequest{CurrentPassword: "SecurePass123!", Code: recovery.Codes[2]}))
+	require.NoError(t, service.DisableMFA(ctx, userID, DisableMFARequest{CurrentPassword: "SecurePass123!", Code: regenerated.Codes[0]}))
+	authResp, err = service.Login(ctx, login, ClientInfo{})
+	require.NoError(t, err)
+	assert.False(t, authResp.MFARequired)
+	assert.NotEmpty(t, authResp.AccessToken)
+}
+
+func TestService_MFAConcurrentGuesses(t *testing.T) {
+	service, _ := setupTestServiceWithConfig(Config{})
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	enrollment, err := service.EnrollTOTP(ctx, userID, EnrollTOTPRequest{CurrentPassword: "SecurePass123!"})
+	require.NoError(t, err)
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	_, err = service.ConfirmTOTP(ctx, userID, code)
+	require.NoError(t, err)
+	challenge, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+
+	// Guesses sent at once are counted before they are checked, so only the allowed
+	// number of them is checked
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	checked := 0
+	for i := 0; i < 4*maxMFAFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: "000000"}, ClientInfo{})
+			if err == ErrInvalidMFACode {
+				mutex.Lock()
+				checked++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, maxMFAFailures, checked)
+}
+
+func TestService_MFAConcurrentReplay(t *testing.T) {
+	service, _ := setupTestServiceWithConfig(Config{})
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	enrollment, err := service.EnrollTOTP(ctx, userID, EnrollTOTPRequest{CurrentPassword: "SecurePass123!"})
+	require.NoError(t, err)
+	now := time.Now()
+	code, err := totp.Code(enrollment.Secret, now)
+	require.NoError(t, err)
+	recovery, err := service.ConfirmTOTP(ctx, userID, code)
+	require.NoError(t, err)
+	next, err := totp.Code(enrollment.Secret, now.Add(totp.Period))
+	require.NoError(t, err)
+
+	// A code sent several times at once, e.g. by someone who observed it, is accepted once
+	for _, code := range []string{next, recovery.Codes[0]} {
+		challenge, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		accepted := 0
+		for i := 0; i < maxMFAFailures-1; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: code}, ClientInfo{}); err == nil {
+					mutex.Lock()
+					accepted++
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, accepted, code)
+		require.NoError(t, service.UnlockUser(ctx, userID, "admin"))
+	}
+}
+
+func TestService_MFARequired(t *testing.T) {
+	optional, _ := setupTestServiceWithConfig(Config{})
+	assert.False(t, optional.MFARequired([]string{"admin"}))
+
+	required, _ := setupTestServiceWithConfig(Config{RequireMFAForAdmins: true})
+	assert.True(t, required.MFARequired([]string{"admin"}))
+	assert.True(t, required.MFARequired([]string{"user", "admin"}))
+	assert.False(t, required.MFARequired([]string{"user", "catalog_editor"}))
+}
+
+func TestService_LoginLockout(t *testing.T) {
+	service, sent := setupTestServiceWithConfig(Config{LockoutThreshold: 3, LoginBackoffAfter: 3, LockoutDuration: time.Hour})
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	wrong := LoginRequest{Email: "test@example.com", Password: "WrongPass123!"}
+
+	for i := 0; i < 3; i++ {
+		_, err := service.Login(ctx, wrong, ClientInfo{})
+		assert.Equal(t, ErrInvalidCredentials, err)
+	}
+	assert.Equal(t, "Your account was locked", sent.last(t).Subject)
+
+	// The right password does not help while the account is locked
+	_, err := service.Login(ctx, LoginRequest{Email: "TEST@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	var throttled *LoginThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.True(t, throttled.Locked)
+	assert.InDelta(t, time.Hour.Seconds(), throttled.RetryAfter.Seconds(), 5)
+
+	// Unknown emails lock the same way
+	for i := 0; i < 3; i++ {
+		_, err := service.Login(ctx, LoginRequest{Email: "nobody@example.com", Password: "WrongPass123!"}, ClientInfo{})
+		assert.Equal(t, ErrInvalidCredentials, err)
+	}
+	_, err = service.Login(ctx, LoginRequest{Email: "nobody@example.com", Password: "WrongPass123!"}, ClientInfo{})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+
+	assert.Equal(t, ErrUserNotFound, service.UnlockUser(ctx, "missing", "admin"))
+	require.NoError(t, service.UnlockUser(ctx, userID, "admin"))
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.NoError(t, err)
+}
+
+func TestService_AccountChangeLockout(t *testing.T) {
+	service, _ := setupTestServiceWithConfig(Config{LockoutThreshold: 3, LoginBackoffAfter: 3, LockoutDuration: time.Hour})
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+
+	// Confirming account changes counts towards the login lockout
+	for i := 0; i < 3; i++ {
+		_, err := service.ChangePassword(ctx, userID, ChangePasswordRequest{CurrentPassword: "WrongPass123!", NewPassword: "NewSecurePass456!"}, ClientInfo{})
+		assert.Equal(t, ErrIncorrectPassword, err)
+	}
+	_, err := service.EnrollTOTP(ctx, userID, EnrollTOTPRequest{CurrentPassword: "SecurePass123!"})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+}
+
+func TestService_LoginBackoff(t *testing.T) {
+	service, _ := setupTestServiceWithConfig(Config{LoginBackoffAfter: 1, LoginBackoffBase: time.Hour, LockoutDuration: 2 * time.Hour})
+	ctx := context.Background()
+	loginTestUser(t, service)
+	right := LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}
+	wrong := LoginRequest{Email: "test@example.com", Password: "WrongPass123!"}
+
+	// A successful login forgets earlier failures
+	_, err := service.Login(ctx, wrong, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = service.Login(ctx, right, ClientInfo{})
+	require.NoError(t, err)
+	_, err = service.Login(ctx, wrong, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = service.Login(ctx, wrong, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = service.Login(ctx, right, ClientInfo{})
+	assert.ErrorIs(t, err, ErrTooManyLoginAttempts)
+	var throttled *LoginThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.False(t, throttled.Locked)
+	assert.InDelta(t, time.Hour.Seconds(), throttled.RetryAfter.Seconds(), 5)
+}
+
+func TestService_LoginBackoffDelays(t *testing.T) {
+	svc, _ := setupTestServiceWithConfig(Config{LoginBackoffBase: time.Second, LockoutDuration: 5 * time.Second})
+	backoff := svc.(*service).backoff
+
+	// Waits double with every failure beyond those allowed, up to the lockout duration
+	assert.Equal(t, time.Duration(0), backoff(0))
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 4*time.Second, backoff(3))
+	assert.Equal(t, 5*time.Second, backoff(10))
+}
+
+func TestService_LoginIPThrottle(t *testing.T) {
+	service, _ := setupTestServiceWithConfig(Config{IPFailureThreshold: 2, LoginBackoffBase: time.Hour, LockoutDuration: 2 * time.Hour})
+	ctx := context.Background()
+	loginTestUser(t, service)
+	attacker := ClientInfo{IPAddress: "203.0.113.9"}
+
+	// Spreading guesses over accounts does not avoid the per-address limit
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		_, err := service.Login(ctx, LoginRequest{Email: email, Password: "WrongPass123!"}, attacker)
+		assert.Equal(t, ErrInvalidCredentials, err)
+	}
+
+	right := LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}
+	_, err := service.Login(ctx, right, attacker)
+	assert.ErrorIs(t, err, ErrTooManyLoginAttempts)
+	_, err = service.Login(ctx, right, ClientInfo{IPAddress: "198.51.100.7"})
+	assert.NoError(t, err)
+}
+
+// breachedList is a password.RangeSource listing only "Breached-Pass1"
+type breachedList struct{}
+
+func (breachedList) Range(ctx context.Context, prefix string) ([]string, error) {
+	if prefix == "22B51" {
+		return []string{"13AE21B56D145BE34527964433888507087"}, nil
+	}
+	return nil, nil
+}
+
+func TestService_PasswordPolicy(t *testing.T) {
+	service, sent := setupTestServiceWithConfig(Config{
+		ResetPasswordURL: "https://shop.example.com/reset",
+		PasswordPolicy: password.Policy{
+			RequireDigit:         true,
+			DisallowPersonalInfo: true,
+			Breached:             breachedList{},
+		},
+	})
+	ctx := context.Background()
+	violations := func(err error) []string {
+		t.Helper()
+		var policyErr *password.PolicyError
+		require.ErrorAs(t, err, &policyErr)
+		return policyErr.Violations
+	}
+
+	// Lengths fall back to the defaults when the configuration leaves them out
+	_, err := service.Register(ctx, RegisterRequest{Email: "jane@example.com", Password: "short", Name: "Jane Doe"})
+	assert.Equal(t, []string{password.ViolationMin, password.ViolationDigit}, violations(err))
+	_, err = service.Register(ctx, RegisterRequest{Email: "jane@example.com", Password: "JaneDoe-2024", Name: "Jane Doe"})
+	assert.Equal(t, []string{password.ViolationPersonalInfo}, violations(err))
+	_, err = service.Register(ctx, RegisterRequest{Email: "jane@example.com", Password: "Breached-Pass1", Name: "Jane Doe"})
+	assert.Equal(t, []string{password.ViolationBreached}, violations(err))
+
+	userID := loginTestUser(t, service).User.ID
+	_, err = service.ChangePassword(ctx, userID, ChangePasswordRequest{CurrentPassword: "SecurePass123!", NewPassword: "Breached-Pass1"}, ClientInfo{})
+	assert.Equal(t, []string{password.ViolationBreached}, violations(err))
+
+	// A rejected password leaves the reset link usable
+	require.NoError(t, service.ForgotPassword(ctx, "test@example.com"))
+	waitForEmails(service)
+	token := verificationToken(t, sent.last(t))
+	err = service.ResetPassword(ctx, ResetPasswordRequest{Token: token, Password: "no-digits-here"})
+	assert.Equal(t, []string{password.ViolationDigit}, violations(err))
+	require.NoError(t, service.ResetPassword(ctx, ResetPasswordRequest{Token: token, Password: "NewSecurePass456!"}))
+}
+
+func TestService_PasswordRehashOnLogin(t *testing.T) {
+	repo := NewInMemoryRepository()
+	jwtService := jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour)
+	logger, _ := zap.NewDevelopment()
+	newService := func(hashers *password.Hashers) Service {
+		return NewService(repo, NewInMemorySessionRepository(), NewInMemoryPasswordResetRepository(), NewInMemoryLoginAttemptRepository(), NewInMemoryAPIKeyRepository(), NewInMemoryOIDCLoginRepository(), NewInMemoryAddressRepository(), jwtService, &captureMailer{}, Config{PasswordHashers: hashers}, logger)
+	}
+	ctx := context.Background()
+	bcryptHasher := password.BcryptHasher{Cost: 4}
+	argon2idHasher := password.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	registered, err := newService(password.NewHashers(bcryptHasher)).Register(ctx, RegisterRequest{Email: "test@example.com", Password: "SecurePass123!", Name: "Test User"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(registered.PasswordHash, "$2a$04$"))
+
+	// After switching algorithms, a wrong password leaves the old hash alone
+	service := newService(password.NewHashers(argon2idHasher, bcryptHasher))
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "WrongPass123!"}, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+	stored, err := repo.FindByID(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.Equal(t, registered.PasswordHash, stored.PasswordHash)
+
+	// The right one replaces it with a hash from the preferred algorithm
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+	stored, err = repo.FindByID(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored.PasswordHash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.NoError(t, err)
+
+	// Rehashing only writes the hash, and only over the one it replaces, so an admin
+	// disabling the user meanwhile is kept and a password changed meanwhile wins
+	stored.Disabled = true
+	require.NoError(t, repo.Update(ctx, stored))
+	assert.Equal(t, ErrPasswordChanged, repo.UpdatePasswordHash(ctx, registered.ID, registered.PasswordHash, "rehashed"))
+	require.NoError(t, repo.UpdatePasswordHash(ctx, registered.ID, stored.PasswordHash, "rehashed"))
+	stored, err = repo.FindByID(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Disabled)
+	assert.Equal(t, "rehashed", stored.PasswordHash)
+}
+
+func TestService_Roles(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@example.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	service := setupTestService()
+	ctx := context.Background()
+	require.NoError(t, service.BootstrapAdmin(ctx))
+	admin, err := service.Login(ctx, LoginRequest{Email: "admin@example.com", Password: "AdminSecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+	adminID := admin.User.ID
+	userID := loginTestUser(t, service).User.ID
+
+	roles, err := service.GetUserRoles(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user"}, roles.Roles)
+	assert.Empty(t, roles.Permissions)
+
+	roles, err = service.SetUserRoles(ctx, userID, adminID, []string{"user", "catalog_editor", "support", "user"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "catalog_editor", "support"}, roles.Roles)
+	assert.Equal(t, []string{"order:read", "payment:read", "product:write", "promotion:read", "promotion:write", "return:read", "user:read"}, roles.Permissions)
+
+	// New access tokens carry the roles and the permissions they grant
+	authResp, err := service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "user", authResp.User.Role)
+	claims, err := jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour).ValidateToken(authResp.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, roles.Roles, claims.Roles)
+	assert.Equal(t, roles.Permissions, claims.Permissions)
+
+	_, err = service.SetUserRoles(ctx, userID, adminID, []string{"superuser"})
+	assert.Equal(t, ErrUnknownRole, err)
+	_, err = service.SetUserRoles(ctx, "missing", adminID, []string{"user"})
+	assert.Equal(t, ErrUserNotFound, err)
+
+	// The only admin keeps the admin role until there is another one
+	_, err = service.SetUserRoles(ctx, adminID, adminID, []string{"user"})
+	assert.Equal(t, ErrLastAdmin, err)
+	roles, err = service.SetUserRoles(ctx, userID, adminID, []string{"admin"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, authz.All, roles.Permissions)
+	_, err = service.SetUserRoles(ctx, adminID, userID, []string{"user"})
+	require.NoError(t, err)
+	profile, err := service.GetProfile(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", profile.Role)
+	assert.True(t, profile.HasRole("admin"))
+}
+
+func TestService_GrantRevokeRole(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+
+	require.NoError(t, service.GrantRole(ctx, userID, "admin-id", RoleSeller))
+	require.NoError(t, service.GrantRole(ctx, userID, "admin-id", RoleSeller))
+	roles, err := service.GetUserRoles(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "seller"}, roles.Roles)
+	assert.Equal(t, []string{"product:sell"}, roles.Permissions)
+
+	require.NoError(t, service.RevokeRole(ctx, userID, "admin-id", RoleSeller))
+	require.NoError(t, service.RevokeRole(ctx, userID, "admin-id", RoleSeller))
+	roles, err = service.GetUserRoles(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user"}, roles.Roles)
+
+	assert.Equal(t, ErrUserNotFound, service.GrantRole(ctx, "missing", "admin-id", RoleSeller))
+}
+
+func TestService_ConfiguredRoles(t *testing.T) {
+	service, _ := setupTestServiceWithConfig(Config{Roles: map[string][]string{
+		"auditor": {"payment:read", "return:read"},
+		"support": {"user:read"},
+	}})
+
+	definitions := make(map[string][]string)
+	for _, role := range service.ListRoles(context.Background()) {
+		definitions[role.Name] = role.Permissions
+	}
+	assert.Equal(t, []string{"payment:read", "return:read"}, definitions["auditor"])
+	assert.Equal(t, []string{"user:read"}, definitions["support"])
+	assert.Equal(t, []string{"product:write", "promotion:read", "promotion:write"}, definitions["catalog_editor"])
+	assert.ElementsMatch(t, authz.All, definitions["admin"])
+	assert.Empty(t, definitions["user"])
+}
+
+func TestCheckRoles(t *testing.T) {
+	assert.NoError(t, CheckRoles(nil))
+	assert.NoError(t, CheckRoles(map[string][]string{"auditor": {"payment:read"}}))
+	assert.Error(t, CheckRoles(map[string][]string{"admin": {"payment:read"}}))
+	assert.Error(t, CheckRoles(map[string][]string{"": {"payment:read"}}))
+	assert.Error(t, CheckRoles(map[string][]string{"auditor": {"payment:reed"}}))
+}
+
+func TestService_AdminUserManagement(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@example.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	service, sent := setupTestServiceWithConfig(Config{ResetPasswordURL: "https://shop.example.com/reset"})
+	ctx := context.Background()
+	require.NoError(t, service.BootstrapAdmin(ctx))
+	first := loginTestUser(t, service)
+	userID := first.User.ID
+	_, err := service.Register(ctx, RegisterRequest{Email: "jane@example.com", Password: "SecurePass123!", Name: "Jane Smith"})
+	require.NoError(t, err)
+
+	list, err := service.ListUsers(ctx, UserFilters{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, list.TotalCount)
+	assert.Equal(t, "admin@example.com", list.Users[0].Email)
+	list, err = service.ListUsers(ctx, UserFilters{Search: "SMITH"})
+	require.NoError(t, err)
+	require.Len(t, list.Users, 1)
+	assert.Equal(t, "jane@example.com", list.Users[0].Email)
+	list, err = service.ListUsers(ctx, UserFilters{Role: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, list.TotalCount)
+	adminID := list.Users[0].ID
+	list, err = service.ListUsers(ctx, UserFilters{Page: 2, PageSize: 2})
+	require.NoError(t, err)
+	assert.Len(t, list.Users, 1)
+	assert.Equal(t, 2, list.TotalPages)
+
+	// Disabling signs the user out and keeps them out
+	disabled, err := service.DisableUser(ctx, userID, adminID)
+	require.NoError(t, err)
+	assert.True(t, disabled.Disabled)
+	assert.NotNil(t, disabled.DisabledAt)
+	assert.Equal(t, ErrAccountDisabled, service.EnsureActive(ctx, userID))
+	_, err = service.RefreshToken(ctx, first.RefreshToken, ClientInfo{})
+	assert.Error(t, err)
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.Equal(t, ErrAccountDisabled, err)
+	disabledOnly := true
+	list, err = service.ListUsers(ctx, UserFilters{Disabled: &disabledOnly})
+	require.NoError(t, err)
+	assert.Equal(t, 1, list.TotalCount)
+
+	_, err = service.EnableUser(ctx, userID, adminID)
+	require.NoError(t, err)
+	assert.NoError(t, service.EnsureActive(ctx, userID))
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.NoError(t, err)
+
+	// The only admin cannot be disabled or deleted
+	_, err = service.DisableUser(ctx, adminID, adminID)
+	assert.Equal(t, ErrLastAdmin, err)
+	assert.Equal(t, ErrLastAdmin, service.CheckErasure(ctx, adminID))
+	_, err = service.DisableUser(ctx, "missing", adminID)
+	assert.Equal(t, ErrUserNotFound, err)
+
+	// A forced reset locks the old password out until a new one is chosen
+	require.NoError(t, service.ForcePasswordReset(ctx, userID, adminID))
+	assert.Equal(t, "Choose a new password", sent.last(t).Subject)
+	assert.Equal(t, ErrPasswordResetRequired, service.EnsureActive(ctx, userID))
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.Equal(t, ErrPasswordResetRequired, err)
+	require.NoError(t, service.ResetPassword(ctx, ResetPasswordRequest{Token: verificationToken(t, sent.last(t)), Password: "NewSecurePass456!"}))
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "NewSecurePass456!"}, ClientInfo{})
+	assert.NoError(t, err)
+
+	require.NoError(t, service.ErasePersonalData(ctx, userID))
+	assert.Equal(t, ErrUserNotFound, service.EnsureActive(ctx, userID))
+	_, err = service.Login(ctx, LoginRequest{Email: "test@example.com", Password: "NewSecurePass456!"}, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestService_APIKeys(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	_, err := service.SetUserRoles(ctx, userID, "admin-id", []string{RoleCatalogEditor})
+	require.NoError(t, err)
+
+	// Scopes must be permissions the user has
+	_, err = service.CreateAPIKey(ctx, userID, CreateAPIKeyRequest{Name: "CI", Scopes: []string{"user:write"}})
+	assert.Equal(t, ErrInvalidScope, err)
+
+	created, err := service.CreateAPIKey(ctx, userID, CreateAPIKeyRequest{Name: "CI", Scopes: []string{"product:write"}, ExpiresInDays: 7})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix+"_"))
+	assert.NotContains(t, created.KeyHash, created.Key)
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), created.ExpiresAt, time.Minute)
+
+	claims, err := service.AuthenticateAPIKey(ctx, created.Key, "203.0.113.7")
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, created.ID, claims.APIKeyID)
+	assert.Equal(t, []string{"product:write"}, claims.Permissions)
+	assert.Equal(t, []string{"apikey"}, claims.AMR, "keys never count as a second factor")
+
+	keys, err := service.ListAPIKeys(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotNil(t, keys[0].LastUsedAt)
+	assert.Equal(t, "203.0.113.7", keys[0].LastUsedIP)
+
+	// Keys need a scope
+	_, err = service.CreateAPIKey(ctx, userID, CreateAPIKeyRequest{Name: "Scripts"})
+	assert.Equal(t, ErrInvalidScope, err)
+
+	_, err = service.AuthenticateAPIKey(ctx, created.Key+"x", "")
+	assert.Equal(t, ErrInvalidAPIKey, err)
+	_, err = service.AuthenticateAPIKey(ctx, "not-a-key", "")
+	assert.Equal(t, ErrInvalidAPIKey, err)
+
+	// Keys can only be revoked through their own user
+	assert.Equal(t, ErrAPIKeyNotFound, service.RevokeAPIKey(ctx, "other-user", created.ID, "other-user"))
+	require.NoError(t, service.RevokeAPIKey(ctx, userID, created.ID, userID))
+	_, err = service.AuthenticateAPIKey(ctx, created.Key, "")
+	assert.Equal(t, ErrInvalidAPIKey, err)
+	keys, err = service.ListAPIKeys(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestService_ScopedClaims(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	_, err := service.SetUserRoles(ctx, userID, "admin-id", []string{RoleCatalogEditor})
+	require.NoError(t, err)
+
+	// Scopes only narrow the user's permissions, and without any there are none
+	claims, err := service.ScopedClaims(ctx, userID, []string{"product:write", "user:write"})
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, []string{RoleCatalogEditor}, claims.Roles)
+	assert.Equal(t, []string{"product:write"}, claims.Permissions)
+	claims, err = service.ScopedClaims(ctx, userID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Permissions)
+
+	_, err = service.DisableUser(ctx, userID, "admin-id")
+	require.NoError(t, err)
+	_, err = service.ScopedClaims(ctx, userID, nil)
+	assert.Equal(t, ErrAccountDisabled, err)
+	_, err = service.ScopedClaims(ctx, "missing", nil)
+	assert.Equal(t, ErrUserNotFound, err)
+}
+
+func TestService_Impersonate(t *testing.T) {
+	service, _ := setupTestServiceWithConfig(Config{ImpersonationTTL: 5 * time.Minute})
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	reason := ImpersonateRequest{Reason: "Ticket 42"}
+
+	impersonation, err := service.Impersonate(ctx, userID, "admin-id", reason)
+	require.NoError(t, err)
+	assert.Equal(t, userID, impersonation.User.ID)
+	assert.Equal(t, 300, impersonation.ExpiresIn)
+
+	// The token acts as the user and names the admin as its actor
+	claims, err := jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour).ValidateToken(impersonation.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	require.NotNil(t, claims.Act)
+	assert.Equal(t, "admin-id", claims.Act.Subject)
+	assert.NotEmpty(t, claims.ID)
+	assert.Empty(t, claims.AMR)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+
+	// Nobody impersonates themselves or staff, whatever their permissions
+	_, err = service.Impersonate(ctx, userID, userID, reason)
+	assert.Equal(t, ErrCannotImpersonate, err)
+	admin, err := service.Register(ctx, RegisterRequest{Email: "admin@example.com", Password: "SecureAdminPass123!", Name: "Admin"})
+	require.NoError(t, err)
+	_, err = service.SetUserRoles(ctx, admin.ID, "admin-id", []string{RoleAdmin})
+	require.NoError(t, err)
+	_, err = service.Impersonate(ctx, admin.ID, "admin-id", reason)
+	assert.Equal(t, ErrCannotImpersonate, err)
+	staff, err := service.Register(ctx, RegisterRequest{Email: "staff@example.com", Password: "SecureStaffPass123!", Name: "Staff"})
+	require.NoError(t, err)
+	for _, role := range []string{RoleSupport, RoleCatalogEditor} {
+		_, err = service.SetUserRoles(ctx, staff.ID, "admin-id", []string{RoleUser, role})
+		require.NoError(t, err)
+		_, err = service.Impersonate(ctx, staff.ID, "admin-id", reason)
+		assert.Equal(t, ErrCannotImpersonate, err, role)
+	}
+
+	// Sellers are customers
+	_, err = service.SetUserRoles(ctx, staff.ID, "admin-id", []string{RoleUser, RoleSeller})
+	require.NoError(t, err)
+	_, err = service.Impersonate(ctx, staff.ID, "admin-id", reason)
+	assert.NoError(t, err)
+
+	_, err = service.DisableUser(ctx, userID, "admin-id")
+	require.NoError(t, err)
+	_, err = service.Impersonate(ctx, userID, "admin-id", reason)
+	assert.Equal(t, ErrAccountDisabled, err)
+	_, err = service.Impersonate(ctx, "missing", "admin-id", reason)
+	assert.Equal(t, ErrUserNotFound, err)
+}
+
+func TestService_PersonalData(t *testing.T) {
+	service, _ := setupTestServiceWithConfig(Config{})
+	ctx := context.Background()
+	auth := loginTestUser(t, service)
+	userID := auth.User.ID
+
+	data, err := service.ExportPersonalData(ctx, userID)
+	require.NoError(t, err)
+	personal := data.(*PersonalData)
+	assert.Equal(t, "test@example.com", personal.Profile.Email)
+	assert.Len(t, personal.Sessions, 1)
+
+	require.NoError(t, service.CheckErasure(ctx, userID))
+	require.NoError(t, service.ErasePersonalData(ctx, userID))
+	_, err = service.GetProfile(ctx, userID)
+	assert.Equal(t, ErrUserNotFound, err)
+	_, err = service.RefreshToken(ctx, auth.RefreshToken, ClientInfo{})
+	assert.Error(t, err, "sessions are erased with the account")
+
+	// Erasing again is a no-op, but the only admin is never erased
+	require.NoError(t, service.CheckErasure(ctx, userID))
+	require.NoError(t, service.ErasePersonalData(ctx, userID))
+	admin, err := service.Register(ctx, RegisterRequest{Email: "admin@example.com", Password: "SecureAdminPass123!", Name: "Admin"})
+	require.NoError(t, err)
+	_, err = service.SetUserRoles(ctx, admin.ID, "admin-id", []string{RoleAdmin})
+	require.NoError(t, err)
+	assert.Equal(t, ErrLastAdmin, service.CheckErasure(ctx, admin.ID))
+	assert.Equal(t, ErrLastAdmin, service.ErasePersonalData(ctx, admin.ID))
+}
+
+func TestService_Addresses(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	home := AddressRequest{Label: "Home", Name: "Test User", Line1: "1 Market St", City: "San Francisco", Region: "CA", PostalCode: "94105", Country: "us"}
+
+	first, err := service.CreateAddress(ctx, userID, home)
+	require.NoError(t, err)
+	assert.Equal(t, "US", first.Country)
+	assert.True(t, first.DefaultShipping, "the first address becomes the default")
+	assert.True(t, first.DefaultBilling)
+
+	// Making another address the shipping default takes it from the first
+	office := AddressRequest{Label: "Office", Name: "Test User", Line1: "10 Downing St", City: "London", PostalCode: "sw1a 2aa", Country: "GB", DefaultShipping: true}
+	second, err := service.CreateAddress(ctx, userID, office)
+	require.NoError(t, err)
+	assert.Equal(t, "SW1A 2AA", second.PostalCode)
+	assert.True(t, second.DefaultShipping)
+	assert.False(t, second.DefaultBilling)
+
+	addresses, err := service.ListAddresses(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, addresses, 2)
+	assert.False(t, addresses[0].DefaultShipping)
+	assert.True(t, addresses[0].DefaultBilling)
+
+	home.DefaultShipping = true
+	home.DefaultBilling = true
+	updated, err := service.UpdateAddress(ctx, userID, first.ID, home)
+	require.NoError(t, err)
+	assert.True(t, updated.DefaultShipping)
+	got, err := service.GetAddress(ctx, userID, second.ID)
+	require.NoError(t, err)
+	assert.False(t, got.DefaultShipping)
+
+	// Other users' addresses look unknown
+	_, err = service.GetAddress(ctx, "someone-else", first.ID)
+	assert.Equal(t, ErrAddressNotFound, err)
+	assert.Equal(t, ErrAddressNotFound, service.DeleteAddress(ctx, "someone-else", first.ID))
+
+	require.NoError(t, service.DeleteAddress(ctx, userID, second.ID))
+	_, err = service.GetAddress(ctx, userID, second.ID)
+	assert.Equal(t, ErrAddressNotFound, err)
+}
+
+func TestService_AddressFormats(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+
+	tests := []struct {
+		name           string
+		request        AddressRequest
+		wantViolations []AddressViolation
+	}{
+		{
+			name:    "country without a known format",
+			request: AddressRequest{Label: "Home", Name: "Test User", Line1: "1 Orchard Rd", City: "Singapore", PostalCode: "238801", Country: "SG"},
+		},
+		{
+			name:    "country without postal codes",
+			request: AddressRequest{Label: "Home", Name: "Test User", Line1: "1 Main St", City: "Dublin", Country: "IE"},
+		},
+		{
+			name:           "us address without state or zip code",
+			request:        AddressRequest{Label: "Home", Name: "Test User", Line1: "1 Market St", City: "San Francisco", Country: "US"},
+			wantViolations: []AddressViolation{{Field: "Region", Message: "required"}, {Field: "PostalCode", Message: "required"}},
+		},
+		{
+			name:           "malformed postal code",
+			request:        AddressRequest{Label: "Home", Name: "Test User", Line1: "1 Rue de Rivoli", City: "Paris", PostalCode: "7500", Country: "FR"},
+			wantViolations: []AddressViolation{{Field: "PostalCode", Message: "postal_code"}},
+		},
+		{
+			name:           "unknown country",
+			request:        AddressRequest{Label: "Home", Name: "Test User", Line1: "1 Main St", City: "Nowhere", Country: "XX"},
+			wantViolations: []AddressViolation{{Field: "Country", Message: "country"}},
+		},
+		{
+			name:           "blank required fields and bad phone",
+			request:        AddressRequest{Label: " ", Name: "Test User", Line1: "1 Main St", City: " ", PostalCode: "10115", Country: "DE", Phone: "call me"},
+			wantViolations: []AddressViolation{{Field: "Label", Message: "required"}, {Field: "City", Message: "required"}, {Field: "Phone", Message: "phone"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateAddress(ctx, userID, tt.request)
+			if tt.wantViolations == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var addressErr *AddressError
+			require.ErrorAs(t, err, &addressErr)
+			assert.ErrorIs(t, err, ErrInvalidAddress)
+			assert.Equal(t, tt.wantViolations, addressErr.Violations)
+		})
+	}
+}
+
+func TestService_AddressLimit(t *testing.T) {
+	service := setupTestService()
+	ctx := context.Background()
+	userID := loginTestUser(t, service).User.ID
+	req := AddressRequest{Label: "Home", Name: "Test User", Line1: "1 Main St", City: "Berlin", PostalCode: "10115", Country: "DE"}
+
+	for i := 0; i < maxAddresses; i++ {
+		_, err := service.CreateAddress(ctx, userID, req)
+		require.NoError(t, err)
+	}
+	_, err := service.CreateAddress(ctx, userID, req)
+	assert.Equal(t, ErrAddressLimitReached, err)
+}
+
+func TestService_OIDCLogin(t *testing.T) {
+	provider, err := oidctest.NewProvider("shop", "shop-secret")
+	require.NoError(t, err)
+	defer provider.Close()
+	client := oidc.NewClient(provider.Config("http://localhost:3000/auth/callback"), nil)
+	service, _ := setupTestServiceWithConfig(Config{OIDCProviders: []OIDCProvider{
+		{Name: "mock", Client: client},
+		{Name: "trusted", Client: client, TrustEmail: true},
+	}})
+	ctx := context.Background()
+	assert.Equal(t, []string{"mock", "trusted"}, service.OIDCProviders())
+
+	// start logs in at a provider, returning what the browser comes back with
+	type started struct {
+		req        OIDCCallbackRequest
+		browserKey string
+	}
+	start := func(name string) started {
+		authURL, browserKey, err := service.StartOIDCLogin(ctx, name)
+		require.NoError(t, err)
+		code, state, err := provider.Login(authURL)
+		require.NoError(t, err)
+		return started{req: OIDCCallbackRequest{Code: code, State: state}, browserKey: browserKey}
+	}
+	complete := func(name string, login started) (*AuthResponse, error) {
+		return service.CompleteOIDCLogin(ctx, name, login.req, login.browserKey, ClientInfo{})
+	}
+
+	// The first login creates a user linked to the provider account, later ones find it
+	req := start("mock")
+	first, err := complete("mock", req)
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.AccessToken)
+	assert.Equal(t, "oidc.user@example.com", first.User.Email)
+	assert.Equal(t, "OIDC User", first.User.Name)
+	assert.True(t, first.User.EmailVerified)
+	require.Len(t, first.User.Identities, 1)
+	assert.Equal(t, "oidctest-user", first.User.Identities[0].Subject)
+	again, err := complete("mock", start("mock"))
+	require.NoError(t, err)
+	assert.Equal(t, first.User.ID, again.User.ID)
+
+	// States work once, at the provider the login started with
+	_, err = complete("mock", req)
+	assert.Equal(t, ErrInvalidOIDCState, err)
+	_, err = complete("trusted", start("mock"))
+	assert.Equal(t, ErrInvalidOIDCState, err)
+	_, _, err = service.StartOIDCLogin(ctx, "unknown")
+	assert.Equal(t, ErrUnknownProvider, err)
+
+	// States only work in the browser the login started in
+	other := start("mock")
+	other.browserKey = start("mock").browserKey
+	_, err = complete("mock", other)
+	assert.Equal(t, ErrInvalidOIDCState, err)
+
+	// Users created by a provider have no password to log in with
+	_, err = service.Login(ctx, LoginRequest{Email: "oidc.user@example.com", Password: "SecurePass123!"}, ClientInfo{})
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	// Existing accounts are only linked by providers trusted with email addresses
+	existing := loginTestUser(t, service).User
+	provider.SetUser(oidctest.User{Subject: "other-user", Email: existing.Email, EmailVerified: true, Name: "Test User"})
+	_, err = complete("mock", start("mock"))
+	assert.Equal(t, ErrAccountExists, err)
+	linked, err := complete("trusted", start("trusted"))
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, linked.User.ID)
+	require.Len(t, linked.User.Identities, 1)
+	assert.Equal(t, "trusted", linked.User.Identities[0].Provider)
+	_, err = service.Login(ctx, LoginRequest{Email: existing.Email, Password: "SecurePass123!"}, ClientInfo{})
+	assert.NoError(t, err)
+
+	// Users with MFA still need their second factor, which adds to the provider login
+	enrollment, err := service.EnrollTOTP(ctx, existing.ID, EnrollTOTPRequest{CurrentPassword: "SecurePass123!"})
+	require.NoError(t, err)
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	recovery, err := service.ConfirmTOTP(ctx, existing.ID, code)
+	require.NoError(t, err)
+	provider.SetUser(oidctest.User{Subject: "other-user", Email: existing.Email, EmailVerified: true, Name: "Test User"})
+	challenge, err := complete("trusted", start("trusted"))
+	require.NoError(t, err)
+	require.True(t, challenge.MFARequired)
+	authResp, err := service.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: challenge.MFAToken, Code: recovery.Codes[0]}, ClientInfo{})
+	require.NoError(t, err)
+	claims, err := jwtPkg.NewService("test-secret", 15*time.Minute, 7*24*time.Hour).ValidateToken(authResp.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{jwtPkg.AuthMethodFederated, jwtPkg.AuthMethodOTP}, claims.AMR)
+
+	provider.SetUser(oidctest.User{Subject: "unverified", Email: existing.Email})
+	_, err = complete("trusted", start("trusted"))
+	assert.Equal(t, ErrAccountExists, err)
+}
@@ -0,0 +1,213 @@
+package user
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionRepository defines the interface for login session and refresh token data access.
+// A session's ID is the family ID shared by the refresh tokens rotated from its login.
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session, token *RefreshToken) error
+	FindSession(ctx context.Context, id string) (*Session, error)
+	ListSessions(ctx context.Context, userID string) ([]*Session, error)
+	FindRefreshToken(ctx context.Context, id string) (*RefreshToken, error)
+	Rotate(ctx context.Context, id string, next *RefreshToken, ipAddress string) error
+	RevokeSession(ctx context.Context, id string) error
+	RevokeUserSessions(ctx context.Context, userID string) error
+	// DeleteUserSessions deletes every session of a user with its refresh tokens, revoked
+	// or not
+	DeleteUserSessions(ctx context.Context, userID string) error
+}
+
+// InMemorySessionRepository implements SessionRepository using in-memory storage. It
+// stores and returns copies, so callers never see sessions change under them.
+type InMemorySessionRepository struct {
+	sessions map[string]*Session
+	tokens   map[string]*RefreshToken
+	mutex    sync.RWMutex
+}
+
+// NewInMemorySessionRepository creates a new in-memory session repository
+func NewInMemorySessionRepository() *InMemorySessionRepository {
+	return &InMemorySessionRepository{
+		sessions: make(map[string]*Session),
+		tokens:   make(map[string]*RefreshToken),
+	}
+}
+
+// Create stores a new session with its first refresh token
+func (r *InMemorySessionRepository) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.purgeExpired(time.Now())
+	storedSession := *session
+	storedToken := *token
+	r.sessions[session.ID] = &storedSession
+	r.tokens[token.ID] = &storedToken
+	return nil
+}
+
+// FindSession finds a session by ID
+func (r *InMemorySessionRepository) FindSession(ctx context.Context, id string) (*Session, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+
+	found := *session
+	return &found, nil
+}
+
+// ListSessions lists a user's active sessions, most recently used first
+func (r *InMemorySessionRepository) ListSessions(ctx context.Context, userID string) ([]*Session, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := time.Now()
+	sessions := make([]*Session, 0)
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && now.Before(session.ExpiresAt) {
+			found := *session
+			sessions = append(sessions, &found)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// FindRefreshToken finds a refresh token by its ID (jti)
+func (r *InMemorySessionRepository) FindRefreshToken(ctx context.Context, id string) (*RefreshToken, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	token, exists := r.tokens[id]
+	if !exists {
+		return nil, ErrRefreshTokenNotFound
+	}
+
+	found := *token
+	return &found, nil
+}
+
+// Rotate marks a refresh token as used and stores its successor in one step, so that
+// two concurrent refreshes with the same token cannot both succeed. The session is
+// marked as used from ipAddress.
+func (r *InMemorySessionRepository) Rotate(ctx context.Context, id string, next *RefreshToken, ipAddress string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, exists := r.tokens[id]
+	if !exists {
+		return ErrRefreshTokenNotFound
+	}
+	session, exists := r.sessions[token.FamilyID]
+	if !exists {
+		return ErrRefreshTokenNotFound
+	}
+	if token.RevokedAt != nil || session.RevokedAt != nil {
+		return ErrRefreshTokenRevoked
+	}
+	if token.RotatedAt != nil {
+		return ErrRefreshTokenReused
+	}
+
+	now := time.Now()
+	token.RotatedAt = &now
+	stored := *next
+	r.tokens[next.ID] = &stored
+
+	session.LastUsedAt = now
+	session.ExpiresAt = next.ExpiresAt
+	if ipAddress != "" {
+		session.IPAddress = ipAddress
+	}
+	return nil
+}
+
+// RevokeSession revokes a session and every refresh token rotated from its login
+func (r *InMemorySessionRepository) RevokeSession(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return ErrSessionNotFound
+	}
+
+	r.revoke(time.Now(), func(s *Session) bool { return s == session })
+	return nil
+}
+
+// RevokeUserSessions revokes every session of a user
+func (r *InMemorySessionRepository) RevokeUserSessions(ctx context.Context, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.revoke(time.Now(), func(s *Session) bool { return s.UserID == userID })
+	return nil
+}
+
+// DeleteUserSessions deletes every session of a user along with its refresh tokens
+func (r *InMemorySessionRepository) DeleteUserSessions(ctx context.Context, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	deleted := make(map[string]bool)
+	for id, session := range r.sessions {
+		if session.UserID == userID {
+			deleted[id] = true
+			delete(r.sessions, id)
+		}
+	}
+	for id, token := range r.tokens {
+		if deleted[token.FamilyID] {
+			delete(r.tokens, id)
+		}
+	}
+	return nil
+}
+
+// revoke marks the sessions matching match and their refresh tokens as revoked.
+// Must be called with the mutex held.
+func (r *InMemorySessionRepository) revoke(now time.Time, match func(session *Session) bool) {
+	revoked := make(map[string]bool)
+	for id, session := range r.sessions {
+		if match(session) {
+			revoked[id] = true
+			if session.RevokedAt == nil {
+				session.RevokedAt = &now
+			}
+		}
+	}
+
+	for _, token := range r.tokens {
+		if revoked[token.FamilyID] && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+}
+
+// purgeExpired drops sessions and tokens that can no longer be used. Must be called with
+// the mutex held.
+func (r *InMemorySessionRepository) purgeExpired(now time.Time) {
+	for id, token := range r.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(r.tokens, id)
+		}
+	}
+	for id, session := range r.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(r.sessions, id)
+		}
+	}
+}
@@ -4,17 +4,31 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// oidcProviderName matches the names OIDC providers may have, as they appear in URLs
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
 // Config holds all configuration for the application
 type Config struct {
-	Server  ServerConfig  `yaml:"server"`
-	Logging LoggingConfig `yaml:"logging"`
-	CORS    CORSConfig    `yaml:"cors"`
+	Server   ServerConfig   `yaml:"server"`
+	Logging  LoggingConfig  `yaml:"logging"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Payment  PaymentConfig  `yaml:"payment"`
+	Tax      TaxConfig      `yaml:"tax"`
+	Shipping ShippingConfig `yaml:"shipping"`
+	Returns  ReturnsConfig  `yaml:"returns"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Mailer   MailerConfig   `yaml:"mailer"`
+	Users    UsersConfig    `yaml:"users"`
+	OIDC     OIDCConfig     `yaml:"oidc"`
+	Privacy  PrivacyConfig  `yaml:"privacy"`
 }
 
 // ServerConfig holds server-specific configuration
@@ -40,6 +54,192 @@ type CORSConfig struct {
 	AllowedHeaders []string `yaml:"allowed_headers"`
 }
 
+// PaymentConfig holds payment provider configuration
+type PaymentConfig struct {
+	Provider      string        `yaml:"provider"`
+	WebhookSecret string        `yaml:"webhook_secret"`
+	WebhookDelay  time.Duration `yaml:"webhook_delay"`
+}
+
+// TaxConfig holds tax calculation configuration
+type TaxConfig struct {
+	PricesIncludeTax bool              `yaml:"prices_include_tax"`
+	Rounding         string            `yaml:"rounding"`
+	DefaultClass     string            `yaml:"default_class"`
+	Regions          []TaxRegionConfig `yaml:"regions"`
+}
+
+// TaxRegionConfig holds the tax rates of a country, one of its regions, or a set of
+// postal code prefixes. Rates are percentages keyed by product tax class.
+type TaxRegionConfig struct {
+	Name        string             `yaml:"name"`
+	Country     string             `yaml:"country"`
+	Region      string             `yaml:"region"`
+	PostalCodes []string           `yaml:"postal_codes"`
+	Rates       map[string]float64 `yaml:"rates"`
+}
+
+// ShippingConfig holds shipping zones and methods
+type ShippingConfig struct {
+	VolumetricDivisor float64                `yaml:"volumetric_divisor"`
+	Zones             []ShippingZoneConfig   `yaml:"zones"`
+	Methods           []ShippingMethodConfig `yaml:"methods"`
+}
+
+// ShippingZoneConfig holds a set of countries, optionally narrowed to postal code prefixes
+type ShippingZoneConfig struct {
+	ID          string   `yaml:"id"`
+	Name        string   `yaml:"name"`
+	Countries   []string `yaml:"countries"`
+	PostalCodes []string `yaml:"postal_codes"`
+}
+
+// ShippingMethodConfig holds a shipping method and its pricing
+type ShippingMethodConfig struct {
+	ID        string               `yaml:"id"`
+	Name      string               `yaml:"name"`
+	Type      string               `yaml:"type"`
+	Zones     []string             `yaml:"zones"`
+	Rate      float64              `yaml:"rate"`
+	Threshold float64              `yaml:"threshold"`
+	Tiers     []ShippingTierConfig `yaml:"tiers"`
+}
+
+// ShippingTierConfig holds the rate of a weight band in kilograms
+type ShippingTierConfig struct {
+	MaxWeight float64 `yaml:"max_weight"`
+	Rate      float64 `yaml:"rate"`
+}
+
+// ReturnsConfig holds return policy configuration
+type ReturnsConfig struct {
+	Window time.Duration `yaml:"window"`
+}
+
+// PrivacyConfig holds personal data erasure configuration. Accounts are erased
+// DeletionGracePeriod after their owner asks, by a worker running every DeletionInterval.
+type PrivacyConfig struct {
+	DeletionGracePeriod time.Duration `yaml:"deletion_grace_period"`
+	DeletionInterval    time.Duration `yaml:"deletion_interval"`
+}
+
+// JWTConfig holds token signing configuration. HS256 signs with the JWT_SECRET shared
+// secret; the asymmetric algorithms sign with the key in PrivateKeyFile, or a generated
+// key when it is empty.
+type JWTConfig struct {
+	Algorithm        string        `yaml:"algorithm"`
+	PrivateKeyFile   string        `yaml:"private_key_file"`
+	RotationInterval time.Duration `yaml:"rotation_interval"`
+}
+
+// MailerConfig holds outgoing email configuration. The outbox driver writes messages to
+// files in OutboxDir instead of sending them; the smtp driver sends them through SMTP.
+type MailerConfig struct {
+	Driver    string     `yaml:"driver"`
+	From      string     `yaml:"from"`
+	OutboxDir string     `yaml:"outbox_dir"`
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig holds SMTP relay configuration
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// UsersConfig holds account policy configuration
+type UsersConfig struct {
+	// EmailVerification is "none", "login" (unverified users cannot log in) or "actions"
+	// (unverified users cannot pay or request returns)
+	EmailVerification     string        `yaml:"email_verification"`
+	VerificationTokenTTL  time.Duration `yaml:"verification_token_ttl"`
+	VerifyEmailURL        string        `yaml:"verify_email_url"`
+	PasswordResetTokenTTL time.Duration `yaml:"password_reset_token_ttl"`
+	ResetPasswordURL      string        `yaml:"reset_password_url"`
+	ConfirmEmailChangeURL string        `yaml:"confirm_email_change_url"`
+	// MFAIssuer names the service in authenticator apps
+	MFAIssuer           string                `yaml:"mfa_issuer"`
+	RequireMFAForAdmins bool                  `yaml:"require_mfa_for_admins"`
+	Lockout             LockoutConfig         `yaml:"lockout"`
+	PasswordPolicy      PasswordPolicyConfig  `yaml:"password_policy"`
+	PasswordHashing     PasswordHashingConfig `yaml:"password_hashing"`
+	// Roles maps role names to the permissions they grant, adding to or replacing the
+	// built-in roles other than admin
+	Roles map[string][]string `yaml:"roles"`
+	// ImpersonationTTL is how long the tokens admins get to act as a user stay valid
+	ImpersonationTTL time.Duration `yaml:"impersonation_ttl"`
+}
+
+// OIDCConfig holds the external OpenID Connect providers users can log in with
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig `yaml:"providers"`
+}
+
+// OIDCProviderConfig holds the registration of the application with an OpenID provider
+type OIDCProviderConfig struct {
+	// Name identifies the provider in URLs, e.g. "google"
+	Name string `yaml:"name"`
+	// IssuerURL is the provider's issuer, which serves its discovery document
+	IssuerURL string `yaml:"issuer_url"`
+	ClientID  string `yaml:"client_id"`
+	// ClientSecret is better set with the OIDC_<NAME>_CLIENT_SECRET environment variable
+	ClientSecret string `yaml:"client_secret"`
+	// RedirectURL is the frontend page the provider sends users back to, which posts the
+	// code and state it receives to the callback endpoint
+	RedirectURL string `yaml:"redirect_url"`
+	// Scopes are requested in addition to openid; email and profile when empty
+	Scopes []string `yaml:"scopes"`
+	// TrustEmail links logins to existing accounts with the same verified email address
+	TrustEmail bool `yaml:"trust_email"`
+}
+
+// PasswordHashingConfig selects how new passwords are hashed. Existing hashes made with
+// the other algorithm or other parameters are upgraded when their user logs in.
+type PasswordHashingConfig struct {
+	// Algorithm is "bcrypt" or "argon2id"
+	Algorithm  string         `yaml:"algorithm"`
+	BcryptCost int            `yaml:"bcrypt_cost"`
+	Argon2id   Argon2idConfig `yaml:"argon2id"`
+}
+
+// Argon2idConfig holds the Argon2id cost parameters
+type Argon2idConfig struct {
+	MemoryKiB   uint32 `yaml:"memory_kib"`
+	Iterations  uint32 `yaml:"iterations"`
+	Parallelism uint8  `yaml:"parallelism"`
+}
+
+// PasswordPolicyConfig holds the rules new passwords must follow
+type PasswordPolicyConfig struct {
+	MinLength int `yaml:"min_length"`
+	MaxLength int `yaml:"max_length"`
+	// MinAdminLength applies to admin accounts when it is longer than MinLength
+	MinAdminLength   int  `yaml:"min_admin_length"`
+	RequireLowercase bool `yaml:"require_lowercase"`
+	RequireUppercase bool `yaml:"require_uppercase"`
+	RequireDigit     bool `yaml:"require_digit"`
+	RequireSymbol    bool `yaml:"require_symbol"`
+	// DisallowPersonalInfo rejects passwords containing the user's email or name
+	DisallowPersonalInfo bool `yaml:"disallow_personal_info"`
+	// BreachedListFile lists SHA-1 hashes of breached passwords to reject, one per line
+	BreachedListFile string `yaml:"breached_list_file"`
+}
+
+// LockoutConfig holds the brute-force protection settings for logins
+type LockoutConfig struct {
+	// Threshold is how many failed logins in a row lock an account for Duration
+	Threshold int           `yaml:"threshold"`
+	Duration  time.Duration `yaml:"duration"`
+	// BackoffAfter is how many failed logins an account may have before every further
+	// attempt must wait, starting at BackoffBase and doubling with each failure
+	BackoffAfter int           `yaml:"backoff_after"`
+	BackoffBase  time.Duration `yaml:"backoff_base"`
+	// IPThreshold is how many failed logins a client address may have before it backs off
+	IPThreshold int `yaml:"ip_threshold"`
+}
+
 // Load loads configuration from file
 func Load() (*Config, error) {
 	// Default configuration
@@ -107,6 +307,65 @@ func newDefaultConfig() *Config {
 			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
 			AllowedHeaders: []string{"Content-Type", "Authorization"},
 		},
+		Payment: PaymentConfig{
+			Provider:      "mock",
+			WebhookSecret: "mock-webhook-secret-change-in-production",
+			WebhookDelay:  2 * time.Second,
+		},
+		Tax: TaxConfig{
+			Rounding:     "line",
+			DefaultClass: "standard",
+		},
+		Shipping: ShippingConfig{
+			VolumetricDivisor: 5000,
+		},
+		Returns: ReturnsConfig{
+			Window: 30 * 24 * time.Hour,
+		},
+		Privacy: PrivacyConfig{
+			DeletionGracePeriod: 30 * 24 * time.Hour,
+			DeletionInterval:    time.Hour,
+		},
+		JWT: JWTConfig{
+			Algorithm: "RS256",
+		},
+		Mailer: MailerConfig{
+			Driver:    "outbox",
+			From:      "Angidi <no-reply@angidi.local>",
+			OutboxDir: "tmp/outbox",
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+		},
+		Users: UsersConfig{
+			EmailVerification:     "none",
+			VerificationTokenTTL:  24 * time.Hour,
+			PasswordResetTokenTTL: 30 * time.Minute,
+			MFAIssuer:             "Angidi",
+			ImpersonationTTL:      15 * time.Minute,
+			Lockout: LockoutConfig{
+				Threshold:    10,
+				Duration:     15 * time.Minute,
+				BackoffAfter: 3,
+				BackoffBase:  time.Second,
+				IPThreshold:  20,
+			},
+			PasswordPolicy: PasswordPolicyConfig{
+				MinLength:            8,
+				MaxLength:            128,
+				MinAdminLength:       12,
+				DisallowPersonalInfo: true,
+			},
+			PasswordHashing: PasswordHashingConfig{
+				Algorithm:  "bcrypt",
+				BcryptCost: 12,
+				Argon2id: Argon2idConfig{
+					MemoryKiB:   64 * 1024,
+					Iterations:  3,
+					Parallelism: 2,
+				},
+			},
+		},
 	}
 }
 
@@ -127,6 +386,190 @@ func (c *Config) Validate() error {
 	if c.Logging.Level == "" {
 		return fmt.Errorf("log level cannot be empty")
 	}
+	if c.Payment.Provider != "" && c.Payment.Provider != "mock" {
+		return fmt.Errorf("unsupported payment provider: %s", c.Payment.Provider)
+	}
+	if c.Payment.WebhookDelay < 0 {
+		return fmt.Errorf("payment webhook delay cannot be negative")
+	}
+	if c.Tax.Rounding != "" && c.Tax.Rounding != "line" && c.Tax.Rounding != "order" {
+		return fmt.Errorf("invalid tax rounding: %s", c.Tax.Rounding)
+	}
+	for _, region := range c.Tax.Regions {
+		if region.Country == "" {
+			return fmt.Errorf("tax region %q must have a country", region.Name)
+		}
+		for class, rate := range region.Rates {
+			if rate < 0 || rate > 100 {
+				return fmt.Errorf("tax rate for class %q in region %q must be between 0 and 100", class, region.Name)
+			}
+		}
+	}
+	if c.Returns.Window < 0 {
+		return fmt.Errorf("return window cannot be negative")
+	}
+	if c.Privacy.DeletionGracePeriod < 0 {
+		return fmt.Errorf("deletion grace period cannot be negative")
+	}
+	if c.Privacy.DeletionInterval <= 0 {
+		return fmt.Errorf("deletion interval must be positive")
+	}
+	switch c.JWT.Algorithm {
+	case "HS256":
+		if c.JWT.PrivateKeyFile != "" {
+			return fmt.Errorf("jwt private key file cannot be used with HS256")
+		}
+	case "RS256", "ES256", "EdDSA":
+	default:
+		return fmt.Errorf("unsupported jwt algorithm: %s", c.JWT.Algorithm)
+	}
+	if c.JWT.RotationInterval < 0 {
+		return fmt.Errorf("jwt rotation interval cannot be negative")
+	}
+	switch c.Mailer.Driver {
+	case "outbox":
+		if c.Mailer.OutboxDir == "" {
+			return fmt.Errorf("mailer outbox directory cannot be empty")
+		}
+	case "smtp":
+		if c.Mailer.SMTP.Host == "" || c.Mailer.SMTP.Port < 1 || c.Mailer.SMTP.Port > 65535 {
+			return fmt.Errorf("smtp mailer needs a host and a valid port")
+		}
+	default:
+		return fmt.Errorf("unsupported mailer driver: %s", c.Mailer.Driver)
+	}
+	if c.Mailer.From == "" {
+		return fmt.Errorf("mailer from address cannot be empty")
+	}
+	switch c.Users.EmailVerification {
+	case "none", "login", "actions":
+	default:
+		return fmt.Errorf("invalid email verification mode: %s", c.Users.EmailVerification)
+	}
+	if c.Users.VerificationTokenTTL <= 0 {
+		return fmt.Errorf("verification token ttl must be positive")
+	}
+	if c.Users.PasswordResetTokenTTL <= 0 || c.Users.PasswordResetTokenTTL > 24*time.Hour {
+		return fmt.Errorf("password reset token ttl must be positive and at most 24h")
+	}
+	if c.Users.ImpersonationTTL <= 0 || c.Users.ImpersonationTTL > time.Hour {
+		return fmt.Errorf("impersonation ttl must be positive and at most 1h")
+	}
+	if err := c.Users.Lockout.validate(); err != nil {
+		return err
+	}
+	if err := c.Users.PasswordPolicy.validate(); err != nil {
+		return err
+	}
+	if err := c.Users.PasswordHashing.validate(); err != nil {
+		return err
+	}
+	if err := c.OIDC.validate(); err != nil {
+		return err
+	}
+	return c.Shipping.validate()
+}
+
+// validate checks that the lockout settings can be applied
+func (l *LockoutConfig) validate() error {
+	if l.Threshold <= 0 || l.IPThreshold <= 0 {
+		return fmt.Errorf("lockout thresholds must be positive")
+	}
+	if l.BackoffAfter <= 0 || l.BackoffAfter >= l.Threshold {
+		return fmt.Errorf("lockout backoff must start after at least one and fewer than threshold failures")
+	}
+	if l.Duration <= 0 || l.BackoffBase <= 0 {
+		return fmt.Errorf("lockout durations must be positive")
+	}
+	return nil
+}
+
+// validate checks that some password can satisfy the policy
+func (p *PasswordPolicyConfig) validate() error {
+	if p.MinLength < 1 || p.MaxLength < p.MinLength || p.MaxLength < p.MinAdminLength {
+		return fmt.Errorf("password policy lengths must be positive and max length at least the min lengths")
+	}
+	return nil
+}
+
+// validate checks that the hashing algorithm is supported and its parameters are usable
+func (p *PasswordHashingConfig) validate() error {
+	switch p.Algorithm {
+	case "bcrypt", "argon2id":
+	default:
+		return fmt.Errorf("unsupported password hashing algorithm: %s", p.Algorithm)
+	}
+	// bcrypt accepts costs from 4 to 31; both hashers are kept for verifying older hashes
+	if p.BcryptCost < 10 || p.BcryptCost > 31 {
+		return fmt.Errorf("bcrypt cost must be between 10 and 31")
+	}
+	if p.Argon2id.MemoryKiB < 8*1024 || p.Argon2id.Iterations < 1 || p.Argon2id.Parallelism < 1 {
+		return fmt.Errorf("argon2id needs at least 8 MiB of memory, one iteration and one thread")
+	}
+	return nil
+}
+
+// validate checks that every OIDC provider has a unique URL-safe name and a complete
+// registration
+func (o *OIDCConfig) validate() error {
+	names := make(map[string]bool, len(o.Providers))
+	for _, provider := range o.Providers {
+		if !oidcProviderName.MatchString(provider.Name) || names[provider.Name] {
+			return fmt.Errorf("oidc provider %q must have a unique name of lowercase letters, digits, - and _", provider.Name)
+		}
+		names[provider.Name] = true
+
+		if provider.IssuerURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return fmt.Errorf("oidc provider %q needs an issuer url, client id and redirect url", provider.Name)
+		}
+	}
+	return nil
+}
+
+// validate checks that shipping methods are well formed and reference known zones
+func (s *ShippingConfig) validate() error {
+	if s.VolumetricDivisor < 0 {
+		return fmt.Errorf("shipping volumetric divisor cannot be negative")
+	}
+
+	zones := make(map[string]bool, len(s.Zones))
+	for _, zone := range s.Zones {
+		if zone.ID == "" || len(zone.Countries) == 0 {
+			return fmt.Errorf("shipping zone %q must have an id and at least one country", zone.Name)
+		}
+		zones[zone.ID] = true
+	}
+
+	methods := make(map[string]bool, len(s.Methods))
+	for _, method := range s.Methods {
+		if method.ID == "" || methods[method.ID] {
+			return fmt.Errorf("shipping method %q must have a unique id", method.Name)
+		}
+		methods[method.ID] = true
+
+		switch method.Type {
+		case "flat_rate", "free_over_threshold", "local_pickup":
+		case "weight_based":
+			if len(method.Tiers) == 0 {
+				return fmt.Errorf("weight based shipping method %q needs at least one tier", method.ID)
+			}
+		default:
+			return fmt.Errorf("unsupported shipping method type: %s", method.Type)
+		}
+		if method.Rate < 0 || method.Threshold < 0 {
+			return fmt.Errorf("shipping method %q cannot have a negative rate or threshold", method.ID)
+		}
+		for _, tier := range method.Tiers {
+			if tier.Rate < 0 || tier.MaxWeight < 0 {
+				return fmt.Errorf("shipping method %q cannot have a negative tier", method.ID)
+			}
+		}
+		for _, zone := range method.Zones {
+			if !zones[zone] {
+				return fmt.Errorf("shipping method %q references unknown zone %q", method.ID, zone)
+			}
+		}
+	}
 	return nil
 }
 
@@ -162,5 +605,44 @@ func applyEnvOverrides(cfg *Config) error {
 	if level := os.Getenv("LOG_LEVEL"); level != "" {
 		cfg.Logging.Level = level
 	}
+	if secret := os.Getenv("PAYMENT_WEBHOOK_SECRET"); secret != "" {
+		cfg.Payment.WebhookSecret = secret
+	}
+	if algorithm := os.Getenv("JWT_ALGORITHM"); algorithm != "" {
+		cfg.JWT.Algorithm = algorithm
+	}
+	if keyFile := os.Getenv("JWT_PRIVATE_KEY_FILE"); keyFile != "" {
+		cfg.JWT.PrivateKeyFile = keyFile
+	}
+	if driver := os.Getenv("MAILER_DRIVER"); driver != "" {
+		cfg.Mailer.Driver = driver
+	}
+	if password := os.Getenv("SMTP_PASSWORD"); password != "" {
+		cfg.Mailer.SMTP.Password = password
+	}
+	if mode := os.Getenv("EMAIL_VERIFICATION"); mode != "" {
+		cfg.Users.EmailVerification = mode
+	}
+	if required := os.Getenv("REQUIRE_ADMIN_MFA"); required != "" {
+		value, err := strconv.ParseBool(required)
+		if err != nil {
+			return fmt.Errorf("invalid REQUIRE_ADMIN_MFA: %w", err)
+		}
+		cfg.Users.RequireMFAForAdmins = value
+	}
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		cfg.Users.PasswordHashing.Algorithm = algorithm
+	}
+	for i, provider := range cfg.OIDC.Providers {
+		if secret := os.Getenv(oidcSecretEnv(provider.Name)); secret != "" {
+			cfg.OIDC.Providers[i].ClientSecret = secret
+		}
+	}
 	return nil
 }
+
+// oidcSecretEnv returns the environment variable the client secret of the OIDC provider
+// called name is read from, e.g. OIDC_GOOGLE_CLIENT_SECRET
+func oidcSecretEnv(name string) string {
+	return "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_CLIENT_SECRET"
+}
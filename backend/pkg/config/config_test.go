@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -48,6 +49,24 @@ func TestLoadWithEnvOverride(t *testing.T) {
 	}
 }
 
+func TestLoadRequireAdminMFA(t *testing.T) {
+	t.Setenv("CONFIG_PATH", "nonexistent.yaml")
+
+	t.Setenv("REQUIRE_ADMIN_MFA", "true")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !cfg.Users.RequireMFAForAdmins {
+		t.Error("Expected MFA to be required for admins")
+	}
+
+	t.Setenv("REQUIRE_ADMIN_MFA", "sometimes")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for invalid REQUIRE_ADMIN_MFA")
+	}
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -85,6 +104,219 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid tax rounding",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Tax.Rounding = "invoice"
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "tax rate out of range",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Tax.Regions = []TaxRegionConfig{{Name: "Nowhere", Country: "XX", Rates: map[string]float64{"standard": 120}}}
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "shipping method with unknown zone",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Shipping.Methods = []ShippingMethodConfig{{ID: "standard", Type: "flat_rate", Zones: []string{"mars"}}}
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "weight based shipping method without tiers",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Shipping.Methods = []ShippingMethodConfig{{ID: "parcel", Type: "weight_based"}}
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "unsupported jwt algorithm",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.JWT.Algorithm = "none"
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "jwt private key file with HS256",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.JWT.Algorithm = "HS256"
+				cfg.JWT.PrivateKeyFile = "keys/jwt.pem"
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "unsupported mailer driver",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Mailer.Driver = "pigeon"
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "smtp mailer without host",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Mailer.Driver = "smtp"
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "smtp mailer",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Mailer.Driver = "smtp"
+				cfg.Mailer.SMTP.Host = "smtp.example.com"
+				return cfg
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "invalid email verification mode",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.EmailVerification = "always"
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "long lived password reset tokens",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.PasswordResetTokenTTL = 7 * 24 * time.Hour
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "long lived impersonation tokens",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.ImpersonationTTL = 8 * time.Hour
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "no deletion interval",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Privacy.DeletionInterval = 0
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "lockout backoff after threshold",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.Lockout.BackoffAfter = cfg.Users.Lockout.Threshold
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "no lockout duration",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.Lockout.Duration = 0
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "unsupported password hashing algorithm",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.PasswordHashing.Algorithm = "md5"
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "weak bcrypt cost",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.PasswordHashing.BcryptCost = 4
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "argon2id password hashing",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.PasswordHashing.Algorithm = "argon2id"
+				return cfg
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "password max length below admin min length",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.Users.PasswordPolicy.MaxLength = 10
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "oidc provider",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.OIDC.Providers = []OIDCProviderConfig{validOIDCProvider()}
+				return cfg
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "oidc provider with name unfit for urls",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				provider := validOIDCProvider()
+				provider.Name = "Google Workspace"
+				cfg.OIDC.Providers = []OIDCProviderConfig{provider}
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "duplicate oidc provider",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				cfg.OIDC.Providers = []OIDCProviderConfig{validOIDCProvider(), validOIDCProvider()}
+				return cfg
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "oidc provider without client id",
+			config: func() *Config {
+				cfg := newDefaultConfig()
+				provider := validOIDCProvider()
+				provider.ClientID = ""
+				cfg.OIDC.Providers = []OIDCProviderConfig{provider}
+				return cfg
+			}(),
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -97,6 +329,33 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func validOIDCProvider() OIDCProviderConfig {
+	return OIDCProviderConfig{
+		Name:        "google",
+		IssuerURL:   "https://accounts.google.com",
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost:3000/auth/callback/google",
+	}
+}
+
+func TestApplyEnvOverridesOIDCClientSecret(t *testing.T) {
+	t.Setenv("OIDC_ACME_SSO_CLIENT_SECRET", "from-env")
+	cfg := newDefaultConfig()
+	provider := validOIDCProvider()
+	provider.Name = "acme-sso"
+	cfg.OIDC.Providers = []OIDCProviderConfig{provider, validOIDCProvider()}
+
+	if err := applyEnvOverrides(cfg); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.OIDC.Providers[0].ClientSecret != "from-env" {
+		t.Errorf("Expected client secret from environment, got: %q", cfg.OIDC.Providers[0].ClientSecret)
+	}
+	if cfg.OIDC.Providers[1].ClientSecret != "" {
+		t.Errorf("Expected no client secret for other provider, got: %q", cfg.OIDC.Providers[1].ClientSecret)
+	}
+}
+
 func TestValidateConfigPath(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -2,9 +2,11 @@ package jwt
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
@@ -12,112 +14,332 @@ var (
 	ErrInvalidToken = errors.New("invalid token")
 	// ErrExpiredToken is returned when token has expired
 	ErrExpiredToken = errors.New("token has expired")
+	// ErrWrongTokenType is returned when a valid token of one kind is presented where the other is expected
+	ErrWrongTokenType = errors.New("wrong token type")
+)
+
+// Token types carried in the token_use claim
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypeEmailChange       = "email_change"
+	TokenTypeMFAChallenge      = "mfa_challenge"
+)
+
+// Authentication methods carried in the amr claim (RFC 8176). AuthMethodFederated, for
+// logins through an external identity provider, and AuthMethodAPIKey, for requests made
+// with an API key, are not registered there.
+const (
+	AuthMethodPassword  = "pwd"
+	AuthMethodOTP       = "otp"
+	AuthMethodFederated = "fed"
+	AuthMethodAPIKey    = "apikey"
 )
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	// Role is the user's primary role, kept for clients that know a single role. Roles
+	// lists every role they hold and Permissions what those roles grant.
+	Role        string   `json:"role"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// AMR lists how the user authenticated, e.g. AuthMethodPassword and AuthMethodOTP
+	AMR      []string `json:"amr,omitempty"`
+	TokenUse string   `json:"token_use"`
+	// ClientID is the OAuth client a token was issued to, when it was not issued to the
+	// user directly. Tokens of the client credentials grant have no UserID.
+	ClientID string `json:"client_id,omitempty"`
+	// Act names the admin acting as the user when the token was issued for impersonation
+	Act *Actor `json:"act,omitempty"`
+	// APIKeyID is set on claims resolved from an API key rather than read from a token
+	APIKeyID string `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// Actor identifies who is acting on behalf of the subject of a token, as in the act
+// claim of RFC 8693
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// RefreshClaims represents the claims of a refresh token. The registered ID (jti)
+// identifies the individual token and FamilyID the chain of tokens rotated from one login.
+type RefreshClaims struct {
+	FamilyID string `json:"fid"`
+	TokenUse string `json:"token_use"`
+	jwt.RegisteredClaims
+}
+
+// ActionClaims represents the claims of a short-lived token that authorizes one action,
+// such as confirming an email address. Email binds the token to the address it was sent to.
+type ActionClaims struct {
+	Email    string `json:"email"`
+	TokenUse string `json:"token_use"`
 	jwt.RegisteredClaims
 }
 
 // Service handles JWT token operations
 type Service struct {
-	secretKey              []byte
-	accessTokenDuration    time.Duration
-	refreshTokenDuration   time.Duration
-	issuer                 string
+	keys                 *KeySet
+	accessTokenDuration  time.Duration
+	refreshTokenDuration time.Duration
+	issuer               string
+	accessAudience       string
+	refreshAudience      string
 }
 
-// NewService creates a new JWT service
+// NewService creates a new JWT service that signs with HS256 using a shared secret
 func NewService(secretKey string, accessTokenDuration, refreshTokenDuration time.Duration) *Service {
+	return NewServiceWithKeys(NewKeySet(NewHMACKey([]byte(secretKey))), accessTokenDuration, refreshTokenDuration)
+}
+
+// NewServiceWithKeys creates a new JWT service that signs with the current key of keys.
+// Access tokens are issued for the API audience and refresh tokens for a separate token
+// endpoint audience, so neither is accepted as the other.
+func NewServiceWithKeys(keys *KeySet, accessTokenDuration, refreshTokenDuration time.Duration) *Service {
 	return &Service{
-		secretKey:              []byte(secretKey),
-		accessTokenDuration:    accessTokenDuration,
-		refreshTokenDuration:   refreshTokenDuration,
-		issuer:                 "angidi-api",
+		keys:                 keys,
+		accessTokenDuration:  accessTokenDuration,
+		refreshTokenDuration: refreshTokenDuration,
+		issuer:               "angidi-api",
+		accessAudience:       "angidi-api",
+		refreshAudience:      "angidi-api/refresh",
 	}
 }
 
 // GenerateAccessToken generates a new access token
 func (s *Service) GenerateAccessToken(userID, email, role string) (string, error) {
+	return s.IssueAccessToken(Claims{UserID: userID, Email: email, Role: role})
+}
+
+// IssueAccessToken generates a new access token carrying the user claims of claims.
+// Its type, audience, issuer and lifetime are always set by the service; only the token
+// ID (jti) and subject of the registered claims are kept.
+func (s *Service) IssueAccessToken(claims Claims) (string, error) {
+	return s.issueAccessToken(claims, s.accessTokenDuration)
+}
+
+// IssueImpersonationToken generates an access token that lets actorID act as the user of
+// claims for ttl. The token carries actorID in its act claim and cannot be refreshed.
+func (s *Service) IssueImpersonationToken(claims Claims, actorID string, ttl time.Duration) (string, error) {
+	claims.Act = &Actor{Subject: actorID}
+	return s.issueAccessToken(claims, ttl)
+}
+
+// issueAccessToken signs an access token for claims that expires after ttl
+func (s *Service) issueAccessToken(claims Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.TokenUse = TokenTypeAccess
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        claims.ID,
+		Subject:   claims.Subject,
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    s.issuer,
+		Audience:  jwt.ClaimStrings{s.accessAudience},
+	}
+
+	return s.sign(claims)
+}
+
+// AccessTokenDuration returns how long access tokens are valid for
+func (s *Service) AccessTokenDuration() time.Duration {
+	return s.accessTokenDuration
+}
+
+// GenerateRefreshToken generates a new refresh token with a unique ID in the given family
+func (s *Service) GenerateRefreshToken(userID, familyID string) (string, *RefreshClaims, error) {
 	now := time.Now()
-	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+	claims := &RefreshClaims{
+		FamilyID: familyID,
+		TokenUse: TokenTypeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTokenDuration)),
+			ID:        uuid.New().String(),
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshTokenDuration)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.refreshAudience},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+	signed, err := s.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
-// GenerateRefreshToken generates a new refresh token
-func (s *Service) GenerateRefreshToken(userID string) (string, error) {
-	now := time.Now()
-	claims := jwt.RegisteredClaims{
-		Subject:   userID,
-		ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshTokenDuration)),
-		IssuedAt:  jwt.NewNumericDate(now),
-		NotBefore: jwt.NewNumericDate(now),
-		Issuer:    s.issuer,
+// ValidateToken validates and parses an access token
+func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := s.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkType(claims.TokenUse, claims.Audience, TokenTypeAccess, s.accessAudience); err != nil {
+		return nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+	return claims, nil
 }
 
-// ValidateToken validates and parses a JWT token
-func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return s.secretKey, nil
-	})
+// ValidateRefreshToken validates a refresh token and returns its claims
+func (s *Service) ValidateRefreshToken(tokenString string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	if err := s.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
 
-	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, ErrExpiredToken
-		}
+	if err := s.checkType(claims.TokenUse, claims.Audience, TokenTypeRefresh, s.refreshAudience); err != nil {
+		return nil, err
+	}
+	if claims.ID == "" || claims.FamilyID == "" {
 		return nil, ErrInvalidToken
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
+	return claims, nil
+}
+
+// GenerateActionToken generates a token of type use for userID and email that expires after ttl
+func (s *Service) GenerateActionToken(use, userID, email string, ttl time.Duration) (string, *ActionClaims, error) {
+	now := time.Now()
+	claims := &ActionClaims{
+		Email:    email,
+		TokenUse: use,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.actionAudience(use)},
+		},
+	}
+
+	signed, err := s.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// ValidateActionToken validates a token of type use and returns its claims
+func (s *Service) ValidateActionToken(tokenString, use string) (*ActionClaims, error) {
+	claims := &ActionClaims{}
+	if err := s.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkType(claims.TokenUse, claims.Audience, use, s.actionAudience(use)); err != nil {
+		return nil, err
+	}
+	if claims.ID == "" || claims.Subject == "" {
 		return nil, ErrInvalidToken
 	}
 
 	return claims, nil
 }
 
-// ValidateRefreshToken validates a refresh token and returns the user ID
-func (s *Service) ValidateRefreshToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+// actionAudience returns the audience of action tokens of type use
+func (s *Service) actionAudience(use string) string {
+	return s.issuer + "/" + use
+}
+
+// JWKS returns the public keys that verify tokens issued by this service
+func (s *Service) JWKS() JWKS {
+	return s.keys.PublicKeys()
+}
+
+// RotateKeys switches to a newly generated signing key. The previous key keeps verifying
+// tokens until the longest-lived of them, a refresh token, has expired.
+func (s *Service) RotateKeys() (*Key, error) {
+	return s.keys.Rotate(s.refreshTokenDuration)
+}
+
+// StartKeyRotation rotates the signing key every interval until stop is called. The
+// outcome of each rotation is reported to onRotate, which may be nil.
+func (s *Service) StartKeyRotation(interval time.Duration, onRotate func(key *Key, err error)) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				key, err := s.RotateKeys()
+				if onRotate != nil {
+					onRotate(key, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// sign signs claims with the current key, naming it in the kid header
+func (s *Service) sign(claims jwt.Claims) (string, error) {
+	key := s.keys.Current()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// parse verifies a token's signature, issuer and lifetime and decodes it into claims
+func (s *Service) parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := s.keys.Find(kid)
+		if key == nil || token.Method.Alg() != key.method.Alg() {
 			return nil, ErrInvalidToken
 		}
-		return s.secretKey, nil
-	})
+		return key.verifyKey, nil
+	}, jwt.WithIssuer(s.issuer))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return "", ErrExpiredToken
+			return ErrExpiredToken
 		}
-		return "", ErrInvalidToken
+		return ErrInvalidToken
+	}
+
+	if !token.Valid {
+		return ErrInvalidToken
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok || !token.Valid {
-		return "", ErrInvalidToken
+	return nil
+}
+
+// checkType ensures a verified token is of the expected kind. A token of another kind
+// reports ErrWrongTokenType; tokens without a type or the expected audience are invalid.
+func (s *Service) checkType(tokenUse string, audience jwt.ClaimStrings, wantUse, wantAudience string) error {
+	if tokenUse == "" {
+		return ErrInvalidToken
+	}
+	if tokenUse != wantUse {
+		return ErrWrongTokenType
 	}
 
-	return claims.Subject, nil
+	for _, aud := range audience {
+		if aud == wantAudience {
+			return nil
+		}
+	}
+	return ErrInvalidToken
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,17 +24,67 @@ func TestService_GenerateAccessToken(t *testing.T) {
 	assert.Equal(t, "user", claims.Role)
 }
 
+func TestService_IssueAccessToken(t *testing.T) {
+	service := NewService("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+
+	claims := Claims{UserID: "user-123", ClientID: "client-1", Permissions: []string{"product:write"}}
+	claims.ID = "token-1"
+	claims.Subject = "user-123"
+	claims.Issuer = "someone-else"
+	token, err := service.IssueAccessToken(claims)
+	require.NoError(t, err)
+
+	validated, err := service.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", validated.ID)
+	assert.Equal(t, "user-123", validated.Subject)
+	assert.Equal(t, "client-1", validated.ClientID)
+	assert.Equal(t, []string{"product:write"}, validated.Permissions)
+	// The issuer and lifetime are the service's own
+	assert.Equal(t, "angidi-api", validated.Issuer)
+	assert.WithinDuration(t, time.Now().Add(service.AccessTokenDuration()), validated.ExpiresAt.Time, 5*time.Second)
+}
+
+func TestService_IssueImpersonationToken(t *testing.T) {
+	service := NewService("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+
+	token, err := service.IssueImpersonationToken(Claims{UserID: "user-123", Role: "user"}, "admin-1", 5*time.Minute)
+	require.NoError(t, err)
+
+	validated, err := service.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", validated.UserID)
+	require.NotNil(t, validated.Act)
+	assert.Equal(t, "admin-1", validated.Act.Subject)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), validated.ExpiresAt.Time, 5*time.Second)
+
+	// Regular access tokens name no actor
+	token, err = service.IssueAccessToken(Claims{UserID: "user-123", Role: "user"})
+	require.NoError(t, err)
+	validated, err = service.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Nil(t, validated.Act)
+}
+
 func TestService_GenerateRefreshToken(t *testing.T) {
 	service := NewService("test-secret-key", 15*time.Minute, 7*24*time.Hour)
 
-	token, err := service.GenerateRefreshToken("user-123")
+	token, issued, err := service.GenerateRefreshToken("user-123", "family-1")
 	require.NoError(t, err)
 	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, issued.ID)
 
 	// Validate the generated token
-	userID, err := service.ValidateRefreshToken(token)
+	claims, err := service.ValidateRefreshToken(token)
 	require.NoError(t, err)
-	assert.Equal(t, "user-123", userID)
+	assert.Equal(t, "user-123", claims.Subject)
+	assert.Equal(t, "family-1", claims.FamilyID)
+	assert.Equal(t, issued.ID, claims.ID)
+
+	// Every token gets its own ID, even within a family
+	_, next, err := service.GenerateRefreshToken("user-123", "family-1")
+	require.NoError(t, err)
+	assert.NotEqual(t, issued.ID, next.ID)
 }
 
 func TestService_ValidateToken_InvalidToken(t *testing.T) {
@@ -82,7 +133,7 @@ func TestService_ValidateRefreshToken_InvalidToken(t *testing.T) {
 func TestService_ValidateRefreshToken_ExpiredToken(t *testing.T) {
 	service := NewService("test-secret-key", 15*time.Minute, -1*time.Hour)
 
-	token, err := service.GenerateRefreshToken("user-123")
+	token, _, err := service.GenerateRefreshToken("user-123", "family-1")
 	require.NoError(t, err)
 
 	// Wait a moment to ensure token is expired
@@ -92,3 +143,78 @@ func TestService_ValidateRefreshToken_ExpiredToken(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, ErrExpiredToken, err)
 }
+
+func TestService_TokenTypes(t *testing.T) {
+	service := NewService("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+
+	accessToken, err := service.GenerateAccessToken("user-123", "test@example.com", "user")
+	require.NoError(t, err)
+	refreshToken, _, err := service.GenerateRefreshToken("user-123", "family-1")
+	require.NoError(t, err)
+
+	claims, err := service.ValidateToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, TokenTypeAccess, claims.TokenUse)
+	assert.Equal(t, jwt.ClaimStrings{"angidi-api"}, claims.Audience)
+
+	refreshClaims, err := service.ValidateRefreshToken(refreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, TokenTypeRefresh, refreshClaims.TokenUse)
+	assert.Equal(t, jwt.ClaimStrings{"angidi-api/refresh"}, refreshClaims.Audience)
+
+	// Each kind is rejected where the other is expected
+	_, err = service.ValidateToken(refreshToken)
+	assert.Equal(t, ErrWrongTokenType, err)
+	_, err = service.ValidateRefreshToken(accessToken)
+	assert.Equal(t, ErrWrongTokenType, err)
+}
+
+func TestService_ValidateToken_UntypedToken(t *testing.T) {
+	service := NewService("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+
+	// A correctly signed token without a token_use claim, as issued before token types existed
+	now := time.Now()
+	untyped := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "user-123",
+		Issuer:    "angidi-api",
+		Audience:  jwt.ClaimStrings{"angidi-api"},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	})
+	untyped.Header["kid"] = NewHMACKey([]byte("test-secret-key")).ID
+	token, err := untyped.SignedString([]byte("test-secret-key"))
+	require.NoError(t, err)
+
+	_, err = service.ValidateToken(token)
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = service.ValidateRefreshToken(token)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestService_ActionToken(t *testing.T) {
+	service := NewService("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+
+	token, issued, err := service.GenerateActionToken(TokenTypeEmailVerification, "user-123", "test@example.com", time.Hour)
+	require.NoError(t, err)
+
+	claims, err := service.ValidateActionToken(token, TokenTypeEmailVerification)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", claims.Subject)
+	assert.Equal(t, "test@example.com", claims.Email)
+	assert.Equal(t, issued.ID, claims.ID)
+
+	// Action tokens are not interchangeable with session tokens or other actions
+	_, err = service.ValidateActionToken(token, "password_reset")
+	assert.Equal(t, ErrWrongTokenType, err)
+	_, err = service.ValidateToken(token)
+	assert.Equal(t, ErrWrongTokenType, err)
+	accessToken, err := service.GenerateAccessToken("user-123", "test@example.com", "user")
+	require.NoError(t, err)
+	_, err = service.ValidateActionToken(accessToken, TokenTypeEmailVerification)
+	assert.Equal(t, ErrWrongTokenType, err)
+
+	expired, _, err := service.GenerateActionToken(TokenTypeEmailVerification, "user-123", "test@example.com", -time.Hour)
+	require.NoError(t, err)
+	_, err = service.ValidateActionToken(expired, TokenTypeEmailVerification)
+	assert.Equal(t, ErrExpiredToken, err)
+}
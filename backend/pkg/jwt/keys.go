@@ -0,0 +1,358 @@
+package jwt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	// ErrUnsupportedAlgorithm is returned when a key uses an algorithm other than HS256, RS256, ES256 or EdDSA
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	// ErrInvalidKey is returned when a PEM encoded private key cannot be parsed
+	ErrInvalidKey = errors.New("invalid private key")
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+// Key is a signing key identified by the kid header of the tokens it signs
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	// RetiredAt is set once the key has been rotated out; it then only verifies tokens
+	RetiredAt *time.Time
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACKey creates an HS256 key from a shared secret. Its ID is derived from the
+// secret so that every instance configured with the same secret agrees on it.
+func NewHMACKey(secret []byte) *Key {
+	sum := sha256.Sum256(append([]byte("kid:"), secret...))
+	return &Key{
+		ID:        hex.EncodeToString(sum[:8]),
+		Algorithm: AlgorithmHS256,
+		CreatedAt: time.Now(),
+		method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// GenerateKey creates a new random key for algorithm
+func GenerateKey(algorithm string) (*Key, error) {
+	var private interface{}
+	var err error
+
+	switch algorithm {
+	case AlgorithmHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		key := NewHMACKey(secret)
+		key.ID = uuid.New().String()
+		return key, nil
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newAsymmetricKey(uuid.New().String(), private)
+}
+
+// ParsePrivateKeyPEM loads an RSA, P-256 ECDSA or Ed25519 private key in PKCS#8, PKCS#1
+// or SEC 1 form. The algorithm follows from the key type and the ID from its public key.
+func ParsePrivateKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	var private interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := newAsymmetricKey("", private)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk, err := key.PublicJWK()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = jwk.Thumbprint()
+	return key, nil
+}
+
+// newAsymmetricKey wraps a private key of a supported type
+func newAsymmetricKey(id string, private interface{}) (*Key, error) {
+	key := &Key{ID: id, CreatedAt: time.Now(), signKey: private}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm, key.method, key.verifyKey = AlgorithmRS256, jwt.SigningMethodRS256, &private.PublicKey
+	case *ecdsa.PrivateKey:
+		if private.Curve != elliptic.P256() {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		key.Algorithm, key.method, key.verifyKey = AlgorithmES256, jwt.SigningMethodES256, &private.PublicKey
+	case ed25519.PrivateKey:
+		key.Algorithm, key.method, key.verifyKey = AlgorithmEdDSA, jwt.SigningMethodEdDSA, private.Public()
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return key, nil
+}
+
+// PublicJWK returns the public half of the key as a JSON Web Key. HS256 keys are
+// symmetric and have no public form.
+func (k *Key) PublicJWK() (JWK, error) {
+	jwk := JWK{Use: "sig", Alg: k.Algorithm, Kid: k.ID}
+
+	switch public := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeSegment(public.N.Bytes())
+		jwk.E = encodeSegment(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		point, err := public.ECDH()
+		if err != nil {
+			return JWK{}, err
+		}
+		// Uncompressed point: 0x04 || X || Y
+		raw := point.Bytes()
+		jwk.Kty, jwk.Crv = "EC", "P-256"
+		jwk.X = encodeSegment(raw[1:33])
+		jwk.Y = encodeSegment(raw[33:])
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+		jwk.X = encodeSegment(public)
+	default:
+		return JWK{}, ErrUnsupportedAlgorithm
+	}
+
+	return jwk, nil
+}
+
+// JWK represents a public JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key, used as a stable key ID
+func (j JWK) Thumbprint() string {
+	var canonical string
+	switch j.Kty {
+	case "RSA":
+		canonical = `{"e":"` + j.E + `","kty":"RSA","n":"` + j.N + `"}`
+	case "EC":
+		canonical = `{"crv":"` + j.Crv + `","kty":"EC","x":"` + j.X + `","y":"` + j.Y + `"}`
+	case "OKP":
+		canonical = `{"crv":"` + j.Crv + `","kty":"OKP","x":"` + j.X + `"}`
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return encodeSegment(sum[:])
+}
+
+// PublicKey decodes the key into the public key type golang-jwt verifies its algorithm
+// with: *rsa.PublicKey, *ecdsa.PublicKey on P-256 or ed25519.PublicKey
+func (j JWK) PublicKey() (interface{}, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeSegment(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeSegment(j.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, ErrInvalidKey
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		x, err := decodeSegment(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeSegment(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, ErrInvalidKey
+		}
+		// Parsing the uncompressed point checks that it is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, ErrInvalidKey
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		x, err := decodeSegment(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidKey
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// JWKS represents a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySet holds the current signing key and the retired keys that still verify
+// tokens issued before the last rotation
+type KeySet struct {
+	keys  []*Key // oldest first; the last key is current
+	mutex sync.RWMutex
+}
+
+// NewKeySet creates a key set that signs with initial
+func NewKeySet(initial *Key) *KeySet {
+	return &KeySet{keys: []*Key{initial}}
+}
+
+// Current returns the key new tokens are signed with
+func (k *KeySet) Current() *Key {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.keys[len(k.keys)-1]
+}
+
+// Find returns the key with the given ID, or nil if it is unknown or has been dropped
+func (k *KeySet) Find(id string) *Key {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	for _, key := range k.keys {
+		if key.ID == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// Rotate generates a new current key with the same algorithm and retires the previous one.
+// Retired keys keep verifying tokens for retention, which should be at least the lifetime
+// of the longest-lived token, and are dropped after that.
+func (k *KeySet) Rotate(retention time.Duration) (*Key, error) {
+	next, err := GenerateKey(k.Current().Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	now := next.CreatedAt
+	k.keys[len(k.keys)-1].RetiredAt = &now
+
+	kept := make([]*Key, 0, len(k.keys)+1)
+	for _, key := range k.keys {
+		if now.Sub(*key.RetiredAt) < retention {
+			kept = append(kept, key)
+		}
+	}
+	k.keys = append(kept, next)
+
+	return next, nil
+}
+
+// PublicKeys returns the public keys of the set, current key last. HS256 keys are omitted.
+func (k *KeySet) PublicKeys() JWKS {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		if jwk, err := key.PublicJWK(); err == nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// encodeSegment encodes bytes as unpadded base64url, as used throughout JOSE
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSegment decodes unpadded base64url
+func decodeSegment(segment string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return data, nil
+}
@@ -0,0 +1,204 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySet_Algorithms(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmHS256, AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := GenerateKey(algorithm)
+			require.NoError(t, err)
+			service := NewServiceWithKeys(NewKeySet(key), 15*time.Minute, 7*24*time.Hour)
+
+			token, err := service.GenerateAccessToken("user-123", "test@example.com", "user")
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, parsed.Method.Alg())
+			assert.Equal(t, key.ID, parsed.Header["kid"])
+
+			claims, err := service.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, "user-123", claims.UserID)
+
+			// Symmetric keys must never be published
+			jwks := service.JWKS()
+			if algorithm == AlgorithmHS256 {
+				assert.Empty(t, jwks.Keys)
+			} else {
+				require.Len(t, jwks.Keys, 1)
+				assert.Equal(t, key.ID, jwks.Keys[0].Kid)
+				assert.Equal(t, algorithm, jwks.Keys[0].Alg)
+			}
+		})
+	}
+
+	_, err := GenerateKey("none")
+	assert.Equal(t, ErrUnsupportedAlgorithm, err)
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	key, err := GenerateKey(AlgorithmEdDSA)
+	require.NoError(t, err)
+	service := NewServiceWithKeys(NewKeySet(key), 15*time.Minute, 7*24*time.Hour)
+
+	before, err := service.GenerateAccessToken("user-123", "test@example.com", "user")
+	require.NoError(t, err)
+
+	next, err := service.RotateKeys()
+	require.NoError(t, err)
+	assert.NotEqual(t, key.ID, next.ID)
+	assert.NotNil(t, key.RetiredAt)
+
+	after, err := service.GenerateAccessToken("user-123", "test@example.com", "user")
+	require.NoError(t, err)
+
+	// Tokens signed before the rotation stay valid and both keys are published
+	_, err = service.ValidateToken(before)
+	assert.NoError(t, err)
+	_, err = service.ValidateToken(after)
+	assert.NoError(t, err)
+	jwks := service.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, next.ID, jwks.Keys[1].Kid)
+
+	// Keys retired for longer than the retention period are dropped
+	keys := NewKeySet(key)
+	_, err = keys.Rotate(0)
+	require.NoError(t, err)
+	assert.Nil(t, keys.Find(key.ID))
+}
+
+func TestService_StartKeyRotation(t *testing.T) {
+	key, err := GenerateKey(AlgorithmES256)
+	require.NoError(t, err)
+	service := NewServiceWithKeys(NewKeySet(key), 15*time.Minute, 7*24*time.Hour)
+
+	rotated := make(chan *Key, 1)
+	stop := service.StartKeyRotation(10*time.Millisecond, func(next *Key, err error) {
+		assert.NoError(t, err)
+		select {
+		case rotated <- next:
+		default:
+		}
+	})
+	defer stop()
+
+	select {
+	case next := <-rotated:
+		assert.NotEqual(t, key.ID, next.ID)
+	case <-time.After(time.Second):
+		t.Fatal("key was not rotated")
+	}
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p384DER, err := x509.MarshalECPrivateKey(p384Key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		block         *pem.Block
+		wantAlgorithm string
+		wantErr       error
+	}{
+		{name: "RSA PKCS#1", block: &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, wantAlgorithm: AlgorithmRS256},
+		{name: "EC SEC 1", block: &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}, wantAlgorithm: AlgorithmES256},
+		{name: "Ed25519 PKCS#8", block: &pem.Block{Type: "PRIVATE KEY", Bytes: edDER}, wantAlgorithm: AlgorithmEdDSA},
+		{name: "unsupported curve", block: &pem.Block{Type: "EC PRIVATE KEY", Bytes: p384DER}, wantErr: ErrUnsupportedAlgorithm},
+		{name: "garbage", block: &pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")}, wantErr: ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := pem.EncodeToMemory(tt.block)
+			key, err := ParsePrivateKeyPEM(data)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAlgorithm, key.Algorithm)
+
+			// The key ID is derived from the public key, so reloading the file keeps it
+			again, err := ParsePrivateKeyPEM(data)
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, again.ID)
+		})
+	}
+
+	_, err = ParsePrivateKeyPEM([]byte("not pem"))
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestKey_PublicJWK_VerifiesTokens(t *testing.T) {
+	key, err := GenerateKey(AlgorithmEdDSA)
+	require.NoError(t, err)
+	service := NewServiceWithKeys(NewKeySet(key), 15*time.Minute, 7*24*time.Hour)
+
+	token, err := service.GenerateAccessToken("user-123", "test@example.com", "user")
+	require.NoError(t, err)
+
+	// Another service only holding the published JWK can verify the token
+	jwk := service.JWKS().Keys[0]
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	require.NoError(t, err)
+	parsed, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return ed25519.PublicKey(x), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", parsed.Claims.(*Claims).UserID)
+}
+
+func TestJWK_PublicKey(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := GenerateKey(algorithm)
+			require.NoError(t, err)
+			service := NewServiceWithKeys(NewKeySet(key), 15*time.Minute, 7*24*time.Hour)
+			token, err := service.GenerateAccessToken("user-123", "test@example.com", "user")
+			require.NoError(t, err)
+
+			// The key decoded from the published JWK verifies the token
+			public, err := service.JWKS().Keys[0].PublicKey()
+			require.NoError(t, err)
+			parsed, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+				return public, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "user-123", parsed.Claims.(*Claims).UserID)
+		})
+	}
+
+	_, err := JWK{Kty: "EC", Crv: "P-256", X: encodeSegment(make([]byte, 32)), Y: encodeSegment(make([]byte, 32))}.PublicKey()
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = JWK{Kty: "oct"}.PublicKey()
+	assert.Equal(t, ErrUnsupportedAlgorithm, err)
+}
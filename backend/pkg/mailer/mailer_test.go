@@ -0,0 +1,156 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxMailer_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := NewOutboxMailer(dir, "Angidi <no-reply@angidi.test>")
+	require.NoError(t, err)
+
+	err = m.Send(context.Background(), Message{To: "user@example.com", Subject: "Welcome", Body: "Line one\nLine two"})
+	require.NoError(t, err)
+	err = m.Send(context.Background(), Message{To: "user@example.com", Subject: "Grüße", Body: "Second"})
+	require.NoError(t, err)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.True(t, strings.HasSuffix(files[0].Name(), ".eml"))
+
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	content := string(data)
+	assert.Contains(t, content, "From: Angidi <no-reply@angidi.test>\r\n")
+	assert.Contains(t, content, "To: user@example.com\r\n")
+	assert.Contains(t, content, "Subject: Welcome\r\n")
+	assert.Contains(t, content, "Message-ID: <")
+	assert.Contains(t, content, "@angidi.test>\r\n")
+	assert.True(t, strings.HasSuffix(content, "\r\n\r\nLine one\r\nLine two\r\n"))
+
+	data, err = os.ReadFile(filepath.Join(dir, files[1].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Subject: =?UTF-8?q?Gr=C3=BC=C3=9Fe?=\r\n")
+}
+
+func TestMessage_Invalid(t *testing.T) {
+	m, err := NewOutboxMailer(t.TempDir(), "no-reply@angidi.test")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{name: "missing recipient", msg: Message{Subject: "Hi", Body: "Body"}},
+		{name: "malformed recipient", msg: Message{To: "not an address", Subject: "Hi", Body: "Body"}},
+		{name: "header injection in subject", msg: Message{To: "user@example.com", Subject: "Hi\r\nBcc: victim@example.com", Body: "Body"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ErrInvalidMessage, m.Send(context.Background(), tt.msg))
+		})
+	}
+}
+
+// smtpStandIn is a minimal SMTP server that accepts one message and records it
+type smtpStandIn struct {
+	listener net.Listener
+	from     string
+	to       []string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &smtpStandIn{listener: listener, done: make(chan struct{})}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *smtpStandIn) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP stand-in")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			s.from = command[len("MAIL FROM:"):]
+			reply("250 OK")
+		case "RCPT":
+			s.to = append(s.to, command[len("RCPT TO:"):])
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.data = data.String()
+			reply("250 OK: queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	server := newSMTPStandIn(t)
+	host, port, err := net.SplitHostPort(server.listener.Addr().String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	m := NewSMTPMailer(SMTPConfig{Host: host, Port: portNumber, From: "Angidi <no-reply@angidi.test>"})
+	err = m.Send(context.Background(), Message{To: "User <user@example.com>", Subject: "Verify", Body: "Click the link"})
+	require.NoError(t, err)
+	<-server.done
+
+	assert.Equal(t, "<no-reply@angidi.test>", server.from)
+	assert.Equal(t, []string{"<user@example.com>"}, server.to)
+	assert.Contains(t, server.data, "Subject: Verify\r\n")
+	assert.Contains(t, server.data, "\r\n\r\nClick the link\r\n")
+}
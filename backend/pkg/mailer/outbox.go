@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMailer writes each message to a .eml file in a directory instead of sending it,
+// so that local development and tests need no mail server
+type OutboxMailer struct {
+	dir  string
+	from string
+}
+
+// NewOutboxMailer creates a mailer that writes messages from the given sender to dir,
+// creating the directory if needed
+func NewOutboxMailer(dir, from string) (*OutboxMailer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &OutboxMailer{dir: dir, from: from}, nil
+}
+
+// Send writes msg to the outbox directory
+func (m *OutboxMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now().UTC()
+	data, err := msg.encode(m.from, now)
+	if err != nil {
+		return err
+	}
+
+	// Timestamped names keep the outbox in the order messages were sent
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000Z"), uuid.New().String())
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o600)
+}
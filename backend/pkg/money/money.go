@@ -0,0 +1,38 @@
+// Package money provides helpers for working with currency amounts
+package money
+
+import "math"
+
+// Round rounds an amount to two decimal places, half away from zero
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Allocate splits total across weights proportionally, rounding each share to two
+// decimal places. Any rounding remainder is assigned to the largest share so the
+// parts always sum to the rounded total.
+func Allocate(total float64, weights []float64) []float64 {
+	shares := make([]float64, len(weights))
+
+	var weightSum float64
+	for _, w := range weights {
+		weightSum += w
+	}
+	if weightSum <= 0 || len(weights) == 0 {
+		return shares
+	}
+
+	total = Round(total)
+	var allocated float64
+	largest := 0
+	for i, w := range weights {
+		shares[i] = Round(total * w / weightSum)
+		allocated += shares[i]
+		if w > weights[largest] {
+			largest = i
+		}
+	}
+	shares[largest] = Round(shares[largest] + total - allocated)
+
+	return shares
+}
@@ -0,0 +1,55 @@
+package money
+
+import (
+	"testing"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount float64
+		want   float64
+	}{
+		{name: "already rounded", amount: 10.5, want: 10.5},
+		{name: "rounds half up", amount: 1.005000001, want: 1.01},
+		{name: "rounds down", amount: 2.344, want: 2.34},
+		{name: "negative rounds away from zero", amount: -2.345000001, want: -2.35},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Round(tt.amount); got != tt.want {
+				t.Errorf("Round(%v) = %v, want %v", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   float64
+		weights []float64
+		want    []float64
+	}{
+		{name: "even split", total: 10, weights: []float64{1, 1}, want: []float64{5, 5}},
+		{name: "remainder goes to largest share", total: 10, weights: []float64{1, 1, 1}, want: []float64{3.34, 3.33, 3.33}},
+		{name: "proportional split", total: 6, weights: []float64{20, 40}, want: []float64{2, 4}},
+		{name: "zero weights", total: 5, weights: []float64{0, 0}, want: []float64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Allocate(tt.total, tt.weights)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Allocate() returned %d shares, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Allocate() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
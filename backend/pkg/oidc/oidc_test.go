@@ -0,0 +1,106 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost:3000/auth/callback"
+
+func setupProvider(t *testing.T) *oidctest.Provider {
+	provider, err := oidctest.NewProvider("shop", "shop-secret")
+	require.NoError(t, err)
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+func TestClient_AuthorizationCodeFlow(t *testing.T) {
+	provider := setupProvider(t)
+	client := oidc.NewClient(provider.Config(redirectURL), nil)
+	ctx := context.Background()
+
+	verifier, err := oidc.NewCodeVerifier()
+	require.NoError(t, err)
+	authURL, err := client.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	assert.Equal(t, oidc.CodeChallenge(verifier), parsed.Query().Get("code_challenge"))
+	assert.Equal(t, redirectURL, parsed.Query().Get("redirect_uri"))
+
+	code, state, err := provider.Login(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "state-1", state)
+
+	idToken, err := client.Exchange(ctx, code, verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, provider.Issuer(), idToken.Issuer)
+	assert.Equal(t, "oidctest-user", idToken.Subject)
+	assert.Equal(t, "oidc.user@example.com", idToken.Email)
+	assert.True(t, idToken.EmailVerified)
+	assert.Equal(t, "OIDC User", idToken.Name)
+
+	// Codes can only be exchanged once
+	_, err = client.Exchange(ctx, code, verifier, "nonce-1")
+	assert.True(t, errors.Is(err, oidc.ErrExchangeFailed))
+}
+
+func TestClient_RejectsMismatchedLogins(t *testing.T) {
+	provider := setupProvider(t)
+	client := oidc.NewClient(provider.Config(redirectURL), nil)
+	ctx := context.Background()
+
+	login := func(nonce string) (code, verifier string) {
+		verifier, err := oidc.NewCodeVerifier()
+		require.NoError(t, err)
+		authURL, err := client.AuthCodeURL(ctx, "state", nonce, verifier)
+		require.NoError(t, err)
+		code, _, err = provider.Login(authURL)
+		require.NoError(t, err)
+		return code, verifier
+	}
+
+	// Another code verifier than the one the login started with
+	code, _ := login("nonce")
+	other, err := oidc.NewCodeVerifier()
+	require.NoError(t, err)
+	_, err = client.Exchange(ctx, code, other, "nonce")
+	assert.True(t, errors.Is(err, oidc.ErrExchangeFailed))
+
+	// An ID token issued for another login
+	code, verifier := login("nonce")
+	_, err = client.Exchange(ctx, code, verifier, "other-nonce")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken))
+
+	// An ID token issued to another client
+	idToken, err := provider.IDToken("other-client", "nonce")
+	require.NoError(t, err)
+	_, err = client.VerifyIDToken(ctx, idToken, "nonce")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken))
+	idToken, err = provider.IDToken("shop", "nonce")
+	require.NoError(t, err)
+	_, err = client.VerifyIDToken(ctx, idToken, "nonce")
+	assert.NoError(t, err)
+	_, err = client.VerifyIDToken(ctx, idToken+"x", "nonce")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken))
+}
+
+func TestClient_DiscoveryChecksIssuer(t *testing.T) {
+	provider := setupProvider(t)
+	cfg := provider.Config(redirectURL)
+	cfg.IssuerURL += "/"
+	client := oidc.NewClient(cfg, nil)
+
+	_, err := client.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.Error(t, err)
+}
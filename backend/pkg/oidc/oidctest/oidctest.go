@@ -0,0 +1,252 @@
+// Package oidctest provides a local OpenID provider for testing OpenID Connect logins
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/response"
+)
+
+// keyID identifies the provider's signing key
+const keyID = "oidctest"
+
+// User is the account the provider signs in whoever visits its login page
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// authorization is an issued authorization code waiting to be exchanged
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	user          User
+}
+
+// Provider is an OpenID provider served by an httptest server. Its login page signs in
+// the current User without asking and sends them back with an authorization code, which
+// its token endpoint exchanges for an RS256 signed ID token.
+type Provider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key   *rsa.PrivateKey
+	mutex sync.Mutex
+	user  User
+	codes map[string]authorization
+}
+
+// NewProvider starts a provider with one registered client. Close it when done.
+func NewProvider(clientID, clientSecret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		user:         User{Subject: "oidctest-user", Email: "oidc.user@example.com", EmailVerified: true, Name: "OIDC User"},
+		codes:        make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+	return p, nil
+}
+
+// Issuer returns the provider's issuer identifier
+func (p *Provider) Issuer() string {
+	return p.Server.URL
+}
+
+// Config returns the configuration of a client registered with the provider
+func (p *Provider) Config(redirectURL string) oidc.Config {
+	return oidc.Config{IssuerURL: p.Issuer(), ClientID: p.ClientID, ClientSecret: p.ClientSecret, RedirectURL: redirectURL}
+}
+
+// SetUser changes the account the provider signs in
+func (p *Provider) SetUser(user User) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.user = user
+}
+
+// Close shuts the provider down
+func (p *Provider) Close() {
+	p.Server.Close()
+}
+
+// Login visits authURL like a browser and returns the authorization code and state the
+// provider sends the user back with
+func (p *Provider) Login(authURL string) (code, state string, err error) {
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("login page responded with status %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	if location.Query().Get("code") == "" {
+		return "", "", errors.New("no authorization code in redirect")
+	}
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+// IDToken returns an ID token for the current user issued to audience with nonce, as the
+// token endpoint would
+func (p *Provider) IDToken(audience, nonce string) (string, error) {
+	p.mutex.Lock()
+	user := p.user
+	p.mutex.Unlock()
+
+	return p.signIDToken(user, audience, nonce)
+}
+
+// signIDToken signs an ID token for user that expires in five minutes
+func (p *Provider) signIDToken(user User, audience, nonce string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.Issuer(),
+		"sub":            user.Subject,
+		"aud":            audience,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.Name,
+	})
+	token.Header["kid"] = keyID
+	return token.SignedString(p.key)
+}
+
+// discovery serves the discovery document
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	response.WriteJSON(w, http.StatusOK, oidc.Metadata{
+		Issuer:                        p.Issuer(),
+		AuthorizationEndpoint:         p.Issuer() + "/authorize",
+		TokenEndpoint:                 p.Issuer() + "/token",
+		JWKSURI:                       p.Issuer() + "/jwks",
+		CodeChallengeMethodsSupported: []string{"S256"},
+	})
+}
+
+// jwks serves the public signing key
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	public := p.key.PublicKey
+	response.WriteJSON(w, http.StatusOK, jwtPkg.JWKS{Keys: []jwtPkg.JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: keyID,
+		N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+	}}})
+}
+
+// authorize signs the current user in and redirects back with an authorization code
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	if query.Get("client_id") != p.ClientID || redirectURI == "" {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "authorization code flow with PKCE required", http.StatusBadRequest)
+		return
+	}
+
+	code := rand.Text()
+	p.mutex.Lock()
+	p.codes[code] = authorization{
+		clientID:      p.ClientID,
+		redirectURI:   redirectURI,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		user:          p.user,
+	}
+	p.mutex.Unlock()
+
+	back, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect uri", http.StatusBadRequest)
+		return
+	}
+	params := back.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	back.RawQuery = params.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+// token exchanges an authorization code for an ID token
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+	if clientID != p.ClientID || clientSecret != p.ClientSecret {
+		response.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	p.mutex.Lock()
+	grant, exists := p.codes[code]
+	delete(p.codes, code)
+	p.mutex.Unlock()
+
+	if r.PostFormValue("grant_type") != "authorization_code" || !exists ||
+		r.PostFormValue("redirect_uri") != grant.redirectURI ||
+		oidc.CodeChallenge(r.PostFormValue("code_verifier")) != grant.codeChallenge {
+		response.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := p.signIDToken(grant.user, grant.clientID, grant.nonce)
+	if err != nil {
+		response.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
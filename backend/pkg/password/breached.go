@@ -0,0 +1,84 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" // #nosec G505 - breached password lists are keyed by SHA-1, not used for storage
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefixLength is how many hex characters of a SHA-1 hash select a range, as in the Pwned
+// Passwords range API
+const prefixLength = 5
+
+// RangeSource looks up breached passwords by k-anonymity: given the first five hex
+// characters of a SHA-1 hash it returns the remaining characters of every breached
+// password hash with that prefix. A remote source never learns the full hash.
+type RangeSource interface {
+	Range(ctx context.Context, prefix string) ([]string, error)
+}
+
+// IsBreached reports whether source lists password as breached
+func IsBreached(ctx context.Context, source RangeSource, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password)) // #nosec G401 - lookup key, not a password hash
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := source.Range(ctx, hash[:prefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if suffix == hash[prefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FileSource is a RangeSource backed by a local list of SHA-1 hashes
+type FileSource struct {
+	ranges map[string][]string
+}
+
+// LoadFile reads a list of uppercase or lowercase hex SHA-1 password hashes, one per
+// line, such as a Pwned Passwords download. Text after a colon, like the breach count in
+// those downloads, blank lines and lines starting with # are ignored.
+func LoadFile(path string) (*FileSource, error) {
+	f, err := os.Open(path) // #nosec G304 - path comes from trusted configuration
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	source := &FileSource{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(strings.TrimSpace(hash))
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, line)
+		}
+		prefix := hash[:prefixLength]
+		source.ranges[prefix] = append(source.ranges[prefix], hash[prefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// Range returns the hash suffixes listed under prefix
+func (s *FileSource) Range(ctx context.Context, prefix string) ([]string, error) {
+	return s.ranges[strings.ToUpper(prefix)], nil
+}
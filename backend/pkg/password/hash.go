@@ -0,0 +1,192 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUnknownHash is returned when a stored hash was not made by any known hasher
+	ErrUnknownHash = errors.New("unknown password hash format")
+	// ErrMalformedHash is returned when a stored hash cannot be decoded
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// Hasher hashes passwords with one algorithm. Hashes encode the algorithm and its
+// parameters, so they can be verified after the parameters change.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Recognizes reports whether encoded was made with this hasher's algorithm
+	Recognizes(encoded string) bool
+	// Verify reports whether password matches encoded, a hash the hasher recognizes
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether encoded was made with other parameters than the hasher's
+	NeedsRehash(encoded string) bool
+}
+
+// Hashers hashes new passwords with a preferred hasher and verifies hashes made by any
+// of the known ones
+type Hashers struct {
+	preferred Hasher
+	known     []Hasher
+}
+
+// NewHashers returns Hashers that hash with preferred and also verify hashes from others
+func NewHashers(preferred Hasher, others ...Hasher) *Hashers {
+	return &Hashers{preferred: preferred, known: append([]Hasher{preferred}, others...)}
+}
+
+// Hash hashes password with the preferred hasher
+func (h *Hashers) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify reports whether password matches encoded, and whether encoded should be
+// replaced by a hash from the preferred hasher because it uses another algorithm or
+// outdated parameters
+func (h *Hashers) Verify(encoded, password string) (ok, rehash bool, err error) {
+	for _, hasher := range h.known {
+		if !hasher.Recognizes(encoded) {
+			continue
+		}
+		ok, err := hasher.Verify(encoded, password)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, hasher != h.preferred || hasher.NeedsRehash(encoded), nil
+	}
+	return false, false, ErrUnknownHash
+}
+
+// BcryptHasher hashes passwords with bcrypt at Cost
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash hashes password with bcrypt
+func (b BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Recognizes reports whether encoded is a bcrypt hash
+func (b BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// Verify reports whether password matches the bcrypt hash encoded
+func (b BcryptHasher) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NeedsRehash reports whether encoded uses another cost
+func (b BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.Cost
+}
+
+// Argon2idHasher hashes passwords with Argon2id, encoding hashes in the PHC string format
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// argon2idPrefix starts every Argon2id hash
+const argon2idPrefix = "$argon2id$"
+
+// argon2idParams are the parameters decoded from an Argon2id hash
+type argon2idParams struct {
+	memory, iterations uint32
+	parallelism        uint8
+	salt, key          []byte
+}
+
+// Hash hashes password with Argon2id and a random salt
+func (a Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Recognizes reports whether encoded is an Argon2id hash
+func (a Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+// Verify reports whether password matches the Argon2id hash encoded, using the
+// parameters stored in it
+func (a Argon2idHasher) Verify(encoded, password string) (bool, error) {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+// NeedsRehash reports whether encoded uses other parameters
+func (a Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory != a.Memory || params.iterations != a.Iterations || params.parallelism != a.Parallelism ||
+		uint32(len(params.salt)) != a.SaltLength || uint32(len(params.key)) != a.KeyLength
+}
+
+// decodeArgon2id parses an Argon2id hash in the PHC string format
+func decodeArgon2id(encoded string) (*argon2idParams, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrMalformedHash
+	}
+
+	params := &argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, ErrMalformedHash
+	}
+	if params.iterations == 0 || params.parallelism == 0 {
+		return nil, ErrMalformedHash
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrMalformedHash
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, ErrMalformedHash
+	}
+	return params, nil
+}
@@ -0,0 +1,58 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testArgon2id uses small parameters to keep tests fast
+var testArgon2id = Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashers_Verify(t *testing.T) {
+	hashers := NewHashers(testArgon2id, BcryptHasher{Cost: 4})
+
+	argonHash, err := hashers.Hash("SecurePass123!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	ok, rehash, err := hashers.Verify(argonHash, "SecurePass123!")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, _, err = hashers.Verify(argonHash, "WrongPass123!")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Hashes from another known algorithm verify but should be replaced
+	bcryptHash, err := BcryptHasher{Cost: 4}.Hash("SecurePass123!")
+	require.NoError(t, err)
+	ok, rehash, err = hashers.Verify(bcryptHash, "SecurePass123!")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	// So should hashes with outdated parameters
+	stronger := testArgon2id
+	stronger.Iterations = 2
+	ok, rehash, err = NewHashers(stronger).Verify(argonHash, "SecurePass123!")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	_, _, err = hashers.Verify("plaintext", "plaintext")
+	assert.Equal(t, ErrUnknownHash, err)
+	_, _, err = hashers.Verify("$argon2id$v=19$m=1024$bad", "SecurePass123!")
+	assert.Equal(t, ErrMalformedHash, err)
+}
+
+func TestBcryptHasher_NeedsRehash(t *testing.T) {
+	hash, err := BcryptHasher{Cost: 4}.Hash("SecurePass123!")
+	require.NoError(t, err)
+
+	assert.False(t, BcryptHasher{Cost: 4}.NeedsRehash(hash))
+	assert.True(t, BcryptHasher{Cost: 5}.NeedsRehash(hash))
+}
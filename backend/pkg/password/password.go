@@ -0,0 +1,138 @@
+// Package password checks new passwords against a configurable policy and hashes them
+// for storage
+package password
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violations reported by Check, named like the validator tags used for other fields
+const (
+	ViolationMin          = "min"
+	ViolationMax          = "max"
+	ViolationLowercase    = "lowercase"
+	ViolationUppercase    = "uppercase"
+	ViolationDigit        = "digit"
+	ViolationSymbol       = "symbol"
+	ViolationPersonalInfo = "personal_info"
+	ViolationBreached     = "breached"
+)
+
+// minPersonalInfoLength is the shortest part of an email or name that passwords may not contain
+const minPersonalInfoLength = 3
+
+// Policy describes which passwords are accepted. Lengths count characters, not bytes.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MinAdminLength applies to admin accounts when it is longer than MinLength
+	MinAdminLength   int
+	RequireLowercase bool
+	RequireUppercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	// DisallowPersonalInfo rejects passwords containing the user's email address, the part
+	// of it before the @ or a word of their name
+	DisallowPersonalInfo bool
+	// Breached rejects passwords found in a breached password list when set
+	Breached RangeSource
+}
+
+// PolicyError is returned by Check when a password violates the policy
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("password violates policy: %s", strings.Join(e.Violations, ", "))
+}
+
+// Check returns a PolicyError listing every rule password violates. personal holds the
+// email address and name of the account the password is for.
+func (p Policy) Check(ctx context.Context, password string, admin bool, personal ...string) error {
+	var violations []string
+
+	minLength := p.MinLength
+	if admin && p.MinAdminLength > minLength {
+		minLength = p.MinAdminLength
+	}
+	length := utf8.RuneCountInString(password)
+	if length < minLength {
+		violations = append(violations, ViolationMin)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, ViolationMax)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireLowercase && !lower {
+		violations = append(violations, ViolationLowercase)
+	}
+	if p.RequireUppercase && !upper {
+		violations = append(violations, ViolationUppercase)
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, ViolationDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, ViolationSymbol)
+	}
+
+	if p.DisallowPersonalInfo && containsPersonalInfo(password, personal) {
+		violations = append(violations, ViolationPersonalInfo)
+	}
+
+	if p.Breached != nil {
+		breached, err := IsBreached(ctx, p.Breached, password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, ViolationBreached)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether password contains an email address from personal,
+// its local part or a word of a name, ignoring case
+func containsPersonalInfo(password string, personal []string) bool {
+	password = strings.ToLower(password)
+
+	var parts []string
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			parts = append(parts, value, local)
+			continue
+		}
+		parts = append(parts, strings.Fields(value)...)
+	}
+
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minPersonalInfoLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
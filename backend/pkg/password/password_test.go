@@ -0,0 +1,88 @@
+package password
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	policy := Policy{
+		MinLength:            8,
+		MaxLength:            20,
+		MinAdminLength:       12,
+		RequireLowercase:     true,
+		RequireUppercase:     true,
+		RequireDigit:         true,
+		RequireSymbol:        true,
+		DisallowPersonalInfo: true,
+	}
+	personal := []string{"jane.doe@example.com", "Jane Doe"}
+
+	tests := []struct {
+		name     string
+		password string
+		admin    bool
+		want     []string
+	}{
+		{name: "valid", password: "Correct-Horse7", want: nil},
+		{name: "too short", password: "Ab1!", want: []string{ViolationMin}},
+		{name: "too short for admins", password: "Correct-Ho7", admin: true, want: []string{ViolationMin}},
+		{name: "too long", password: "Correct-Horse7-Battery-Staple", want: []string{ViolationMax}},
+		{name: "lengths count characters", password: "Ünïcödé-Pässwörd7", want: nil},
+		{name: "missing classes", password: "correcthorse", want: []string{ViolationUppercase, ViolationDigit, ViolationSymbol}},
+		{name: "contains email", password: "Jane.Doe@example.com1", want: []string{ViolationMax, ViolationPersonalInfo}},
+		{name: "contains email local part", password: "My-jane.doe-9", want: []string{ViolationPersonalInfo}},
+		{name: "contains name", password: "Doe-Family-2024", want: []string{ViolationPersonalInfo}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(context.Background(), tt.password, tt.admin, personal...)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var policyErr *PolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, tt.want, policyErr.Violations)
+		})
+	}
+}
+
+func TestPolicy_Breached(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	list := "# SHA-1 of password and Password123\n" +
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n" +
+		"b2e98ad6f6eb8508dd6a14cfa704bad7f05f6fb1\n\n"
+	require.NoError(t, os.WriteFile(path, []byte(list), 0o600))
+
+	source, err := LoadFile(path)
+	require.NoError(t, err)
+
+	suffixes, err := source.Range(context.Background(), "5baa6")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1E4C9B93F3F0682250B6CF8331B7EE68FD8"}, suffixes)
+
+	policy := Policy{MinLength: 8, Breached: source}
+	var policyErr *PolicyError
+	require.ErrorAs(t, policy.Check(context.Background(), "password", false), &policyErr)
+	assert.Equal(t, []string{ViolationBreached}, policyErr.Violations)
+	require.ErrorAs(t, policy.Check(context.Background(), "Password123", false), &policyErr)
+	assert.NoError(t, policy.Check(context.Background(), "Correct-Horse7", false))
+}
+
+func TestLoadFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte("not-a-hash\n"), 0o600))
+
+	_, err := LoadFile(path)
+	assert.Error(t, err)
+
+	_, err = LoadFile(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
@@ -5,9 +5,17 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,30 +23,96 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/checkout"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/gateway"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/oauth"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/order"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/payment"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/privacy"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/product"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/promotion"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/returns"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/seller"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/shipping"
+	"github.com/yesoreyeram/angidi-demo-app/backend/internal/tax"
 	"github.com/yesoreyeram/angidi-demo-app/backend/internal/user"
 	jwtPkg "github.com/yesoreyeram/angidi-demo-app/backend/pkg/jwt"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/mailer"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/oidc/oidctest"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/password"
+	"github.com/yesoreyeram/angidi-demo-app/backend/pkg/totp"
 )
 
 func setupTestServer(t *testing.T) *httptest.Server {
+	server, _ := setupTestServerWithConfig(t, user.Config{})
+	return server
+}
+
+// setupTestServerWithConfig starts a server with the given account policy and returns it
+// along with the directory account emails are written to
+func setupTestServerWithConfig(t *testing.T, userConfig user.Config) (*httptest.Server, string) {
 	zapLogger, err := zap.NewDevelopment()
 	require.NoError(t, err)
 
+	outboxDir := t.TempDir()
+	outbox, err := mailer.NewOutboxMailer(outboxDir, "no-reply@angidi.test")
+	require.NoError(t, err)
+
 	jwtService := jwtPkg.NewService("test-secret-key", 15*time.Minute, 7*24*time.Hour)
 
 	userRepo := user.NewInMemoryRepository()
 	productRepo := product.NewInMemoryRepository()
 
-	userService := user.NewService(userRepo, jwtService, zapLogger)
+	userService := user.NewService(userRepo, user.NewInMemorySessionRepository(), user.NewInMemoryPasswordResetRepository(), user.NewInMemoryLoginAttemptRepository(), user.NewInMemoryAPIKeyRepository(), user.NewInMemoryOIDCLoginRepository(), user.NewInMemoryAddressRepository(), jwtService, outbox, userConfig, zapLogger)
 	productService := product.NewService(productRepo, zapLogger)
-
-	userHandler := user.NewHandler(userService, zapLogger)
+	promotionService := promotion.NewService(promotion.NewInMemoryRepository(), zapLogger)
+	checkoutService := checkout.NewService(productService, promotionService, tax.NewCalculator(tax.Config{}), shipping.NewCalculator(shipping.Config{}), zapLogger)
+	orderService := order.NewService(order.NewInMemoryRepository(), checkoutService, productService, promotionService, zapLogger)
+	paymentService := payment.NewService(payment.NewInMemoryRepository(), payment.NewMockProvider("test-webhook-secret", 0), orderService, zapLogger)
+	returnService := returns.NewService(returns.NewInMemoryRepository(), order.NewReturnsReader(orderService), productService, paymentService, 0, zapLogger)
+	oauthService := oauth.NewService(oauth.NewInMemoryRepository(), userService, jwtService, zapLogger)
+	sellerService := seller.NewService(seller.NewInMemoryRepository(), userService, productService, zapLogger)
+	privacyService := privacy.NewService(privacy.NewInMemoryRepository(), 30*24*time.Hour, zapLogger)
+	privacyService.Register("orders", orderService)
+	privacyService.Register("payments", paymentService)
+	privacyService.Register("returns", returnService)
+	privacyService.Register("promotions", promotionService)
+	privacyService.Register("oauth_consents", oauthService)
+	privacyService.Register("seller", sellerService)
+	privacyService.Register("account", userService)
+
+	// Creates an admin only when a test sets ADMIN_EMAIL and ADMIN_PASSWORD
+	require.NoError(t, userService.BootstrapAdmin(context.Background()))
+
+	userHandler := user.NewHandler(userService, privacyService, zapLogger)
 	productHandler := product.NewHandler(productService, zapLogger)
-
-	router := gateway.Router(userHandler, productHandler, jwtService, zapLogger)
-
-	return httptest.NewServer(router)
+	paymentHandler := payment.NewHandler(paymentService, zapLogger)
+	promotionHandler := promotion.NewHandler(promotionService, zapLogger)
+	checkoutHandler := checkout.NewHandler(checkoutService, zapLogger)
+	orderHandler := order.NewHandler(orderService, zapLogger)
+	returnHandler := returns.NewHandler(returnService, zapLogger)
+	oauthHandler := oauth.NewHandler(oauthService, zapLogger)
+	privacyHandler := privacy.NewHandler(privacyService, zapLogger)
+	sellerHandler := seller.NewHandler(sellerService, zapLogger)
+
+	router := gateway.Router(
+		userHandler,
+		productHandler,
+		paymentHandler,
+		promotionHandler,
+		checkoutHandler,
+		orderHandler,
+		returnHandler,
+		oauthHandler,
+		privacyHandler,
+		sellerHandler,
+		jwtService,
+		userService,
+		zapLogger,
+	)
+
+	return httptest.NewServer(router), outboxDir
 }
 
 func TestHealthEndpoint_Integration(t *testing.T) {
@@ -60,6 +134,23 @@ func TestHealthEndpoint_Integration(t *testing.T) {
 	assert.Equal(t, "healthy", data["status"])
 }
 
+func TestJWKS_Integration(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/.well-known/jwks.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var jwks map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&jwks)
+	require.NoError(t, err)
+	// The test server signs with a shared secret, which is never published
+	assert.Equal(t, []interface{}{}, jwks["keys"])
+}
+
 func TestUserRegistrationFlow_Integration(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Close()
@@ -199,6 +290,18 @@ func TestProtectedEndpoints_Integration(t *testing.T) {
 	defer resp.Body.Close()
 
 	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Test accessing protected endpoint with a refresh token fails
+	req, _ = http.NewRequest("GET", server.URL+"/api/v1/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+authData["refresh_token"].(string))
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	var errorResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&errorResult)
+	assert.Equal(t, "WRONG_TOKEN_TYPE", errorResult["error"].(map[string]interface{})["code"])
 }
 
 func TestProductCRUD_Integration(t *testing.T) {
@@ -289,6 +392,680 @@ func TestRefreshToken_Integration(t *testing.T) {
 	newAuthData := refreshResult["data"].(map[string]interface{})
 	assert.NotEmpty(t, newAuthData["access_token"])
 	assert.NotEmpty(t, newAuthData["refresh_token"])
+
+	// Replaying the rotated token is rejected and revokes the new one too
+	body, _ = json.Marshal(refreshPayload)
+	replay, err := http.Post(server.URL+"/api/v1/users/refresh-token", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer replay.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, replay.StatusCode)
+
+	body, _ = json.Marshal(map[string]string{"refresh_token": newAuthData["refresh_token"].(string)})
+	revoked, err := http.Post(server.URL+"/api/v1/users/refresh-token", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer revoked.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, revoked.StatusCode)
+}
+
+func TestLogout_Integration(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Close()
+
+	registerPayload := map[string]string{
+		"email":    "logout@test.com",
+		"password": "SecurePass123!",
+		"name":     "Logout Test User",
+	}
+	body, _ := json.Marshal(registerPayload)
+	resp, err := http.Post(server.URL+"/api/v1/users/register", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	loginPayload := map[string]string{
+		"email":    "logout@test.com",
+		"password": "SecurePass123!",
+	}
+	body, _ = json.Marshal(loginPayload)
+	resp, err = http.Post(server.URL+"/api/v1/users/login", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+
+	var loginResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&loginResult)
+	resp.Body.Close()
+	refreshToken := loginResult["data"].(map[string]interface{})["refresh_token"].(string)
+
+	body, _ = json.Marshal(map[string]string{"refresh_token": refreshToken})
+	resp, err = http.Post(server.URL+"/api/v1/users/logout", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// The refresh token no longer works after logout
+	body, _ = json.Marshal(map[string]string{"refresh_token": refreshToken})
+	resp, err = http.Post(server.URL+"/api/v1/users/refresh-token", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSessions_Integration(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Close()
+	client := &http.Client{}
+
+	registerPayload := map[string]string{
+		"email":    "sessions@test.com",
+		"password": "SecurePass123!",
+		"name":     "Sessions Test User",
+	}
+	body, _ := json.Marshal(registerPayload)
+	resp, err := http.Post(server.URL+"/api/v1/users/register", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// Log in from two devices
+	login := func(userAgent string) map[string]interface{} {
+		body, _ := json.Marshal(map[string]string{"email": "sessions@test.com", "password": "SecurePass123!"})
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/users/login", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result["data"].(map[string]interface{})
+	}
+	laptop := login("Laptop/1.0")
+	phone := login("Phone/1.0")
+	accessToken := laptop["access_token"].(string)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/users/me/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var listResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&listResult)
+	sessions := listResult["data"].([]interface{})
+	require.Len(t, sessions, 2)
+	phoneSession := sessions[0].(map[string]interface{})
+	assert.Equal(t, "Phone/1.0", phoneSession["user_agent"])
+	assert.Equal(t, "127.0.0.1", phoneSession["ip_address"])
+
+	// Sign the phone out from the laptop
+	req, _ = http.NewRequest(http.MethodDelete, server.URL+"/api/v1/users/me/sessions/"+phoneSession["id"].(string), nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	body, _ = json.Marshal(map[string]string{"refresh_token": phone["refresh_token"].(string)})
+	resp, err = http.Post(server.URL+"/api/v1/users/refresh-token", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// waitForEmail waits until the most recent email in the outbox has the given subject
+func waitForEmail(t *testing.T, outboxDir, subject string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		files, err := os.ReadDir(outboxDir)
+		if err != nil || len(files) == 0 {
+			return false
+		}
+		data, err := os.ReadFile(filepath.Join(outboxDir, files[len(files)-1].Name()))
+		return err == nil && strings.Contains(string(data), "\r\nSubject: "+subject+"\r\n")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// outboxToken returns the token from the link in the most recent email in the outbox
+func outboxToken(t *testing.T, outboxDir string) string {
+	t.Helper()
+	files, err := os.ReadDir(outboxDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	data, err := os.ReadFile(filepath.Join(outboxDir, files[len(files)-1].Name()))
+	require.NoError(t, err)
+
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if link, err := url.Parse(line); err == nil && link.Query().Get("token") != "" {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no token link in %s", data)
+	return ""
+}
+
+func TestEmailVerification_Integration(t *testing.T) {
+	server, outboxDir := setupTestServerWithConfig(t, user.Config{
+		EmailVerification: user.VerificationRequiredForActions,
+		VerifyEmailURL:    "http://localhost:3000/verify-email",
+	})
+	defer server.Close()
+	client := &http.Client{}
+
+	registerPayload := map[string]string{
+		"email":    "verify@test.com",
+		"password": "SecurePass123!",
+		"name":     "Verify Test User",
+	}
+	body, _ := json.Marshal(registerPayload)
+	resp, err := http.Post(server.URL+"/api/v1/users/register", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	login := func() string {
+		body, _ := json.Marshal(map[string]string{"email": "verify@test.com", "password": "SecurePass123!"})
+		resp, err := http.Post(server.URL+"/api/v1/users/login", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result["data"].(map[string]interface{})["access_token"].(string)
+	}
+	createReturn := func(accessToken string) int {
+		body, _ := json.Marshal(map[string]interface{}{})
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/returns", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Unverified users can log in but not request returns
+	assert.Equal(t, http.StatusForbidden, createReturn(login()))
+
+	body, _ = json.Marshal(map[string]string{"token": outboxToken(t, outboxDir)})
+	resp, err = http.Post(server.URL+"/api/v1/users/verify-email", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	var verifyResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&verifyResult)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, verifyResult["data"].(map[string]interface{})["email_verified"])
+
+	// Tokens are single use
+	resp, err = http.Post(server.URL+"/api/v1/users/verify-email", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Verified users get past the check and on to request validation
+	assert.Equal(t, http.StatusBadRequest, createReturn(login()))
+
+	// Resending never reveals whether an account exists
+	for _, email := range []string{"verify@test.com", "nobody@test.com"} {
+		body, _ = json.Marshal(map[string]string{"email": email})
+		resp, err = http.Post(server.URL+"/api/v1/users/verify-email/resend", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	}
+}
+
+func TestPasswordReset_Integration(t *testing.T) {
+	server, outboxDir := setupTestServerWithConfig(t, user.Config{ResetPasswordURL: "http://localhost:3000/reset-password"})
+	defer server.Close()
+
+	registerPayload := map[string]string{
+		"email":    "reset@test.com",
+		"password": "SecurePass123!",
+		"name":     "Reset Test User",
+	}
+	body, _ := json.Marshal(registerPayload)
+	resp, err := http.Post(server.URL+"/api/v1/users/register", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	login := func(password string) *http.Response {
+		body, _ := json.Marshal(map[string]string{"email": "reset@test.com", "password": password})
+		resp, err := http.Post(server.URL+"/api/v1/users/login", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	loginResp := login("SecurePass123!")
+	require.Equal(t, http.StatusOK, loginResp.StatusCode)
+
+	// Known and unknown addresses get the same response
+	for _, email := range []string{"nobody@test.com", "reset@test.com"} {
+		body, _ = json.Marshal(map[string]string{"email": email})
+		resp, err = http.Post(server.URL+"/api/v1/users/password/forgot", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	}
+
+	// Reset links are emailed in the background
+	waitForEmail(t, outboxDir, "Reset your password")
+	body, _ = json.Marshal(map[string]string{"token": outboxToken(t, outboxDir), "password": "NewSecurePass456!"})
+	resp, err = http.Post(server.URL+"/api/v1/users/password/reset", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// The link works once
+	resp, err = http.Post(server.URL+"/api/v1/users/password/reset", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	assert.Equal(t, http.StatusUnauthorized, login("SecurePass123!").StatusCode)
+	assert.Equal(t, http.StatusOK, login("NewSecurePass456!").StatusCode)
+}
+
+func TestChangeCredentials_Integration(t *testing.T) {
+	server, outboxDir := setupTestServerWithConfig(t, user.Config{
+		VerifyEmailURL:        "http://localhost:3000/verify-email",
+		ConfirmEmailChangeURL: "http://localhost:3000/confirm-email",
+	})
+	defer server.Close()
+	client := &http.Client{}
+
+	registerPayload := map[string]string{
+		"email":    "change@test.com",
+		"password": "SecurePass123!",
+		"name":     "Change Test User",
+	}
+	body, _ := json.Marshal(registerPayload)
+	resp, err := http.Post(server.URL+"/api/v1/users/register", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	body, _ = json.Marshal(map[string]string{"email": "change@test.com", "password": "SecurePass123!"})
+	resp, err = http.Post(server.URL+"/api/v1/users/login", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	var loginResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&loginResult)
+	resp.Body.Close()
+	accessToken := loginResult["data"].(map[string]interface{})["access_token"].(string)
+
+	send := func(method, path string, payload interface{}) *http.Response {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp = send(http.MethodPut, "/api/v1/users/me/password", map[string]string{"current_password": "WrongPass123!", "new_password": "NewSecurePass456!"})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = send(http.MethodPut, "/api/v1/users/me/password", map[string]string{"current_password": "SecurePass123!", "new_password": "SecurePass123!"})
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = send(http.MethodPut, "/api/v1/users/me/password", map[string]string{"current_password": "SecurePass123!", "new_password": "NewSecurePass456!"})
+	var changeResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&changeResult)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, changeResult["data"].(map[string]interface{})["refresh_token"])
+
+	resp = send(http.MethodPost, "/api/v1/users/me/email", map[string]string{"new_email": "changed@test.com", "current_password": "NewSecurePass456!"})
+	var emailResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&emailResult)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "changed@test.com", emailResult["data"].(map[string]interface{})["pending_email"])
+
+	body, _ = json.Marshal(map[string]string{"token": outboxToken(t, outboxDir)})
+	resp, err = http.Post(server.URL+"/api/v1/users/email/confirm", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	var confirmResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&confirmResult)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "changed@test.com", confirmResult["data"].(map[string]interface{})["email"])
+
+	body, _ = json.Marshal(map[string]string{"email": "changed@test.com", "password": "NewSecurePass456!"})
+	resp, err = http.Post(server.URL+"/api/v1/users/login", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAdminMFA_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server, _ := setupTestServerWithConfig(t, user.Config{RequireMFAForAdmins: true})
+	defer server.Close()
+	client := &http.Client{}
+
+	send := func(method, path, accessToken string, payload interface{}) (*http.Response, map[string]interface{}) {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	login := map[string]string{"email": "admin@test.com", "password": "AdminSecurePass123!"}
+
+	// An admin without MFA can sign in to enroll, but not use admin routes
+	resp, result := send(http.MethodPost, "/api/v1/users/login", "", login)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	accessToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	resp, result = send(http.MethodDelete, "/api/v1/products/missing", accessToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "MFA_REQUIRED", result["error"].(map[string]interface{})["code"])
+
+	resp, result = send(http.MethodPost, "/api/v1/users/me/mfa/totp", accessToken, map[string]string{"current_password": "AdminSecurePass123!"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	enrollment := result["data"].(map[string]interface{})
+	assert.Contains(t, enrollment["otpauth_uri"], "otpauth://totp/")
+	secret := enrollment["secret"].(string)
+
+	now := time.Now()
+	code, err := totp.Code(secret, now)
+	require.NoError(t, err)
+	resp, result = send(http.MethodPost, "/api/v1/users/me/mfa/totp/confirm", accessToken, map[string]string{"code": code})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, result["data"].(map[string]interface{})["recovery_codes"], 10)
+
+	// Admins cannot turn MFA off while it is required
+	resp, result = send(http.MethodPost, "/api/v1/users/me/mfa/disable", accessToken, map[string]string{"current_password": "AdminSecurePass123!", "code": code})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "MFA_REQUIRED", result["error"].(map[string]interface{})["code"])
+
+	// The password alone now only yields a challenge
+	resp, result = send(http.MethodPost, "/api/v1/users/login", "", login)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	challenge := result["data"].(map[string]interface{})
+	assert.Equal(t, true, challenge["mfa_required"])
+	assert.Nil(t, challenge["access_token"])
+
+	resp, result = send(http.MethodPost, "/api/v1/users/login/mfa", "", map[string]string{"mfa_token": challenge["mfa_token"].(string), "code": "000000"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "INVALID_MFA_CODE", result["error"].(map[string]interface{})["code"])
+
+	next, err := totp.Code(secret, now.Add(totp.Period))
+	require.NoError(t, err)
+	resp, result = send(http.MethodPost, "/api/v1/users/login/mfa", "", map[string]string{"mfa_token": challenge["mfa_token"].(string), "code": next})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	accessToken = result["data"].(map[string]interface{})["access_token"].(string)
+
+	resp, _ = send(http.MethodDelete, "/api/v1/products/missing", accessToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// API keys are not a second factor, even when created after one
+	resp, result = send(http.MethodPost, "/api/v1/users/me/api-keys", accessToken, map[string]interface{}{"name": "Scripts"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, result = send(http.MethodPost, "/api/v1/users/me/api-keys", accessToken, map[string]interface{}{"name": "Scripts", "scopes": []string{"product:write"}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/api/v1/products/missing", nil)
+	req.Header.Set("X-API-Key", result["data"].(map[string]interface{})["key"].(string))
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "MFA_REQUIRED", result["error"].(map[string]interface{})["code"])
+}
+
+func TestLoginLockout_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server, _ := setupTestServerWithConfig(t, user.Config{LockoutThreshold: 3, LoginBackoffAfter: 3, LockoutDuration: time.Hour})
+	defer server.Close()
+
+	login := func(email, password string) (*http.Response, map[string]interface{}) {
+		body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+		resp, err := http.Post(server.URL+"/api/v1/users/login", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	body, _ := json.Marshal(map[string]string{"email": "locked@test.com", "password": "SecurePass123!", "name": "Locked User"})
+	resp, err := http.Post(server.URL+"/api/v1/users/register", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	var registerResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&registerResult)
+	resp.Body.Close()
+	userID := registerResult["data"].(map[string]interface{})["id"].(string)
+
+	for i := 0; i < 3; i++ {
+		resp, _ := login("locked@test.com", "WrongPass123!")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	resp, result := login("locked@test.com", "SecurePass123!")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "ACCOUNT_LOCKED", result["error"].(map[string]interface{})["code"])
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, 3600, retryAfter, 5)
+
+	resp, result = login("admin@test.com", "AdminSecurePass123!")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	adminToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/admin/users/"+userID+"/unlock", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, _ = login("locked@test.com", "SecurePass123!")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestPasswordPolicy_Integration(t *testing.T) {
+	server, _ := setupTestServerWithConfig(t, user.Config{
+		PasswordPolicy: password.Policy{RequireUppercase: true, RequireDigit: true, DisallowPersonalInfo: true},
+	})
+	defer server.Close()
+
+	register := func(pw string) (*http.Response, map[string]interface{}) {
+		body, _ := json.Marshal(map[string]string{"email": "policy@test.com", "password": pw, "name": "Policy User"})
+		resp, err := http.Post(server.URL+"/api/v1/users/register", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	resp, result := register("policy-password")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	errorResult := result["error"].(map[string]interface{})
+	assert.Equal(t, "VALIDATION_ERROR", errorResult["code"])
+	var messages []string
+	for _, detail := range errorResult["details"].([]interface{}) {
+		assert.Equal(t, "Password", detail.(map[string]interface{})["field"])
+		messages = append(messages, detail.(map[string]interface{})["message"].(string))
+	}
+	assert.Equal(t, []string{"uppercase", "digit", "personal_info"}, messages)
+
+	resp, _ = register("SecurePass123!")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestRolePermissions_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server, _ := setupTestServerWithConfig(t, user.Config{})
+	defer server.Close()
+
+	do := func(method, path, token string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	login := func(email, password string) map[string]interface{} {
+		resp, result := do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": email, "password": password})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return result["data"].(map[string]interface{})
+	}
+
+	admin := login("admin@test.com", "AdminSecurePass123!")
+	adminToken := admin["access_token"].(string)
+	adminID := admin["user"].(map[string]interface{})["id"].(string)
+
+	resp, result := do(http.MethodPost, "/api/v1/users/register", "", map[string]string{"email": "editor@test.com", "password": "SecurePass123!", "name": "Catalog Editor"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	editorID := result["data"].(map[string]interface{})["id"].(string)
+	product := map[string]interface{}{"name": "Desk Lamp", "description": "A lamp", "price": 19.99, "stock": 5, "category_id": "lighting"}
+
+	editorToken := login("editor@test.com", "SecurePass123!")["access_token"].(string)
+	resp, _ = do(http.MethodPost, "/api/v1/products", editorToken, product)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, result = do(http.MethodGet, "/api/v1/admin/roles", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, result["data"])
+
+	resp, result = do(http.MethodPut, "/api/v1/admin/users/"+editorID+"/roles", adminToken, map[string]interface{}{"roles": []string{"user", "catalog_editor"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, result["data"].(map[string]interface{})["permissions"], "product:write")
+
+	// The new roles apply to access tokens issued after the change
+	editor := login("editor@test.com", "SecurePass123!")
+	editorToken = editor["access_token"].(string)
+	assert.Equal(t, []interface{}{"user", "catalog_editor"}, editor["user"].(map[string]interface{})["roles"])
+	resp, _ = do(http.MethodPost, "/api/v1/products", editorToken, product)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "/api/v1/admin/users/"+editorID+"/roles", editorToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = do(http.MethodPut, "/api/v1/admin/users/"+editorID+"/roles", editorToken, map[string]interface{}{"roles": []string{"admin"}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, result = do(http.MethodPut, "/api/v1/admin/users/"+editorID+"/roles", adminToken, map[string]interface{}{"roles": []string{"superuser"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "UNKNOWN_ROLE", result["error"].(map[string]interface{})["code"])
+	resp, result = do(http.MethodPut, "/api/v1/admin/users/"+adminID+"/roles", adminToken, map[string]interface{}{"roles": []string{"user"}})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "LAST_ADMIN", result["error"].(map[string]interface{})["code"])
+	resp, _ = do(http.MethodPut, "/api/v1/admin/users/"+editorID+"/roles", adminToken, map[string]interface{}{"roles": []string{}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAdminUserManagement_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server, outboxDir := setupTestServerWithConfig(t, user.Config{ResetPasswordURL: "http://localhost:3000/reset-password"})
+	defer server.Close()
+
+	do := func(method, path, token string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	login := func(email, password string) (*http.Response, map[string]interface{}) {
+		return do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": email, "password": password})
+	}
+	errorCode := func(result map[string]interface{}) interface{} {
+		return result["error"].(map[string]interface{})["code"]
+	}
+
+	_, result := login("admin@test.com", "AdminSecurePass123!")
+	adminToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	resp, result := do(http.MethodPost, "/api/v1/users/register", "", map[string]string{"email": "managed@test.com", "password": "SecurePass123!", "name": "Managed User"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	userID := result["data"].(map[string]interface{})["id"].(string)
+	_, result = login("managed@test.com", "SecurePass123!")
+	userToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	resp, _ = do(http.MethodGet, "/api/v1/admin/users", userToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, result = do(http.MethodGet, "/api/v1/admin/users?search=managed&page_size=5", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	list := result["data"].(map[string]interface{})
+	assert.Equal(t, float64(1), list["total_count"])
+	assert.Equal(t, "managed@test.com", list["users"].([]interface{})[0].(map[string]interface{})["email"])
+
+	resp, result = do(http.MethodGet, "/api/v1/admin/users/"+userID, adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, false, result["data"].(map[string]interface{})["disabled"])
+
+	// Disabled users are turned away with the tokens they already hold
+	resp, result = do(http.MethodPost, "/api/v1/admin/users/"+userID+"/disable", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, result["data"].(map[string]interface{})["disabled"])
+	resp, result = do(http.MethodGet, "/api/v1/users/me", userToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "ACCOUNT_DISABLED", errorCode(result))
+	resp, result = login("managed@test.com", "SecurePass123!")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "ACCOUNT_DISABLED", errorCode(result))
+
+	resp, _ = do(http.MethodPost, "/api/v1/admin/users/"+userID+"/enable", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = login("managed@test.com", "SecurePass123!")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = do(http.MethodPost, "/api/v1/admin/users/"+userID+"/password-reset", adminToken, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, result = login("managed@test.com", "SecurePass123!")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "PASSWORD_RESET_REQUIRED", errorCode(result))
+	resp, _ = do(http.MethodPost, "/api/v1/users/password/reset", "", map[string]string{"token": outboxToken(t, outboxDir), "password": "NewSecurePass456!"})
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, result = login("managed@test.com", "NewSecurePass456!")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	userToken = result["data"].(map[string]interface{})["access_token"].(string)
+
+	resp, _ = do(http.MethodDelete, "/api/v1/admin/users/"+userID, adminToken, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "/api/v1/users/me", userToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "/api/v1/admin/users/"+userID, adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, result = do(http.MethodDelete, "/api/v1/admin/users/"+userID, adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "USER_NOT_FOUND", errorCode(result))
 }
 
 func TestInputValidation_Integration(t *testing.T) {
@@ -321,3 +1098,776 @@ func TestInputValidation_Integration(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 }
+
+func TestAPIKeys_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server, _ := setupTestServerWithConfig(t, user.Config{})
+	defer server.Close()
+
+	// do sends a request authenticated with header set to value
+	do := func(method, path, header, value string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	errorCode := func(result map[string]interface{}) interface{} {
+		return result["error"].(map[string]interface{})["code"]
+	}
+
+	_, result := do(http.MethodPost, "/api/v1/users/login", "", "", map[string]string{"email": "admin@test.com", "password": "AdminSecurePass123!"})
+	adminToken := "Bearer " + result["data"].(map[string]interface{})["access_token"].(string)
+	_, result = do(http.MethodGet, "/api/v1/users/me", "Authorization", adminToken, nil)
+	adminID := result["data"].(map[string]interface{})["id"].(string)
+
+	resp, result := do(http.MethodPost, "/api/v1/users/me/api-keys", "Authorization", adminToken, map[string]interface{}{"name": "Reporting", "scopes": []string{"orders:delete"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, result = do(http.MethodPost, "/api/v1/users/me/api-keys", "Authorization", adminToken, map[string]interface{}{"name": "Reporting", "scopes": []string{"user:read"}, "expires_in_days": 30})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	created := result["data"].(map[string]interface{})
+	key := created["key"].(string)
+	keyID := created["id"].(string)
+	assert.True(t, strings.HasPrefix(key, created["prefix"].(string)))
+	assert.NotContains(t, created, "key_hash")
+
+	// Keys are accepted in either header, with permissions narrowed to their scopes
+	resp, _ = do(http.MethodGet, "/api/v1/admin/users", "X-API-Key", key, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "/api/v1/users/me", "Authorization", "ApiKey "+key, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do(http.MethodPost, "/api/v1/admin/users/"+adminID+"/unlock", "X-API-Key", key, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Account security cannot be changed with a key, including creating more keys
+	resp, result = do(http.MethodPost, "/api/v1/users/me/api-keys", "X-API-Key", key, map[string]interface{}{"name": "Another"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "API_KEY_NOT_ALLOWED", errorCode(result))
+	resp, _ = do(http.MethodPut, "/api/v1/users/me/password", "X-API-Key", key, map[string]string{"current_password": "AdminSecurePass123!", "new_password": "NewAdminSecurePass456!"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, result = do(http.MethodGet, "/api/v1/users/me/api-keys", "Authorization", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	keys := result["data"].([]interface{})
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].(map[string]interface{})["last_used_at"])
+	assert.NotContains(t, keys[0], "key")
+
+	resp, result = do(http.MethodGet, "/api/v1/admin/users/"+adminID+"/api-keys", "Authorization", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, result["data"].([]interface{}), 1)
+
+	resp, _ = do(http.MethodDelete, "/api/v1/users/me/api-keys/"+keyID, "Authorization", adminToken, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, result = do(http.MethodGet, "/api/v1/users/me", "X-API-Key", key, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "INVALID_API_KEY", errorCode(result))
+	resp, _ = do(http.MethodDelete, "/api/v1/users/me/api-keys/"+keyID+"x", "Authorization", adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestOIDCLogin_Integration(t *testing.T) {
+	provider, err := oidctest.NewProvider("shop", "shop-secret")
+	require.NoError(t, err)
+	defer provider.Close()
+	server, _ := setupTestServerWithConfig(t, user.Config{OIDCProviders: []user.OIDCProvider{
+		{Name: "mock", Client: oidc.NewClient(provider.Config("http://localhost:3000/auth/callback/mock"), nil)},
+	}})
+	defer server.Close()
+
+	// browser keeps the cookie binding a login to the browser that started it
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	browser := &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	noRedirects := &http.Client{CheckRedirect: browser.CheckRedirect}
+	callback := func(client *http.Client, providerName string, payload map[string]string) (*http.Response, map[string]interface{}) {
+		body, _ := json.Marshal(payload)
+		resp, err := client.Post(server.URL+"/api/v1/users/oidc/"+providerName+"/callback", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	resp, err := http.Get(server.URL + "/api/v1/users/oidc/providers")
+	require.NoError(t, err)
+	var providers map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&providers)
+	resp.Body.Close()
+	assert.Equal(t, []interface{}{"mock"}, providers["data"])
+
+	// The authorize endpoint redirects to the provider, which sends the user back to the
+	// frontend with a code and state for the callback endpoint
+	authorize := func(client *http.Client) (code, state string) {
+		resp, err := client.Get(server.URL + "/api/v1/users/oidc/mock/authorize")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		location := resp.Header.Get("Location")
+		assert.True(t, strings.HasPrefix(location, provider.Issuer()+"/authorize?"))
+		code, state, err = provider.Login(location)
+		require.NoError(t, err)
+		return code, state
+	}
+
+	// A login started elsewhere, e.g. by an attacker wanting the victim signed in to the
+	// attacker's account, cannot be completed without the cookie set when it started
+	code, state := authorize(noRedirects)
+	resp, result := callback(browser, "mock", map[string]string{"code": code, "state": state})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "INVALID_STATE", result["error"].(map[string]interface{})["code"])
+
+	code, state = authorize(browser)
+	resp, result = callback(browser, "mock", map[string]string{"code": code, "state": state})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, "oidc.user@example.com", data["user"].(map[string]interface{})["email"])
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+data["access_token"].(string))
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, result = callback(browser, "mock", map[string]string{"code": code, "state": state})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "INVALID_STATE", result["error"].(map[string]interface{})["code"])
+	resp, _ = callback(browser, "mock", map[string]string{"code": code})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = noRedirects.Get(server.URL + "/api/v1/users/oidc/unknown/authorize")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestOAuthAuthorizationServer_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server, _ := setupTestServerWithConfig(t, user.Config{})
+	defer server.Close()
+
+	// do sends a JSON request authenticated with token
+	do := func(method, path, token string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	// form posts a form to an OAuth endpoint, authenticating the client with HTTP Basic
+	form := func(path, clientID, secret string, values url.Values) (*http.Response, map[string]interface{}) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, secret)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	errorCode := func(result map[string]interface{}) interface{} {
+		return result["error"].(map[string]interface{})["code"]
+	}
+
+	_, result := do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": "admin@test.com", "password": "AdminSecurePass123!"})
+	adminToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	resp, result := do(http.MethodPost, "/api/v1/admin/oauth/clients", adminToken, map[string]interface{}{
+		"name":          "Partner App",
+		"redirect_uris": []string{"https://partner.example.com/callback"},
+		"grant_types":   []string{"authorization_code", "refresh_token"},
+		"scopes":        []string{"product:write"},
+	})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	partner := result["data"].(map[string]interface{})
+	partnerID := partner["client_id"].(string)
+	partnerSecret := partner["client_secret"].(string)
+
+	// A regular user authorizes the partner app; the scopes are narrowed to what they hold
+	do(http.MethodPost, "/api/v1/users/register", "", map[string]string{"email": "oauth@test.com", "password": "SecurePass123!", "name": "OAuth User"})
+	_, result = do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": "oauth@test.com", "password": "SecurePass123!"})
+	userToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	verifier := "a-code-verifier-that-is-long-enough-for-pkce-0123456789"
+	authorization := map[string]interface{}{
+		"client_id":             partnerID,
+		"redirect_uri":          "https://partner.example.com/callback",
+		"response_type":         "code",
+		"state":                 "xyz",
+		"code_challenge":        oidc.CodeChallenge(verifier),
+		"code_challenge_method": "S256",
+	}
+	query := url.Values{}
+	for name, value := range authorization {
+		query.Set(name, value.(string))
+	}
+	resp, result = do(http.MethodGet, "/api/v1/oauth/authorize?"+query.Encode(), userToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, false, result["data"].(map[string]interface{})["consented"])
+
+	authorization["approve"] = true
+	resp, result = do(http.MethodPost, "/api/v1/oauth/authorize", userToken, authorization)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	redirect, err := url.Parse(result["data"].(map[string]interface{})["redirect_to"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+
+	resp, result = form("/api/v1/oauth/token", partnerID, "wrong-secret", url.Values{"grant_type": {"authorization_code"}})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "invalid_client", result["error"])
+
+	resp, result = form("/api/v1/oauth/token", partnerID, partnerSecret, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {redirect.Query().Get("code")},
+		"redirect_uri":  {"https://partner.example.com/callback"},
+		"code_verifier": {verifier},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	accessToken := result["access_token"].(string)
+	assert.NotEmpty(t, result["refresh_token"])
+
+	// The app acts as the user, but neither beyond the granted scopes nor on account security
+	resp, _ = do(http.MethodGet, "/api/v1/users/me", accessToken, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do(http.MethodPost, "/api/v1/products", accessToken, map[string]interface{}{"name": "Desk Lamp", "price": 19.99, "stock": 5, "category_id": "lighting"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, result = do(http.MethodGet, "/api/v1/users/me/sessions", accessToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "CLIENT_TOKEN_NOT_ALLOWED", errorCode(result))
+
+	resp, result = form("/api/v1/oauth/introspect", partnerID, partnerSecret, url.Values{"token": {accessToken}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, result["active"])
+	assert.Equal(t, partnerID, result["client_id"])
+
+	resp, result = do(http.MethodGet, "/api/v1/users/me/oauth/consents", userToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, result["data"], 1)
+
+	// Revoking the consent revokes the tokens issued under it
+	resp, _ = do(http.MethodDelete, "/api/v1/users/me/oauth/consents/"+partnerID, userToken, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, result = do(http.MethodGet, "/api/v1/users/me", accessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "TOKEN_REVOKED", errorCode(result))
+	_, result = form("/api/v1/oauth/introspect", partnerID, partnerSecret, url.Values{"token": {accessToken}})
+	assert.Equal(t, false, result["active"])
+
+	// A client credentials client acts on its own with the scopes it was registered for
+	resp, result = do(http.MethodPost, "/api/v1/admin/oauth/clients", adminToken, map[string]interface{}{
+		"name":        "Catalog Sync",
+		"grant_types": []string{"client_credentials"},
+		"scopes":      []string{"product:write"},
+	})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	syncClient := result["data"].(map[string]interface{})
+	syncID := syncClient["client_id"].(string)
+	syncSecret := syncClient["client_secret"].(string)
+
+	resp, result = form("/api/v1/oauth/token", syncID, syncSecret, url.Values{"grant_type": {"client_credentials"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	syncToken := result["access_token"].(string)
+	assert.Nil(t, result["refresh_token"])
+
+	resp, _ = do(http.MethodPost, "/api/v1/products", syncToken, map[string]interface{}{"name": "Desk Lamp", "price": 19.99, "stock": 5, "category_id": "lighting"})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "/api/v1/users/me", syncToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = form("/api/v1/oauth/revoke", syncID, syncSecret, url.Values{"token": {syncToken}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, result = do(http.MethodPost, "/api/v1/products", syncToken, map[string]interface{}{"name": "Desk Lamp", "price": 19.99, "stock": 5, "category_id": "lighting"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "TOKEN_REVOKED", errorCode(result))
+
+	resp, _ = do(http.MethodDelete, "/api/v1/admin/oauth/clients/"+syncID, adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "/api/v1/admin/oauth/clients/"+syncID, adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestImpersonation_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server, _ := setupTestServerWithConfig(t, user.Config{})
+	defer server.Close()
+
+	// do sends a JSON request authenticated with token
+	do := func(method, path, token string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	errorCode := func(result map[string]interface{}) interface{} {
+		return result["error"].(map[string]interface{})["code"]
+	}
+
+	_, result := do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": "admin@test.com", "password": "AdminSecurePass123!"})
+	adminToken := result["data"].(map[string]interface{})["access_token"].(string)
+	_, result = do(http.MethodGet, "/api/v1/users/me", adminToken, nil)
+	adminID := result["data"].(map[string]interface{})["id"].(string)
+
+	_, result = do(http.MethodPost, "/api/v1/users/register", "", map[string]string{"email": "customer@test.com", "password": "SecurePass123!", "name": "Customer"})
+	customerID := result["data"].(map[string]interface{})["id"].(string)
+	_, result = do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": "customer@test.com", "password": "SecurePass123!"})
+	customerToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	// Only admins impersonate, giving a reason, and never other admins
+	resp, _ := do(http.MethodPost, "/api/v1/admin/users/"+adminID+"/impersonate", customerToken, map[string]string{"reason": "Ticket 42"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = do(http.MethodPost, "/api/v1/admin/users/"+customerID+"/impersonate", adminToken, map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, result = do(http.MethodPost, "/api/v1/admin/users/"+adminID+"/impersonate", adminToken, map[string]string{"reason": "Ticket 42"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "CANNOT_IMPERSONATE", errorCode(result))
+
+	resp, result = do(http.MethodPost, "/api/v1/admin/users/"+customerID+"/impersonate", adminToken, map[string]string{"reason": "Ticket 42"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	impersonation := result["data"].(map[string]interface{})
+	token := impersonation["access_token"].(string)
+	assert.Equal(t, float64(900), impersonation["expires_in"])
+
+	// The token acts as the customer, but cannot change their account or pay
+	resp, result = do(http.MethodGet, "/api/v1/users/me", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, customerID, result["data"].(map[string]interface{})["id"])
+	resp, result = do(http.MethodPut, "/api/v1/users/me/password", token, map[string]string{"current_password": "SecurePass123!", "new_password": "NewSecurePass456!"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "IMPERSONATION_NOT_ALLOWED", errorCode(result))
+	resp, _ = do(http.MethodPost, "/api/v1/users/me/api-keys", token, map[string]interface{}{"name": "Sneaky"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, result = do(http.MethodPost, "/api/v1/payments", token, map[string]interface{}{"order_id": "order-1", "amount": 1000, "currency": "USD"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "IMPERSONATION_NOT_ALLOWED", errorCode(result))
+	resp, result = do(http.MethodGet, "/api/v1/admin/users", token, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "IMPERSONATION_NOT_ALLOWED", errorCode(result))
+}
+
+func TestPersonalData_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server, _ := setupTestServerWithConfig(t, user.Config{})
+	defer server.Close()
+
+	// do sends a JSON request authenticated with token
+	do := func(method, path, token string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	errorCode := func(result map[string]interface{}) interface{} {
+		return result["error"].(map[string]interface{})["code"]
+	}
+
+	_, result := do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": "admin@test.com", "password": "AdminSecurePass123!"})
+	adminToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	_, result = do(http.MethodPost, "/api/v1/users/register", "", map[string]string{"email": "customer@test.com", "password": "SecurePass123!", "name": "Customer"})
+	customerID := result["data"].(map[string]interface{})["id"].(string)
+	_, result = do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": "customer@test.com", "password": "SecurePass123!"})
+	customerToken := result["data"].(map[string]interface{})["access_token"].(string)
+
+	// The export is a raw JSON attachment with a section per module
+	resp, result := do(http.MethodGet, "/api/v1/users/me/export", customerToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+	assert.Equal(t, customerID, result["user_id"])
+	data := result["data"].(map[string]interface{})
+	for _, section := range []string{"account", "payments", "returns", "promotions", "oauth_consents"} {
+		assert.Contains(t, data, section)
+	}
+	account := data["account"].(map[string]interface{})
+	assert.Equal(t, "customer@test.com", account["profile"].(map[string]interface{})["email"])
+	assert.Len(t, account["sessions"], 1)
+
+	// Deletion is scheduled after a grace period, during which it can be cancelled
+	resp, result = do(http.MethodGet, "/api/v1/users/me/deletion", customerToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "DELETION_NOT_FOUND", errorCode(result))
+
+	resp, result = do(http.MethodDelete, "/api/v1/users/me", customerToken, nil)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	deletion := result["data"].(map[string]interface{})
+	assert.Equal(t, customerID, deletion["user_id"])
+	scheduledFor, err := time.Parse(time.RFC3339, deletion["scheduled_for"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), scheduledFor, time.Minute)
+
+	resp, result = do(http.MethodGet, "/api/v1/users/me/deletion", customerToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, deletion["scheduled_for"], result["data"].(map[string]interface{})["scheduled_for"])
+
+	resp, _ = do(http.MethodDelete, "/api/v1/users/me/deletion", customerToken, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(http.MethodDelete, "/api/v1/users/me/deletion", customerToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// The only admin cannot delete their account
+	resp, result = do(http.MethodDelete, "/api/v1/users/me", adminToken, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "DELETION_NOT_ALLOWED", errorCode(result))
+
+	// Nor can an admin impersonating the user
+	_, result = do(http.MethodPost, "/api/v1/admin/users/"+customerID+"/impersonate", adminToken, map[string]string{"reason": "Ticket 42"})
+	impersonationToken := result["data"].(map[string]interface{})["access_token"].(string)
+	resp, result = do(http.MethodDelete, "/api/v1/users/me", impersonationToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "IMPERSONATION_NOT_ALLOWED", errorCode(result))
+	resp, _ = do(http.MethodGet, "/api/v1/users/me/export", impersonationToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestAddressBook_Integration(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Close()
+
+	// do sends a JSON request authenticated with token
+	do := func(method, path, token string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	login := func(email string) string {
+		do(http.MethodPost, "/api/v1/users/register", "", map[string]string{"email": email, "password": "SecurePass123!", "name": "Customer"})
+		_, result := do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": email, "password": "SecurePass123!"})
+		return result["data"].(map[string]interface{})["access_token"].(string)
+	}
+	token := login("customer@test.com")
+	otherToken := login("other@test.com")
+
+	resp, _ := do(http.MethodGet, "/api/v1/users/me/addresses", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Addresses are checked against the format of their country
+	resp, result := do(http.MethodPost, "/api/v1/users/me/addresses", token, map[string]string{"label": "Home", "name": "Customer", "line1": "1 Market St", "city": "San Francisco", "postal_code": "ABC", "country": "US"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	details := result["error"].(map[string]interface{})["details"].([]interface{})
+	assert.Len(t, details, 2)
+	resp, _ = do(http.MethodPost, "/api/v1/users/me/addresses", token, map[string]string{"label": "Home"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, result = do(http.MethodPost, "/api/v1/users/me/addresses", token, map[string]string{"label": "Home", "name": "Customer", "line1": "1 Market St", "city": "San Francisco", "region": "CA", "postal_code": "94105", "country": "us"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	home := result["data"].(map[string]interface{})
+	homeID := home["id"].(string)
+	assert.Equal(t, "US", home["country"])
+	assert.Equal(t, true, home["default_shipping"])
+	assert.Equal(t, true, home["default_billing"])
+
+	resp, result = do(http.MethodPost, "/api/v1/users/me/addresses", token, map[string]interface{}{"label": "Office", "name": "Customer", "line1": "10 Downing St", "city": "London", "postal_code": "SW1A 2AA", "country": "GB", "default_billing": true})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	officeID := result["data"].(map[string]interface{})["id"].(string)
+
+	resp, result = do(http.MethodGet, "/api/v1/users/me/addresses", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	addresses := result["data"].([]interface{})
+	require.Len(t, addresses, 2)
+	assert.Equal(t, false, addresses[0].(map[string]interface{})["default_billing"], "the new billing default replaces the old one")
+
+	resp, result = do(http.MethodPut, "/api/v1/users/me/addresses/"+officeID, token, map[string]interface{}{"label": "Work", "name": "Customer", "line1": "10 Downing St", "city": "London", "postal_code": "SW1A 2AA", "country": "GB", "default_billing": true})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Work", result["data"].(map[string]interface{})["label"])
+
+	// Other users cannot see or change the address book
+	resp, result = do(http.MethodGet, "/api/v1/users/me/addresses/"+homeID, otherToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "ADDRESS_NOT_FOUND", result["error"].(map[string]interface{})["code"])
+	resp, _ = do(http.MethodDelete, "/api/v1/users/me/addresses/"+homeID, otherToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(http.MethodDelete, "/api/v1/users/me/addresses/"+homeID, token, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "/api/v1/users/me/addresses/"+homeID, token, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Saved addresses are part of the personal data export
+	_, result = do(http.MethodGet, "/api/v1/users/me/export", token, nil)
+	account := result["data"].(map[string]interface{})["account"].(map[string]interface{})
+	assert.Len(t, account["addresses"], 1)
+}
+
+func TestSellerMarketplace_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server := setupTestServer(t)
+	defer server.Close()
+
+	// do sends a JSON request authenticated with token
+	do := func(method, path, token string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	login := func(email, password string) string {
+		do(http.MethodPost, "/api/v1/users/register", "", map[string]string{"email": email, "password": password, "name": "Seller"})
+		_, result := do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": email, "password": password})
+		return result["data"].(map[string]interface{})["access_token"].(string)
+	}
+	errorCode := func(result map[string]interface{}) interface{} {
+		return result["error"].(map[string]interface{})["code"]
+	}
+	adminToken := login("admin@test.com", "AdminSecurePass123!")
+	sellerToken := login("seller@test.com", "SecurePass123!")
+	otherToken := login("other@test.com", "SecurePass123!")
+	item := map[string]interface{}{"name": "Glazed Bowl", "price": 20, "stock": 3, "category_id": "ceramics"}
+
+	// Applying does not let a user sell until an admin approves them
+	resp, result := do(http.MethodPost, "/api/v1/sellers/me", sellerToken, map[string]string{"store_name": "Potter's Wheel", "contact_email": "shop@example.com"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	sellerID := result["data"].(map[string]interface{})["id"].(string)
+	assert.Equal(t, "pending", result["data"].(map[string]interface{})["status"])
+	resp, result = do(http.MethodPost, "/api/v1/sellers/me", sellerToken, map[string]string{"store_name": "Potter's Wheel", "contact_email": "shop@example.com"})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "SELLER_EXISTS", errorCode(result))
+	resp, _ = do(http.MethodPost, "/api/v1/sellers/me/products", sellerToken, item)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "/api/v1/sellers/"+sellerID, "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(http.MethodGet, "/api/v1/admin/sellers?status=pending", sellerToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, result = do(http.MethodGet, "/api/v1/admin/sellers?status=pending", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, result["data"], 1)
+	resp, result = do(http.MethodPost, "/api/v1/admin/sellers/"+sellerID+"/approve", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "approved", result["data"].(map[string]interface{})["status"])
+
+	// The seller role applies to access tokens issued after approval
+	sellerToken = login("seller@test.com", "SecurePass123!")
+	resp, result = do(http.MethodPost, "/api/v1/sellers/me/products", sellerToken, item)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	productID := result["data"].(map[string]interface{})["id"].(string)
+	assert.Equal(t, sellerID, result["data"].(map[string]interface{})["seller_id"])
+	resp, _ = do(http.MethodPost, "/api/v1/products", sellerToken, item)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "sellers cannot add store products")
+
+	resp, result = do(http.MethodPost, "/api/v1/products", adminToken, item)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	storeProductID := result["data"].(map[string]interface{})["id"].(string)
+	resp, result = do(http.MethodPut, "/api/v1/sellers/me/products/"+storeProductID, sellerToken, map[string]interface{}{"price": 1})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "NOT_PRODUCT_OWNER", errorCode(result))
+
+	// Only the seller's products are listed under their storefront
+	for _, path := range []string{"/api/v1/sellers/me/products", "/api/v1/sellers/" + sellerID + "/products", "/api/v1/products?seller_id=" + sellerID} {
+		resp, result = do(http.MethodGet, path, sellerToken, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+		products := result["data"].(map[string]interface{})["products"].([]interface{})
+		require.Len(t, products, 1, path)
+		assert.Equal(t, productID, products[0].(map[string]interface{})["id"], path)
+	}
+	resp, result = do(http.MethodGet, "/api/v1/sellers/"+sellerID, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Potter's Wheel", result["data"].(map[string]interface{})["store_name"])
+	assert.NotContains(t, result["data"], "contact_email")
+
+	resp, _ = do(http.MethodPut, "/api/v1/sellers/me/products/"+productID, otherToken, map[string]interface{}{"price": 1})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, result = do(http.MethodPut, "/api/v1/sellers/me/products/"+productID, sellerToken, map[string]interface{}{"price": 25, "stock": 3})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 25.0, result["data"].(map[string]interface{})["price"])
+	// Admins can change every product
+	resp, result = do(http.MethodPut, "/api/v1/products/"+productID, adminToken, map[string]interface{}{"price": 22, "stock": 3})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, sellerID, result["data"].(map[string]interface{})["seller_id"])
+
+	// Suspension takes effect at once, even for access tokens issued before
+	resp, _ = do(http.MethodPost, "/api/v1/admin/sellers/"+sellerID+"/suspend", adminToken, map[string]string{"reason": "Counterfeit goods"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, result = do(http.MethodDelete, "/api/v1/sellers/me/products/"+productID, sellerToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "SELLER_NOT_APPROVED", errorCode(result))
+	resp, _ = do(http.MethodGet, "/api/v1/sellers/"+sellerID+"/products", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, result = do(http.MethodPost, "/api/v1/admin/sellers/"+sellerID+"/reject", adminToken, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "INVALID_SELLER_STATE", errorCode(result))
+
+	// The seller profile is part of the personal data export
+	resp, result = do(http.MethodGet, "/api/v1/users/me/export", sellerToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "suspended", result["data"].(map[string]interface{})["seller"].(map[string]interface{})["status"])
+}
+
+func TestOrdersAndReturns_Integration(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@test.com")
+	t.Setenv("ADMIN_PASSWORD", "AdminSecurePass123!")
+	server := setupTestServer(t)
+	defer server.Close()
+
+	// do sends a JSON request authenticated with token
+	do := func(method, path, token string, payload interface{}) (*http.Response, map[string]interface{}) {
+		var body io.Reader
+		if payload != nil {
+			encoded, _ := json.Marshal(payload)
+			body = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	login := func(email, password string) string {
+		do(http.MethodPost, "/api/v1/users/register", "", map[string]string{"email": email, "password": password, "name": "Shopper"})
+		_, result := do(http.MethodPost, "/api/v1/users/login", "", map[string]string{"email": email, "password": password})
+		return result["data"].(map[string]interface{})["access_token"].(string)
+	}
+	errorCode := func(result map[string]interface{}) interface{} {
+		return result["error"].(map[string]interface{})["code"]
+	}
+	adminToken := login("admin@test.com", "AdminSecurePass123!")
+	customerToken := login("customer@test.com", "SecurePass123!")
+	otherToken := login("other@test.com", "SecurePass123!")
+
+	resp, result := do(http.MethodPost, "/api/v1/products", adminToken, map[string]interface{}{"name": "Teapot", "price": 20, "stock": 3, "category_id": "kitchen"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	productID := result["data"].(map[string]interface{})["id"].(string)
+
+	// Placing an order takes its items out of stock
+	resp, result = do(http.MethodPost, "/api/v1/orders", customerToken, map[string]interface{}{"items": []map[string]interface{}{{"product_id": productID, "quantity": 2}}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	order := result["data"].(map[string]interface{})
+	orderID := order["id"].(string)
+	lineID := order["lines"].([]interface{})[0].(map[string]interface{})["id"].(string)
+	assert.Equal(t, "pending_payment", order["status"])
+	assert.Equal(t, 40.0, order["total"])
+	resp, result = do(http.MethodGet, "/api/v1/products/"+productID, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1.0, result["data"].(map[string]interface{})["stock"])
+	resp, result = do(http.MethodPost, "/api/v1/orders", otherToken, map[string]interface{}{"items": []map[string]interface{}{{"product_id": productID, "quantity": 2}}})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "INSUFFICIENT_STOCK", errorCode(result))
+
+	resp, _ = do(http.MethodGet, "/api/v1/orders/"+orderID, otherToken, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, result = do(http.MethodGet, "/api/v1/orders", otherToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, result["data"])
+
+	// Undelivered orders cannot be returned, and only paid orders can be delivered
+	returnRequest := map[string]interface{}{"order_id": orderID, "lines": []map[string]interface{}{{"order_line_id": lineID, "quantity": 1, "reason": "damaged"}}}
+	resp, result = do(http.MethodPost, "/api/v1/returns", customerToken, returnRequest)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "ORDER_NOT_RETURNABLE", errorCode(result))
+	resp, result = do(http.MethodPost, "/api/v1/orders/"+orderID+"/deliver", adminToken, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "INVALID_ORDER_STATE", errorCode(result))
+
+	// A verified capture of the order total pays for the order
+	resp, result = do(http.MethodPost, "/api/v1/payments", customerToken, map[string]interface{}{"order_id": orderID, "amount": 40, "currency": "usd", "payment_method": "mock_card_success"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	paymentID := result["data"].(map[string]interface{})["id"].(string)
+	resp, _ = do(http.MethodPost, "/api/v1/payments/"+paymentID+"/capture", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, result = do(http.MethodGet, "/api/v1/orders/"+orderID, customerToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "paid", result["data"].(map[string]interface{})["status"])
+	assert.Equal(t, paymentID, result["data"].(map[string]interface{})["payment_id"])
+
+	resp, _ = do(http.MethodPost, "/api/v1/orders/"+orderID+"/deliver", customerToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, result = do(http.MethodPost, "/api/v1/orders/"+orderID+"/deliver", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "delivered", result["data"].(map[string]interface{})["status"])
+
+	// Delivered orders can be returned against their payment
+	resp, result = do(http.MethodPost, "/api/v1/returns", otherToken, returnRequest)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "ORDER_NOT_FOUND", errorCode(result))
+	resp, result = do(http.MethodPost, "/api/v1/returns", customerToken, returnRequest)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	ret := result["data"].(map[string]interface{})
+	assert.Equal(t, 20.0, ret["items_value"])
+	assert.Equal(t, paymentID, ret["payment_id"])
+}